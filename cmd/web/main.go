@@ -1,11 +1,22 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"patient-appointment-demo-go/internal/app"
+	"patient-appointment-demo-go/internal/auditchain"
+	"patient-appointment-demo-go/internal/changefeed"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/fieldcrypt"
+	"patient-appointment-demo-go/internal/reminder"
 	"strconv"
+	"strings"
+	"time"
+	// doctors' schedules use IANA zone names, do not depend on the host
+	_ "time/tzdata"
 
 	"github.com/joho/godotenv"
 )
@@ -30,7 +41,19 @@ func main() {
 	dbPort := os.Getenv("DB_PORT")
     dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", dbUser, dbPassword, dbHost, dbPort, dbName)
 
-	app := app.New(app.ConfigWithPort(int(appPort)))
+	requireMigrated, _ := strconv.ParseBool(os.Getenv("DB_REQUIRE_MIGRATED"))
+	changeFeed, _ := strconv.ParseBool(os.Getenv("CHANGE_FEED_ENABLED"))
+
+	app := app.New(
+		app.ConfigWithPort(int(appPort)).
+			WithDBPool(poolConfigFromEnv()).
+			WithSchemaCheck(requireMigrated).
+			WithChangeFeed(changeFeed).
+			WithChangeLogRetention(changeLogRetentionFromEnv()).
+			WithReminders(reminderOffsetsFromEnv(), reminderNotifiersFromEnv()...).
+			WithAuditCheckpoints(auditCheckpointsFromEnv()).
+			WithEncryption(masterKeysFromEnv()),
+	)
 
 	err = app.ConnectDB(dbURL)
 	if err != nil {
@@ -40,6 +63,30 @@ func main() {
 
 	fmt.Println("Succesfully connected to database")
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(&app, os.Args[2:]); err != nil {
+				app.CloseDB()
+				log.Fatalf("Migration Error: %v", err)
+			}
+		case "verify-audit":
+			if err := runVerifyAudit(&app, os.Args[2:]); err != nil {
+				app.CloseDB()
+				log.Fatalf("Audit Verification Error: %v", err)
+			}
+		case "rotate-keys":
+			if err := runRotateKeys(&app, os.Args[2:]); err != nil {
+				app.CloseDB()
+				log.Fatalf("Key Rotation Error: %v", err)
+			}
+		default:
+			app.CloseDB()
+			log.Fatalf("unknown command %q", os.Args[1])
+		}
+		return
+	}
+
 	fmt.Printf("Starting server on port %d\n", appPort)
 	err = app.Start()
 
@@ -49,3 +96,178 @@ func main() {
 
 }
 
+func poolConfigFromEnv() database.PoolConfig {
+	config := database.DefaultPoolConfig()
+
+	if v := os.Getenv("DB_POOL_MIN_CONNS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			fmt.Printf("failed to parse env var DB_POOL_MIN_CONNS, defaulting to %d\n", config.MinConns)
+		} else {
+			config.MinConns = int32(n)
+		}
+	}
+
+	if v := os.Getenv("DB_POOL_MAX_CONNS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			fmt.Printf("failed to parse env var DB_POOL_MAX_CONNS, defaulting to %d\n", config.MaxConns)
+		} else {
+			config.MaxConns = int32(n)
+		}
+	}
+
+	if v := os.Getenv("DB_POOL_HEALTH_CHECK_PERIOD"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			fmt.Printf("failed to parse env var DB_POOL_HEALTH_CHECK_PERIOD, defaulting to %s\n", config.HealthCheckPeriod)
+		} else {
+			config.HealthCheckPeriod = d
+		}
+	}
+
+	if v := os.Getenv("DB_POOL_ACQUIRE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			fmt.Printf("failed to parse env var DB_POOL_ACQUIRE_TIMEOUT, defaulting to %s\n", config.AcquireTimeout)
+		} else {
+			config.AcquireTimeout = d
+		}
+	}
+
+	return config
+}
+
+
+// reminderOffsetsFromEnv reads REMINDER_OFFSETS, e.g. "24h,2h".
+func reminderOffsetsFromEnv() []time.Duration {
+	var offsets []time.Duration
+
+	for _, v := range strings.Split(os.Getenv("REMINDER_OFFSETS"), ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute {
+			fmt.Printf("failed to parse %q in env var REMINDER_OFFSETS, skipping it\n", v)
+			continue
+		}
+		offsets = append(offsets, d)
+	}
+
+	return offsets
+}
+
+// reminderNotifiersFromEnv sets up a notifier for every channel configured.
+func reminderNotifiersFromEnv() []reminder.Notifier {
+	var notifiers []reminder.Notifier
+
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			fmt.Println("failed to parse env var SMTP_PORT, defaulting to 587")
+			port = 587
+		}
+
+		notifier, err := reminder.NewSMTPNotifier(reminder.SMTPConfig{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		})
+		if err != nil {
+			log.Fatalf("email reminders: %v", err)
+		}
+		notifiers = append(notifiers, notifier)
+	}
+
+	if url := os.Getenv("SMS_GATEWAY_URL"); url != "" {
+		notifiers = append(notifiers, reminder.NewSMSGatewayNotifier(reminder.SMSGatewayConfig{
+			URL:    url,
+			APIKey: os.Getenv("SMS_GATEWAY_API_KEY"),
+			From:   os.Getenv("SMS_GATEWAY_FROM"),
+		}))
+	}
+
+	if path := os.Getenv("REMINDER_LOG_FILE"); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatalf("reminder log: %v", err)
+		}
+		notifiers = append(notifiers, reminder.NewLogNotifier(f))
+	}
+
+	return notifiers
+}
+
+// changeLogRetentionFromEnv reads CHANGE_LOG_RETENTION, how long changes
+// are kept for the change feed to catch up on; 0 keeps them for good.
+func changeLogRetentionFromEnv() time.Duration {
+	retention := changefeed.DefaultRetention
+	if v := os.Getenv("CHANGE_LOG_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			fmt.Printf("failed to parse env var CHANGE_LOG_RETENTION, defaulting to %s\n", retention)
+		} else {
+			retention = d
+		}
+	}
+
+	return retention
+}
+
+// auditCheckpointsFromEnv reads AUDIT_CHECKPOINT_KEY, AUDIT_CHECKPOINT_FILE
+// and AUDIT_CHECKPOINT_INTERVAL. No checkpoints are written unless the key
+// and file are both set.
+func auditCheckpointsFromEnv() (ed25519.PrivateKey, io.Writer, time.Duration) {
+	encodedKey := os.Getenv("AUDIT_CHECKPOINT_KEY")
+	path := os.Getenv("AUDIT_CHECKPOINT_FILE")
+	if encodedKey == "" || path == "" {
+		return nil, nil, 0
+	}
+
+	key, err := auditchain.ParsePrivateKey(encodedKey)
+	if err != nil {
+		log.Fatalf("audit checkpoints: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Fatalf("audit checkpoints: %v", err)
+	}
+
+	interval := time.Hour
+	if v := os.Getenv("AUDIT_CHECKPOINT_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute {
+			fmt.Printf("failed to parse env var AUDIT_CHECKPOINT_INTERVAL, defaulting to %s\n", interval)
+		} else {
+			interval = d
+		}
+	}
+
+	fmt.Printf("signing audit checkpoints with public key %s\n", auditchain.EncodePublicKey(key.Public().(ed25519.PublicKey)))
+
+	return key, f, interval
+}
+
+// masterKeysFromEnv reads the master keys from PHI_MASTER_KEY, or else from
+// the file PHI_MASTER_KEY_FILE. Without either the server refuses to start.
+func masterKeysFromEnv() []fieldcrypt.MasterKey {
+	var keys []fieldcrypt.MasterKey
+	var err error
+
+	if v := os.Getenv("PHI_MASTER_KEY"); v != "" {
+		keys, err = fieldcrypt.ParseMasterKeys(v)
+	} else if path := os.Getenv("PHI_MASTER_KEY_FILE"); path != "" {
+		keys, err = fieldcrypt.ReadMasterKeyFile(path)
+	}
+	if err != nil {
+		log.Fatalf("master key: %v", err)
+	}
+
+	return keys
+}
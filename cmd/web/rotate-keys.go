@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"patient-appointment-demo-go/internal/app"
+	"patient-appointment-demo-go/internal/fieldcrypt"
+	"patient-appointment-demo-go/internal/repositories"
+	"time"
+)
+
+const rotateKeysUsage = `usage: web rotate-keys [reencrypt]
+
+Wraps every encryption key with the first master key of PHI_MASTER_KEY or
+PHI_MASTER_KEY_FILE, makes a new data key active and re-encrypts patients'
+contact details, appointment notes and webhook event payloads with it. Older
+master keys can be removed once it is done.
+
+reencrypt only re-encrypts what isn't encrypted with the active data key yet,
+e.g. rows written before encryption or by a rotation that was interrupted.`
+
+// reencryptBatchSize is how many rows are re-encrypted per transaction.
+const reencryptBatchSize = 100
+
+func runRotateKeys(a *app.App, args []string) error {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "reencrypt") {
+		return errors.New(rotateKeysUsage)
+	}
+
+	// an older schema copies what is re-encrypted to the audit log as it
+	// was, plaintext included
+	if err := a.CheckSchema(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		rotation, err := a.Keyring.Rotate(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rewrapped %d keys, data key %d is now active\n", rotation.Rewrapped, rotation.DataKeyID)
+
+		// running servers keep encrypting with the previous key until they
+		// reload theirs
+		fmt.Printf("waiting %s for running servers to pick up the new key\n", fieldcrypt.DefaultRefreshInterval)
+		time.Sleep(fieldcrypt.DefaultRefreshInterval)
+	} else if err := a.Keyring.Load(ctx); err != nil {
+		return err
+	}
+
+	patients, err := reencryptAll(ctx, a.TxManager(), func(repos repositories.TxRepositories, afterId int32) (int32, int, error) {
+		return repos.Patients.Reencrypt(ctx, afterId, reencryptBatchSize)
+	})
+	if err != nil {
+		return fmt.Errorf("patients: %w", err)
+	}
+	fmt.Printf("re-encrypted %d patients\n", patients)
+
+	appointments, err := reencryptAll(ctx, a.TxManager(), func(repos repositories.TxRepositories, afterId int32) (int32, int, error) {
+		return repos.Appointments.Reencrypt(ctx, afterId, reencryptBatchSize)
+	})
+	if err != nil {
+		return fmt.Errorf("appointments: %w", err)
+	}
+	fmt.Printf("re-encrypted %d appointments\n", appointments)
+
+	events, err := reencryptAll(ctx, a.TxManager(), func(repos repositories.TxRepositories, afterId int64) (int64, int, error) {
+		return repos.Outbox.Reencrypt(ctx, afterId, reencryptBatchSize)
+	})
+	if err != nil {
+		return fmt.Errorf("webhook events: %w", err)
+	}
+	fmt.Printf("re-encrypted %d webhook events\n", events)
+
+	return nil
+}
+
+// reencryptAll runs batch in a transaction of its own until it reports no
+// rows left, and returns how many it rewrote in total.
+func reencryptAll[ID int32 | int64](ctx context.Context, tx repositories.TxManagerInterface, batch func(repos repositories.TxRepositories, afterId ID) (ID, int, error)) (int, error) {
+	var afterId ID
+	total := 0
+
+	for {
+		var lastId ID
+		var updated int
+
+		err := tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+			var err error
+			lastId, updated, err = batch(repos, afterId)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += updated
+		if lastId == 0 {
+			return total, nil
+		}
+		afterId = lastId
+	}
+}
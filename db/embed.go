@@ -0,0 +1,10 @@
+// Package db embeds the SQL schema so the binary can migrate the database
+// without the source tree.
+package db
+
+import "embed"
+
+//go:embed schema/*.sql
+var Schema embed.FS
+
+const SchemaDir = "schema"
@@ -1,28 +1,66 @@
 -- name: CreateAppointment :one
-INSERT INTO appointments (patient_id, user_id, visit_date, visit_timestamp, patient_notes, doctor_notes)
-VALUES ($1, $2, $3, $4, $5, $6)
+INSERT INTO appointments (patient_id, user_id, doctor_id, series_id, visit_date, visit_timestamp, duration_minutes, patient_notes, doctor_notes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING *;
 
 -- name: GetAppointmentByID :one
+SELECT * FROM appointments WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetAppointmentByIDWithDeleted :one
 SELECT * FROM appointments WHERE id = $1;
 
+-- name: GetAppointmentByIDForUpdate :one
+-- Locked until the transaction ends, so it can be checked before it is
+-- written.
+SELECT * FROM appointments WHERE id = $1 AND deleted_at IS NULL
+FOR UPDATE;
+
 -- name: GetAllAppointments :many
+-- One page of appointments matching the filters that are set, see
+-- GetAllPatients for how the cursor works.
 SELECT * FROM appointments
-ORDER BY visit_date DESC;
+WHERE (sqlc.narg('visit_from')::date IS NULL OR visit_date >= sqlc.narg('visit_from')::date)
+  AND (sqlc.narg('visit_to')::date IS NULL OR visit_date <= sqlc.narg('visit_to')::date)
+  AND (sqlc.narg('doctor_id')::int IS NULL OR doctor_id = sqlc.narg('doctor_id')::int)
+  AND (sqlc.narg('patient_id')::int IS NULL OR patient_id = sqlc.narg('patient_id')::int)
+  AND (sqlc.narg('statuses')::text[] IS NULL OR status = ANY(sqlc.narg('statuses')::text[]))
+  AND (@include_deleted::boolean OR deleted_at IS NULL)
+  AND (
+      NOT @has_cursor::boolean
+      OR (@sort_by::text = 'visit_timestamp' AND NOT @sort_desc::boolean AND (visit_timestamp, id) > (@cursor_time::timestamptz, @cursor_id::int))
+      OR (@sort_by::text = 'visit_timestamp' AND @sort_desc::boolean AND (visit_timestamp, id) < (@cursor_time::timestamptz, @cursor_id::int))
+      OR (@sort_by::text = 'created_at' AND NOT @sort_desc::boolean AND (COALESCE(created_at, 'epoch'), id) > (@cursor_time::timestamptz, @cursor_id::int))
+      OR (@sort_by::text = 'created_at' AND @sort_desc::boolean AND (COALESCE(created_at, 'epoch'), id) < (@cursor_time::timestamptz, @cursor_id::int))
+  )
+ORDER BY
+    CASE WHEN @sort_by::text = 'visit_timestamp' AND NOT @sort_desc::boolean THEN visit_timestamp END ASC,
+    CASE WHEN @sort_by::text = 'visit_timestamp' AND @sort_desc::boolean THEN visit_timestamp END DESC,
+    CASE WHEN @sort_by::text = 'created_at' AND NOT @sort_desc::boolean THEN COALESCE(created_at, 'epoch') END ASC,
+    CASE WHEN @sort_by::text = 'created_at' AND @sort_desc::boolean THEN COALESCE(created_at, 'epoch') END DESC,
+    CASE WHEN NOT @sort_desc::boolean THEN id END ASC,
+    CASE WHEN @sort_desc::boolean THEN id END DESC
+LIMIT @max_results;
 
 -- name: GetAppointmentsByDate :many
 SELECT * FROM appointments
-WHERE visit_date = $1
+WHERE visit_date = $1 AND deleted_at IS NULL
 ORDER BY appointment_sequence ASC;
 
 -- name: GetAppointmentsByPatient :many
 SELECT * FROM appointments
-WHERE patient_id = $1
+WHERE patient_id = $1 AND deleted_at IS NULL
 ORDER BY appointment_sequence ASC;
 
+-- name: GetAppointmentsByDoctor :many
+SELECT * FROM appointments
+WHERE doctor_id = @doctor_id
+  AND (sqlc.narg('visit_date')::date IS NULL OR visit_date = sqlc.narg('visit_date')::date)
+  AND deleted_at IS NULL
+ORDER BY visit_timestamp ASC;
+
 -- name: GetAppointmentBySequence :one
 SELECT * FROM appointments
-WHERE visit_date = $1 AND appointment_sequence = $2
+WHERE visit_date = $1 AND appointment_sequence = $2 AND deleted_at IS NULL
 ORDER BY created_at;
 
 -- name: UpdateAppointment :one
@@ -31,9 +69,107 @@ SET
     patient_notes = COALESCE($2, patient_notes),
     doctor_notes = COALESCE($3, doctor_notes),
     updated_at = NOW()
-WHERE id = $1
+WHERE id = $1 AND deleted_at IS NULL
 RETURNING *;
 
 -- name: DeleteAppointment :exec
-DELETE FROM appointments WHERE id = $1;
+UPDATE appointments
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestoreAppointment :one
+-- Appointments of a deleted patient stay deleted, restore the patient.
+UPDATE appointments a
+SET deleted_at = NULL
+WHERE a.id = $1
+  AND NOT EXISTS (
+      SELECT 1 FROM patients p
+      WHERE p.id = a.patient_id AND p.deleted_at IS NOT NULL
+  )
+RETURNING *;
+
+
+-- name: UpdateAppointmentStatus :one
+UPDATE appointments
+SET
+    status = @to_status::text,
+    status_updated_at = NOW(),
+    status_updated_by = @changed_by,
+    cancel_reason = COALESCE(sqlc.narg('cancel_reason'), cancel_reason),
+    updated_at = NOW()
+WHERE id = @id AND status = @from_status::text AND deleted_at IS NULL
+RETURNING *;
+
+-- name: CreateAppointmentStatusHistory :one
+INSERT INTO appointment_status_history (appointment_id, from_status, to_status, changed_by, reason)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetAppointmentStatusHistory :many
+SELECT * FROM appointment_status_history
+WHERE appointment_id = $1
+ORDER BY created_at ASC, id ASC;
+
+-- name: GetActiveAppointmentsByDoctorBetween :many
+SELECT * FROM appointments
+WHERE doctor_id = @doctor_id
+  AND visit_end > @from_time::timestamptz
+  AND visit_timestamp < @to_time::timestamptz
+  AND status NOT IN ('cancelled', 'no_show')
+  AND deleted_at IS NULL
+ORDER BY visit_timestamp ASC;
+
+-- name: GetOverlappingAppointments :many
+SELECT * FROM appointments
+WHERE (doctor_id = @doctor_id OR patient_id = @patient_id)
+  AND id <> @exclude_id
+  AND status NOT IN ('cancelled', 'no_show')
+  AND deleted_at IS NULL
+  AND tstzrange(visit_timestamp, visit_end) && tstzrange(@from_time::timestamptz, @to_time::timestamptz)
+ORDER BY visit_timestamp ASC;
 
+-- name: RescheduleAppointment :one
+-- Moving to another day takes the next sequence of that day, the old day
+-- keeps a gap since sequences are never handed out twice.
+UPDATE appointments
+SET
+    visit_timestamp = @visit_timestamp,
+    visit_date = @visit_date,
+    appointment_sequence = CASE
+        WHEN visit_date = @visit_date THEN appointment_sequence
+        ELSE next_appointment_sequence(@visit_date)
+    END,
+    updated_at = NOW()
+WHERE id = @id AND deleted_at IS NULL
+RETURNING *;
+
+-- name: CreateAppointmentRescheduleHistory :one
+INSERT INTO appointment_reschedule_history (appointment_id, old_visit_timestamp, new_visit_timestamp, rescheduled_by, reason)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetAppointmentRescheduleHistory :many
+SELECT * FROM appointment_reschedule_history
+WHERE appointment_id = $1
+ORDER BY created_at ASC, id ASC;
+
+-- name: GetAppointmentsBySeries :many
+SELECT * FROM appointments
+WHERE series_id = $1 AND deleted_at IS NULL
+ORDER BY visit_timestamp ASC;
+
+-- name: GetAppointmentsForReencryption :many
+-- Every appointment after after_id, deleted or not, locked until the
+-- transaction ends so their notes can be written back re-encrypted.
+SELECT * FROM appointments
+WHERE id > @after_id::int
+ORDER BY id
+LIMIT @max_results
+FOR UPDATE;
+
+-- name: UpdateAppointmentEncryption :exec
+UPDATE appointments
+SET
+    patient_notes = @patient_notes,
+    doctor_notes = @doctor_notes
+WHERE id = @id;
@@ -0,0 +1,10 @@
+-- name: CreateAppointmentSeries :one
+INSERT INTO appointment_series (
+    patient_id, doctor_id, user_id, frequency, repeat_interval, occurrence_count,
+    until_date, weekdays, timezone, starts_at, duration_minutes
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING *;
+
+-- name: GetAppointmentSeries :one
+SELECT * FROM appointment_series WHERE id = $1;
@@ -0,0 +1,38 @@
+-- name: SetAuditSource :exec
+-- Who makes the transaction's changes, the audit triggers record it with
+-- each of them. Empty values are recorded as null.
+SELECT
+    set_config('audit.actor_id', @actor_id::text, true),
+    set_config('audit.ip', @ip::text, true),
+    set_config('audit.request_id', @request_id::text, true);
+
+-- name: CreateAuditEntries :exec
+-- One entry per entity id, changes are recorded by the triggers instead.
+INSERT INTO audit_log (actor_id, action, entity, entity_id, ip, request_id)
+SELECT sqlc.narg('actor_id')::int, @action::text, @entity::text, entity_id, sqlc.narg('ip')::text, sqlc.narg('request_id')::text
+FROM unnest(@entity_ids::int[]) AS entity_id;
+
+-- name: GetAuditEntries :many
+-- Newest entries first. With has_cursor only those before cursor_id.
+SELECT * FROM audit_log
+WHERE (sqlc.narg('entity')::text IS NULL OR entity = sqlc.narg('entity')::text)
+  AND (sqlc.narg('entity_id')::int IS NULL OR entity_id = sqlc.narg('entity_id')::int)
+  AND (sqlc.narg('actor_id')::int IS NULL OR actor_id = sqlc.narg('actor_id')::int)
+  AND (sqlc.narg('from_time')::timestamptz IS NULL OR created_at >= sqlc.narg('from_time')::timestamptz)
+  AND (sqlc.narg('to_time')::timestamptz IS NULL OR created_at < sqlc.narg('to_time')::timestamptz)
+  AND (NOT @has_cursor::boolean OR id < @cursor_id::bigint)
+ORDER BY id DESC
+LIMIT @max_results;
+
+-- name: GetAuditChain :many
+-- Entries chained after the entry after_id in the order they are chained,
+-- from the start of the chain when there is no such entry. Entries not
+-- chained yet are left out.
+SELECT * FROM audit_log
+WHERE chain_seq > COALESCE((SELECT a.chain_seq FROM audit_log a WHERE a.id = @after_id::bigint), 0)
+ORDER BY chain_seq
+LIMIT @max_results;
+
+-- name: ChainAuditEntries :one
+-- Chains up to max_results entries, see audit_chain_pending.
+SELECT audit_chain_pending(@max_results::int)::int AS chained;
@@ -0,0 +1,65 @@
+-- name: CreateCalendarFeedToken :one
+INSERT INTO calendar_feed_tokens (doctor_id, token_hash, created_by)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetCalendarFeedTokens :many
+SELECT * FROM calendar_feed_tokens
+WHERE doctor_id = $1
+ORDER BY id ASC;
+
+-- name: UseCalendarFeedToken :one
+-- Finds the live token with the hash and records that it was used.
+UPDATE calendar_feed_tokens
+SET last_used_at = NOW()
+WHERE token_hash = $1 AND revoked_at IS NULL
+RETURNING *;
+
+-- name: RevokeCalendarFeedToken :one
+UPDATE calendar_feed_tokens
+SET revoked_at = COALESCE(revoked_at, NOW())
+WHERE id = $1 AND doctor_id = $2
+RETURNING *;
+
+-- name: GetDoctorCalendar :many
+-- The doctor's appointments visiting between from and to, cancelled ones
+-- included so calendars drop them. Deleted ones are left out of the feed. sequence counts the changes a calendar
+-- has to pick up: every reschedule, and the cancellation.
+SELECT
+    a.id,
+    a.appointment_sequence,
+    a.visit_timestamp,
+    a.visit_end,
+    a.status,
+    a.cancel_reason,
+    p.name AS patient_name,
+    ((SELECT COUNT(*) FROM appointment_reschedule_history h WHERE h.appointment_id = a.id)
+        + CASE WHEN a.status = 'cancelled' THEN 1 ELSE 0 END)::int AS sequence
+FROM appointments a
+JOIN patients p ON p.id = a.patient_id
+WHERE a.doctor_id = @doctor_id
+  AND a.deleted_at IS NULL
+  AND a.visit_timestamp >= @visit_from::timestamptz
+  AND a.visit_timestamp < @visit_to::timestamptz
+ORDER BY a.visit_timestamp ASC
+LIMIT @max_results;
+
+-- name: GetAppointmentInvite :one
+-- An appointment with who it is between, for an invite to the patient.
+SELECT
+    a.id,
+    a.appointment_sequence,
+    a.visit_timestamp,
+    a.visit_end,
+    a.status,
+    a.cancel_reason,
+    a.patient_id,
+    p.name AS patient_name,
+    p.email AS patient_email,
+    d.email AS doctor_email,
+    ((SELECT COUNT(*) FROM appointment_reschedule_history h WHERE h.appointment_id = a.id)
+        + CASE WHEN a.status = 'cancelled' THEN 1 ELSE 0 END)::int AS sequence
+FROM appointments a
+JOIN patients p ON p.id = a.patient_id
+LEFT JOIN users d ON d.id = a.doctor_id
+WHERE a.id = $1 AND a.deleted_at IS NULL;
@@ -0,0 +1,19 @@
+-- name: GetChangesSince :many
+SELECT * FROM change_log
+WHERE id > $1
+ORDER BY id ASC
+LIMIT $2;
+
+-- name: GetLatestChangeID :one
+SELECT COALESCE(MAX(id), 0)::bigint AS id FROM change_log;
+
+-- name: DeleteChangesBefore :execrows
+-- Deletes up to max_results of the changes logged before the given time, oldest
+-- first, a batch at a time so the table isn't locked for long.
+DELETE FROM change_log
+WHERE id IN (
+    SELECT id FROM change_log
+    WHERE created_at < @before::timestamptz
+    ORDER BY id
+    LIMIT @max_results
+);
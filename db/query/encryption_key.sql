@@ -0,0 +1,21 @@
+-- name: GetEncryptionKeys :many
+SELECT * FROM encryption_keys
+ORDER BY id;
+
+-- name: CreateEncryptionKey :one
+INSERT INTO encryption_keys (purpose, wrapped_key, master_key_id)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: RewrapEncryptionKey :exec
+UPDATE encryption_keys
+SET wrapped_key = $2, master_key_id = $3
+WHERE id = $1;
+
+-- name: RetireEncryptionKeys :exec
+-- Retires every data key but the active one.
+UPDATE encryption_keys
+SET retired_at = NOW()
+WHERE purpose = 'data'
+  AND id <> @active_id::int
+  AND retired_at IS NULL;
@@ -1,21 +1,159 @@
 -- name: CreatePatient :one
-INSERT INTO patients (name, phone, email, age, weight, height, gender, address)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+INSERT INTO patients (name, phone, email, age, weight, height, gender, address, email_bidx, phone_bidx)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 RETURNING *;
 
 -- name: GetPatientByID :one
+SELECT * FROM patients WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetPatientByIDWithDeleted :one
 SELECT * FROM patients WHERE id = $1;
 
 -- name: GetAllPatients :many
+-- One page of patients in the requested order, id breaking ties. With
+-- has_cursor only the patients after the cursor's sort key and id are
+-- returned; the cursor value matching sort_by is the one used.
 SELECT * FROM patients
 WHERE (@name::text = '' OR name::text ILIKE '%' || @name::text || '%')
+  AND (@include_deleted::boolean OR deleted_at IS NULL)
+  AND (
+      NOT @has_cursor::boolean
+      OR (@sort_by::text = 'name' AND NOT @sort_desc::boolean AND (name, id) > (@cursor_name::text, @cursor_id::int))
+      OR (@sort_by::text = 'name' AND @sort_desc::boolean AND (name, id) < (@cursor_name::text, @cursor_id::int))
+      OR (@sort_by::text = 'age' AND NOT @sort_desc::boolean AND (COALESCE(age, -1), id) > (@cursor_age::int, @cursor_id::int))
+      OR (@sort_by::text = 'age' AND @sort_desc::boolean AND (COALESCE(age, -1), id) < (@cursor_age::int, @cursor_id::int))
+      OR (@sort_by::text = 'created_at' AND NOT @sort_desc::boolean AND (COALESCE(created_at, 'epoch'), id) > (@cursor_created_at::timestamp, @cursor_id::int))
+      OR (@sort_by::text = 'created_at' AND @sort_desc::boolean AND (COALESCE(created_at, 'epoch'), id) < (@cursor_created_at::timestamp, @cursor_id::int))
+  )
 ORDER BY
-    CASE
-        WHEN @sort_by::text = 'name' THEN name
-        WHEN @sort_by::text = 'age' THEN age::TEXT
-        ELSE created_at::TEXT
-    END
-    || CASE WHEN @sort_direction::text = 'DESC' THEN ' DESC' ELSE ' ASC' END;
+    CASE WHEN @sort_by::text = 'name' AND NOT @sort_desc::boolean THEN name END ASC,
+    CASE WHEN @sort_by::text = 'name' AND @sort_desc::boolean THEN name END DESC,
+    CASE WHEN @sort_by::text = 'age' AND NOT @sort_desc::boolean THEN COALESCE(age, -1) END ASC,
+    CASE WHEN @sort_by::text = 'age' AND @sort_desc::boolean THEN COALESCE(age, -1) END DESC,
+    CASE WHEN @sort_by::text = 'created_at' AND NOT @sort_desc::boolean THEN COALESCE(created_at, 'epoch') END ASC,
+    CASE WHEN @sort_by::text = 'created_at' AND @sort_desc::boolean THEN COALESCE(created_at, 'epoch') END DESC,
+    CASE WHEN NOT @sort_desc::boolean THEN id END ASC,
+    CASE WHEN @sort_desc::boolean THEN id END DESC
+LIMIT @max_results;
+
+-- name: SearchPatients :many
+-- Patients matching a search, best first. prefix_query is a to_tsquery
+-- string matching the start of words in the name, which the search as typed
+-- also finds names containing or resembling it. Emails and phones are
+-- encrypted, they only match as a whole by their blind index. The name comes
+-- back highlighted with <mark> where its words matched.
+SELECT
+    p.id, p.name, p.phone, p.email, p.age, p.weight, p.height, p.gender, p.address, p.created_at, p.updated_at,
+    COALESCE(p.email_bidx = q.email_bidx, false) AS email_match,
+    COALESCE(p.phone_bidx = q.phone_bidx, false) AS phone_match,
+    (
+        ts_rank(to_tsvector('simple', p.name), q.ts)
+        + word_similarity(q.term, p.name)
+        + CASE WHEN p.email_bidx = q.email_bidx THEN 1 ELSE 0 END
+        + CASE WHEN p.phone_bidx = q.phone_bidx THEN 1 ELSE 0 END
+    )::real AS rank,
+    ts_headline('simple', p.name, q.ts, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS name_highlight
+FROM patients p,
+    (SELECT to_tsquery('simple', @prefix_query::text) AS ts, @term::text AS term, @pattern::text AS pattern, sqlc.narg('email_bidx')::bytea AS email_bidx, sqlc.narg('phone_bidx')::bytea AS phone_bidx) q
+WHERE p.deleted_at IS NULL
+  AND (
+      to_tsvector('simple', p.name) @@ q.ts
+      OR p.name ILIKE q.pattern
+      OR q.term % p.name
+      OR q.term <% p.name
+      OR p.email_bidx = q.email_bidx
+      OR p.phone_bidx = q.phone_bidx
+  )
+ORDER BY rank DESC, p.id ASC
+LIMIT @max_results;
+
+-- name: FindDuplicatePatients :many
+-- Patients that may be the same person as the given details: a similar
+-- name, the same phone digits or the same email, the last two by their
+-- blind indexes. Each match is returned with what it has in common, closest
+-- names first. exclude_id leaves out the patient the details belong to, 0
+-- for a new one.
+SELECT
+    id, name, phone, email, age, weight, height, gender, address, created_at, updated_at,
+    similarity(name, @name::text)::real AS name_similarity,
+    COALESCE(email_bidx = sqlc.narg('email_bidx')::bytea, false) AS email_match,
+    COALESCE(phone_bidx = sqlc.narg('phone_bidx')::bytea, false) AS phone_match,
+    (@age::int > 0 AND age = @age::int) AS age_match
+FROM patients
+WHERE id <> @exclude_id::int
+  AND deleted_at IS NULL
+  AND (
+      name % @name::text
+      OR email_bidx = sqlc.narg('email_bidx')::bytea
+      OR phone_bidx = sqlc.narg('phone_bidx')::bytea
+  )
+ORDER BY name_similarity DESC, id ASC
+LIMIT @max_results;
+
+-- name: GetPatientForUpdate :one
+SELECT * FROM patients WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;
+
+-- name: GetPatientMergeOverlaps :many
+-- The active appointments of either patient that overlap an active one of
+-- the other, which the patient overlap constraint keeps from being moved
+-- onto the survivor.
+SELECT a.* FROM appointments a
+WHERE a.patient_id IN (@survivor_id::int, @duplicate_id::int)
+  AND a.status NOT IN ('cancelled', 'no_show')
+  AND a.deleted_at IS NULL
+  AND EXISTS (
+      SELECT 1 FROM appointments o
+      WHERE o.patient_id = CASE WHEN a.patient_id = @survivor_id::int THEN @duplicate_id::int ELSE @survivor_id::int END
+        AND o.status NOT IN ('cancelled', 'no_show')
+        AND o.deleted_at IS NULL
+        AND tstzrange(o.visit_timestamp, o.visit_end) && tstzrange(a.visit_timestamp, a.visit_end)
+  )
+ORDER BY a.visit_timestamp, a.id;
+
+-- name: MovePatientAppointments :many
+UPDATE appointments
+SET patient_id = @survivor_id
+WHERE patient_id = @duplicate_id
+RETURNING *;
+
+-- name: MovePatientAppointmentSeries :execrows
+UPDATE appointment_series
+SET patient_id = @survivor_id
+WHERE patient_id = @duplicate_id;
+
+-- name: MovePatientWaitlistEntries :execrows
+UPDATE waitlist_entries
+SET patient_id = @survivor_id
+WHERE patient_id = @duplicate_id;
+
+-- name: FillPatientFromDuplicate :one
+-- Copies the details the survivor lacks from the duplicate.
+UPDATE patients s
+SET
+    phone = COALESCE(s.phone, d.phone),
+    phone_bidx = CASE WHEN s.phone IS NULL THEN d.phone_bidx ELSE s.phone_bidx END,
+    age = COALESCE(s.age, d.age),
+    weight = COALESCE(s.weight, d.weight),
+    height = COALESCE(s.height, d.height),
+    gender = COALESCE(s.gender, d.gender),
+    address = COALESCE(s.address, d.address)
+FROM patients d
+WHERE s.id = @survivor_id AND d.id = @duplicate_id
+RETURNING s.*;
+
+-- name: CreatePatientMerge :one
+-- Records the merge along with the duplicate's row, call it before the
+-- duplicate is deleted.
+INSERT INTO patient_merges (survivor_id, duplicate_id, duplicate, appointments_moved, series_moved, waitlist_entries_moved, merged_by)
+SELECT @survivor_id::int, p.id, to_jsonb(p), @appointments_moved::int, @series_moved::int, @waitlist_entries_moved::int, sqlc.narg('merged_by')::int
+FROM patients p
+WHERE p.id = @duplicate_id
+RETURNING *;
+
+-- name: GetPatientMerges :many
+SELECT * FROM patient_merges
+WHERE survivor_id = $1
+ORDER BY id DESC;
 
 -- name: UpdatePatient :one
 UPDATE patients
@@ -28,10 +166,59 @@ SET
     height = COALESCE($7, height),
     gender = COALESCE($8, gender),
     address = COALESCE($9, address),
+    email_bidx = COALESCE($10, email_bidx),
+    phone_bidx = COALESCE($11, phone_bidx),
     updated_at = NOW()
-WHERE id = $1
+WHERE id = $1 AND deleted_at IS NULL
 RETURNING *;
 
 -- name: DeletePatient :exec
-DELETE FROM patients WHERE id = $1;
+-- Tombstones the patient along with its appointments that aren't deleted
+-- yet, all with the same deleted_at.
+WITH patient AS (
+    UPDATE patients
+    SET deleted_at = NOW()
+    WHERE id = $1 AND deleted_at IS NULL
+    RETURNING id, deleted_at
+)
+UPDATE appointments a
+SET deleted_at = patient.deleted_at
+FROM patient
+WHERE a.patient_id = patient.id AND a.deleted_at IS NULL;
+
+-- name: RestorePatient :one
+-- Brings back the patient and the appointments deleted along with it.
+WITH patient AS (
+    SELECT id, deleted_at FROM patients
+    WHERE id = $1
+    FOR UPDATE
+), appointments_restored AS (
+    UPDATE appointments a
+    SET deleted_at = NULL
+    FROM patient
+    WHERE a.patient_id = patient.id AND a.deleted_at = patient.deleted_at
+)
+UPDATE patients
+SET deleted_at = NULL
+FROM patient
+WHERE patients.id = patient.id
+RETURNING patients.*;
+
+-- name: GetPatientsForReencryption :many
+-- Every patient after after_id, deleted or not, locked until the
+-- transaction ends so they can be written back re-encrypted.
+SELECT * FROM patients
+WHERE id > @after_id::int
+ORDER BY id
+LIMIT @max_results
+FOR UPDATE;
 
+-- name: UpdatePatientEncryption :exec
+UPDATE patients
+SET
+    phone = @phone,
+    email = @email,
+    address = @address,
+    email_bidx = @email_bidx,
+    phone_bidx = @phone_bidx
+WHERE id = @id;
@@ -0,0 +1,31 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (
+    user_id, family_id, token_hash, token_version, user_agent, ip, device_name, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: UseRefreshToken :one
+-- Finds the live token with the hash and marks it used, a token can only be
+-- used once.
+UPDATE refresh_tokens
+SET used_at = NOW()
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND revoked_at IS NULL
+  AND expires_at > NOW()
+RETURNING *;
+
+-- name: GetRefreshTokenByHash :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :exec
+DELETE FROM refresh_tokens
+WHERE user_id = $1 AND expires_at <= NOW();
@@ -0,0 +1,49 @@
+-- name: GetDueReminders :many
+-- Scheduled appointments whose reminder offset before the visit has been
+-- reached and that have no reminder yet on the channel, or only a failed
+-- one with attempts left. Appointments booked after the offset had already
+-- passed are left to the shorter offsets.
+SELECT
+    a.id AS appointment_id,
+    a.appointment_sequence,
+    a.visit_timestamp,
+    p.name AS patient_name,
+    p.email AS patient_email,
+    p.phone AS patient_phone,
+    COALESCE(s.timezone, 'UTC')::text AS timezone
+FROM appointments a
+JOIN patients p ON p.id = a.patient_id
+LEFT JOIN doctor_schedules s ON s.doctor_id = a.doctor_id
+WHERE a.status = 'scheduled'
+  AND a.deleted_at IS NULL
+  AND a.visit_timestamp > NOW()
+  AND a.visit_timestamp <= NOW() + make_interval(mins => @offset_minutes::int)
+  AND a.created_at <= a.visit_timestamp - make_interval(mins => @offset_minutes::int)
+  AND NOT EXISTS (
+      SELECT 1 FROM appointment_reminders r
+      WHERE r.appointment_id = a.id
+        AND r.offset_minutes = @offset_minutes::int
+        AND r.channel = @channel::text
+        AND r.visit_timestamp = a.visit_timestamp
+        AND (r.status <> 'failed' OR r.attempts >= @max_attempts::int)
+  )
+ORDER BY a.visit_timestamp ASC
+LIMIT @max_results;
+
+-- name: ClaimReminder :one
+-- Records the reminder as being sent, or takes a failed one back for another
+-- attempt. No row comes back when it was already sent or is being sent.
+INSERT INTO appointment_reminders (appointment_id, offset_minutes, channel, visit_timestamp)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (appointment_id, offset_minutes, channel, visit_timestamp) DO UPDATE
+SET status = 'sending', attempts = appointment_reminders.attempts + 1, last_error = NULL
+WHERE appointment_reminders.status = 'failed'
+RETURNING *;
+
+-- name: CompleteReminder :exec
+UPDATE appointment_reminders
+SET
+    status = $2,
+    last_error = $3,
+    sent_at = CASE WHEN $2 = 'sent' THEN NOW() ELSE sent_at END
+WHERE id = $1;
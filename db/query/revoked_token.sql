@@ -0,0 +1,15 @@
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (jti, user_id, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: GetRevokedTokens :many
+-- The revoked tokens that haven't expired yet, the others are rejected
+-- anyway.
+SELECT * FROM revoked_tokens
+WHERE expires_at > NOW()
+ORDER BY revoked_at;
+
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at <= NOW();
@@ -0,0 +1,49 @@
+-- name: GetDoctorSchedule :one
+SELECT * FROM doctor_schedules WHERE doctor_id = $1;
+
+-- name: UpsertDoctorSchedule :one
+INSERT INTO doctor_schedules (doctor_id, slot_minutes, timezone)
+VALUES ($1, $2, $3)
+ON CONFLICT (doctor_id) DO UPDATE
+SET slot_minutes = EXCLUDED.slot_minutes, timezone = EXCLUDED.timezone
+RETURNING *;
+
+-- name: GetDoctorWorkingHours :many
+SELECT * FROM doctor_working_hours
+WHERE doctor_id = $1
+ORDER BY weekday ASC, start_time ASC;
+
+-- name: CreateDoctorWorkingHour :one
+INSERT INTO doctor_working_hours (doctor_id, weekday, start_time, end_time)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: DeleteDoctorWorkingHours :exec
+DELETE FROM doctor_working_hours WHERE doctor_id = $1;
+
+-- name: GetDoctorBreaks :many
+SELECT * FROM doctor_breaks
+WHERE doctor_id = $1
+ORDER BY weekday ASC, start_time ASC;
+
+-- name: CreateDoctorBreak :one
+INSERT INTO doctor_breaks (doctor_id, weekday, start_time, end_time)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: DeleteDoctorBreaks :exec
+DELETE FROM doctor_breaks WHERE doctor_id = $1;
+
+-- name: GetDoctorScheduleExceptions :many
+SELECT * FROM doctor_schedule_exceptions
+WHERE doctor_id = @doctor_id
+  AND exception_date BETWEEN @from_date::date AND @to_date::date
+ORDER BY exception_date ASC, start_time ASC NULLS FIRST;
+
+-- name: CreateDoctorScheduleException :one
+INSERT INTO doctor_schedule_exceptions (doctor_id, exception_date, kind, start_time, end_time, reason)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: DeleteDoctorScheduleException :exec
+DELETE FROM doctor_schedule_exceptions WHERE id = $1 AND doctor_id = $2;
@@ -1,10 +1,34 @@
 -- name: GetUser :one
 SELECT * FROM users
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
+
+-- name: GetUserWithDeleted :one
+SELECT * FROM users
 WHERE id = $1 LIMIT 1;
 
 -- name: GetAllUsers :many
+-- One page of users, see GetAllPatients for how the cursor works. Sorting
+-- by id needs no cursor value besides cursor_id.
 SELECT * FROM public.users
-ORDER BY id ASC;
+WHERE (sqlc.narg('type')::text IS NULL OR type = sqlc.narg('type')::text)
+  AND (@include_deleted::boolean OR deleted_at IS NULL)
+  AND (
+      NOT @has_cursor::boolean
+      OR (@sort_by::text = 'email' AND NOT @sort_desc::boolean AND (email, id) > (@cursor_email::text, @cursor_id::int))
+      OR (@sort_by::text = 'email' AND @sort_desc::boolean AND (email, id) < (@cursor_email::text, @cursor_id::int))
+      OR (@sort_by::text = 'created_at' AND NOT @sort_desc::boolean AND (COALESCE(created_at, 'epoch'), id) > (@cursor_created_at::timestamp, @cursor_id::int))
+      OR (@sort_by::text = 'created_at' AND @sort_desc::boolean AND (COALESCE(created_at, 'epoch'), id) < (@cursor_created_at::timestamp, @cursor_id::int))
+      OR (@sort_by::text = 'id' AND NOT @sort_desc::boolean AND id > @cursor_id::int)
+      OR (@sort_by::text = 'id' AND @sort_desc::boolean AND id < @cursor_id::int)
+  )
+ORDER BY
+    CASE WHEN @sort_by::text = 'email' AND NOT @sort_desc::boolean THEN email END ASC,
+    CASE WHEN @sort_by::text = 'email' AND @sort_desc::boolean THEN email END DESC,
+    CASE WHEN @sort_by::text = 'created_at' AND NOT @sort_desc::boolean THEN COALESCE(created_at, 'epoch') END ASC,
+    CASE WHEN @sort_by::text = 'created_at' AND @sort_desc::boolean THEN COALESCE(created_at, 'epoch') END DESC,
+    CASE WHEN NOT @sort_desc::boolean THEN id END ASC,
+    CASE WHEN @sort_desc::boolean THEN id END DESC
+LIMIT @max_results;
 
 -- name: CreateUser :one
 INSERT INTO public.users (
@@ -17,15 +41,31 @@ RETURNING *;
 -- name: UpdateUser :one
 UPDATE public.users
 SET email = $2 , password = $3, type = $4
-WHERE id = $1
+WHERE id = $1 AND deleted_at IS NULL
 RETURNING *;
 
 -- name: GetUserByEmail :one
-SELECT id, email, password, type, created_at, updated_at
+SELECT id, email, password, type, created_at, updated_at, deleted_at, token_version
 FROM users
-WHERE email = $1
+WHERE email = $1 AND deleted_at IS NULL
 LIMIT 1;
 
 -- name: DeleteUser :exec
-DELETE FROM users WHERE id = $1;
+UPDATE users
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL
+WHERE id = $1
+RETURNING *;
 
+
+-- name: IncrementUserTokenVersion :one
+-- Revokes every token issued to the user so far, they carry the version
+-- they were issued with.
+UPDATE users
+SET token_version = token_version + 1
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
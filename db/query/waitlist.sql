@@ -0,0 +1,84 @@
+-- name: CreateWaitlistEntry :one
+INSERT INTO waitlist_entries (patient_id, doctor_id, from_date, to_date, time_preference, notes, created_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetWaitlistEntry :one
+SELECT * FROM waitlist_entries WHERE id = $1;
+
+-- name: GetWaitlistEntries :many
+SELECT * FROM waitlist_entries
+ORDER BY created_at ASC, id ASC;
+
+-- name: UpdateWaitlistEntry :one
+UPDATE waitlist_entries
+SET
+    doctor_id = $2,
+    from_date = $3,
+    to_date = $4,
+    time_preference = $5,
+    notes = $6,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetWaitlistEntryStatus :one
+UPDATE waitlist_entries
+SET status = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteWaitlistEntry :exec
+DELETE FROM waitlist_entries WHERE id = $1;
+
+-- name: FindWaitlistMatches :many
+-- Entries asking for this doctor come before those taking anyone, then the
+-- ones with a matching time of day before 'any', then first come first
+-- served. Entries already holding a slot, or of deleted patients, are
+-- skipped.
+SELECT w.* FROM waitlist_entries w
+JOIN patients p ON p.id = w.patient_id AND p.deleted_at IS NULL
+WHERE w.status = 'waiting'
+  AND @visit_date::date BETWEEN w.from_date AND w.to_date
+  AND (w.doctor_id IS NULL OR w.doctor_id = @doctor_id)
+  AND w.time_preference IN ('any', @time_preference::text)
+  AND NOT EXISTS (
+      SELECT 1 FROM waitlist_holds h
+      WHERE h.waitlist_entry_id = w.id AND h.status = 'pending' AND h.expires_at > NOW()
+  )
+ORDER BY (w.doctor_id IS NOT NULL) DESC, (w.time_preference <> 'any') DESC, w.created_at ASC, w.id ASC
+LIMIT @max_results;
+
+-- name: CreateWaitlistHold :one
+INSERT INTO waitlist_holds (waitlist_entry_id, doctor_id, visit_timestamp, duration_minutes, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetWaitlistHoldForUpdate :one
+SELECT * FROM waitlist_holds WHERE id = $1 FOR UPDATE;
+
+-- name: GetActiveWaitlistHolds :many
+SELECT * FROM waitlist_holds
+WHERE status = 'pending' AND expires_at > NOW()
+ORDER BY expires_at ASC, id ASC;
+
+-- name: GetActiveWaitlistHoldsForDoctorBetween :many
+SELECT * FROM waitlist_holds
+WHERE doctor_id = @doctor_id
+  AND status = 'pending'
+  AND expires_at > NOW()
+  AND tstzrange(visit_timestamp, visit_timestamp + make_interval(mins => duration_minutes)) && tstzrange(@from_time::timestamptz, @to_time::timestamptz)
+ORDER BY visit_timestamp ASC;
+
+-- name: ResolveWaitlistHold :one
+UPDATE waitlist_holds
+SET status = $2, appointment_id = $3, resolved_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ReleaseCompetingWaitlistHolds :execrows
+-- Releases the other pending offers of the same slot once one of them is
+-- confirmed.
+UPDATE waitlist_holds
+SET status = 'released', resolved_at = NOW()
+WHERE doctor_id = $1 AND visit_timestamp = $2 AND status = 'pending' AND id <> $3;
@@ -0,0 +1,104 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox_events (event_type, payload)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: DispatchOutboxEvents :execrows
+-- Queues a delivery of each undispatched event for every active webhook
+-- that wants it. SKIP LOCKED lets several app instances do this at once.
+WITH events AS (
+    SELECT id, event_type FROM outbox_events
+    WHERE dispatched_at IS NULL
+    ORDER BY id ASC
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+), queued AS (
+    INSERT INTO webhook_deliveries (webhook_id, event_id)
+    SELECT w.id, e.id FROM events e
+    JOIN webhooks w ON w.active AND (cardinality(w.event_types) = 0 OR e.event_type = ANY(w.event_types))
+    ON CONFLICT (webhook_id, event_id) DO NOTHING
+)
+UPDATE outbox_events SET dispatched_at = NOW()
+WHERE id IN (SELECT id FROM events);
+
+-- name: ClaimWebhookDeliveries :many
+-- Takes the due deliveries and pushes their next attempt out by the lease,
+-- so another instance won't send them too. If the sender dies the delivery
+-- is retried once the lease runs out.
+-- Deliveries of a paused webhook wait until it is active again.
+WITH due AS (
+    SELECT d.id FROM webhook_deliveries d
+    JOIN webhooks w ON w.id = d.webhook_id
+    WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND w.active
+    ORDER BY d.next_attempt_at ASC
+    LIMIT @max_results
+    FOR UPDATE OF d SKIP LOCKED
+)
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => @lease_seconds::float8)
+FROM due, webhooks w, outbox_events e
+WHERE d.id = due.id AND w.id = d.webhook_id AND e.id = d.event_id
+RETURNING d.id, d.webhook_id, d.event_id, d.attempts, w.url, w.secret, e.event_type, e.payload, e.created_at AS event_created_at;
+
+-- name: CompleteWebhookDeliveryAttempt :one
+UPDATE webhook_deliveries
+SET
+    status = $2,
+    next_attempt_at = $3,
+    last_status_code = $4,
+    last_error = $5,
+    delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() ELSE delivered_at END
+WHERE id = $1
+RETURNING *;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = @webhook_id
+  AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+ORDER BY id DESC
+LIMIT @max_results;
+
+-- name: ReplayWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), last_error = NULL, last_status_code = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: ReplayDeadWebhookDeliveries :execrows
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), last_error = NULL, last_status_code = NULL
+WHERE webhook_id = $1 AND status = 'dead';
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (url, secret, event_types, created_by)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetWebhooks :many
+SELECT * FROM webhooks
+ORDER BY id ASC;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks WHERE id = $1;
+
+-- name: SetWebhookActive :one
+UPDATE webhooks
+SET active = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks WHERE id = $1;
+
+-- name: GetOutboxEventsForReencryption :many
+-- Every event after after_id, locked until the transaction ends so their
+-- payloads can be written back re-encrypted.
+SELECT * FROM outbox_events
+WHERE id > @after_id::bigint
+ORDER BY id
+LIMIT @max_results
+FOR UPDATE;
+
+-- name: UpdateOutboxEventPayload :exec
+UPDATE outbox_events SET payload = $2
+WHERE id = $1;
@@ -0,0 +1,68 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS appointment_sequence_counters (
+    visit_date DATE PRIMARY KEY,
+    last_sequence SMALLINT NOT NULL
+);
+
+INSERT INTO appointment_sequence_counters (visit_date, last_sequence)
+SELECT visit_date, MAX(appointment_sequence)
+FROM appointments
+GROUP BY visit_date
+ON CONFLICT (visit_date) DO NOTHING;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION next_appointment_sequence(for_date DATE)
+RETURNS SMALLINT AS $$
+DECLARE
+    next_sequence SMALLINT;
+BEGIN
+    -- The upsert locks the day's counter row until the booking transaction
+    -- ends, so concurrent bookings for one day queue up here instead of
+    -- reading the same MAX(). A rolled back booking also rolls back its
+    -- increment, which keeps the sequence free of gaps.
+    INSERT INTO appointment_sequence_counters AS c (visit_date, last_sequence)
+    VALUES (
+        for_date,
+        COALESCE((SELECT MAX(appointment_sequence) FROM appointments WHERE visit_date = for_date), 0) + 1
+    )
+    ON CONFLICT (visit_date)
+    DO UPDATE SET last_sequence = c.last_sequence + 1
+    RETURNING c.last_sequence INTO next_sequence;
+
+    RETURN next_sequence;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION generate_appointment_sequence()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.appointment_sequence := next_appointment_sequence(NEW.visit_date);
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION generate_appointment_sequence()
+RETURNS TRIGGER AS $$
+DECLARE
+    max_sequence SMALLINT;
+BEGIN
+    SELECT COALESCE(MAX(appointment_sequence), 0) + 1 INTO max_sequence
+    FROM appointments
+    WHERE visit_date = NEW.visit_date;
+
+    NEW.appointment_sequence := max_sequence;
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP FUNCTION IF EXISTS next_appointment_sequence;
+DROP TABLE IF EXISTS appointment_sequence_counters;
@@ -0,0 +1,31 @@
+-- +goose Up
+ALTER TABLE appointments
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'scheduled',
+    ADD COLUMN status_updated_at TIMESTAMPTZ,
+    ADD COLUMN status_updated_by INT REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN cancel_reason TEXT,
+    ADD CONSTRAINT appointments_status_check CHECK (
+        status IN ('scheduled', 'checked_in', 'in_consultation', 'completed', 'cancelled', 'no_show')
+    );
+
+CREATE TABLE IF NOT EXISTS appointment_status_history (
+    id SERIAL PRIMARY KEY,
+    appointment_id INT NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    changed_by INT REFERENCES users(id) ON DELETE SET NULL,
+    reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX appointment_status_history_appointment_id_idx ON appointment_status_history (appointment_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS appointment_status_history;
+
+ALTER TABLE appointments
+    DROP CONSTRAINT IF EXISTS appointments_status_check,
+    DROP COLUMN IF EXISTS cancel_reason,
+    DROP COLUMN IF EXISTS status_updated_by,
+    DROP COLUMN IF EXISTS status_updated_at,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- user_id keeps recording who booked the appointment, doctor_id is who it is for
+ALTER TABLE appointments
+    ADD COLUMN doctor_id INT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX appointments_doctor_id_visit_date_idx ON appointments (doctor_id, visit_date);
+
+-- +goose Down
+DROP INDEX IF EXISTS appointments_doctor_id_visit_date_idx;
+
+ALTER TABLE appointments
+    DROP COLUMN IF EXISTS doctor_id;
@@ -0,0 +1,60 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS doctor_schedules (
+    doctor_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    slot_minutes SMALLINT NOT NULL DEFAULT 15 CHECK (slot_minutes > 0),
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TRIGGER update_updated_at_on_doctor_schedules_trigger
+BEFORE UPDATE ON doctor_schedules
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at();
+
+-- weekday follows Go's time.Weekday: 0 is Sunday
+CREATE TABLE IF NOT EXISTS doctor_working_hours (
+    id SERIAL PRIMARY KEY,
+    doctor_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    CHECK (end_time > start_time)
+);
+
+CREATE INDEX doctor_working_hours_doctor_id_idx ON doctor_working_hours (doctor_id);
+
+CREATE TABLE IF NOT EXISTS doctor_breaks (
+    id SERIAL PRIMARY KEY,
+    doctor_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    CHECK (end_time > start_time)
+);
+
+CREATE INDEX doctor_breaks_doctor_id_idx ON doctor_breaks (doctor_id);
+
+-- leave and holiday remove time from the weekly hours, extra_hours adds some.
+-- Without start_time and end_time the exception covers the whole day.
+CREATE TABLE IF NOT EXISTS doctor_schedule_exceptions (
+    id SERIAL PRIMARY KEY,
+    doctor_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    exception_date DATE NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('leave', 'holiday', 'extra_hours')),
+    start_time TIME,
+    end_time TIME,
+    reason TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK ((start_time IS NULL AND end_time IS NULL) OR (start_time IS NOT NULL AND end_time > start_time)),
+    CHECK (kind <> 'extra_hours' OR start_time IS NOT NULL)
+);
+
+CREATE INDEX doctor_schedule_exceptions_doctor_id_date_idx ON doctor_schedule_exceptions (doctor_id, exception_date);
+
+-- +goose Down
+DROP TABLE IF EXISTS doctor_schedule_exceptions;
+DROP TABLE IF EXISTS doctor_breaks;
+DROP TABLE IF EXISTS doctor_working_hours;
+DROP TRIGGER IF EXISTS update_updated_at_on_doctor_schedules_trigger ON doctor_schedules;
+DROP TABLE IF EXISTS doctor_schedules;
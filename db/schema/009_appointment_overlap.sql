@@ -0,0 +1,56 @@
+-- +goose Up
+-- btree_gist lets the exclusion constraints compare doctor_id/patient_id
+-- with = next to the time range
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE appointments
+    ADD COLUMN duration_minutes SMALLINT NOT NULL DEFAULT 15,
+    ADD COLUMN visit_end TIMESTAMPTZ,
+    ADD CONSTRAINT appointments_duration_minutes_check CHECK (duration_minutes > 0);
+
+UPDATE appointments
+SET visit_end = visit_timestamp + make_interval(mins => duration_minutes);
+
+ALTER TABLE appointments
+    ALTER COLUMN visit_end SET NOT NULL;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION set_appointment_visit_end()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.visit_end := NEW.visit_timestamp + make_interval(mins => NEW.duration_minutes);
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER set_appointment_visit_end
+BEFORE INSERT OR UPDATE OF visit_timestamp, duration_minutes ON appointments
+FOR EACH ROW
+EXECUTE PROCEDURE set_appointment_visit_end();
+
+-- Existing overlapping bookings make these fail, cancel or move them first.
+-- Cancelled and no-show appointments do not hold on to their time.
+ALTER TABLE appointments
+    ADD CONSTRAINT appointments_doctor_overlap_excl EXCLUDE USING gist (
+        doctor_id WITH =,
+        tstzrange(visit_timestamp, visit_end) WITH &&
+    ) WHERE (status NOT IN ('cancelled', 'no_show')),
+    ADD CONSTRAINT appointments_patient_overlap_excl EXCLUDE USING gist (
+        patient_id WITH =,
+        tstzrange(visit_timestamp, visit_end) WITH &&
+    ) WHERE (status NOT IN ('cancelled', 'no_show'));
+
+-- +goose Down
+ALTER TABLE appointments
+    DROP CONSTRAINT IF EXISTS appointments_patient_overlap_excl,
+    DROP CONSTRAINT IF EXISTS appointments_doctor_overlap_excl;
+
+DROP TRIGGER IF EXISTS set_appointment_visit_end ON appointments;
+DROP FUNCTION IF EXISTS set_appointment_visit_end();
+
+ALTER TABLE appointments
+    DROP CONSTRAINT IF EXISTS appointments_duration_minutes_check,
+    DROP COLUMN IF EXISTS visit_end,
+    DROP COLUMN IF EXISTS duration_minutes;
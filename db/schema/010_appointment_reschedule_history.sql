@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS appointment_reschedule_history (
+    id SERIAL PRIMARY KEY,
+    appointment_id INT NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    old_visit_timestamp TIMESTAMPTZ NOT NULL,
+    new_visit_timestamp TIMESTAMPTZ NOT NULL,
+    rescheduled_by INT REFERENCES users(id) ON DELETE SET NULL,
+    reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX appointment_reschedule_history_appointment_id_idx ON appointment_reschedule_history (appointment_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS appointment_reschedule_history;
@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS appointment_series (
+    id SERIAL PRIMARY KEY,
+    patient_id INT NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    doctor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    frequency VARCHAR(10) NOT NULL,
+    repeat_interval SMALLINT NOT NULL DEFAULT 1,
+    occurrence_count SMALLINT,
+    until_date DATE,
+    weekdays SMALLINT[] NOT NULL DEFAULT '{}',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    starts_at TIMESTAMPTZ NOT NULL,
+    duration_minutes SMALLINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT appointment_series_frequency_check CHECK (frequency IN ('daily', 'weekly')),
+    CONSTRAINT appointment_series_repeat_interval_check CHECK (repeat_interval > 0),
+    CONSTRAINT appointment_series_end_check CHECK ((occurrence_count IS NULL) <> (until_date IS NULL))
+);
+
+ALTER TABLE appointments
+    ADD COLUMN series_id INT REFERENCES appointment_series(id) ON DELETE SET NULL;
+
+CREATE INDEX appointments_series_id_idx ON appointments (series_id, visit_timestamp);
+
+-- +goose Down
+DROP INDEX IF EXISTS appointments_series_id_idx;
+
+ALTER TABLE appointments
+    DROP COLUMN IF EXISTS series_id;
+
+DROP TABLE IF EXISTS appointment_series;
@@ -0,0 +1,48 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS waitlist_entries (
+    id SERIAL PRIMARY KEY,
+    patient_id INT NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    -- NULL means any doctor will do
+    doctor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    from_date DATE NOT NULL,
+    to_date DATE NOT NULL,
+    time_preference VARCHAR(10) NOT NULL DEFAULT 'any',
+    notes TEXT,
+    status VARCHAR(10) NOT NULL DEFAULT 'waiting',
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CONSTRAINT waitlist_entries_date_range_check CHECK (to_date >= from_date),
+    CONSTRAINT waitlist_entries_time_preference_check CHECK (time_preference IN ('any', 'morning', 'afternoon', 'evening')),
+    CONSTRAINT waitlist_entries_status_check CHECK (status IN ('waiting', 'booked'))
+);
+
+CREATE INDEX waitlist_entries_status_dates_idx ON waitlist_entries (status, from_date, to_date);
+
+CREATE TRIGGER update_updated_at_on_waitlist_entries_trigger
+BEFORE UPDATE ON waitlist_entries
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at();
+
+-- A hold reserves a freed slot for a waitlisted patient until it expires or
+-- staff confirm it into an appointment or release it.
+CREATE TABLE IF NOT EXISTS waitlist_holds (
+    id SERIAL PRIMARY KEY,
+    waitlist_entry_id INT NOT NULL REFERENCES waitlist_entries(id) ON DELETE CASCADE,
+    doctor_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    visit_timestamp TIMESTAMPTZ NOT NULL,
+    duration_minutes SMALLINT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    appointment_id INT REFERENCES appointments(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ,
+    CONSTRAINT waitlist_holds_status_check CHECK (status IN ('pending', 'confirmed', 'released'))
+);
+
+CREATE INDEX waitlist_holds_doctor_id_visit_timestamp_idx ON waitlist_holds (doctor_id, visit_timestamp) WHERE status = 'pending';
+CREATE INDEX waitlist_holds_waitlist_entry_id_idx ON waitlist_holds (waitlist_entry_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS waitlist_holds;
+DROP TABLE IF EXISTS waitlist_entries;
@@ -0,0 +1,128 @@
+-- +goose Up
+-- Every change to patients and appointments is written here and announced
+-- on the change_feed channel. Listeners that were away read what they
+-- missed from here by id.
+CREATE TABLE IF NOT EXISTS change_log (
+    id BIGSERIAL PRIMARY KEY,
+    table_name VARCHAR(63) NOT NULL,
+    operation VARCHAR(6) NOT NULL,
+    row_id INT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX change_log_created_at_idx ON change_log (created_at);
+
+-- The payload only names the patient and the columns that changed, so no
+-- personal data ends up in the log or on the channel.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION log_patient_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    row_id INT;
+    changed TEXT[];
+    log_id BIGINT;
+    log_payload JSONB;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_id := OLD.id;
+    ELSE
+        row_id := NEW.id;
+    END IF;
+
+    IF TG_OP = 'UPDATE' THEN
+        SELECT COALESCE(array_agg(n.key ORDER BY n.key), '{}') INTO changed
+        FROM jsonb_each(to_jsonb(NEW)) n
+        WHERE n.key <> 'updated_at'
+          AND n.value IS DISTINCT FROM to_jsonb(OLD) -> n.key;
+
+        IF cardinality(changed) = 0 THEN
+            RETURN NULL;
+        END IF;
+    END IF;
+
+    log_payload := jsonb_build_object('changed', changed);
+
+    INSERT INTO change_log (table_name, operation, row_id, payload)
+    VALUES (TG_TABLE_NAME, TG_OP, row_id, log_payload)
+    RETURNING id INTO log_id;
+
+    PERFORM pg_notify('change_feed', jsonb_build_object(
+        'id', log_id,
+        'table', TG_TABLE_NAME,
+        'op', TG_OP,
+        'row_id', row_id,
+        'at', NOW(),
+        'data', log_payload
+    )::text);
+
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION log_appointment_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    rec appointments%ROWTYPE;
+    old_status VARCHAR;
+    log_id BIGINT;
+    log_payload JSONB;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec := OLD;
+    ELSE
+        rec := NEW;
+    END IF;
+
+    IF TG_OP = 'UPDATE' THEN
+        old_status := OLD.status;
+    END IF;
+
+    log_payload := jsonb_build_object(
+        'patient_id', rec.patient_id,
+        'doctor_id', rec.doctor_id,
+        'status', rec.status,
+        'old_status', old_status,
+        'visit_date', rec.visit_date,
+        'visit_timestamp', rec.visit_timestamp,
+        'appointment_sequence', rec.appointment_sequence
+    );
+
+    INSERT INTO change_log (table_name, operation, row_id, payload)
+    VALUES (TG_TABLE_NAME, TG_OP, rec.id, log_payload)
+    RETURNING id INTO log_id;
+
+    PERFORM pg_notify('change_feed', jsonb_build_object(
+        'id', log_id,
+        'table', TG_TABLE_NAME,
+        'op', TG_OP,
+        'row_id', rec.id,
+        'at', NOW(),
+        'data', log_payload
+    )::text);
+
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER log_patient_change
+AFTER INSERT OR UPDATE OR DELETE ON patients
+FOR EACH ROW
+EXECUTE FUNCTION log_patient_change();
+
+CREATE TRIGGER log_appointment_change
+AFTER INSERT OR UPDATE OR DELETE ON appointments
+FOR EACH ROW
+EXECUTE FUNCTION log_appointment_change();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS log_appointment_change ON appointments;
+DROP TRIGGER IF EXISTS log_patient_change ON patients;
+DROP FUNCTION IF EXISTS log_appointment_change();
+DROP FUNCTION IF EXISTS log_patient_change();
+DROP TABLE IF EXISTS change_log;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- One row per reminder sent, or being sent, for an appointment on a channel.
+-- The row is written before sending, so a restart never sends it twice.
+-- visit_timestamp is part of the key so a rescheduled appointment is
+-- reminded again of its new time.
+CREATE TABLE IF NOT EXISTS appointment_reminders (
+    id BIGSERIAL PRIMARY KEY,
+    appointment_id INT NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    offset_minutes INT NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    visit_timestamp TIMESTAMPTZ NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'sending',
+    attempts INT NOT NULL DEFAULT 1,
+    last_error TEXT,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT appointment_reminders_key UNIQUE (appointment_id, offset_minutes, channel, visit_timestamp),
+    CONSTRAINT appointment_reminders_status_check CHECK (status IN ('sending', 'sent', 'failed', 'skipped'))
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS appointment_reminders;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Calendar apps can't send an Authorization header, so a doctor's calendar
+-- feed is read with one of these tokens in its URL instead. Only the
+-- SHA-256 of a token is kept, the token itself is shown once.
+CREATE TABLE IF NOT EXISTS calendar_feed_tokens (
+    id SERIAL PRIMARY KEY,
+    doctor_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT calendar_feed_tokens_token_hash_key UNIQUE (token_hash)
+);
+
+CREATE INDEX calendar_feed_tokens_doctor_id_idx ON calendar_feed_tokens (doctor_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS calendar_feed_tokens;
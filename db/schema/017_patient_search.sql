@@ -0,0 +1,41 @@
+-- +goose Up
+-- Patient search matches whole and partial words with full-text search and
+-- typos or fragments with trigrams. Both go through expression indexes, so
+-- the patients table itself is unchanged.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION patient_search_document(name TEXT, phone TEXT, email TEXT, address TEXT)
+RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector('simple', COALESCE(name, '')), 'A')
+        || setweight(to_tsvector('simple', COALESCE(email, '')), 'B')
+        || setweight(to_tsvector('simple', COALESCE(phone, '')), 'B')
+        || setweight(to_tsvector('simple', COALESCE(address, '')), 'C');
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;
+-- +goose StatementEnd
+
+-- Phone numbers are written with all kinds of separators, they are searched
+-- by their digits only.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION phone_digits(phone TEXT)
+RETURNS TEXT AS $$
+    SELECT regexp_replace(COALESCE(phone, ''), '\D', '', 'g');
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;
+-- +goose StatementEnd
+
+CREATE INDEX patients_search_document_idx ON patients
+USING GIN (patient_search_document(name, phone, email, address));
+
+CREATE INDEX patients_name_trgm_idx ON patients USING GIN (name gin_trgm_ops);
+CREATE INDEX patients_email_trgm_idx ON patients USING GIN (email gin_trgm_ops);
+CREATE INDEX patients_phone_digits_trgm_idx ON patients USING GIN (phone_digits(phone) gin_trgm_ops);
+CREATE INDEX patients_address_trgm_idx ON patients USING GIN (address gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS patients_address_trgm_idx;
+DROP INDEX IF EXISTS patients_phone_digits_trgm_idx;
+DROP INDEX IF EXISTS patients_email_trgm_idx;
+DROP INDEX IF EXISTS patients_name_trgm_idx;
+DROP INDEX IF EXISTS patients_search_document_idx;
+DROP FUNCTION IF EXISTS phone_digits(TEXT);
+DROP FUNCTION IF EXISTS patient_search_document(TEXT, TEXT, TEXT, TEXT);
@@ -0,0 +1,23 @@
+-- +goose Up
+-- A record of every duplicate patient merged into another. The duplicate is
+-- deleted by the merge, so its row is kept here as it was.
+CREATE TABLE IF NOT EXISTS patient_merges (
+    id SERIAL PRIMARY KEY,
+    survivor_id INT NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    duplicate_id INT NOT NULL,
+    duplicate JSONB NOT NULL,
+    appointments_moved INT NOT NULL DEFAULT 0,
+    series_moved INT NOT NULL DEFAULT 0,
+    waitlist_entries_moved INT NOT NULL DEFAULT 0,
+    merged_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX patient_merges_survivor_id_idx ON patient_merges (survivor_id);
+
+-- Duplicates are looked up by email regardless of case.
+CREATE INDEX patients_lower_email_idx ON patients (LOWER(email));
+
+-- +goose Down
+DROP INDEX IF EXISTS patients_lower_email_idx;
+DROP TABLE IF EXISTS patient_merges;
@@ -0,0 +1,80 @@
+-- +goose Up
+-- Patients, appointments and users are no longer deleted but given a
+-- deleted_at tombstone. Deleting a patient tombstones its appointments with
+-- the same deleted_at, so restoring the patient brings back exactly those.
+ALTER TABLE patients ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE appointments ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- A patient that is deleted for real must not take its visit history with it.
+ALTER TABLE appointments
+    DROP CONSTRAINT appointments_patient_id_fkey,
+    ADD CONSTRAINT appointments_patient_id_fkey FOREIGN KEY (patient_id) REFERENCES patients(id) ON DELETE RESTRICT;
+ALTER TABLE appointment_series
+    DROP CONSTRAINT appointment_series_patient_id_fkey,
+    ADD CONSTRAINT appointment_series_patient_id_fkey FOREIGN KEY (patient_id) REFERENCES patients(id) ON DELETE RESTRICT;
+ALTER TABLE waitlist_entries
+    DROP CONSTRAINT waitlist_entries_patient_id_fkey,
+    ADD CONSTRAINT waitlist_entries_patient_id_fkey FOREIGN KEY (patient_id) REFERENCES patients(id) ON DELETE RESTRICT;
+
+-- Emails only have to be unique among the records that aren't deleted. The
+-- indexes keep the constraint names the code checks for.
+ALTER TABLE patients
+    DROP CONSTRAINT IF EXISTS patients_email_key,
+    DROP CONSTRAINT IF EXISTS patients_email_key1;
+CREATE UNIQUE INDEX patients_email_key ON patients (email) WHERE deleted_at IS NULL;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX users_email_key ON users (email) WHERE deleted_at IS NULL;
+
+-- Deleted appointments no longer hold on to their time.
+ALTER TABLE appointments
+    DROP CONSTRAINT appointments_doctor_overlap_excl,
+    DROP CONSTRAINT appointments_patient_overlap_excl,
+    ADD CONSTRAINT appointments_doctor_overlap_excl EXCLUDE USING gist (
+        doctor_id WITH =,
+        tstzrange(visit_timestamp, visit_end) WITH &&
+    ) WHERE (status NOT IN ('cancelled', 'no_show') AND deleted_at IS NULL),
+    ADD CONSTRAINT appointments_patient_overlap_excl EXCLUDE USING gist (
+        patient_id WITH =,
+        tstzrange(visit_timestamp, visit_end) WITH &&
+    ) WHERE (status NOT IN ('cancelled', 'no_show') AND deleted_at IS NULL);
+
+CREATE INDEX appointments_patient_id_deleted_at_idx ON appointments (patient_id, deleted_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS appointments_patient_id_deleted_at_idx;
+
+ALTER TABLE appointments
+    DROP CONSTRAINT appointments_doctor_overlap_excl,
+    DROP CONSTRAINT appointments_patient_overlap_excl,
+    ADD CONSTRAINT appointments_doctor_overlap_excl EXCLUDE USING gist (
+        doctor_id WITH =,
+        tstzrange(visit_timestamp, visit_end) WITH &&
+    ) WHERE (status NOT IN ('cancelled', 'no_show')),
+    ADD CONSTRAINT appointments_patient_overlap_excl EXCLUDE USING gist (
+        patient_id WITH =,
+        tstzrange(visit_timestamp, visit_end) WITH &&
+    ) WHERE (status NOT IN ('cancelled', 'no_show'));
+
+-- Rolling back brings deleted records back as live ones, and fails while a
+-- deleted record shares its email with another.
+DROP INDEX IF EXISTS users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+DROP INDEX IF EXISTS patients_email_key;
+ALTER TABLE patients ADD CONSTRAINT patients_email_key UNIQUE (email);
+
+ALTER TABLE waitlist_entries
+    DROP CONSTRAINT waitlist_entries_patient_id_fkey,
+    ADD CONSTRAINT waitlist_entries_patient_id_fkey FOREIGN KEY (patient_id) REFERENCES patients(id) ON DELETE CASCADE;
+ALTER TABLE appointment_series
+    DROP CONSTRAINT appointment_series_patient_id_fkey,
+    ADD CONSTRAINT appointment_series_patient_id_fkey FOREIGN KEY (patient_id) REFERENCES patients(id) ON DELETE CASCADE;
+ALTER TABLE appointments
+    DROP CONSTRAINT appointments_patient_id_fkey,
+    ADD CONSTRAINT appointments_patient_id_fkey FOREIGN KEY (patient_id) REFERENCES patients(id) ON DELETE CASCADE;
+
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE appointments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE patients DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,131 @@
+-- +goose Up
+-- Who viewed or changed which patient, appointment or user, and from where.
+-- Changes are written by triggers so none is missed, views by the API.
+-- Entries are never updated or deleted.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INT,
+    action VARCHAR(16) NOT NULL,
+    entity VARCHAR(16) NOT NULL,
+    entity_id INT,
+    changes JSONB,
+    ip TEXT,
+    request_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX audit_log_entity_idx ON audit_log (entity, entity_id);
+CREATE INDEX audit_log_actor_id_idx ON audit_log (actor_id);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_log_append_only()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_append_only
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW
+EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+BEFORE TRUNCATE ON audit_log
+FOR EACH STATEMENT
+EXECUTE FUNCTION audit_log_append_only();
+
+-- The columns that differ between two versions of a row as
+-- {"column": {"from": ..., "to": ...}}, either row being null on creation
+-- and deletion. Password hashes are never copied, only that they changed.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_diff(old_row JSONB, new_row JSONB)
+RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(k.key, CASE
+        WHEN k.key = 'password' THEN jsonb_build_object('changed', true)
+        ELSE jsonb_build_object('from', old_row -> k.key, 'to', new_row -> k.key)
+    END), '{}')
+    FROM jsonb_object_keys(COALESCE(new_row, old_row)) k(key)
+    WHERE k.key <> 'updated_at'
+      AND (new_row -> k.key) IS DISTINCT FROM (old_row -> k.key);
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+-- The actor, ip and request id are set for the transaction by the API, see
+-- SetAuditSource. Changes made outside of it have no actor.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_row_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_row JSONB;
+    new_row JSONB;
+    audit_action TEXT;
+    audit_changes JSONB;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_row := to_jsonb(OLD);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_row := to_jsonb(NEW);
+    END IF;
+
+    audit_changes := audit_diff(old_row, new_row);
+    IF audit_changes = '{}' THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        audit_action := 'create';
+    ELSIF TG_OP = 'DELETE' THEN
+        audit_action := 'purge';
+    ELSIF old_row ->> 'deleted_at' IS NULL AND new_row ->> 'deleted_at' IS NOT NULL THEN
+        audit_action := 'delete';
+    ELSIF old_row ->> 'deleted_at' IS NOT NULL AND new_row ->> 'deleted_at' IS NULL THEN
+        audit_action := 'restore';
+    ELSE
+        audit_action := 'update';
+    END IF;
+
+    INSERT INTO audit_log (actor_id, action, entity, entity_id, changes, ip, request_id)
+    VALUES (
+        NULLIF(current_setting('audit.actor_id', true), '')::INT,
+        audit_action,
+        TG_ARGV[0],
+        (COALESCE(new_row, old_row) ->> 'id')::INT,
+        audit_changes,
+        NULLIF(current_setting('audit.ip', true), ''),
+        NULLIF(current_setting('audit.request_id', true), '')
+    );
+
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_patient_change
+AFTER INSERT OR UPDATE OR DELETE ON patients
+FOR EACH ROW
+EXECUTE FUNCTION audit_row_change('patient');
+
+CREATE TRIGGER audit_appointment_change
+AFTER INSERT OR UPDATE OR DELETE ON appointments
+FOR EACH ROW
+EXECUTE FUNCTION audit_row_change('appointment');
+
+CREATE TRIGGER audit_user_change
+AFTER INSERT OR UPDATE OR DELETE ON users
+FOR EACH ROW
+EXECUTE FUNCTION audit_row_change('user');
+
+-- +goose Down
+DROP TRIGGER IF EXISTS audit_user_change ON users;
+DROP TRIGGER IF EXISTS audit_appointment_change ON appointments;
+DROP TRIGGER IF EXISTS audit_patient_change ON patients;
+DROP FUNCTION IF EXISTS audit_row_change();
+DROP FUNCTION IF EXISTS audit_diff(JSONB, JSONB);
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
@@ -0,0 +1,95 @@
+-- +goose Up
+-- Each audit entry carries the SHA-256 of its content and of the entry
+-- before it, so editing or removing an entry breaks the chain from there on.
+-- Entries are chained in id order, ids are handed out by the chain so that
+-- concurrent transactions can't commit them out of order.
+ALTER TABLE audit_log
+    ADD COLUMN prev_hash BYTEA,
+    ADD COLUMN hash BYTEA;
+
+-- The hash of an entry, computed the same way by auditchain.EntryHash: the
+-- previous hash followed by the entry's columns, one per line, nulls as
+-- empty lines and created_at in UTC.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_entry_hash(prev_hash BYTEA, entry audit_log)
+RETURNS BYTEA AS $$
+    SELECT sha256(COALESCE(prev_hash, '') || convert_to(concat_ws(E'\n',
+        entry.id::text,
+        COALESCE(entry.actor_id::text, ''),
+        entry.action,
+        entry.entity,
+        COALESCE(entry.entity_id::text, ''),
+        COALESCE(entry.changes::text, ''),
+        COALESCE(entry.ip, ''),
+        COALESCE(entry.request_id, ''),
+        to_char(entry.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+    ), 'UTF8'));
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- The last entry of the chain. Appending locks it until the transaction
+-- ends, which keeps the chain in commit order.
+CREATE TABLE IF NOT EXISTS audit_chain_head (
+    singleton BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (singleton),
+    last_id BIGINT NOT NULL,
+    last_hash BYTEA
+);
+
+LOCK TABLE audit_log IN EXCLUSIVE MODE;
+
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_append_only;
+
+-- +goose StatementBegin
+DO $$
+DECLARE
+    entry audit_log;
+    last_hash BYTEA;
+BEGIN
+    FOR entry IN SELECT * FROM audit_log ORDER BY id LOOP
+        UPDATE audit_log
+        SET prev_hash = last_hash, hash = audit_entry_hash(last_hash, entry)
+        WHERE id = entry.id
+        RETURNING hash INTO last_hash;
+    END LOOP;
+
+    INSERT INTO audit_chain_head (last_id, last_hash)
+    SELECT COALESCE(MAX(id), 0), last_hash FROM audit_log;
+END;
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_append_only;
+
+ALTER TABLE audit_log ALTER COLUMN hash SET NOT NULL;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_log_chain()
+RETURNS TRIGGER AS $$
+DECLARE
+    head audit_chain_head;
+BEGIN
+    SELECT * INTO head FROM audit_chain_head FOR UPDATE;
+
+    NEW.id := head.last_id + 1;
+    NEW.prev_hash := head.last_hash;
+    NEW.hash := audit_entry_hash(NEW.prev_hash, NEW);
+
+    UPDATE audit_chain_head SET last_id = NEW.id, last_hash = NEW.hash;
+
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_chain
+BEFORE INSERT ON audit_log
+FOR EACH ROW
+EXECUTE FUNCTION audit_log_chain();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS audit_log_chain ON audit_log;
+DROP FUNCTION IF EXISTS audit_log_chain();
+DROP TABLE IF EXISTS audit_chain_head;
+DROP FUNCTION IF EXISTS audit_entry_hash(BYTEA, audit_log);
+ALTER TABLE audit_log DROP COLUMN IF EXISTS hash, DROP COLUMN IF EXISTS prev_hash;
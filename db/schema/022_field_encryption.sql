@@ -0,0 +1,103 @@
+-- +goose Up
+-- Patients' contact details and appointment notes are stored encrypted by
+-- the API, see fieldcrypt. The data keys they are encrypted with are kept
+-- here, wrapped by a master key that is not. Rows written before are
+-- encrypted by "web rotate-keys".
+CREATE TABLE IF NOT EXISTS encryption_keys (
+    id SERIAL PRIMARY KEY,
+    purpose VARCHAR(16) NOT NULL CHECK (purpose IN ('data', 'blind_index')),
+    wrapped_key BYTEA NOT NULL,
+    master_key_id VARCHAR(16) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    retired_at TIMESTAMPTZ
+);
+
+-- Blind indexes stay valid only as long as their key does.
+CREATE UNIQUE INDEX encryption_keys_blind_index_key ON encryption_keys (purpose) WHERE purpose = 'blind_index';
+
+-- Encrypted values no longer fit the old sizes, nor can the database search
+-- them. Emails and phones are looked up by their blind index instead.
+DROP INDEX IF EXISTS patients_search_document_idx;
+DROP INDEX IF EXISTS patients_email_trgm_idx;
+DROP INDEX IF EXISTS patients_phone_digits_trgm_idx;
+DROP INDEX IF EXISTS patients_address_trgm_idx;
+DROP INDEX IF EXISTS patients_lower_email_idx;
+DROP INDEX IF EXISTS patients_email_key;
+DROP FUNCTION IF EXISTS patient_search_document(TEXT, TEXT, TEXT, TEXT);
+DROP FUNCTION IF EXISTS phone_digits(TEXT);
+
+ALTER TABLE patients
+    ALTER COLUMN phone TYPE TEXT,
+    ALTER COLUMN email TYPE TEXT,
+    ADD COLUMN email_bidx BYTEA,
+    ADD COLUMN phone_bidx BYTEA;
+
+-- Keeps the name of the constraint the code checks for.
+CREATE UNIQUE INDEX patients_email_key ON patients (email_bidx) WHERE deleted_at IS NULL;
+CREATE INDEX patients_phone_bidx_idx ON patients (phone_bidx);
+CREATE INDEX patients_name_search_idx ON patients USING GIN (to_tsvector('simple', name));
+
+-- Blind indexes are left out of the audit log like password hashes, they
+-- are only there to look values up by.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_diff(old_row JSONB, new_row JSONB)
+RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(k.key, CASE
+        WHEN k.key = 'password' THEN jsonb_build_object('changed', true)
+        ELSE jsonb_build_object('from', old_row -> k.key, 'to', new_row -> k.key)
+    END), '{}')
+    FROM jsonb_object_keys(COALESCE(new_row, old_row)) k(key)
+    WHERE k.key <> 'updated_at'
+      AND k.key NOT LIKE '%\_bidx'
+      AND (new_row -> k.key) IS DISTINCT FROM (old_row -> k.key);
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Values already encrypted stay encrypted.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_diff(old_row JSONB, new_row JSONB)
+RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(k.key, CASE
+        WHEN k.key = 'password' THEN jsonb_build_object('changed', true)
+        ELSE jsonb_build_object('from', old_row -> k.key, 'to', new_row -> k.key)
+    END), '{}')
+    FROM jsonb_object_keys(COALESCE(new_row, old_row)) k(key)
+    WHERE k.key <> 'updated_at'
+      AND (new_row -> k.key) IS DISTINCT FROM (old_row -> k.key);
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+DROP INDEX IF EXISTS patients_name_search_idx;
+DROP INDEX IF EXISTS patients_phone_bidx_idx;
+DROP INDEX IF EXISTS patients_email_key;
+ALTER TABLE patients
+    DROP COLUMN IF EXISTS phone_bidx,
+    DROP COLUMN IF EXISTS email_bidx;
+CREATE UNIQUE INDEX patients_email_key ON patients (email) WHERE deleted_at IS NULL;
+CREATE INDEX patients_lower_email_idx ON patients (LOWER(email));
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION patient_search_document(name TEXT, phone TEXT, email TEXT, address TEXT)
+RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector('simple', COALESCE(name, '')), 'A')
+        || setweight(to_tsvector('simple', COALESCE(email, '')), 'B')
+        || setweight(to_tsvector('simple', COALESCE(phone, '')), 'B')
+        || setweight(to_tsvector('simple', COALESCE(address, '')), 'C');
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION phone_digits(phone TEXT)
+RETURNS TEXT AS $$
+    SELECT regexp_replace(COALESCE(phone, ''), '\D', '', 'g');
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;
+-- +goose StatementEnd
+
+CREATE INDEX patients_search_document_idx ON patients
+USING GIN (patient_search_document(name, phone, email, address));
+CREATE INDEX patients_email_trgm_idx ON patients USING GIN (email gin_trgm_ops);
+CREATE INDEX patients_phone_digits_trgm_idx ON patients USING GIN (phone_digits(phone) gin_trgm_ops);
+CREATE INDEX patients_address_trgm_idx ON patients USING GIN (address gin_trgm_ops);
+
+DROP TABLE IF EXISTS encryption_keys;
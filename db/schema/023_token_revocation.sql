@@ -0,0 +1,18 @@
+-- +goose Up
+-- Tokens are revoked one at a time on logout, by their jti claim, and all of
+-- a user's at once by bumping the user's token version, which every token
+-- carries. Revoked tokens are only kept until they would have expired.
+ALTER TABLE users ADD COLUMN token_version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS revoked_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS token_version;
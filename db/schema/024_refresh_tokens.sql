@@ -0,0 +1,27 @@
+-- +goose Up
+-- Access tokens live a few minutes, a refresh token gets a new one along
+-- with the refresh token to use next time. The tokens handed out from one
+-- login are a family, a refresh token used twice means it was stolen and
+-- revokes its family. Only the SHA-256 of a token is kept.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id VARCHAR(32) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    -- the user's token version it was issued with, see revoked_tokens
+    token_version INT NOT NULL,
+    user_agent TEXT,
+    ip TEXT,
+    device_name VARCHAR(255),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT refresh_tokens_token_hash_key UNIQUE (token_hash)
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS refresh_tokens;
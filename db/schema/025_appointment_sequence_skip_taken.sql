@@ -0,0 +1,64 @@
+-- +goose Up
+-- A day's counter can fall behind its appointments, for rows written before
+-- the counter existed or moved there by a reschedule. The next number is
+-- then past both, so it is never one that is taken. MAX() is read from the
+-- (visit_date, appointment_sequence) unique index, deleted rows included as
+-- they keep their numbers.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION next_appointment_sequence(for_date DATE)
+RETURNS SMALLINT AS $$
+DECLARE
+    next_sequence SMALLINT;
+BEGIN
+    -- The upsert locks the day's counter row until the booking transaction
+    -- ends, so concurrent bookings for one day queue up here instead of
+    -- reading the same MAX(). A rolled back booking also rolls back its
+    -- increment, which keeps the sequence free of gaps.
+    INSERT INTO appointment_sequence_counters AS c (visit_date, last_sequence)
+    VALUES (
+        for_date,
+        COALESCE((SELECT MAX(appointment_sequence) FROM appointments WHERE visit_date = for_date), 0) + 1
+    )
+    ON CONFLICT (visit_date)
+    DO UPDATE SET last_sequence = GREATEST(
+        c.last_sequence,
+        COALESCE((SELECT MAX(appointment_sequence) FROM appointments WHERE visit_date = for_date), 0)
+    ) + 1
+    RETURNING c.last_sequence INTO next_sequence;
+
+    RETURN next_sequence;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+UPDATE appointment_sequence_counters c
+SET last_sequence = m.max_sequence
+FROM (
+    SELECT visit_date, MAX(appointment_sequence) AS max_sequence
+    FROM appointments
+    GROUP BY visit_date
+) m
+WHERE m.visit_date = c.visit_date AND m.max_sequence > c.last_sequence;
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION next_appointment_sequence(for_date DATE)
+RETURNS SMALLINT AS $$
+DECLARE
+    next_sequence SMALLINT;
+BEGIN
+    INSERT INTO appointment_sequence_counters AS c (visit_date, last_sequence)
+    VALUES (
+        for_date,
+        COALESCE((SELECT MAX(appointment_sequence) FROM appointments WHERE visit_date = for_date), 0) + 1
+    )
+    ON CONFLICT (visit_date)
+    DO UPDATE SET last_sequence = c.last_sequence + 1
+    RETURNING c.last_sequence INTO next_sequence;
+
+    RETURN next_sequence;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
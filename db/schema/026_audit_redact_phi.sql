@@ -0,0 +1,151 @@
+-- +goose Up
+-- Patients' contact details and appointment notes are health information.
+-- Like password hashes they are never copied to the audit log, only that
+-- they changed: the log is append-only and hash chained, so nothing written
+-- to it can be scrubbed later. Entries written before are left as they are.
+
+-- The columns of a table whose changes are recorded without their values.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_redacted_columns(table_name TEXT)
+RETURNS TEXT[] AS $$
+    SELECT CASE table_name
+        WHEN 'patients' THEN ARRAY['phone', 'email', 'address']
+        WHEN 'appointments' THEN ARRAY['patient_notes', 'doctor_notes']
+        ELSE ARRAY[]::TEXT[]
+    END;
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_diff(old_row JSONB, new_row JSONB, redacted TEXT[])
+RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(k.key, CASE
+        WHEN k.key = 'password' OR k.key = ANY(redacted) THEN jsonb_build_object('changed', true)
+        ELSE jsonb_build_object('from', old_row -> k.key, 'to', new_row -> k.key)
+    END), '{}')
+    FROM jsonb_object_keys(COALESCE(new_row, old_row)) k(key)
+    WHERE k.key <> 'updated_at'
+      AND k.key NOT LIKE '%\_bidx'
+      AND (new_row -> k.key) IS DISTINCT FROM (old_row -> k.key);
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_row_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_row JSONB;
+    new_row JSONB;
+    audit_action TEXT;
+    audit_changes JSONB;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_row := to_jsonb(OLD);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_row := to_jsonb(NEW);
+    END IF;
+
+    audit_changes := audit_diff(old_row, new_row, audit_redacted_columns(TG_TABLE_NAME));
+    IF audit_changes = '{}' THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        audit_action := 'create';
+    ELSIF TG_OP = 'DELETE' THEN
+        audit_action := 'purge';
+    ELSIF old_row ->> 'deleted_at' IS NULL AND new_row ->> 'deleted_at' IS NOT NULL THEN
+        audit_action := 'delete';
+    ELSIF old_row ->> 'deleted_at' IS NOT NULL AND new_row ->> 'deleted_at' IS NULL THEN
+        audit_action := 'restore';
+    ELSE
+        audit_action := 'update';
+    END IF;
+
+    INSERT INTO audit_log (actor_id, action, entity, entity_id, changes, ip, request_id)
+    VALUES (
+        NULLIF(current_setting('audit.actor_id', true), '')::INT,
+        audit_action,
+        TG_ARGV[0],
+        (COALESCE(new_row, old_row) ->> 'id')::INT,
+        audit_changes,
+        NULLIF(current_setting('audit.ip', true), ''),
+        NULLIF(current_setting('audit.request_id', true), '')
+    );
+
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP FUNCTION IF EXISTS audit_diff(JSONB, JSONB);
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_diff(old_row JSONB, new_row JSONB)
+RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(k.key, CASE
+        WHEN k.key = 'password' THEN jsonb_build_object('changed', true)
+        ELSE jsonb_build_object('from', old_row -> k.key, 'to', new_row -> k.key)
+    END), '{}')
+    FROM jsonb_object_keys(COALESCE(new_row, old_row)) k(key)
+    WHERE k.key <> 'updated_at'
+      AND k.key NOT LIKE '%\_bidx'
+      AND (new_row -> k.key) IS DISTINCT FROM (old_row -> k.key);
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_row_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_row JSONB;
+    new_row JSONB;
+    audit_action TEXT;
+    audit_changes JSONB;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_row := to_jsonb(OLD);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_row := to_jsonb(NEW);
+    END IF;
+
+    audit_changes := audit_diff(old_row, new_row);
+    IF audit_changes = '{}' THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        audit_action := 'create';
+    ELSIF TG_OP = 'DELETE' THEN
+        audit_action := 'purge';
+    ELSIF old_row ->> 'deleted_at' IS NULL AND new_row ->> 'deleted_at' IS NOT NULL THEN
+        audit_action := 'delete';
+    ELSIF old_row ->> 'deleted_at' IS NOT NULL AND new_row ->> 'deleted_at' IS NULL THEN
+        audit_action := 'restore';
+    ELSE
+        audit_action := 'update';
+    END IF;
+
+    INSERT INTO audit_log (actor_id, action, entity, entity_id, changes, ip, request_id)
+    VALUES (
+        NULLIF(current_setting('audit.actor_id', true), '')::INT,
+        audit_action,
+        TG_ARGV[0],
+        (COALESCE(new_row, old_row) ->> 'id')::INT,
+        audit_changes,
+        NULLIF(current_setting('audit.ip', true), ''),
+        NULLIF(current_setting('audit.request_id', true), '')
+    );
+
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP FUNCTION IF EXISTS audit_diff(JSONB, JSONB, TEXT[]);
+DROP FUNCTION IF EXISTS audit_redacted_columns(TEXT);
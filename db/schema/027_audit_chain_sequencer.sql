@@ -0,0 +1,138 @@
+-- +goose Up
+-- Appending to the chain locked its head until the appending transaction
+-- ended, so every transaction writing to the audit log, which is every read
+-- and write of patient data, waited on the one before it. Entries are now
+-- inserted unchained and chained afterwards by audit_chain_pending, which
+-- holds the head only for as long as it takes to hash them.
+--
+-- Entries are chained in chain_seq order, the order they were chained in:
+-- an entry that commits after one with a higher id is chained after it.
+-- Entries chained so far keep their hashes, their chain_seq is their id.
+LOCK TABLE audit_log IN EXCLUSIVE MODE;
+
+DROP TRIGGER IF EXISTS audit_log_chain ON audit_log;
+DROP FUNCTION IF EXISTS audit_log_chain();
+
+ALTER TABLE audit_log ADD COLUMN chain_seq BIGINT;
+
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_append_only;
+UPDATE audit_log SET chain_seq = id;
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_append_only;
+
+ALTER TABLE audit_log ALTER COLUMN hash DROP NOT NULL;
+
+CREATE UNIQUE INDEX audit_log_chain_seq_idx ON audit_log (chain_seq);
+CREATE INDEX audit_log_unchained_idx ON audit_log (id) WHERE chain_seq IS NULL;
+
+ALTER TABLE audit_chain_head ADD COLUMN last_seq BIGINT;
+UPDATE audit_chain_head SET last_seq = last_id;
+ALTER TABLE audit_chain_head ALTER COLUMN last_seq SET NOT NULL;
+
+-- ids were handed out by the chain, not the sequence
+SELECT setval(pg_get_serial_sequence('audit_log', 'id'), GREATEST((SELECT last_id FROM audit_chain_head), 1), (SELECT last_id FROM audit_chain_head) > 0);
+
+-- Entries are still never edited or removed. The one update allowed is the
+-- chaining of an unchained entry, and its chain columns are computed here
+-- whatever they were set to, so they can't be forged either.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_log_append_only()
+RETURNS TRIGGER AS $$
+DECLARE
+    head audit_chain_head;
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        IF OLD.chain_seq IS NULL
+           AND to_jsonb(NEW) - ARRAY['chain_seq', 'prev_hash', 'hash'] = to_jsonb(OLD) - ARRAY['chain_seq', 'prev_hash', 'hash'] THEN
+            SELECT * INTO head FROM audit_chain_head FOR UPDATE;
+
+            NEW.chain_seq := head.last_seq + 1;
+            NEW.prev_hash := head.last_hash;
+            NEW.hash := audit_entry_hash(NEW.prev_hash, NEW);
+
+            UPDATE audit_chain_head SET last_id = NEW.id, last_seq = NEW.chain_seq, last_hash = NEW.hash;
+
+            RETURN NEW;
+        END IF;
+    END IF;
+
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Chains up to max_results committed entries in id order and returns how
+-- many. Run in a transaction of its own so the head isn't held any longer.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_chain_pending(max_results INT)
+RETURNS INT AS $$
+DECLARE
+    entry_id BIGINT;
+    chained INT := 0;
+BEGIN
+    -- one at a time, the entries are read once the head is ours
+    PERFORM 1 FROM audit_chain_head FOR UPDATE;
+
+    FOR entry_id IN
+        SELECT id FROM audit_log WHERE chain_seq IS NULL ORDER BY id LIMIT max_results
+    LOOP
+        UPDATE audit_log SET chain_seq = 0 WHERE id = entry_id;
+        chained := chained + 1;
+    END LOOP;
+
+    RETURN chained;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Entries chained out of id order no longer verify once the chain is
+-- followed by id again.
+LOCK TABLE audit_log IN EXCLUSIVE MODE;
+
+SELECT audit_chain_pending(2147483647);
+
+DROP FUNCTION IF EXISTS audit_chain_pending(INT);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_log_append_only()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP INDEX IF EXISTS audit_log_unchained_idx;
+DROP INDEX IF EXISTS audit_log_chain_seq_idx;
+ALTER TABLE audit_log ALTER COLUMN hash SET NOT NULL;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS chain_seq;
+ALTER TABLE audit_chain_head DROP COLUMN IF EXISTS last_seq;
+UPDATE audit_chain_head SET last_id = (SELECT COALESCE(MAX(id), 0) FROM audit_log);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_log_chain()
+RETURNS TRIGGER AS $$
+DECLARE
+    head audit_chain_head;
+BEGIN
+    SELECT * INTO head FROM audit_chain_head FOR UPDATE;
+
+    NEW.id := head.last_id + 1;
+    NEW.prev_hash := head.last_hash;
+    NEW.hash := audit_entry_hash(NEW.prev_hash, NEW);
+
+    UPDATE audit_chain_head SET last_id = NEW.id, last_hash = NEW.hash;
+
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_chain
+BEFORE INSERT ON audit_log
+FOR EACH ROW
+EXECUTE FUNCTION audit_log_chain();
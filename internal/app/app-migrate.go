@@ -0,0 +1,31 @@
+package app
+
+import (
+	"context"
+	"patient-appointment-demo-go/db"
+	"patient-appointment-demo-go/internal/migrate"
+	"time"
+)
+
+func (a *App) Migrator() (*migrate.Migrator, error) {
+	migrations, err := migrate.Load(db.Schema, db.SchemaDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(a.DbPool, migrations), nil
+}
+
+// CheckSchema fails with migrate.ErrSchemaBehind when the database has not
+// been migrated to the schema embedded in the binary.
+func (a *App) CheckSchema() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	migrator, err := a.Migrator()
+	if err != nil {
+		return err
+	}
+
+	return migrator.Check(ctx)
+}
@@ -1,20 +1,104 @@
 package app
 
 import (
+	"patient-appointment-demo-go/internal/auditchain"
+	"patient-appointment-demo-go/internal/changefeed"
 	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/queue"
+	"patient-appointment-demo-go/internal/reminder"
 	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/internal/webhook"
+	"time"
+
+	"github.com/jackc/pgx/v5"
 )
 
 
 func (a *App) UserRepo() repositories.UserRepositoryInterface {
-    return repositories.NewUserRepository(database.New(a.DbConn))
+    return repositories.NewUserRepository(database.New(a.DbPool), a.Revocations)
 }
 
 func (a *App) PatientRepo() repositories.PatientRepositoryInterface {
-    return repositories.NewPatientRepository(database.New(a.DbConn))
+    return repositories.NewPatientRepository(database.New(a.DbPool), a.Keyring)
 }
 
 func (a *App) AppointmentRepo() repositories.AppointmentRepositoryInterface {
-    return repositories.NewAppointmentRepository(database.New(a.DbConn))
+    return repositories.NewAppointmentRepository(database.New(a.DbPool), a.Keyring)
 }
 
+
+func (a *App) ScheduleRepo() repositories.ScheduleRepositoryInterface {
+    return repositories.NewScheduleRepository(database.New(a.DbPool))
+}
+
+func (a *App) WaitlistRepo() repositories.WaitlistRepositoryInterface {
+    return repositories.NewWaitlistRepository(database.New(a.DbPool))
+}
+
+func (a *App) WebhookRepo() repositories.WebhookRepositoryInterface {
+    return repositories.NewWebhookRepository(database.New(a.DbPool))
+}
+
+func (a *App) ReminderRepo() repositories.ReminderRepositoryInterface {
+    return repositories.NewReminderRepository(database.New(a.DbPool), a.Keyring)
+}
+
+func (a *App) CalendarRepo() repositories.CalendarRepositoryInterface {
+    return repositories.NewCalendarRepository(database.New(a.DbPool), a.Keyring)
+}
+
+func (a *App) AuditRepo() repositories.AuditRepositoryInterface {
+    return repositories.NewAuditRepository(database.New(a.DbPool))
+}
+
+func (a *App) EncryptionKeyRepo() repositories.EncryptionKeyRepositoryInterface {
+    return repositories.NewEncryptionKeyRepository(database.New(a.DbPool))
+}
+
+func (a *App) RevokedTokenRepo() repositories.RevokedTokenRepositoryInterface {
+    return repositories.NewRevokedTokenRepository(database.New(a.DbPool))
+}
+
+func (a *App) RefreshTokenRepo() repositories.RefreshTokenRepositoryInterface {
+    return repositories.NewRefreshTokenRepository(database.New(a.DbPool), a.Revocations)
+}
+
+func (a *App) TxManager() repositories.TxManagerInterface {
+    return repositories.NewTxManager(
+        a.DbPool,
+        func(tx pgx.Tx) repositories.TxQueriesContract {
+            return database.New(a.DbPool).WithTx(tx)
+        },
+        a.Keyring,
+        a.Revocations,
+        repositories.WithSerializationRetries(3, 50*time.Millisecond),
+    )
+}
+
+func (a *App) QueueFeed() *queue.Feed {
+    return queue.NewFeed(a.Events)
+}
+
+func (a *App) WebhookDispatcher() *webhook.Dispatcher {
+    return webhook.NewDispatcher(a.WebhookRepo(), a.Keyring)
+}
+
+func (a *App) ReminderScheduler() *reminder.Scheduler {
+    return reminder.NewScheduler(a.ReminderRepo(), a.reminderOffsets, a.reminderNotifiers)
+}
+
+func (a *App) ChangeLogPruner() *changefeed.Pruner {
+    return changefeed.NewPruner(database.New(a.DbPool), a.changeLogRetention)
+}
+
+func (a *App) AuditSequencer() *auditchain.Sequencer {
+    return auditchain.NewSequencer(a.AuditRepo())
+}
+
+func (a *App) AuditCheckpointer() *auditchain.Checkpointer {
+    var opts []auditchain.Option
+    if a.auditCheckpointInterval > 0 {
+        opts = append(opts, auditchain.WithInterval(a.auditCheckpointInterval))
+    }
+    return auditchain.NewCheckpointer(a.AuditRepo(), a.auditCheckpointKey, a.auditCheckpoints, opts...)
+}
@@ -20,9 +20,16 @@ func (a *App) initRouter() http.Handler{
 		}).
 		Register(a.Mux)
 
-	routes.NewAuthRouter(a.Mux, a.UserRepo()).Register()
-	routes.NewPatientRouter(a.Mux, a.PatientRepo(), a.UserRepo()).Register()
-	routes.NewAppointmentRouter(a.Mux, a.AppointmentRepo(), a.UserRepo()).Register()
+	routes.NewAuthRouter(a.Mux, a.UserRepo(), a.RefreshTokenRepo()).Register()
+	routes.NewUserRouter(a.Mux, a.UserRepo(), a.AuditRepo(), a.TxManager()).Register()
+	routes.NewPatientRouter(a.Mux, a.PatientRepo(), a.UserRepo(), a.AuditRepo(), a.TxManager()).Register()
+	routes.NewAppointmentRouter(a.Mux, a.AppointmentRepo(), a.UserRepo(), a.ScheduleRepo(), a.WaitlistRepo(), a.AuditRepo(), a.TxManager(), a.QueueFeed()).Register()
+	routes.NewScheduleRouter(a.Mux, a.ScheduleRepo(), a.AppointmentRepo(), a.WaitlistRepo(), a.UserRepo(), a.TxManager()).Register()
+	routes.NewWaitlistRouter(a.Mux, a.WaitlistRepo(), a.UserRepo(), a.AuditRepo(), a.TxManager(), a.QueueFeed()).Register()
+	routes.NewQueueRouter(a.Mux, a.AppointmentRepo(), a.UserRepo(), a.QueueFeed()).Register()
+	routes.NewCalendarRouter(a.Mux, a.CalendarRepo(), a.UserRepo(), a.AuditRepo()).Register()
+	routes.NewWebhookRouter(a.Mux, a.WebhookRepo(), a.UserRepo(), a.WebhookDispatcher()).Register()
+	routes.NewAuditRouter(a.Mux, a.AuditRepo(), a.UserRepo()).Register()
 
-    return routes.CorsMiddleware(a.Mux)
+    return routes.CorsMiddleware(routes.RequestMiddleware(a.Mux))
 }
@@ -2,33 +2,134 @@ package app
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
+	"io"
 	"net/http"
+	"patient-appointment-demo-go/internal/changefeed"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/fieldcrypt"
+	"patient-appointment-demo-go/internal/pubsub"
+	"patient-appointment-demo-go/internal/reminder"
+	"patient-appointment-demo-go/internal/revocation"
 	"time"
-
-	"github.com/jackc/pgx/v5"
 )
 
 type AppConfig struct {
 	Port int
+	DB   database.PoolConfig
+	// RequireCurrentSchema makes Start refuse to serve while migrations are
+	// pending.
+	RequireCurrentSchema bool
+	// ChangeFeed makes Start listen for changes to patients and
+	// appointments made by anyone writing to the database.
+	ChangeFeed bool
+	// ChangeLogRetention is how long changes are kept in change_log for
+	// listeners to catch up on, for good when 0.
+	ChangeLogRetention time.Duration
+	// ReminderOffsets are how long before a visit patients are reminded of
+	// it, through each of ReminderNotifiers. Nothing is sent unless both are
+	// set.
+	ReminderOffsets   []time.Duration
+	ReminderNotifiers []reminder.Notifier
+	// AuditCheckpointKey signs a checkpoint of the audit log written to
+	// AuditCheckpoints every AuditCheckpointInterval. None are written unless
+	// both are set.
+	AuditCheckpointKey      ed25519.PrivateKey
+	AuditCheckpoints        io.Writer
+	AuditCheckpointInterval time.Duration
+	// MasterKeys wrap the keys patients' contact details and appointment
+	// notes are encrypted with, the first one wraps new keys.
+	MasterKeys []fieldcrypt.MasterKey
 }
 
 func ConfigWithPort(port int) AppConfig {
 	return AppConfig{
-		Port: port,
+		Port:               port,
+		DB:                 database.DefaultPoolConfig(),
+		ChangeLogRetention: changefeed.DefaultRetention,
 	}
 }
 
+func (c AppConfig) WithDBPool(pool database.PoolConfig) AppConfig {
+	c.DB = pool
+	return c
+}
+
+func (c AppConfig) WithSchemaCheck(required bool) AppConfig {
+	c.RequireCurrentSchema = required
+	return c
+}
+
+func (c AppConfig) WithChangeFeed(enabled bool) AppConfig {
+	c.ChangeFeed = enabled
+	return c
+}
+
+func (c AppConfig) WithChangeLogRetention(retention time.Duration) AppConfig {
+	c.ChangeLogRetention = retention
+	return c
+}
+
+func (c AppConfig) WithReminders(offsets []time.Duration, notifiers ...reminder.Notifier) AppConfig {
+	c.ReminderOffsets = offsets
+	c.ReminderNotifiers = notifiers
+	return c
+}
+
+func (c AppConfig) WithAuditCheckpoints(key ed25519.PrivateKey, w io.Writer, interval time.Duration) AppConfig {
+	c.AuditCheckpointKey = key
+	c.AuditCheckpoints = w
+	c.AuditCheckpointInterval = interval
+	return c
+}
+
+func (c AppConfig) WithEncryption(masterKeys []fieldcrypt.MasterKey) AppConfig {
+	c.MasterKeys = masterKeys
+	return c
+}
+
 type App struct {
-	port      int
-	Mux *http.ServeMux
-	DbConn    *pgx.Conn
+	port                    int
+	dbConfig                database.PoolConfig
+	requireCurrentSchema    bool
+	changeFeed              bool
+	changeLogRetention      time.Duration
+	reminderOffsets         []time.Duration
+	reminderNotifiers       []reminder.Notifier
+	auditCheckpointKey      ed25519.PrivateKey
+	auditCheckpoints        io.Writer
+	auditCheckpointInterval time.Duration
+	masterKeys              []fieldcrypt.MasterKey
+	Mux                     *http.ServeMux
+	DbPool                  *database.Pool
+	// Events carries in-process notifications such as queue changes.
+	Events pubsub.PubSub
+	// ChangeFeed is set by Start when the change feed is enabled.
+	ChangeFeed *changefeed.Listener
+	// Keyring is set by ConnectDB, it encrypts with the keys stored in the
+	// database.
+	Keyring *fieldcrypt.Keyring
+	// Revocations is set by ConnectDB, it keeps the tokens revoked before
+	// they expire.
+	Revocations *revocation.List
 }
 
 func New(config AppConfig) App {
 	return App{
-		port:      config.Port,
-		Mux: http.NewServeMux(),
+		port:                    config.Port,
+		dbConfig:                config.DB,
+		requireCurrentSchema:    config.RequireCurrentSchema,
+		changeFeed:              config.ChangeFeed,
+		changeLogRetention:      config.ChangeLogRetention,
+		reminderOffsets:         config.ReminderOffsets,
+		reminderNotifiers:       config.ReminderNotifiers,
+		auditCheckpointKey:      config.AuditCheckpointKey,
+		auditCheckpoints:        config.AuditCheckpoints,
+		auditCheckpointInterval: config.AuditCheckpointInterval,
+		masterKeys:              config.MasterKeys,
+		Mux:                     http.NewServeMux(),
+		Events:                  pubsub.NewMemory(),
 	}
 }
 
@@ -37,29 +138,59 @@ func (a *App) ConnectDB(con string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-    //TODO: make pool of connection
-	conn, err := pgx.Connect(ctx, con)
+	pool, err := database.NewPool(ctx, con, a.dbConfig)
 
 	if err != nil {
 		return err
 	}
 
-	a.DbConn = conn
+	a.DbPool = pool
+	a.Keyring = fieldcrypt.NewKeyring(a.EncryptionKeyRepo(), a.masterKeys)
+	a.Revocations = revocation.NewList(a.RevokedTokenRepo())
 
 	return nil
 }
 
 func (a *App) CloseDB() {
-    ctx, cancel := context.WithTimeout(context.Background(),30 * time.Second)
-    defer cancel()
-
-    a.DbConn.Close(ctx)
+	a.DbPool.Close()
 }
 
 func (a *App) Start() error {
+	if a.requireCurrentSchema {
+		if err := a.CheckSchema(); err != nil {
+			return err
+		}
+	}
+
+	// a master key that can't unwrap the stored keys fails here rather
+	// than on the first request
+	if err := a.Keyring.Load(context.Background()); err != nil {
+		return fmt.Errorf("encryption keys: %w", err)
+	}
+
+	go a.WebhookDispatcher().Run(context.Background())
+
+	if len(a.reminderOffsets) > 0 && len(a.reminderNotifiers) > 0 {
+		go a.ReminderScheduler().Run(context.Background())
+	}
+
+	go a.AuditSequencer().Run(context.Background())
+
+	if a.auditCheckpointKey != nil && a.auditCheckpoints != nil {
+		go a.AuditCheckpointer().Run(context.Background())
+	}
+
+	if a.changeLogRetention > 0 {
+		go a.ChangeLogPruner().Run(context.Background())
+	}
+
+	if a.changeFeed {
+		a.ChangeFeed = changefeed.NewListener(changefeed.PoolConnector(a.DbPool))
+		go a.ChangeFeed.Run(context.Background())
+	}
+
 	return http.ListenAndServe(
 		fmt.Sprintf(":%d", a.port),
 		a.initRouter(),
 	)
 }
-
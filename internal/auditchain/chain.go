@@ -0,0 +1,168 @@
+// Package auditchain checks that the audit log has not been edited since it
+// was written. Every entry carries the SHA-256 of its content and of the
+// entry before it, computed by the database as the Sequencer chains it, and
+// signed checkpoints of the chain are kept outside of the database so it
+// can't be quietly rewritten either.
+package auditchain
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Store is what verifying and checkpointing need from the audit repository.
+type Store interface {
+	GetChain(ctx context.Context, afterId int64, limit int32) ([]database.AuditLog, error)
+}
+
+const createdAtLayout = "2006-01-02T15:04:05.000000Z"
+
+// EntryHash returns the hash entry should carry after prevHash. It must
+// match audit_entry_hash in the database, which computes it on chaining.
+func EntryHash(prevHash []byte, entry database.AuditLog) []byte {
+	h := sha256.New()
+	h.Write(prevHash)
+	h.Write([]byte(strconv.FormatInt(entry.ID, 10)))
+
+	for _, field := range []string{
+		int4String(entry.ActorID),
+		entry.Action,
+		entry.Entity,
+		int4String(entry.EntityID),
+		string(entry.Changes),
+		entry.Ip.String,
+		entry.RequestID.String,
+		entry.CreatedAt.Time.UTC().Format(createdAtLayout),
+	} {
+		h.Write([]byte{'\n'})
+		h.Write([]byte(field))
+	}
+
+	return h.Sum(nil)
+}
+
+func int4String(v pgtype.Int4) string {
+	if !v.Valid {
+		return ""
+	}
+	return strconv.Itoa(int(v.Int32))
+}
+
+// Break is the first entry that doesn't fit the chain.
+type Break struct {
+	ID     int64
+	Reason string
+}
+
+func (b Break) Error() string {
+	return fmt.Sprintf("audit chain broken at entry %d: %s", b.ID, b.Reason)
+}
+
+// Report is the outcome of Verify. Broken is nil when the chain is intact.
+type Report struct {
+	// Entries is how many entries were checked, up to LastID.
+	Entries     int64
+	LastID      int64
+	Checkpoints int
+	Broken      *Break
+}
+
+const batchSize = 1000
+
+// Verify walks the whole chain, checking each entry's hash against its
+// content and the entry before it, and the hashes of the checkpoints, which
+// must have had their signatures checked already. It stops at the first
+// entry that doesn't fit.
+//
+// Entries written after the latest checkpoint are only protected by the
+// chain: removing them all leaves it intact.
+func Verify(ctx context.Context, store Store, checkpoints []Checkpoint) (Report, error) {
+	var report Report
+
+	pending := make(map[int64][]Checkpoint, len(checkpoints))
+	for _, cp := range checkpoints {
+		pending[cp.ID] = append(pending[cp.ID], cp)
+	}
+
+	var head link
+	broken, err := head.follow(ctx, store, func(entry database.AuditLog) *Break {
+		for _, cp := range pending[entry.ID] {
+			if !bytes.Equal(entry.Hash, cp.Hash) {
+				return &Break{
+					ID:     entry.ID,
+					Reason: fmt.Sprintf("hash differs from the checkpoint of %s", cp.CreatedAt.Format(timeLayout)),
+				}
+			}
+		}
+
+		report.Entries++
+		report.Checkpoints += len(pending[entry.ID])
+		delete(pending, entry.ID)
+		return nil
+	})
+	report.LastID = head.id
+	if err != nil {
+		return report, err
+	}
+	report.Broken = broken
+
+	// Checkpoints of entries that are gone, the earliest is where the chain
+	// was cut.
+	for id, cps := range pending {
+		if report.Broken == nil || id < report.Broken.ID {
+			report.Broken = &Break{
+				ID:     id,
+				Reason: fmt.Sprintf("missing, signed in the checkpoint of %s", cps[0].CreatedAt.Format(timeLayout)),
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// link is the last entry of the chain checked so far, the zero link is the
+// start of the chain.
+type link struct {
+	id   int64
+	hash []byte
+}
+
+// follow checks the entries after l up to the end of the chain, moving l
+// along as they fit. visit can reject an entry that fits.
+func (l *link) follow(ctx context.Context, store Store, visit func(database.AuditLog) *Break) (*Break, error) {
+	for {
+		entries, err := store.GetChain(ctx, l.id, batchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if !bytes.Equal(entry.PrevHash, l.hash) {
+				return &Break{ID: entry.ID, Reason: "previous hash does not match the entry before it"}, nil
+			}
+
+			if !bytes.Equal(entry.Hash, EntryHash(l.hash, entry)) {
+				return &Break{ID: entry.ID, Reason: "hash does not match its content"}, nil
+			}
+
+			if visit != nil {
+				if broken := visit(entry); broken != nil {
+					return broken, nil
+				}
+			}
+
+			l.id = entry.ID
+			l.hash = entry.Hash
+		}
+
+		if len(entries) < batchSize {
+			return nil, nil
+		}
+	}
+}
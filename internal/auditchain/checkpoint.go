@@ -0,0 +1,155 @@
+package auditchain
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const timeLayout = time.RFC3339
+
+var ErrInvalidSignature = errors.New("invalid checkpoint signature")
+
+// Checkpoint is a signed statement of the hash the chain had at entry ID.
+// Kept out of reach of whoever can write to the database, checkpoints show
+// whether the chain up to them has been rewritten since.
+type Checkpoint struct {
+	ID        int64
+	Hash      []byte
+	CreatedAt time.Time
+	Signature []byte
+}
+
+// checkpointLine is how a checkpoint is written to a checkpoint file, one
+// JSON line each.
+type checkpointLine struct {
+	ID        int64     `json:"id"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	Signature string    `json:"signature"`
+}
+
+// NewCheckpoint signs the hash of entry id at createdAt with key.
+func NewCheckpoint(key ed25519.PrivateKey, id int64, hash []byte, createdAt time.Time) Checkpoint {
+	cp := Checkpoint{
+		ID:        id,
+		Hash:      hash,
+		CreatedAt: createdAt.UTC().Truncate(time.Second),
+	}
+	cp.Signature = ed25519.Sign(key, cp.message())
+
+	return cp
+}
+
+func (c Checkpoint) message() []byte {
+	return []byte("audit-checkpoint\n" +
+		strconv.FormatInt(c.ID, 10) + "\n" +
+		hex.EncodeToString(c.Hash) + "\n" +
+		c.CreatedAt.UTC().Format(timeLayout))
+}
+
+// Verify reports whether the checkpoint was signed by the private half of
+// key.
+func (c Checkpoint) Verify(key ed25519.PublicKey) bool {
+	return ed25519.Verify(key, c.message(), c.Signature)
+}
+
+// WriteCheckpoint appends cp to a checkpoint file.
+func WriteCheckpoint(w io.Writer, cp Checkpoint) error {
+	line, err := json.Marshal(checkpointLine{
+		ID:        cp.ID,
+		Hash:      hex.EncodeToString(cp.Hash),
+		CreatedAt: cp.CreatedAt,
+		Signature: base64.StdEncoding.EncodeToString(cp.Signature),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// ReadCheckpoints reads a checkpoint file, failing on the first line that
+// can't be read or wasn't signed with key.
+func ReadCheckpoints(r io.Reader, key ed25519.PublicKey) ([]Checkpoint, error) {
+	var checkpoints []Checkpoint
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var line checkpointLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("checkpoint on line %d: %w", n, err)
+		}
+
+		hash, err := hex.DecodeString(line.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("checkpoint on line %d: invalid hash", n)
+		}
+		signature, err := base64.StdEncoding.DecodeString(line.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("checkpoint on line %d: %w", n, ErrInvalidSignature)
+		}
+
+		cp := Checkpoint{
+			ID:        line.ID,
+			Hash:      hash,
+			CreatedAt: line.CreatedAt,
+			Signature: signature,
+		}
+		if !cp.Verify(key) {
+			return nil, fmt.Errorf("checkpoint on line %d: %w", n, ErrInvalidSignature)
+		}
+
+		checkpoints = append(checkpoints, cp)
+	}
+
+	return checkpoints, scanner.Err()
+}
+
+// ParsePrivateKey reads a base64 Ed25519 key, either its 32 byte seed or the
+// full 64 byte key.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("checkpoint key is not base64")
+	}
+
+	switch len(b) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(b), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(b), nil
+	default:
+		return nil, fmt.Errorf("checkpoint key is %d bytes, not %d", len(b), ed25519.SeedSize)
+	}
+}
+
+// ParsePublicKey reads a base64 Ed25519 public key, as given by
+// EncodePublicKey.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("checkpoint public key is not base64")
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("checkpoint public key is %d bytes, not %d", len(b), ed25519.PublicKeySize)
+	}
+
+	return ed25519.PublicKey(b), nil
+}
+
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
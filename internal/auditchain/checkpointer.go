@@ -0,0 +1,107 @@
+package auditchain
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Checkpointer periodically writes a checkpoint of the latest entry of the
+// chain. It only signs entries it has followed the chain to, from the start
+// of the chain when it starts and from its last checkpoint after that, so a
+// chain that was tampered with is reported instead of signed.
+type Checkpointer struct {
+	store    Store
+	key      ed25519.PrivateKey
+	w        io.Writer
+	interval time.Duration
+	head     link
+	now      func() time.Time
+}
+
+type Option func(*Checkpointer)
+
+// WithInterval sets how often Run writes a checkpoint.
+func WithInterval(interval time.Duration) Option {
+	return func(c *Checkpointer) {
+		c.interval = interval
+	}
+}
+
+// WithClock sets where checkpoints take their time from.
+func WithClock(now func() time.Time) Option {
+	return func(c *Checkpointer) {
+		c.now = now
+	}
+}
+
+// NewCheckpointer writes checkpoints signed with key to w, usually a file
+// opened for appending.
+func NewCheckpointer(store Store, key ed25519.PrivateKey, w io.Writer, opts ...Option) *Checkpointer {
+	c := &Checkpointer{
+		store:    store,
+		key:      key,
+		w:        w,
+		interval: time.Hour,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Run writes a checkpoint every interval until ctx is done.
+func (c *Checkpointer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, _, err := c.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println("audit checkpoints:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce follows the chain to its latest entry and writes a checkpoint of
+// it. It reports false when nothing was appended since the last checkpoint,
+// and a Break when the chain doesn't hold.
+func (c *Checkpointer) RunOnce(ctx context.Context) (Checkpoint, bool, error) {
+	head := c.head
+
+	broken, err := head.follow(ctx, c.store, nil)
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	if broken != nil {
+		return Checkpoint{}, false, *broken
+	}
+	if head.id == c.head.id {
+		return Checkpoint{}, false, nil
+	}
+
+	cp := NewCheckpoint(c.key, head.id, head.hash, c.now())
+	if err := WriteCheckpoint(c.w, cp); err != nil {
+		return Checkpoint{}, false, err
+	}
+
+	if s, ok := c.w.(interface{ Sync() error }); ok {
+		if err := s.Sync(); err != nil {
+			return Checkpoint{}, false, err
+		}
+	}
+
+	c.head = head
+
+	return cp, true, nil
+}
@@ -0,0 +1,66 @@
+package auditchain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const sequenceBatchSize = 500
+
+// SequenceStore appends the entries written since it last ran to the chain,
+// see repositories.AuditRepository.
+type SequenceStore interface {
+	ChainPending(ctx context.Context, limit int32) (int32, error)
+}
+
+// Sequencer chains new audit entries every interval. Entries are written
+// unchained so that the transactions writing them don't wait on each other
+// for the head of the chain; each batch holds it only while it is hashed.
+// Several instances can run at once, they take turns.
+type Sequencer struct {
+	store    SequenceStore
+	interval time.Duration
+}
+
+func NewSequencer(store SequenceStore) *Sequencer {
+	return &Sequencer{
+		store:    store,
+		interval: time.Second,
+	}
+}
+
+// Run chains new entries every interval until ctx is done.
+func (s *Sequencer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println("audit chaining:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce chains every entry committed so far and returns how many.
+func (s *Sequencer) RunOnce(ctx context.Context) (int64, error) {
+	total := int64(0)
+
+	for {
+		n, err := s.store.ChainPending(ctx, sequenceBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += int64(n)
+		if n < sequenceBatchSize {
+			return total, nil
+		}
+	}
+}
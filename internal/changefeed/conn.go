@@ -0,0 +1,72 @@
+package changefeed
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Conn is a connection the listener holds for as long as it listens. It is
+// dropped and a new one is made whenever one of its calls fails.
+type Conn interface {
+	// Listen subscribes the connection to Channel.
+	Listen(ctx context.Context) error
+	// WaitForNotification blocks until a notification on Channel arrives
+	// and returns its payload.
+	WaitForNotification(ctx context.Context) ([]byte, error)
+	ChangesSince(ctx context.Context, afterId int64, limit int32) ([]database.ChangeLog, error)
+	LatestChangeID(ctx context.Context) (int64, error)
+	Close(ctx context.Context) error
+}
+
+type Connector func(ctx context.Context) (Conn, error)
+
+// PoolConnector takes connections out of the pool for good: a listening
+// connection can't be shared, so it is closed rather than released.
+func PoolConnector(pool *database.Pool) Connector {
+	return func(ctx context.Context) (Conn, error) {
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &pgxConn{conn: conn.Hijack()}, nil
+	}
+}
+
+type pgxConn struct {
+	conn *pgx.Conn
+}
+
+func (c *pgxConn) Listen(ctx context.Context) error {
+	_, err := c.conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize())
+	return err
+}
+
+func (c *pgxConn) WaitForNotification(ctx context.Context) ([]byte, error) {
+	for {
+		n, err := c.conn.WaitForNotification(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if n.Channel == Channel {
+			return []byte(n.Payload), nil
+		}
+	}
+}
+
+func (c *pgxConn) ChangesSince(ctx context.Context, afterId int64, limit int32) ([]database.ChangeLog, error) {
+	return database.New(c.conn).GetChangesSince(ctx, database.GetChangesSinceParams{
+		ID:    afterId,
+		Limit: limit,
+	})
+}
+
+func (c *pgxConn) LatestChangeID(ctx context.Context) (int64, error) {
+	return database.New(c.conn).GetLatestChangeID(ctx)
+}
+
+func (c *pgxConn) Close(ctx context.Context) error {
+	return c.conn.Close(ctx)
+}
@@ -0,0 +1,146 @@
+// Package changefeed listens on the change_feed channel, which the
+// patients and appointments triggers notify, and hands the changes to
+// subscribers in the process as typed events. Changes made while the
+// listener was disconnected are read back from the change_log table.
+package changefeed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+// Channel is the channel the triggers notify.
+const Channel = "change_feed"
+
+const (
+	TablePatients     = "patients"
+	TableAppointments = "appointments"
+)
+
+type Op string
+
+const (
+	OpInsert Op = "INSERT"
+	OpUpdate Op = "UPDATE"
+	OpDelete Op = "DELETE"
+)
+
+var ErrUnknownTable = errors.New("change for an unknown table")
+
+// Event is one row changed in patients or appointments. Exactly one of
+// Patient and Appointment is set, depending on Table.
+type Event struct {
+	// ID is the change_log id, it grows with every change.
+	ID          int64
+	Table       string
+	Op          Op
+	RowID       int32
+	At          time.Time
+	Patient     *PatientChange
+	Appointment *AppointmentChange
+}
+
+// PatientChange carries no personal data, only which columns an update
+// touched. Read the patient for the values.
+type PatientChange struct {
+	Changed []string
+}
+
+// AppointmentChange is the state of the appointment after the change, or
+// before it for a delete.
+type AppointmentChange struct {
+	PatientID int32
+	DoctorID  *int32
+	Status    string
+	// OldStatus is only set on updates.
+	OldStatus      *string
+	VisitDate      time.Time
+	VisitTimestamp time.Time
+	Token          int16
+}
+
+type notification struct {
+	ID    int64           `json:"id"`
+	Table string          `json:"table"`
+	Op    Op              `json:"op"`
+	RowID int32           `json:"row_id"`
+	At    time.Time       `json:"at"`
+	Data  json.RawMessage `json:"data"`
+}
+
+type patientData struct {
+	Changed []string `json:"changed"`
+}
+
+type appointmentData struct {
+	PatientID           int32     `json:"patient_id"`
+	DoctorID            *int32    `json:"doctor_id"`
+	Status              string    `json:"status"`
+	OldStatus           *string   `json:"old_status"`
+	VisitDate           string    `json:"visit_date"`
+	VisitTimestamp      time.Time `json:"visit_timestamp"`
+	AppointmentSequence int16     `json:"appointment_sequence"`
+}
+
+// Decode reads a NOTIFY payload of the change_feed channel.
+func Decode(payload []byte) (Event, error) {
+	var n notification
+	if err := json.Unmarshal(payload, &n); err != nil {
+		return Event{}, fmt.Errorf("decode change notification: %w", err)
+	}
+
+	return newEvent(n.ID, n.Table, n.Op, n.RowID, n.At, n.Data)
+}
+
+// FromLog turns a change_log row into the event that was notified for it.
+func FromLog(row database.ChangeLog) (Event, error) {
+	return newEvent(row.ID, row.TableName, Op(row.Operation), row.RowID, row.CreatedAt.Time, row.Payload)
+}
+
+func newEvent(id int64, table string, op Op, rowId int32, at time.Time, data []byte) (Event, error) {
+	event := Event{
+		ID:    id,
+		Table: table,
+		Op:    op,
+		RowID: rowId,
+		At:    at,
+	}
+
+	switch table {
+	case TablePatients:
+		var d patientData
+		if err := json.Unmarshal(data, &d); err != nil {
+			return event, fmt.Errorf("decode patient change %d: %w", id, err)
+		}
+		event.Patient = &PatientChange{Changed: d.Changed}
+
+	case TableAppointments:
+		var d appointmentData
+		if err := json.Unmarshal(data, &d); err != nil {
+			return event, fmt.Errorf("decode appointment change %d: %w", id, err)
+		}
+
+		visitDate, err := time.Parse("2006-01-02", d.VisitDate)
+		if err != nil {
+			return event, fmt.Errorf("decode appointment change %d: %w", id, err)
+		}
+
+		event.Appointment = &AppointmentChange{
+			PatientID:      d.PatientID,
+			DoctorID:       d.DoctorID,
+			Status:         d.Status,
+			OldStatus:      d.OldStatus,
+			VisitDate:      visitDate,
+			VisitTimestamp: d.VisitTimestamp,
+			Token:          d.AppointmentSequence,
+		}
+
+	default:
+		return event, fmt.Errorf("%w %q", ErrUnknownTable, table)
+	}
+
+	return event, nil
+}
@@ -0,0 +1,262 @@
+package changefeed
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	catchUpPageSize = 500
+	// resumeOverlap is how far back of the last seen id a catch-up starts.
+	// Ids are taken when a row is written, not when it commits, so a change
+	// with a smaller id can still show up after a bigger one was delivered.
+	resumeOverlap = 100
+	// seenSize bounds the ids remembered to drop repeats from the overlap.
+	seenSize        = 1024
+	subscriberQueue = 64
+)
+
+type subscriber struct {
+	ctx    context.Context
+	tables map[string]bool
+	ch     chan Event
+}
+
+// Listener keeps a connection listening on Channel and fans the changes out
+// to its subscribers. After a reconnect it first delivers the changes it
+// missed from change_log, so subscribers see every change once, though not
+// strictly in id order.
+type Listener struct {
+	connect    Connector
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu          sync.Mutex
+	lastID      int64
+	resumable   bool
+	seen        map[int64]bool
+	seenOrder   []int64
+	subscribers map[*subscriber]bool
+}
+
+type Option func(*Listener)
+
+// WithResumeFrom makes the first connection replay the changes logged since
+// id, rather than only deliver the ones made from then on. Like any
+// catch-up it starts resumeOverlap ids early. Changes older than the
+// retention of change_log are gone by then, see Pruner.
+func WithResumeFrom(id int64) Option {
+	return func(l *Listener) {
+		l.lastID = id
+		l.resumable = true
+	}
+}
+
+// WithBackoff sets the wait between reconnects, doubling from min up to max.
+func WithBackoff(min, max time.Duration) Option {
+	return func(l *Listener) {
+		l.minBackoff = min
+		l.maxBackoff = max
+	}
+}
+
+func NewListener(connect Connector, opts ...Option) *Listener {
+	l := &Listener{
+		connect:     connect,
+		minBackoff:  500 * time.Millisecond,
+		maxBackoff:  30 * time.Second,
+		seen:        make(map[int64]bool),
+		subscribers: make(map[*subscriber]bool),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Subscribe returns the changes to the given tables, or to every table when
+// none are given, until ctx is done. A subscriber that doesn't keep up holds
+// back the others, so it should hand slow work off.
+func (l *Listener) Subscribe(ctx context.Context, tables ...string) <-chan Event {
+	sub := &subscriber{
+		ctx:    ctx,
+		tables: make(map[string]bool),
+		ch:     make(chan Event, subscriberQueue),
+	}
+	for _, table := range tables {
+		sub.tables[table] = true
+	}
+
+	l.mu.Lock()
+	l.subscribers[sub] = true
+	l.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		l.mu.Lock()
+		delete(l.subscribers, sub)
+		close(sub.ch)
+		l.mu.Unlock()
+	}()
+
+	return sub.ch
+}
+
+// LastID is the highest change_log id delivered so far.
+func (l *Listener) LastID() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lastID
+}
+
+// Run listens until ctx is done, reconnecting whenever the connection fails.
+func (l *Listener) Run(ctx context.Context) error {
+	backoff := l.minBackoff
+
+	for {
+		connected, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Println("change feed:", err)
+
+		if connected {
+			backoff = l.minBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > l.maxBackoff {
+			backoff = l.maxBackoff
+		}
+	}
+}
+
+// listen runs one connection until it fails. connected reports whether it
+// got as far as waiting for notifications.
+func (l *Listener) listen(ctx context.Context) (connected bool, err error) {
+	conn, err := l.connect(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn.Close(closeCtx)
+	}()
+
+	// Listen before catching up so nothing committed in between is lost,
+	// the overlap is dropped as repeats.
+	if err := conn.Listen(ctx); err != nil {
+		return false, err
+	}
+
+	if err := l.catchUp(ctx, conn); err != nil {
+		return false, err
+	}
+
+	for {
+		payload, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		event, err := Decode(payload)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		l.dispatch(ctx, event)
+	}
+}
+
+func (l *Listener) catchUp(ctx context.Context, conn Conn) error {
+	l.mu.Lock()
+	afterId, resumable := l.lastID, l.resumable
+	l.mu.Unlock()
+
+	if !resumable {
+		latest, err := conn.LatestChangeID(ctx)
+		if err != nil {
+			return err
+		}
+
+		l.mu.Lock()
+		l.lastID = latest
+		l.resumable = true
+		l.mu.Unlock()
+		return nil
+	}
+
+	afterId -= resumeOverlap
+	if afterId < 0 {
+		afterId = 0
+	}
+
+	for {
+		rows, err := conn.ChangesSince(ctx, afterId, catchUpPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			afterId = row.ID
+
+			event, err := FromLog(row)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+
+			l.dispatch(ctx, event)
+		}
+
+		if len(rows) < catchUpPageSize {
+			return nil
+		}
+	}
+}
+
+func (l *Listener) dispatch(ctx context.Context, event Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.seen[event.ID] {
+		return
+	}
+
+	l.seen[event.ID] = true
+	l.seenOrder = append(l.seenOrder, event.ID)
+	if len(l.seenOrder) > seenSize {
+		delete(l.seen, l.seenOrder[0])
+		l.seenOrder = l.seenOrder[1:]
+	}
+
+	if event.ID > l.lastID {
+		l.lastID = event.ID
+	}
+
+	for sub := range l.subscribers {
+		if len(sub.tables) > 0 && !sub.tables[event.Table] {
+			continue
+		}
+
+		select {
+		case sub.ch <- event:
+		case <-sub.ctx.Done():
+		case <-ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package changefeed
+
+import (
+	"context"
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DefaultRetention is how long changes are kept in change_log unless set
+// otherwise. A listener away for longer can't catch up on what it missed.
+const DefaultRetention = 7 * 24 * time.Hour
+
+const pruneBatchSize = 1000
+
+// PruneStore deletes old changes, see database.Queries.
+type PruneStore interface {
+	DeleteChangesBefore(ctx context.Context, arg database.DeleteChangesBeforeParams) (int64, error)
+}
+
+// Pruner deletes the changes older than its retention from change_log every
+// interval, which would otherwise grow with every write for good.
+type Pruner struct {
+	store     PruneStore
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPruner(store PruneStore, retention time.Duration) *Pruner {
+	return &Pruner{
+		store:     store,
+		retention: retention,
+		interval:  time.Hour,
+	}
+}
+
+// Run prunes every interval until ctx is done.
+func (p *Pruner) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println("change log pruning:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce deletes the changes logged more than the retention ago and
+// returns how many.
+func (p *Pruner) RunOnce(ctx context.Context) (int64, error) {
+	before := pgtype.Timestamptz{Time: time.Now().Add(-p.retention), Valid: true}
+	total := int64(0)
+
+	for {
+		n, err := p.store.DeleteChangesBefore(ctx, database.DeleteChangesBeforeParams{
+			Before:     before,
+			MaxResults: pruneBatchSize,
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if n < pruneBatchSize {
+			return total, nil
+		}
+	}
+}
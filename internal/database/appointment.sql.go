@@ -12,26 +12,32 @@ import (
 )
 
 const createAppointment = `-- name: CreateAppointment :one
-INSERT INTO appointments (patient_id, user_id, visit_date, visit_timestamp, patient_notes, doctor_notes)
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at
+INSERT INTO appointments (patient_id, user_id, doctor_id, series_id, visit_date, visit_timestamp, duration_minutes, patient_notes, doctor_notes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at
 `
 
 type CreateAppointmentParams struct {
-	PatientID      int32
-	UserID         pgtype.Int4
-	VisitDate      pgtype.Date
-	VisitTimestamp pgtype.Timestamptz
-	PatientNotes   pgtype.Text
-	DoctorNotes    pgtype.Text
+	PatientID       int32
+	UserID          pgtype.Int4
+	DoctorID        pgtype.Int4
+	SeriesID        pgtype.Int4
+	VisitDate       pgtype.Date
+	VisitTimestamp  pgtype.Timestamptz
+	DurationMinutes int16
+	PatientNotes    pgtype.Text
+	DoctorNotes     pgtype.Text
 }
 
 func (q *Queries) CreateAppointment(ctx context.Context, arg CreateAppointmentParams) (Appointment, error) {
 	row := q.db.QueryRow(ctx, createAppointment,
 		arg.PatientID,
 		arg.UserID,
+		arg.DoctorID,
+		arg.SeriesID,
 		arg.VisitDate,
 		arg.VisitTimestamp,
+		arg.DurationMinutes,
 		arg.PatientNotes,
 		arg.DoctorNotes,
 	)
@@ -47,12 +53,93 @@ func (q *Queries) CreateAppointment(ctx context.Context, arg CreateAppointmentPa
 		&i.DoctorNotes,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Status,
+		&i.StatusUpdatedAt,
+		&i.StatusUpdatedBy,
+		&i.CancelReason,
+		&i.DoctorID,
+		&i.DurationMinutes,
+		&i.VisitEnd,
+		&i.SeriesID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const createAppointmentRescheduleHistory = `-- name: CreateAppointmentRescheduleHistory :one
+INSERT INTO appointment_reschedule_history (appointment_id, old_visit_timestamp, new_visit_timestamp, rescheduled_by, reason)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, appointment_id, old_visit_timestamp, new_visit_timestamp, rescheduled_by, reason, created_at
+`
+
+type CreateAppointmentRescheduleHistoryParams struct {
+	AppointmentID     int32
+	OldVisitTimestamp pgtype.Timestamptz
+	NewVisitTimestamp pgtype.Timestamptz
+	RescheduledBy     pgtype.Int4
+	Reason            pgtype.Text
+}
+
+func (q *Queries) CreateAppointmentRescheduleHistory(ctx context.Context, arg CreateAppointmentRescheduleHistoryParams) (AppointmentRescheduleHistory, error) {
+	row := q.db.QueryRow(ctx, createAppointmentRescheduleHistory,
+		arg.AppointmentID,
+		arg.OldVisitTimestamp,
+		arg.NewVisitTimestamp,
+		arg.RescheduledBy,
+		arg.Reason,
+	)
+	var i AppointmentRescheduleHistory
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.OldVisitTimestamp,
+		&i.NewVisitTimestamp,
+		&i.RescheduledBy,
+		&i.Reason,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createAppointmentStatusHistory = `-- name: CreateAppointmentStatusHistory :one
+INSERT INTO appointment_status_history (appointment_id, from_status, to_status, changed_by, reason)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, appointment_id, from_status, to_status, changed_by, reason, created_at
+`
+
+type CreateAppointmentStatusHistoryParams struct {
+	AppointmentID int32
+	FromStatus    string
+	ToStatus      string
+	ChangedBy     pgtype.Int4
+	Reason        pgtype.Text
+}
+
+func (q *Queries) CreateAppointmentStatusHistory(ctx context.Context, arg CreateAppointmentStatusHistoryParams) (AppointmentStatusHistory, error) {
+	row := q.db.QueryRow(ctx, createAppointmentStatusHistory,
+		arg.AppointmentID,
+		arg.FromStatus,
+		arg.ToStatus,
+		arg.ChangedBy,
+		arg.Reason,
+	)
+	var i AppointmentStatusHistory
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.FromStatus,
+		&i.ToStatus,
+		&i.ChangedBy,
+		&i.Reason,
+		&i.CreatedAt,
 	)
 	return i, err
 }
 
 const deleteAppointment = `-- name: DeleteAppointment :exec
-DELETE FROM appointments WHERE id = $1
+UPDATE appointments
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) DeleteAppointment(ctx context.Context, id int32) error {
@@ -60,13 +147,119 @@ func (q *Queries) DeleteAppointment(ctx context.Context, id int32) error {
 	return err
 }
 
+const getActiveAppointmentsByDoctorBetween = `-- name: GetActiveAppointmentsByDoctorBetween :many
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments
+WHERE doctor_id = $1
+  AND visit_end > $2::timestamptz
+  AND visit_timestamp < $3::timestamptz
+  AND status NOT IN ('cancelled', 'no_show')
+  AND deleted_at IS NULL
+ORDER BY visit_timestamp ASC
+`
+
+type GetActiveAppointmentsByDoctorBetweenParams struct {
+	DoctorID pgtype.Int4
+	FromTime pgtype.Timestamptz
+	ToTime   pgtype.Timestamptz
+}
+
+func (q *Queries) GetActiveAppointmentsByDoctorBetween(ctx context.Context, arg GetActiveAppointmentsByDoctorBetweenParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, getActiveAppointmentsByDoctorBetween, arg.DoctorID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PatientID,
+			&i.UserID,
+			&i.VisitDate,
+			&i.AppointmentSequence,
+			&i.VisitTimestamp,
+			&i.PatientNotes,
+			&i.DoctorNotes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusUpdatedAt,
+			&i.StatusUpdatedBy,
+			&i.CancelReason,
+			&i.DoctorID,
+			&i.DurationMinutes,
+			&i.VisitEnd,
+			&i.SeriesID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAllAppointments = `-- name: GetAllAppointments :many
-SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at FROM appointments
-ORDER BY visit_date DESC
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments
+WHERE ($1::date IS NULL OR visit_date >= $1::date)
+  AND ($2::date IS NULL OR visit_date <= $2::date)
+  AND ($3::int IS NULL OR doctor_id = $3::int)
+  AND ($4::int IS NULL OR patient_id = $4::int)
+  AND ($5::text[] IS NULL OR status = ANY($5::text[]))
+  AND ($6::boolean OR deleted_at IS NULL)
+  AND (
+      NOT $7::boolean
+      OR ($8::text = 'visit_timestamp' AND NOT $9::boolean AND (visit_timestamp, id) > ($10::timestamptz, $11::int))
+      OR ($8::text = 'visit_timestamp' AND $9::boolean AND (visit_timestamp, id) < ($10::timestamptz, $11::int))
+      OR ($8::text = 'created_at' AND NOT $9::boolean AND (COALESCE(created_at, 'epoch'), id) > ($10::timestamptz, $11::int))
+      OR ($8::text = 'created_at' AND $9::boolean AND (COALESCE(created_at, 'epoch'), id) < ($10::timestamptz, $11::int))
+  )
+ORDER BY
+    CASE WHEN $8::text = 'visit_timestamp' AND NOT $9::boolean THEN visit_timestamp END ASC,
+    CASE WHEN $8::text = 'visit_timestamp' AND $9::boolean THEN visit_timestamp END DESC,
+    CASE WHEN $8::text = 'created_at' AND NOT $9::boolean THEN COALESCE(created_at, 'epoch') END ASC,
+    CASE WHEN $8::text = 'created_at' AND $9::boolean THEN COALESCE(created_at, 'epoch') END DESC,
+    CASE WHEN NOT $9::boolean THEN id END ASC,
+    CASE WHEN $9::boolean THEN id END DESC
+LIMIT $12
 `
 
-func (q *Queries) GetAllAppointments(ctx context.Context) ([]Appointment, error) {
-	rows, err := q.db.Query(ctx, getAllAppointments)
+type GetAllAppointmentsParams struct {
+	VisitFrom      pgtype.Date
+	VisitTo        pgtype.Date
+	DoctorID       pgtype.Int4
+	PatientID      pgtype.Int4
+	Statuses       []string
+	IncludeDeleted bool
+	HasCursor      bool
+	SortBy         string
+	SortDesc       bool
+	CursorTime     pgtype.Timestamptz
+	CursorID       int32
+	MaxResults     int32
+}
+
+// One page of appointments matching the filters that are set, see
+// GetAllPatients for how the cursor works.
+func (q *Queries) GetAllAppointments(ctx context.Context, arg GetAllAppointmentsParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, getAllAppointments,
+		arg.VisitFrom,
+		arg.VisitTo,
+		arg.DoctorID,
+		arg.PatientID,
+		arg.Statuses,
+		arg.IncludeDeleted,
+		arg.HasCursor,
+		arg.SortBy,
+		arg.SortDesc,
+		arg.CursorTime,
+		arg.CursorID,
+		arg.MaxResults,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +278,15 @@ func (q *Queries) GetAllAppointments(ctx context.Context) ([]Appointment, error)
 			&i.DoctorNotes,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusUpdatedAt,
+			&i.StatusUpdatedBy,
+			&i.CancelReason,
+			&i.DoctorID,
+			&i.DurationMinutes,
+			&i.VisitEnd,
+			&i.SeriesID,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -97,7 +299,7 @@ func (q *Queries) GetAllAppointments(ctx context.Context) ([]Appointment, error)
 }
 
 const getAppointmentByID = `-- name: GetAppointmentByID :one
-SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at FROM appointments WHERE id = $1
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetAppointmentByID(ctx context.Context, id int32) (Appointment, error) {
@@ -114,13 +316,87 @@ func (q *Queries) GetAppointmentByID(ctx context.Context, id int32) (Appointment
 		&i.DoctorNotes,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Status,
+		&i.StatusUpdatedAt,
+		&i.StatusUpdatedBy,
+		&i.CancelReason,
+		&i.DoctorID,
+		&i.DurationMinutes,
+		&i.VisitEnd,
+		&i.SeriesID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getAppointmentByIDForUpdate = `-- name: GetAppointmentByIDForUpdate :one
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments WHERE id = $1 AND deleted_at IS NULL
+FOR UPDATE
+`
+
+// Locked until the transaction ends, so it can be checked before it is
+// written.
+func (q *Queries) GetAppointmentByIDForUpdate(ctx context.Context, id int32) (Appointment, error) {
+	row := q.db.QueryRow(ctx, getAppointmentByIDForUpdate, id)
+	var i Appointment
+	err := row.Scan(
+		&i.ID,
+		&i.PatientID,
+		&i.UserID,
+		&i.VisitDate,
+		&i.AppointmentSequence,
+		&i.VisitTimestamp,
+		&i.PatientNotes,
+		&i.DoctorNotes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Status,
+		&i.StatusUpdatedAt,
+		&i.StatusUpdatedBy,
+		&i.CancelReason,
+		&i.DoctorID,
+		&i.DurationMinutes,
+		&i.VisitEnd,
+		&i.SeriesID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getAppointmentByIDWithDeleted = `-- name: GetAppointmentByIDWithDeleted :one
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments WHERE id = $1
+`
+
+func (q *Queries) GetAppointmentByIDWithDeleted(ctx context.Context, id int32) (Appointment, error) {
+	row := q.db.QueryRow(ctx, getAppointmentByIDWithDeleted, id)
+	var i Appointment
+	err := row.Scan(
+		&i.ID,
+		&i.PatientID,
+		&i.UserID,
+		&i.VisitDate,
+		&i.AppointmentSequence,
+		&i.VisitTimestamp,
+		&i.PatientNotes,
+		&i.DoctorNotes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Status,
+		&i.StatusUpdatedAt,
+		&i.StatusUpdatedBy,
+		&i.CancelReason,
+		&i.DoctorID,
+		&i.DurationMinutes,
+		&i.VisitEnd,
+		&i.SeriesID,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getAppointmentBySequence = `-- name: GetAppointmentBySequence :one
-SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at FROM appointments
-WHERE visit_date = $1 AND appointment_sequence = $2
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments
+WHERE visit_date = $1 AND appointment_sequence = $2 AND deleted_at IS NULL
 ORDER BY created_at
 `
 
@@ -143,13 +419,90 @@ func (q *Queries) GetAppointmentBySequence(ctx context.Context, arg GetAppointme
 		&i.DoctorNotes,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Status,
+		&i.StatusUpdatedAt,
+		&i.StatusUpdatedBy,
+		&i.CancelReason,
+		&i.DoctorID,
+		&i.DurationMinutes,
+		&i.VisitEnd,
+		&i.SeriesID,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
+const getAppointmentRescheduleHistory = `-- name: GetAppointmentRescheduleHistory :many
+SELECT id, appointment_id, old_visit_timestamp, new_visit_timestamp, rescheduled_by, reason, created_at FROM appointment_reschedule_history
+WHERE appointment_id = $1
+ORDER BY created_at ASC, id ASC
+`
+
+func (q *Queries) GetAppointmentRescheduleHistory(ctx context.Context, appointmentID int32) ([]AppointmentRescheduleHistory, error) {
+	rows, err := q.db.Query(ctx, getAppointmentRescheduleHistory, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AppointmentRescheduleHistory
+	for rows.Next() {
+		var i AppointmentRescheduleHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.AppointmentID,
+			&i.OldVisitTimestamp,
+			&i.NewVisitTimestamp,
+			&i.RescheduledBy,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAppointmentStatusHistory = `-- name: GetAppointmentStatusHistory :many
+SELECT id, appointment_id, from_status, to_status, changed_by, reason, created_at FROM appointment_status_history
+WHERE appointment_id = $1
+ORDER BY created_at ASC, id ASC
+`
+
+func (q *Queries) GetAppointmentStatusHistory(ctx context.Context, appointmentID int32) ([]AppointmentStatusHistory, error) {
+	rows, err := q.db.Query(ctx, getAppointmentStatusHistory, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AppointmentStatusHistory
+	for rows.Next() {
+		var i AppointmentStatusHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.AppointmentID,
+			&i.FromStatus,
+			&i.ToStatus,
+			&i.ChangedBy,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAppointmentsByDate = `-- name: GetAppointmentsByDate :many
-SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at FROM appointments
-WHERE visit_date = $1
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments
+WHERE visit_date = $1 AND deleted_at IS NULL
 ORDER BY appointment_sequence ASC
 `
 
@@ -173,6 +526,68 @@ func (q *Queries) GetAppointmentsByDate(ctx context.Context, visitDate pgtype.Da
 			&i.DoctorNotes,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusUpdatedAt,
+			&i.StatusUpdatedBy,
+			&i.CancelReason,
+			&i.DoctorID,
+			&i.DurationMinutes,
+			&i.VisitEnd,
+			&i.SeriesID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAppointmentsByDoctor = `-- name: GetAppointmentsByDoctor :many
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments
+WHERE doctor_id = $1
+  AND ($2::date IS NULL OR visit_date = $2::date)
+  AND deleted_at IS NULL
+ORDER BY visit_timestamp ASC
+`
+
+type GetAppointmentsByDoctorParams struct {
+	DoctorID  pgtype.Int4
+	VisitDate pgtype.Date
+}
+
+func (q *Queries) GetAppointmentsByDoctor(ctx context.Context, arg GetAppointmentsByDoctorParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, getAppointmentsByDoctor, arg.DoctorID, arg.VisitDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PatientID,
+			&i.UserID,
+			&i.VisitDate,
+			&i.AppointmentSequence,
+			&i.VisitTimestamp,
+			&i.PatientNotes,
+			&i.DoctorNotes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusUpdatedAt,
+			&i.StatusUpdatedBy,
+			&i.CancelReason,
+			&i.DoctorID,
+			&i.DurationMinutes,
+			&i.VisitEnd,
+			&i.SeriesID,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -185,8 +600,8 @@ func (q *Queries) GetAppointmentsByDate(ctx context.Context, visitDate pgtype.Da
 }
 
 const getAppointmentsByPatient = `-- name: GetAppointmentsByPatient :many
-SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at FROM appointments
-WHERE patient_id = $1
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments
+WHERE patient_id = $1 AND deleted_at IS NULL
 ORDER BY appointment_sequence ASC
 `
 
@@ -210,6 +625,61 @@ func (q *Queries) GetAppointmentsByPatient(ctx context.Context, patientID int32)
 			&i.DoctorNotes,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusUpdatedAt,
+			&i.StatusUpdatedBy,
+			&i.CancelReason,
+			&i.DoctorID,
+			&i.DurationMinutes,
+			&i.VisitEnd,
+			&i.SeriesID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAppointmentsBySeries = `-- name: GetAppointmentsBySeries :many
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments
+WHERE series_id = $1 AND deleted_at IS NULL
+ORDER BY visit_timestamp ASC
+`
+
+func (q *Queries) GetAppointmentsBySeries(ctx context.Context, seriesID pgtype.Int4) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, getAppointmentsBySeries, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PatientID,
+			&i.UserID,
+			&i.VisitDate,
+			&i.AppointmentSequence,
+			&i.VisitTimestamp,
+			&i.PatientNotes,
+			&i.DoctorNotes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusUpdatedAt,
+			&i.StatusUpdatedBy,
+			&i.CancelReason,
+			&i.DoctorID,
+			&i.DurationMinutes,
+			&i.VisitEnd,
+			&i.SeriesID,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -221,14 +691,221 @@ func (q *Queries) GetAppointmentsByPatient(ctx context.Context, patientID int32)
 	return items, nil
 }
 
+const getAppointmentsForReencryption = `-- name: GetAppointmentsForReencryption :many
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments
+WHERE id > $1::int
+ORDER BY id
+LIMIT $2
+FOR UPDATE
+`
+
+type GetAppointmentsForReencryptionParams struct {
+	AfterID    int32
+	MaxResults int32
+}
+
+// Every appointment after after_id, deleted or not, locked until the
+// transaction ends so their notes can be written back re-encrypted.
+func (q *Queries) GetAppointmentsForReencryption(ctx context.Context, arg GetAppointmentsForReencryptionParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, getAppointmentsForReencryption, arg.AfterID, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PatientID,
+			&i.UserID,
+			&i.VisitDate,
+			&i.AppointmentSequence,
+			&i.VisitTimestamp,
+			&i.PatientNotes,
+			&i.DoctorNotes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusUpdatedAt,
+			&i.StatusUpdatedBy,
+			&i.CancelReason,
+			&i.DoctorID,
+			&i.DurationMinutes,
+			&i.VisitEnd,
+			&i.SeriesID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOverlappingAppointments = `-- name: GetOverlappingAppointments :many
+SELECT id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at FROM appointments
+WHERE (doctor_id = $1 OR patient_id = $2)
+  AND id <> $3
+  AND status NOT IN ('cancelled', 'no_show')
+  AND deleted_at IS NULL
+  AND tstzrange(visit_timestamp, visit_end) && tstzrange($4::timestamptz, $5::timestamptz)
+ORDER BY visit_timestamp ASC
+`
+
+type GetOverlappingAppointmentsParams struct {
+	DoctorID  pgtype.Int4
+	PatientID int32
+	ExcludeID int32
+	FromTime  pgtype.Timestamptz
+	ToTime    pgtype.Timestamptz
+}
+
+func (q *Queries) GetOverlappingAppointments(ctx context.Context, arg GetOverlappingAppointmentsParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, getOverlappingAppointments,
+		arg.DoctorID,
+		arg.PatientID,
+		arg.ExcludeID,
+		arg.FromTime,
+		arg.ToTime,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PatientID,
+			&i.UserID,
+			&i.VisitDate,
+			&i.AppointmentSequence,
+			&i.VisitTimestamp,
+			&i.PatientNotes,
+			&i.DoctorNotes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusUpdatedAt,
+			&i.StatusUpdatedBy,
+			&i.CancelReason,
+			&i.DoctorID,
+			&i.DurationMinutes,
+			&i.VisitEnd,
+			&i.SeriesID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rescheduleAppointment = `-- name: RescheduleAppointment :one
+UPDATE appointments
+SET
+    visit_timestamp = $1,
+    visit_date = $2,
+    appointment_sequence = CASE
+        WHEN visit_date = $2 THEN appointment_sequence
+        ELSE next_appointment_sequence($2)
+    END,
+    updated_at = NOW()
+WHERE id = $3 AND deleted_at IS NULL
+RETURNING id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at
+`
+
+type RescheduleAppointmentParams struct {
+	VisitTimestamp pgtype.Timestamptz
+	VisitDate      pgtype.Date
+	ID             int32
+}
+
+// Moving to another day takes the next sequence of that day, the old day
+// keeps a gap since sequences are never handed out twice.
+func (q *Queries) RescheduleAppointment(ctx context.Context, arg RescheduleAppointmentParams) (Appointment, error) {
+	row := q.db.QueryRow(ctx, rescheduleAppointment, arg.VisitTimestamp, arg.VisitDate, arg.ID)
+	var i Appointment
+	err := row.Scan(
+		&i.ID,
+		&i.PatientID,
+		&i.UserID,
+		&i.VisitDate,
+		&i.AppointmentSequence,
+		&i.VisitTimestamp,
+		&i.PatientNotes,
+		&i.DoctorNotes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Status,
+		&i.StatusUpdatedAt,
+		&i.StatusUpdatedBy,
+		&i.CancelReason,
+		&i.DoctorID,
+		&i.DurationMinutes,
+		&i.VisitEnd,
+		&i.SeriesID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const restoreAppointment = `-- name: RestoreAppointment :one
+UPDATE appointments a
+SET deleted_at = NULL
+WHERE a.id = $1
+  AND NOT EXISTS (
+      SELECT 1 FROM patients p
+      WHERE p.id = a.patient_id AND p.deleted_at IS NOT NULL
+  )
+RETURNING id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at
+`
+
+// Appointments of a deleted patient stay deleted, restore the patient.
+func (q *Queries) RestoreAppointment(ctx context.Context, id int32) (Appointment, error) {
+	row := q.db.QueryRow(ctx, restoreAppointment, id)
+	var i Appointment
+	err := row.Scan(
+		&i.ID,
+		&i.PatientID,
+		&i.UserID,
+		&i.VisitDate,
+		&i.AppointmentSequence,
+		&i.VisitTimestamp,
+		&i.PatientNotes,
+		&i.DoctorNotes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Status,
+		&i.StatusUpdatedAt,
+		&i.StatusUpdatedBy,
+		&i.CancelReason,
+		&i.DoctorID,
+		&i.DurationMinutes,
+		&i.VisitEnd,
+		&i.SeriesID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const updateAppointment = `-- name: UpdateAppointment :one
 UPDATE appointments
 SET
     patient_notes = COALESCE($2, patient_notes),
     doctor_notes = COALESCE($3, doctor_notes),
     updated_at = NOW()
-WHERE id = $1
-RETURNING id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at
 `
 
 type UpdateAppointmentParams struct {
@@ -251,6 +928,87 @@ func (q *Queries) UpdateAppointment(ctx context.Context, arg UpdateAppointmentPa
 		&i.DoctorNotes,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Status,
+		&i.StatusUpdatedAt,
+		&i.StatusUpdatedBy,
+		&i.CancelReason,
+		&i.DoctorID,
+		&i.DurationMinutes,
+		&i.VisitEnd,
+		&i.SeriesID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateAppointmentEncryption = `-- name: UpdateAppointmentEncryption :exec
+UPDATE appointments
+SET
+    patient_notes = $1,
+    doctor_notes = $2
+WHERE id = $3
+`
+
+type UpdateAppointmentEncryptionParams struct {
+	PatientNotes pgtype.Text
+	DoctorNotes  pgtype.Text
+	ID           int32
+}
+
+func (q *Queries) UpdateAppointmentEncryption(ctx context.Context, arg UpdateAppointmentEncryptionParams) error {
+	_, err := q.db.Exec(ctx, updateAppointmentEncryption, arg.PatientNotes, arg.DoctorNotes, arg.ID)
+	return err
+}
+
+const updateAppointmentStatus = `-- name: UpdateAppointmentStatus :one
+UPDATE appointments
+SET
+    status = $1::text,
+    status_updated_at = NOW(),
+    status_updated_by = $2,
+    cancel_reason = COALESCE($3, cancel_reason),
+    updated_at = NOW()
+WHERE id = $4 AND status = $5::text AND deleted_at IS NULL
+RETURNING id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at
+`
+
+type UpdateAppointmentStatusParams struct {
+	ToStatus     string
+	ChangedBy    pgtype.Int4
+	CancelReason pgtype.Text
+	ID           int32
+	FromStatus   string
+}
+
+func (q *Queries) UpdateAppointmentStatus(ctx context.Context, arg UpdateAppointmentStatusParams) (Appointment, error) {
+	row := q.db.QueryRow(ctx, updateAppointmentStatus,
+		arg.ToStatus,
+		arg.ChangedBy,
+		arg.CancelReason,
+		arg.ID,
+		arg.FromStatus,
+	)
+	var i Appointment
+	err := row.Scan(
+		&i.ID,
+		&i.PatientID,
+		&i.UserID,
+		&i.VisitDate,
+		&i.AppointmentSequence,
+		&i.VisitTimestamp,
+		&i.PatientNotes,
+		&i.DoctorNotes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Status,
+		&i.StatusUpdatedAt,
+		&i.StatusUpdatedBy,
+		&i.CancelReason,
+		&i.DoctorID,
+		&i.DurationMinutes,
+		&i.VisitEnd,
+		&i.SeriesID,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -19,6 +19,162 @@ type Appointment struct {
 	DoctorNotes         pgtype.Text
 	CreatedAt           pgtype.Timestamptz
 	UpdatedAt           pgtype.Timestamptz
+	Status              string
+	StatusUpdatedAt     pgtype.Timestamptz
+	StatusUpdatedBy     pgtype.Int4
+	CancelReason        pgtype.Text
+	DoctorID            pgtype.Int4
+	DurationMinutes     int16
+	VisitEnd            pgtype.Timestamptz
+	SeriesID            pgtype.Int4
+	DeletedAt           pgtype.Timestamptz
+}
+
+type AppointmentReminder struct {
+	ID             int64
+	AppointmentID  int32
+	OffsetMinutes  int32
+	Channel        string
+	VisitTimestamp pgtype.Timestamptz
+	Status         string
+	Attempts       int32
+	LastError      pgtype.Text
+	SentAt         pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+}
+
+type AppointmentRescheduleHistory struct {
+	ID                int32
+	AppointmentID     int32
+	OldVisitTimestamp pgtype.Timestamptz
+	NewVisitTimestamp pgtype.Timestamptz
+	RescheduledBy     pgtype.Int4
+	Reason            pgtype.Text
+	CreatedAt         pgtype.Timestamptz
+}
+
+type AppointmentSequenceCounter struct {
+	VisitDate    pgtype.Date
+	LastSequence int16
+}
+
+type AppointmentSeries struct {
+	ID              int32
+	PatientID       int32
+	DoctorID        pgtype.Int4
+	UserID          pgtype.Int4
+	Frequency       string
+	RepeatInterval  int16
+	OccurrenceCount pgtype.Int2
+	UntilDate       pgtype.Date
+	Weekdays        []int16
+	Timezone        string
+	StartsAt        pgtype.Timestamptz
+	DurationMinutes int16
+	CreatedAt       pgtype.Timestamptz
+}
+
+type AppointmentStatusHistory struct {
+	ID            int32
+	AppointmentID int32
+	FromStatus    string
+	ToStatus      string
+	ChangedBy     pgtype.Int4
+	Reason        pgtype.Text
+	CreatedAt     pgtype.Timestamptz
+}
+
+type AuditLog struct {
+	ID        int64
+	ActorID   pgtype.Int4
+	Action    string
+	Entity    string
+	EntityID  pgtype.Int4
+	Changes   []byte
+	Ip        pgtype.Text
+	RequestID pgtype.Text
+	CreatedAt pgtype.Timestamptz
+	PrevHash  []byte
+	Hash      []byte
+	ChainSeq  pgtype.Int8
+}
+
+type AuditChainHead struct {
+	Singleton bool
+	LastID    int64
+	LastHash  []byte
+	LastSeq   int64
+}
+
+type CalendarFeedToken struct {
+	ID         int32
+	DoctorID   int32
+	TokenHash  string
+	CreatedBy  pgtype.Int4
+	LastUsedAt pgtype.Timestamptz
+	RevokedAt  pgtype.Timestamptz
+	CreatedAt  pgtype.Timestamptz
+}
+
+type ChangeLog struct {
+	ID        int64
+	TableName string
+	Operation string
+	RowID     int32
+	Payload   []byte
+	CreatedAt pgtype.Timestamptz
+}
+
+type DoctorBreak struct {
+	ID        int32
+	DoctorID  int32
+	Weekday   int16
+	StartTime pgtype.Time
+	EndTime   pgtype.Time
+}
+
+type DoctorSchedule struct {
+	DoctorID    int32
+	SlotMinutes int16
+	Timezone    string
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+}
+
+type DoctorScheduleException struct {
+	ID            int32
+	DoctorID      int32
+	ExceptionDate pgtype.Date
+	Kind          string
+	StartTime     pgtype.Time
+	EndTime       pgtype.Time
+	Reason        pgtype.Text
+	CreatedAt     pgtype.Timestamptz
+}
+
+type DoctorWorkingHour struct {
+	ID        int32
+	DoctorID  int32
+	Weekday   int16
+	StartTime pgtype.Time
+	EndTime   pgtype.Time
+}
+
+type EncryptionKey struct {
+	ID          int32
+	Purpose     string
+	WrappedKey  []byte
+	MasterKeyID string
+	CreatedAt   pgtype.Timestamptz
+	RetiredAt   pgtype.Timestamptz
+}
+
+type OutboxEvent struct {
+	ID           int64
+	EventType    string
+	Payload      []byte
+	CreatedAt    pgtype.Timestamptz
+	DispatchedAt pgtype.Timestamptz
 }
 
 type Patient struct {
@@ -33,13 +189,103 @@ type Patient struct {
 	Address   pgtype.Text
 	CreatedAt pgtype.Timestamp
 	UpdatedAt pgtype.Timestamp
+	DeletedAt pgtype.Timestamptz
+	EmailBidx []byte
+	PhoneBidx []byte
+}
+
+type PatientMerge struct {
+	ID                   int32
+	SurvivorID           int32
+	DuplicateID          int32
+	Duplicate            []byte
+	AppointmentsMoved    int32
+	SeriesMoved          int32
+	WaitlistEntriesMoved int32
+	MergedBy             pgtype.Int4
+	CreatedAt            pgtype.Timestamptz
+}
+
+type RefreshToken struct {
+	ID           int32
+	UserID       int32
+	FamilyID     string
+	TokenHash    string
+	TokenVersion int32
+	UserAgent    pgtype.Text
+	Ip           pgtype.Text
+	DeviceName   pgtype.Text
+	ExpiresAt    pgtype.Timestamptz
+	UsedAt       pgtype.Timestamptz
+	RevokedAt    pgtype.Timestamptz
+	CreatedAt    pgtype.Timestamptz
+}
+
+type RevokedToken struct {
+	Jti       string
+	UserID    int32
+	ExpiresAt pgtype.Timestamptz
+	RevokedAt pgtype.Timestamptz
 }
 
 type User struct {
-	ID        int32
-	Email     string
-	Password  string
-	Type      string
-	CreatedAt pgtype.Timestamp
-	UpdatedAt pgtype.Timestamp
+	ID           int32
+	Email        string
+	Password     string
+	Type         string
+	CreatedAt    pgtype.Timestamp
+	UpdatedAt    pgtype.Timestamp
+	DeletedAt    pgtype.Timestamptz
+	TokenVersion int32
+}
+
+type WaitlistEntry struct {
+	ID             int32
+	PatientID      int32
+	DoctorID       pgtype.Int4
+	FromDate       pgtype.Date
+	ToDate         pgtype.Date
+	TimePreference string
+	Notes          pgtype.Text
+	Status         string
+	CreatedBy      pgtype.Int4
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+}
+
+type WaitlistHold struct {
+	ID              int32
+	WaitlistEntryID int32
+	DoctorID        int32
+	VisitTimestamp  pgtype.Timestamptz
+	DurationMinutes int16
+	ExpiresAt       pgtype.Timestamptz
+	Status          string
+	AppointmentID   pgtype.Int4
+	CreatedAt       pgtype.Timestamptz
+	ResolvedAt      pgtype.Timestamptz
+}
+
+type Webhook struct {
+	ID         int32
+	Url        string
+	Secret     string
+	EventTypes []string
+	Active     bool
+	CreatedBy  pgtype.Int4
+	CreatedAt  pgtype.Timestamptz
+	UpdatedAt  pgtype.Timestamptz
+}
+
+type WebhookDelivery struct {
+	ID             int64
+	WebhookID      int32
+	EventID        int64
+	Status         string
+	Attempts       int32
+	NextAttemptAt  pgtype.Timestamptz
+	LastStatusCode pgtype.Int4
+	LastError      pgtype.Text
+	DeliveredAt    pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
 }
@@ -12,20 +12,22 @@ import (
 )
 
 const createPatient = `-- name: CreatePatient :one
-INSERT INTO patients (name, phone, email, age, weight, height, gender, address)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, name, phone, email, age, weight, height, gender, address, created_at, updated_at
+INSERT INTO patients (name, phone, email, age, weight, height, gender, address, email_bidx, phone_bidx)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id, name, phone, email, age, weight, height, gender, address, created_at, updated_at, deleted_at, email_bidx, phone_bidx
 `
 
 type CreatePatientParams struct {
-	Name    string
-	Phone   pgtype.Text
-	Email   string
-	Age     pgtype.Int2
-	Weight  pgtype.Numeric
-	Height  pgtype.Numeric
-	Gender  pgtype.Text
-	Address pgtype.Text
+	Name      string
+	Phone     pgtype.Text
+	Email     string
+	Age       pgtype.Int2
+	Weight    pgtype.Numeric
+	Height    pgtype.Numeric
+	Gender    pgtype.Text
+	Address   pgtype.Text
+	EmailBidx []byte
+	PhoneBidx []byte
 }
 
 func (q *Queries) CreatePatient(ctx context.Context, arg CreatePatientParams) (Patient, error) {
@@ -38,6 +40,8 @@ func (q *Queries) CreatePatient(ctx context.Context, arg CreatePatientParams) (P
 		arg.Height,
 		arg.Gender,
 		arg.Address,
+		arg.EmailBidx,
+		arg.PhoneBidx,
 	)
 	var i Patient
 	err := row.Scan(
@@ -52,39 +56,267 @@ func (q *Queries) CreatePatient(ctx context.Context, arg CreatePatientParams) (P
 		&i.Address,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.EmailBidx,
+		&i.PhoneBidx,
+	)
+	return i, err
+}
+
+const createPatientMerge = `-- name: CreatePatientMerge :one
+INSERT INTO patient_merges (survivor_id, duplicate_id, duplicate, appointments_moved, series_moved, waitlist_entries_moved, merged_by)
+SELECT $1::int, p.id, to_jsonb(p), $2::int, $3::int, $4::int, $5::int
+FROM patients p
+WHERE p.id = $6
+RETURNING id, survivor_id, duplicate_id, duplicate, appointments_moved, series_moved, waitlist_entries_moved, merged_by, created_at
+`
+
+type CreatePatientMergeParams struct {
+	SurvivorID           int32
+	AppointmentsMoved    int32
+	SeriesMoved          int32
+	WaitlistEntriesMoved int32
+	MergedBy             pgtype.Int4
+	DuplicateID          int32
+}
+
+// Records the merge along with the duplicate's row, call it before the
+// duplicate is deleted.
+func (q *Queries) CreatePatientMerge(ctx context.Context, arg CreatePatientMergeParams) (PatientMerge, error) {
+	row := q.db.QueryRow(ctx, createPatientMerge,
+		arg.SurvivorID,
+		arg.AppointmentsMoved,
+		arg.SeriesMoved,
+		arg.WaitlistEntriesMoved,
+		arg.MergedBy,
+		arg.DuplicateID,
+	)
+	var i PatientMerge
+	err := row.Scan(
+		&i.ID,
+		&i.SurvivorID,
+		&i.DuplicateID,
+		&i.Duplicate,
+		&i.AppointmentsMoved,
+		&i.SeriesMoved,
+		&i.WaitlistEntriesMoved,
+		&i.MergedBy,
+		&i.CreatedAt,
 	)
 	return i, err
 }
 
 const deletePatient = `-- name: DeletePatient :exec
-DELETE FROM patients WHERE id = $1
+WITH patient AS (
+    UPDATE patients
+    SET deleted_at = NOW()
+    WHERE id = $1 AND deleted_at IS NULL
+    RETURNING id, deleted_at
+)
+UPDATE appointments a
+SET deleted_at = patient.deleted_at
+FROM patient
+WHERE a.patient_id = patient.id AND a.deleted_at IS NULL
 `
 
+// Tombstones the patient along with its appointments that aren't deleted
+// yet, all with the same deleted_at.
 func (q *Queries) DeletePatient(ctx context.Context, id int32) error {
 	_, err := q.db.Exec(ctx, deletePatient, id)
 	return err
 }
 
+const fillPatientFromDuplicate = `-- name: FillPatientFromDuplicate :one
+UPDATE patients s
+SET
+    phone = COALESCE(s.phone, d.phone),
+    phone_bidx = CASE WHEN s.phone IS NULL THEN d.phone_bidx ELSE s.phone_bidx END,
+    age = COALESCE(s.age, d.age),
+    weight = COALESCE(s.weight, d.weight),
+    height = COALESCE(s.height, d.height),
+    gender = COALESCE(s.gender, d.gender),
+    address = COALESCE(s.address, d.address)
+FROM patients d
+WHERE s.id = $1 AND d.id = $2
+RETURNING s.id, s.name, s.phone, s.email, s.age, s.weight, s.height, s.gender, s.address, s.created_at, s.updated_at, s.deleted_at, s.email_bidx, s.phone_bidx
+`
+
+type FillPatientFromDuplicateParams struct {
+	SurvivorID  int32
+	DuplicateID int32
+}
+
+// Copies the details the survivor lacks from the duplicate.
+func (q *Queries) FillPatientFromDuplicate(ctx context.Context, arg FillPatientFromDuplicateParams) (Patient, error) {
+	row := q.db.QueryRow(ctx, fillPatientFromDuplicate, arg.SurvivorID, arg.DuplicateID)
+	var i Patient
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Phone,
+		&i.Email,
+		&i.Age,
+		&i.Weight,
+		&i.Height,
+		&i.Gender,
+		&i.Address,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.EmailBidx,
+		&i.PhoneBidx,
+	)
+	return i, err
+}
+
+const findDuplicatePatients = `-- name: FindDuplicatePatients :many
+SELECT
+    id, name, phone, email, age, weight, height, gender, address, created_at, updated_at,
+    similarity(name, $1::text)::real AS name_similarity,
+    COALESCE(email_bidx = $2::bytea, false) AS email_match,
+    COALESCE(phone_bidx = $3::bytea, false) AS phone_match,
+    ($4::int > 0 AND age = $4::int) AS age_match
+FROM patients
+WHERE id <> $5::int
+  AND deleted_at IS NULL
+  AND (
+      name % $1::text
+      OR email_bidx = $2::bytea
+      OR phone_bidx = $3::bytea
+  )
+ORDER BY name_similarity DESC, id ASC
+LIMIT $6
+`
+
+type FindDuplicatePatientsParams struct {
+	Name       string
+	EmailBidx  []byte
+	PhoneBidx  []byte
+	Age        int32
+	ExcludeID  int32
+	MaxResults int32
+}
+
+type FindDuplicatePatientsRow struct {
+	ID             int32
+	Name           string
+	Phone          pgtype.Text
+	Email          string
+	Age            pgtype.Int2
+	Weight         pgtype.Numeric
+	Height         pgtype.Numeric
+	Gender         pgtype.Text
+	Address        pgtype.Text
+	CreatedAt      pgtype.Timestamp
+	UpdatedAt      pgtype.Timestamp
+	NameSimilarity float32
+	EmailMatch     bool
+	PhoneMatch     bool
+	AgeMatch       bool
+}
+
+// Patients that may be the same person as the given details: a similar
+// name, the same phone digits or the same email, the last two by their
+// blind indexes. Each match is returned with what it has in common, closest
+// names first. exclude_id leaves out the patient the details belong to, 0
+// for a new one.
+func (q *Queries) FindDuplicatePatients(ctx context.Context, arg FindDuplicatePatientsParams) ([]FindDuplicatePatientsRow, error) {
+	rows, err := q.db.Query(ctx, findDuplicatePatients,
+		arg.Name,
+		arg.EmailBidx,
+		arg.PhoneBidx,
+		arg.Age,
+		arg.ExcludeID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindDuplicatePatientsRow
+	for rows.Next() {
+		var i FindDuplicatePatientsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Phone,
+			&i.Email,
+			&i.Age,
+			&i.Weight,
+			&i.Height,
+			&i.Gender,
+			&i.Address,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.NameSimilarity,
+			&i.EmailMatch,
+			&i.PhoneMatch,
+			&i.AgeMatch,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAllPatients = `-- name: GetAllPatients :many
-SELECT id, name, phone, email, age, weight, height, gender, address, created_at, updated_at FROM patients
+SELECT id, name, phone, email, age, weight, height, gender, address, created_at, updated_at, deleted_at FROM patients
 WHERE ($1::text = '' OR name::text ILIKE '%' || $1::text || '%')
+  AND ($2::boolean OR deleted_at IS NULL)
+  AND (
+      NOT $3::boolean
+      OR ($4::text = 'name' AND NOT $5::boolean AND (name, id) > ($6::text, $7::int))
+      OR ($4::text = 'name' AND $5::boolean AND (name, id) < ($6::text, $7::int))
+      OR ($4::text = 'age' AND NOT $5::boolean AND (COALESCE(age, -1), id) > ($8::int, $7::int))
+      OR ($4::text = 'age' AND $5::boolean AND (COALESCE(age, -1), id) < ($8::int, $7::int))
+      OR ($4::text = 'created_at' AND NOT $5::boolean AND (COALESCE(created_at, 'epoch'), id) > ($9::timestamp, $7::int))
+      OR ($4::text = 'created_at' AND $5::boolean AND (COALESCE(created_at, 'epoch'), id) < ($9::timestamp, $7::int))
+  )
 ORDER BY
-    CASE
-        WHEN $2::text = 'name' THEN name
-        WHEN $2::text = 'age' THEN age::TEXT
-        ELSE created_at::TEXT
-    END
-    || CASE WHEN $3::text = 'DESC' THEN ' DESC' ELSE ' ASC' END
+    CASE WHEN $4::text = 'name' AND NOT $5::boolean THEN name END ASC,
+    CASE WHEN $4::text = 'name' AND $5::boolean THEN name END DESC,
+    CASE WHEN $4::text = 'age' AND NOT $5::boolean THEN COALESCE(age, -1) END ASC,
+    CASE WHEN $4::text = 'age' AND $5::boolean THEN COALESCE(age, -1) END DESC,
+    CASE WHEN $4::text = 'created_at' AND NOT $5::boolean THEN COALESCE(created_at, 'epoch') END ASC,
+    CASE WHEN $4::text = 'created_at' AND $5::boolean THEN COALESCE(created_at, 'epoch') END DESC,
+    CASE WHEN NOT $5::boolean THEN id END ASC,
+    CASE WHEN $5::boolean THEN id END DESC
+LIMIT $10
 `
 
 type GetAllPatientsParams struct {
-	Name          string
-	SortBy        string
-	SortDirection string
+	Name            string
+	IncludeDeleted  bool
+	HasCursor       bool
+	SortBy          string
+	SortDesc        bool
+	CursorName      string
+	CursorID        int32
+	CursorAge       int32
+	CursorCreatedAt pgtype.Timestamp
+	MaxResults      int32
 }
 
+// One page of patients in the requested order, id breaking ties. With
+// has_cursor only the patients after the cursor's sort key and id are
+// returned; the cursor value matching sort_by is the one used.
 func (q *Queries) GetAllPatients(ctx context.Context, arg GetAllPatientsParams) ([]Patient, error) {
-	rows, err := q.db.Query(ctx, getAllPatients, arg.Name, arg.SortBy, arg.SortDirection)
+	rows, err := q.db.Query(ctx, getAllPatients,
+		arg.Name,
+		arg.IncludeDeleted,
+		arg.HasCursor,
+		arg.SortBy,
+		arg.SortDesc,
+		arg.CursorName,
+		arg.CursorID,
+		arg.CursorAge,
+		arg.CursorCreatedAt,
+		arg.MaxResults,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -104,6 +336,7 @@ func (q *Queries) GetAllPatients(ctx context.Context, arg GetAllPatientsParams)
 			&i.Address,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -116,7 +349,7 @@ func (q *Queries) GetAllPatients(ctx context.Context, arg GetAllPatientsParams)
 }
 
 const getPatientByID = `-- name: GetPatientByID :one
-SELECT id, name, phone, email, age, weight, height, gender, address, created_at, updated_at FROM patients WHERE id = $1
+SELECT id, name, phone, email, age, weight, height, gender, address, created_at, updated_at, deleted_at FROM patients WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetPatientByID(ctx context.Context, id int32) (Patient, error) {
@@ -134,10 +367,439 @@ func (q *Queries) GetPatientByID(ctx context.Context, id int32) (Patient, error)
 		&i.Address,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getPatientByIDWithDeleted = `-- name: GetPatientByIDWithDeleted :one
+SELECT id, name, phone, email, age, weight, height, gender, address, created_at, updated_at, deleted_at FROM patients WHERE id = $1
+`
+
+func (q *Queries) GetPatientByIDWithDeleted(ctx context.Context, id int32) (Patient, error) {
+	row := q.db.QueryRow(ctx, getPatientByIDWithDeleted, id)
+	var i Patient
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Phone,
+		&i.Email,
+		&i.Age,
+		&i.Weight,
+		&i.Height,
+		&i.Gender,
+		&i.Address,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getPatientForUpdate = `-- name: GetPatientForUpdate :one
+SELECT id, name, phone, email, age, weight, height, gender, address, created_at, updated_at, deleted_at FROM patients WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+`
+
+func (q *Queries) GetPatientForUpdate(ctx context.Context, id int32) (Patient, error) {
+	row := q.db.QueryRow(ctx, getPatientForUpdate, id)
+	var i Patient
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Phone,
+		&i.Email,
+		&i.Age,
+		&i.Weight,
+		&i.Height,
+		&i.Gender,
+		&i.Address,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
+const getPatientMergeOverlaps = `-- name: GetPatientMergeOverlaps :many
+SELECT a.id, a.patient_id, a.user_id, a.visit_date, a.appointment_sequence, a.visit_timestamp, a.patient_notes, a.doctor_notes, a.created_at, a.updated_at, a.status, a.status_updated_at, a.status_updated_by, a.cancel_reason, a.doctor_id, a.duration_minutes, a.visit_end, a.series_id, a.deleted_at FROM appointments a
+WHERE a.patient_id IN ($1::int, $2::int)
+  AND a.status NOT IN ('cancelled', 'no_show')
+  AND a.deleted_at IS NULL
+  AND EXISTS (
+      SELECT 1 FROM appointments o
+      WHERE o.patient_id = CASE WHEN a.patient_id = $1::int THEN $2::int ELSE $1::int END
+        AND o.status NOT IN ('cancelled', 'no_show')
+        AND o.deleted_at IS NULL
+        AND tstzrange(o.visit_timestamp, o.visit_end) && tstzrange(a.visit_timestamp, a.visit_end)
+  )
+ORDER BY a.visit_timestamp, a.id
+`
+
+type GetPatientMergeOverlapsParams struct {
+	SurvivorID  int32
+	DuplicateID int32
+}
+
+// The active appointments of either patient that overlap an active one of
+// the other, which the patient overlap constraint keeps from being moved
+// onto the survivor.
+func (q *Queries) GetPatientMergeOverlaps(ctx context.Context, arg GetPatientMergeOverlapsParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, getPatientMergeOverlaps, arg.SurvivorID, arg.DuplicateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PatientID,
+			&i.UserID,
+			&i.VisitDate,
+			&i.AppointmentSequence,
+			&i.VisitTimestamp,
+			&i.PatientNotes,
+			&i.DoctorNotes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusUpdatedAt,
+			&i.StatusUpdatedBy,
+			&i.CancelReason,
+			&i.DoctorID,
+			&i.DurationMinutes,
+			&i.VisitEnd,
+			&i.SeriesID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPatientMerges = `-- name: GetPatientMerges :many
+SELECT id, survivor_id, duplicate_id, duplicate, appointments_moved, series_moved, waitlist_entries_moved, merged_by, created_at FROM patient_merges
+WHERE survivor_id = $1
+ORDER BY id DESC
+`
+
+func (q *Queries) GetPatientMerges(ctx context.Context, survivorID int32) ([]PatientMerge, error) {
+	rows, err := q.db.Query(ctx, getPatientMerges, survivorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PatientMerge
+	for rows.Next() {
+		var i PatientMerge
+		if err := rows.Scan(
+			&i.ID,
+			&i.SurvivorID,
+			&i.DuplicateID,
+			&i.Duplicate,
+			&i.AppointmentsMoved,
+			&i.SeriesMoved,
+			&i.WaitlistEntriesMoved,
+			&i.MergedBy,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPatientsForReencryption = `-- name: GetPatientsForReencryption :many
+SELECT id, name, phone, email, age, weight, height, gender, address, created_at, updated_at, deleted_at, email_bidx, phone_bidx FROM patients
+WHERE id > $1::int
+ORDER BY id
+LIMIT $2
+FOR UPDATE
+`
+
+type GetPatientsForReencryptionParams struct {
+	AfterID    int32
+	MaxResults int32
+}
+
+// Every patient after after_id, deleted or not, locked until the
+// transaction ends so they can be written back re-encrypted.
+func (q *Queries) GetPatientsForReencryption(ctx context.Context, arg GetPatientsForReencryptionParams) ([]Patient, error) {
+	rows, err := q.db.Query(ctx, getPatientsForReencryption, arg.AfterID, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Patient
+	for rows.Next() {
+		var i Patient
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Phone,
+			&i.Email,
+			&i.Age,
+			&i.Weight,
+			&i.Height,
+			&i.Gender,
+			&i.Address,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.EmailBidx,
+			&i.PhoneBidx,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const movePatientAppointmentSeries = `-- name: MovePatientAppointmentSeries :execrows
+UPDATE appointment_series
+SET patient_id = $1
+WHERE patient_id = $2
+`
+
+type MovePatientAppointmentSeriesParams struct {
+	SurvivorID  int32
+	DuplicateID int32
+}
+
+func (q *Queries) MovePatientAppointmentSeries(ctx context.Context, arg MovePatientAppointmentSeriesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, movePatientAppointmentSeries, arg.SurvivorID, arg.DuplicateID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const movePatientAppointments = `-- name: MovePatientAppointments :many
+UPDATE appointments
+SET patient_id = $1
+WHERE patient_id = $2
+RETURNING id, patient_id, user_id, visit_date, appointment_sequence, visit_timestamp, patient_notes, doctor_notes, created_at, updated_at, status, status_updated_at, status_updated_by, cancel_reason, doctor_id, duration_minutes, visit_end, series_id, deleted_at
+`
+
+type MovePatientAppointmentsParams struct {
+	SurvivorID  int32
+	DuplicateID int32
+}
+
+func (q *Queries) MovePatientAppointments(ctx context.Context, arg MovePatientAppointmentsParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, movePatientAppointments, arg.SurvivorID, arg.DuplicateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PatientID,
+			&i.UserID,
+			&i.VisitDate,
+			&i.AppointmentSequence,
+			&i.VisitTimestamp,
+			&i.PatientNotes,
+			&i.DoctorNotes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Status,
+			&i.StatusUpdatedAt,
+			&i.StatusUpdatedBy,
+			&i.CancelReason,
+			&i.DoctorID,
+			&i.DurationMinutes,
+			&i.VisitEnd,
+			&i.SeriesID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const movePatientWaitlistEntries = `-- name: MovePatientWaitlistEntries :execrows
+UPDATE waitlist_entries
+SET patient_id = $1
+WHERE patient_id = $2
+`
+
+type MovePatientWaitlistEntriesParams struct {
+	SurvivorID  int32
+	DuplicateID int32
+}
+
+func (q *Queries) MovePatientWaitlistEntries(ctx context.Context, arg MovePatientWaitlistEntriesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, movePatientWaitlistEntries, arg.SurvivorID, arg.DuplicateID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restorePatient = `-- name: RestorePatient :one
+WITH patient AS (
+    SELECT id, deleted_at FROM patients
+    WHERE id = $1
+    FOR UPDATE
+), appointments_restored AS (
+    UPDATE appointments a
+    SET deleted_at = NULL
+    FROM patient
+    WHERE a.patient_id = patient.id AND a.deleted_at = patient.deleted_at
+)
+UPDATE patients
+SET deleted_at = NULL
+FROM patient
+WHERE patients.id = patient.id
+RETURNING patients.id, patients.name, patients.phone, patients.email, patients.age, patients.weight, patients.height, patients.gender, patients.address, patients.created_at, patients.updated_at, patients.deleted_at
+`
+
+// Brings back the patient and the appointments deleted along with it.
+func (q *Queries) RestorePatient(ctx context.Context, id int32) (Patient, error) {
+	row := q.db.QueryRow(ctx, restorePatient, id)
+	var i Patient
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Phone,
+		&i.Email,
+		&i.Age,
+		&i.Weight,
+		&i.Height,
+		&i.Gender,
+		&i.Address,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const searchPatients = `-- name: SearchPatients :many
+SELECT
+    p.id, p.name, p.phone, p.email, p.age, p.weight, p.height, p.gender, p.address, p.created_at, p.updated_at,
+    COALESCE(p.email_bidx = q.email_bidx, false) AS email_match,
+    COALESCE(p.phone_bidx = q.phone_bidx, false) AS phone_match,
+    (
+        ts_rank(to_tsvector('simple', p.name), q.ts)
+        + word_similarity(q.term, p.name)
+        + CASE WHEN p.email_bidx = q.email_bidx THEN 1 ELSE 0 END
+        + CASE WHEN p.phone_bidx = q.phone_bidx THEN 1 ELSE 0 END
+    )::real AS rank,
+    ts_headline('simple', p.name, q.ts, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS name_highlight
+FROM patients p,
+    (SELECT to_tsquery('simple', $1::text) AS ts, $2::text AS term, $3::text AS pattern, $4::bytea AS email_bidx, $5::bytea AS phone_bidx) q
+WHERE p.deleted_at IS NULL
+  AND (
+      to_tsvector('simple', p.name) @@ q.ts
+      OR p.name ILIKE q.pattern
+      OR q.term % p.name
+      OR q.term <% p.name
+      OR p.email_bidx = q.email_bidx
+      OR p.phone_bidx = q.phone_bidx
+  )
+ORDER BY rank DESC, p.id ASC
+LIMIT $6
+`
+
+type SearchPatientsParams struct {
+	PrefixQuery string
+	Term        string
+	Pattern     string
+	EmailBidx   []byte
+	PhoneBidx   []byte
+	MaxResults  int32
+}
+
+type SearchPatientsRow struct {
+	ID            int32
+	Name          string
+	Phone         pgtype.Text
+	Email         string
+	Age           pgtype.Int2
+	Weight        pgtype.Numeric
+	Height        pgtype.Numeric
+	Gender        pgtype.Text
+	Address       pgtype.Text
+	CreatedAt     pgtype.Timestamp
+	UpdatedAt     pgtype.Timestamp
+	EmailMatch    bool
+	PhoneMatch    bool
+	Rank          float32
+	NameHighlight string
+}
+
+// Patients matching a search, best first. prefix_query is a to_tsquery
+// string matching the start of words in the name, which the search as typed
+// also finds names containing or resembling it. Emails and phones are
+// encrypted, they only match as a whole by their blind index. The name comes
+// back highlighted with <mark> where its words matched.
+func (q *Queries) SearchPatients(ctx context.Context, arg SearchPatientsParams) ([]SearchPatientsRow, error) {
+	rows, err := q.db.Query(ctx, searchPatients,
+		arg.PrefixQuery,
+		arg.Term,
+		arg.Pattern,
+		arg.EmailBidx,
+		arg.PhoneBidx,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPatientsRow
+	for rows.Next() {
+		var i SearchPatientsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Phone,
+			&i.Email,
+			&i.Age,
+			&i.Weight,
+			&i.Height,
+			&i.Gender,
+			&i.Address,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EmailMatch,
+			&i.PhoneMatch,
+			&i.Rank,
+			&i.NameHighlight,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updatePatient = `-- name: UpdatePatient :one
 UPDATE patients
 SET
@@ -149,21 +811,25 @@ SET
     height = COALESCE($7, height),
     gender = COALESCE($8, gender),
     address = COALESCE($9, address),
+    email_bidx = COALESCE($10, email_bidx),
+    phone_bidx = COALESCE($11, phone_bidx),
     updated_at = NOW()
-WHERE id = $1
-RETURNING id, name, phone, email, age, weight, height, gender, address, created_at, updated_at
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, name, phone, email, age, weight, height, gender, address, created_at, updated_at, deleted_at, email_bidx, phone_bidx
 `
 
 type UpdatePatientParams struct {
-	ID      int32
-	Name    string
-	Phone   pgtype.Text
-	Email   string
-	Age     pgtype.Int2
-	Weight  pgtype.Numeric
-	Height  pgtype.Numeric
-	Gender  pgtype.Text
-	Address pgtype.Text
+	ID        int32
+	Name      string
+	Phone     pgtype.Text
+	Email     string
+	Age       pgtype.Int2
+	Weight    pgtype.Numeric
+	Height    pgtype.Numeric
+	Gender    pgtype.Text
+	Address   pgtype.Text
+	EmailBidx []byte
+	PhoneBidx []byte
 }
 
 func (q *Queries) UpdatePatient(ctx context.Context, arg UpdatePatientParams) (Patient, error) {
@@ -177,6 +843,8 @@ func (q *Queries) UpdatePatient(ctx context.Context, arg UpdatePatientParams) (P
 		arg.Height,
 		arg.Gender,
 		arg.Address,
+		arg.EmailBidx,
+		arg.PhoneBidx,
 	)
 	var i Patient
 	err := row.Scan(
@@ -191,6 +859,41 @@ func (q *Queries) UpdatePatient(ctx context.Context, arg UpdatePatientParams) (P
 		&i.Address,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.EmailBidx,
+		&i.PhoneBidx,
 	)
 	return i, err
 }
+
+const updatePatientEncryption = `-- name: UpdatePatientEncryption :exec
+UPDATE patients
+SET
+    phone = $1,
+    email = $2,
+    address = $3,
+    email_bidx = $4,
+    phone_bidx = $5
+WHERE id = $6
+`
+
+type UpdatePatientEncryptionParams struct {
+	Phone     pgtype.Text
+	Email     string
+	Address   pgtype.Text
+	EmailBidx []byte
+	PhoneBidx []byte
+	ID        int32
+}
+
+func (q *Queries) UpdatePatientEncryption(ctx context.Context, arg UpdatePatientEncryptionParams) error {
+	_, err := q.db.Exec(ctx, updatePatientEncryption,
+		arg.Phone,
+		arg.Email,
+		arg.Address,
+		arg.EmailBidx,
+		arg.PhoneBidx,
+		arg.ID,
+	)
+	return err
+}
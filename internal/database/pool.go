@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolConfig holds the tunables of the connection pool shared by every
+// repository.
+type PoolConfig struct {
+	MinConns          int32
+	MaxConns          int32
+	HealthCheckPeriod time.Duration
+	// AcquireTimeout bounds how long a query waits for a free connection
+	// before giving up, independently of the query's own deadline.
+	AcquireTimeout time.Duration
+}
+
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MinConns:          2,
+		MaxConns:          10,
+		HealthCheckPeriod: time.Minute,
+		AcquireTimeout:    5 * time.Second,
+	}
+}
+
+// Pool is a pgxpool.Pool that satisfies DBTX, so it can be handed to New
+// directly, and that can start transactions for Queries.WithTx.
+type Pool struct {
+	pool           *pgxpool.Pool
+	acquireTimeout time.Duration
+}
+
+func NewPool(ctx context.Context, connString string, config PoolConfig) (*Pool, error) {
+	pgxConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.MinConns > 0 {
+		pgxConfig.MinConns = config.MinConns
+	}
+	if config.MaxConns > 0 {
+		pgxConfig.MaxConns = config.MaxConns
+	}
+	if config.HealthCheckPeriod > 0 {
+		pgxConfig.HealthCheckPeriod = config.HealthCheckPeriod
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, pgxConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return &Pool{
+		pool:           pool,
+		acquireTimeout: config.AcquireTimeout,
+	}, nil
+}
+
+// Acquire takes a connection from the pool, waiting at most AcquireTimeout.
+// The caller must Release it.
+func (p *Pool) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	if p.acquireTimeout <= 0 {
+		return p.pool.Acquire(ctx)
+	}
+
+	acquireCtx, cancel := context.WithTimeout(ctx, p.acquireTimeout)
+	defer cancel()
+
+	return p.pool.Acquire(acquireCtx)
+}
+
+func (p *Pool) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer conn.Release()
+
+	return conn.Exec(ctx, sql, args...)
+}
+
+func (p *Pool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	return &poolRows{Rows: rows, conn: conn}, nil
+}
+
+func (p *Pool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return errRow{err: err}
+	}
+
+	return &poolRow{row: conn.QueryRow(ctx, sql, args...), conn: conn}
+}
+
+func (p *Pool) Begin(ctx context.Context) (pgx.Tx, error) {
+	return p.BeginTx(ctx, pgx.TxOptions{})
+}
+
+// BeginTx starts a transaction on a dedicated connection; the connection
+// goes back to the pool when the transaction is committed or rolled back.
+func (p *Pool) BeginTx(ctx context.Context, options pgx.TxOptions) (pgx.Tx, error) {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.BeginTx(ctx, options)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	return &poolTx{Tx: tx, conn: conn}, nil
+}
+
+func (p *Pool) Ping(ctx context.Context) error {
+	return p.pool.Ping(ctx)
+}
+
+func (p *Pool) Stat() *pgxpool.Stat {
+	return p.pool.Stat()
+}
+
+func (p *Pool) Close() {
+	p.pool.Close()
+}
+
+type poolRows struct {
+	pgx.Rows
+	conn *pgxpool.Conn
+	once sync.Once
+}
+
+func (r *poolRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+func (r *poolRows) Close() {
+	r.Rows.Close()
+	r.once.Do(r.conn.Release)
+}
+
+type poolRow struct {
+	row  pgx.Row
+	conn *pgxpool.Conn
+}
+
+func (r *poolRow) Scan(dest ...any) error {
+	defer r.conn.Release()
+	return r.row.Scan(dest...)
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...any) error {
+	return r.err
+}
+
+type poolTx struct {
+	pgx.Tx
+	conn *pgxpool.Conn
+	once sync.Once
+}
+
+func (t *poolTx) Commit(ctx context.Context) error {
+	defer t.once.Do(t.conn.Release)
+	return t.Tx.Commit(ctx)
+}
+
+func (t *poolTx) Rollback(ctx context.Context) error {
+	defer t.once.Do(t.conn.Release)
+	return t.Tx.Rollback(ctx)
+}
@@ -7,6 +7,8 @@ package database
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -15,7 +17,7 @@ INSERT INTO public.users (
 ) VALUES (
     $1, $2, $3
 )
-RETURNING id, email, password, type, created_at, updated_at
+RETURNING id, email, password, type, created_at, updated_at, deleted_at, token_version
 `
 
 type CreateUserParams struct {
@@ -34,12 +36,16 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Type,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TokenVersion,
 	)
 	return i, err
 }
 
 const deleteUser = `-- name: DeleteUser :exec
-DELETE FROM users WHERE id = $1
+UPDATE users
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) DeleteUser(ctx context.Context, id int32) error {
@@ -48,12 +54,54 @@ func (q *Queries) DeleteUser(ctx context.Context, id int32) error {
 }
 
 const getAllUsers = `-- name: GetAllUsers :many
-SELECT id, email, password, type, created_at, updated_at FROM public.users
-ORDER BY id ASC
+SELECT id, email, password, type, created_at, updated_at, deleted_at, token_version FROM public.users
+WHERE ($1::text IS NULL OR type = $1::text)
+  AND ($2::boolean OR deleted_at IS NULL)
+  AND (
+      NOT $3::boolean
+      OR ($4::text = 'email' AND NOT $5::boolean AND (email, id) > ($6::text, $7::int))
+      OR ($4::text = 'email' AND $5::boolean AND (email, id) < ($6::text, $7::int))
+      OR ($4::text = 'created_at' AND NOT $5::boolean AND (COALESCE(created_at, 'epoch'), id) > ($8::timestamp, $7::int))
+      OR ($4::text = 'created_at' AND $5::boolean AND (COALESCE(created_at, 'epoch'), id) < ($8::timestamp, $7::int))
+      OR ($4::text = 'id' AND NOT $5::boolean AND id > $7::int)
+      OR ($4::text = 'id' AND $5::boolean AND id < $7::int)
+  )
+ORDER BY
+    CASE WHEN $4::text = 'email' AND NOT $5::boolean THEN email END ASC,
+    CASE WHEN $4::text = 'email' AND $5::boolean THEN email END DESC,
+    CASE WHEN $4::text = 'created_at' AND NOT $5::boolean THEN COALESCE(created_at, 'epoch') END ASC,
+    CASE WHEN $4::text = 'created_at' AND $5::boolean THEN COALESCE(created_at, 'epoch') END DESC,
+    CASE WHEN NOT $5::boolean THEN id END ASC,
+    CASE WHEN $5::boolean THEN id END DESC
+LIMIT $9
 `
 
-func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.Query(ctx, getAllUsers)
+type GetAllUsersParams struct {
+	Type            pgtype.Text
+	IncludeDeleted  bool
+	HasCursor       bool
+	SortBy          string
+	SortDesc        bool
+	CursorEmail     string
+	CursorID        int32
+	CursorCreatedAt pgtype.Timestamp
+	MaxResults      int32
+}
+
+// One page of users, see GetAllPatients for how the cursor works. Sorting
+// by id needs no cursor value besides cursor_id.
+func (q *Queries) GetAllUsers(ctx context.Context, arg GetAllUsersParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, getAllUsers,
+		arg.Type,
+		arg.IncludeDeleted,
+		arg.HasCursor,
+		arg.SortBy,
+		arg.SortDesc,
+		arg.CursorEmail,
+		arg.CursorID,
+		arg.CursorCreatedAt,
+		arg.MaxResults,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -68,6 +116,8 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 			&i.Type,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.TokenVersion,
 		); err != nil {
 			return nil, err
 		}
@@ -80,8 +130,8 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, email, password, type, created_at, updated_at FROM users
-WHERE id = $1 LIMIT 1
+SELECT id, email, password, type, created_at, updated_at, deleted_at, token_version FROM users
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
 `
 
 func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
@@ -94,14 +144,16 @@ func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
 		&i.Type,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TokenVersion,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, password, type, created_at, updated_at
+SELECT id, email, password, type, created_at, updated_at, deleted_at, token_version
 FROM users
-WHERE email = $1
+WHERE email = $1 AND deleted_at IS NULL
 LIMIT 1
 `
 
@@ -115,6 +167,8 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Type,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TokenVersion,
 	)
 	return i, err
 }
@@ -140,11 +194,80 @@ func (q *Queries) GetUserById(ctx context.Context, id int32) (User, error) {
 	return i, err
 }
 
+const getUserWithDeleted = `-- name: GetUserWithDeleted :one
+SELECT id, email, password, type, created_at, updated_at, deleted_at, token_version FROM users
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserWithDeleted(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, getUserWithDeleted, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Password,
+		&i.Type,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TokenVersion,
+	)
+	return i, err
+}
+
+const incrementUserTokenVersion = `-- name: IncrementUserTokenVersion :one
+UPDATE users
+SET token_version = token_version + 1
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, email, password, type, created_at, updated_at, deleted_at, token_version
+`
+
+// Revokes every token issued to the user so far, they carry the version
+// they were issued with.
+func (q *Queries) IncrementUserTokenVersion(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, incrementUserTokenVersion, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Password,
+		&i.Type,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TokenVersion,
+	)
+	return i, err
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL
+WHERE id = $1
+RETURNING id, email, password, type, created_at, updated_at, deleted_at, token_version
+`
+
+func (q *Queries) RestoreUser(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, restoreUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Password,
+		&i.Type,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TokenVersion,
+	)
+	return i, err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE public.users
 SET email = $2 , password = $3, type = $4
-WHERE id = $1
-RETURNING id, email, password, type, created_at, updated_at
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, email, password, type, created_at, updated_at, deleted_at, token_version
 `
 
 type UpdateUserParams struct {
@@ -169,6 +292,8 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Type,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TokenVersion,
 	)
 	return i, err
 }
@@ -0,0 +1,419 @@
+package fieldcrypt
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// What a stored key is for. Data keys encrypt values and are replaced on
+// rotation, the single blind index key never is, so the indexes stay valid.
+const (
+	PurposeData       = "data"
+	PurposeBlindIndex = "blind_index"
+)
+
+// ciphertextPrefix starts every encrypted value, followed by the id of its
+// data key, a colon and the base64 nonce and sealed value. Values without it
+// were stored before encryption and are read as they are.
+const ciphertextPrefix = "enc1:"
+
+var (
+	ErrDecrypt = errors.New("value can't be decrypted")
+	// ErrUnknownKey is a value encrypted with a data key that isn't stored.
+	ErrUnknownKey = errors.New("value is encrypted with an unknown key")
+)
+
+// KeyStore keeps the wrapped keys, see EncryptionKeyRepository.
+type KeyStore interface {
+	GetKeys(ctx context.Context) ([]database.EncryptionKey, error)
+	CreateKey(ctx context.Context, purpose string, wrappedKey []byte, masterKeyId string) (database.EncryptionKey, error)
+	RewrapKey(ctx context.Context, id int32, wrappedKey []byte, masterKeyId string) error
+	// RetireDataKeys retires every data key but activeId, which new values
+	// are encrypted with from then on.
+	RetireDataKeys(ctx context.Context, activeId int32) error
+}
+
+// Keyring encrypts and decrypts values with the data keys of a KeyStore,
+// unwrapped with the configured master keys. The keys are loaded on first
+// use and reloaded every refresh interval, so a rotation made by another
+// process is picked up.
+type Keyring struct {
+	store   KeyStore
+	masters []MasterKey
+	refresh time.Duration
+
+	mu    sync.RWMutex
+	state *keyState
+}
+
+// keyState is the unwrapped keys as last loaded, never changed once built.
+type keyState struct {
+	dataKeys map[int32]cipher.AEAD
+	activeId int32
+	indexKey []byte
+	loadedAt time.Time
+}
+
+// DefaultRefreshInterval is how often keys are reloaded unless set with
+// WithRefreshInterval.
+const DefaultRefreshInterval = time.Minute
+
+type Option func(*Keyring)
+
+// WithRefreshInterval sets how often the keys are reloaded.
+func WithRefreshInterval(refresh time.Duration) Option {
+	return func(k *Keyring) {
+		k.refresh = refresh
+	}
+}
+
+// NewKeyring unwraps keys with masters, the first of which wraps new ones.
+func NewKeyring(store KeyStore, masters []MasterKey, opts ...Option) *Keyring {
+	k := &Keyring{
+		store:   store,
+		masters: masters,
+		refresh: DefaultRefreshInterval,
+	}
+
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	return k
+}
+
+// Load reads and unwraps the stored keys, first creating the blind index
+// key and a data key when there are none yet.
+func (k *Keyring) Load(ctx context.Context) error {
+	if len(k.masters) == 0 {
+		return errors.New("no master key")
+	}
+
+	keys, err := k.store.GetKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	created := false
+	if !hasKey(keys, PurposeBlindIndex, false) {
+		if err := k.createKey(ctx, PurposeBlindIndex); err != nil && !isDuplicateKey(err) {
+			return err
+		}
+		created = true
+	}
+	if !hasKey(keys, PurposeData, true) {
+		if err := k.createKey(ctx, PurposeData); err != nil {
+			return err
+		}
+		created = true
+	}
+
+	if created {
+		if keys, err = k.store.GetKeys(ctx); err != nil {
+			return err
+		}
+	}
+
+	state := &keyState{
+		dataKeys: make(map[int32]cipher.AEAD),
+		loadedAt: time.Now(),
+	}
+
+	for _, key := range keys {
+		raw, err := k.unwrap(key)
+		if err != nil {
+			return err
+		}
+
+		switch key.Purpose {
+		case PurposeBlindIndex:
+			state.indexKey = raw
+		case PurposeData:
+			if state.dataKeys[key.ID], err = newAEAD(raw); err != nil {
+				return err
+			}
+			// the newest key that isn't retired is the active one
+			if !key.RetiredAt.Valid && key.ID > state.activeId {
+				state.activeId = key.ID
+			}
+		}
+	}
+
+	k.mu.Lock()
+	k.state = state
+	k.mu.Unlock()
+
+	return nil
+}
+
+func hasKey(keys []database.EncryptionKey, purpose string, active bool) bool {
+	for _, key := range keys {
+		if key.Purpose == purpose && (!active || !key.RetiredAt.Valid) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDuplicateKey is another process creating the blind index key at the
+// same time, there can only be one.
+func isDuplicateKey(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func (k *Keyring) createKey(ctx context.Context, purpose string) error {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+
+	wrapped, err := k.masters[0].wrap(purpose, raw)
+	if err != nil {
+		return err
+	}
+
+	_, err = k.store.CreateKey(ctx, purpose, wrapped, k.masters[0].ID())
+	return err
+}
+
+func (k *Keyring) master(id string) (MasterKey, bool) {
+	for _, m := range k.masters {
+		if m.ID() == id {
+			return m, true
+		}
+	}
+	return MasterKey{}, false
+}
+
+func (k *Keyring) unwrap(key database.EncryptionKey) ([]byte, error) {
+	m, ok := k.master(key.MasterKeyID)
+	if !ok {
+		return nil, fmt.Errorf("%s key %d is wrapped with master key %s, which is not configured", key.Purpose, key.ID, key.MasterKeyID)
+	}
+
+	raw, err := m.unwrap(key.Purpose, key.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s key %d can't be unwrapped with master key %s", key.Purpose, key.ID, key.MasterKeyID)
+	}
+
+	return raw, nil
+}
+
+// current returns the loaded keys, reloading them when they are older than
+// the refresh interval or when force is set. A failed refresh keeps using
+// the keys loaded before.
+func (k *Keyring) current(ctx context.Context, force bool) (*keyState, error) {
+	k.mu.RLock()
+	state := k.state
+	k.mu.RUnlock()
+
+	if state != nil && !force && time.Since(state.loadedAt) < k.refresh {
+		return state, nil
+	}
+
+	if err := k.Load(ctx); err != nil {
+		if state != nil && !force {
+			fmt.Println("encryption keys:", err)
+			return state, nil
+		}
+		return nil, err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.state, nil
+}
+
+// Encrypt seals plaintext with the active data key. field, the column the
+// value is stored in, is authenticated with it so that a value can't be
+// moved to another column.
+func (k *Keyring) Encrypt(ctx context.Context, field string, plaintext string) (string, error) {
+	state, err := k.current(ctx, false)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := seal(state.dataKeys[state.activeId], []byte(plaintext), []byte(field))
+	if err != nil {
+		return "", err
+	}
+
+	return ciphertextPrefix + strconv.FormatInt(int64(state.activeId), 10) + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value from Encrypt for the same field. Values that aren't
+// encrypted are returned as they are.
+func (k *Keyring) Decrypt(ctx context.Context, field string, value string) (string, error) {
+	keyId, sealed, ok, err := parseCiphertext(value)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return value, nil
+	}
+
+	state, err := k.current(ctx, false)
+	if err != nil {
+		return "", err
+	}
+
+	aead, known := state.dataKeys[keyId]
+	if !known {
+		// encrypted with a key created since the keys were loaded
+		if state, err = k.current(ctx, true); err != nil {
+			return "", err
+		}
+		if aead, known = state.dataKeys[keyId]; !known {
+			return "", ErrUnknownKey
+		}
+	}
+
+	plaintext, err := open(aead, sealed, []byte(field))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// IsCurrent reports whether value is encrypted with the active data key.
+func (k *Keyring) IsCurrent(ctx context.Context, value string) (bool, error) {
+	keyId, _, ok, err := parseCiphertext(value)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	state, err := k.current(ctx, false)
+	if err != nil {
+		return false, err
+	}
+
+	return keyId == state.activeId, nil
+}
+
+// BlindIndex returns the HMAC-SHA256 of value for field. Callers normalize
+// value first, e.g. lowercase an email, so that equal values match.
+func (k *Keyring) BlindIndex(ctx context.Context, field string, value string) ([]byte, error) {
+	state, err := k.current(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, state.indexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+
+	return mac.Sum(nil), nil
+}
+
+// parseCiphertext splits an encrypted value, ok is false when value isn't
+// one.
+func parseCiphertext(value string) (keyId int32, sealed []byte, ok bool, err error) {
+	rest, found := strings.CutPrefix(value, ciphertextPrefix)
+	if !found {
+		return 0, nil, false, nil
+	}
+
+	id, encoded, found := strings.Cut(rest, ":")
+	if !found {
+		return 0, nil, false, ErrDecrypt
+	}
+
+	n, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return 0, nil, false, ErrDecrypt
+	}
+
+	sealed, err = base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, nil, false, ErrDecrypt
+	}
+
+	return int32(n), sealed, true, nil
+}
+
+// Rotation is what Rotate did.
+type Rotation struct {
+	// Rewrapped is how many keys were wrapped with the current master key
+	// instead of an older one.
+	Rewrapped int
+	// DataKeyID is the new active data key.
+	DataKeyID int32
+}
+
+// Rotate wraps every key with the current master key and makes a new data
+// key active. Values encrypted with the older data keys can still be
+// decrypted, re-encrypt them to move them to the new one. Once no key is
+// wrapped with an older master key, that master key can be removed.
+func (k *Keyring) Rotate(ctx context.Context) (Rotation, error) {
+	var rotation Rotation
+
+	// make sure the blind index key exists before it is rewrapped
+	if err := k.Load(ctx); err != nil {
+		return rotation, err
+	}
+
+	keys, err := k.store.GetKeys(ctx)
+	if err != nil {
+		return rotation, err
+	}
+
+	current := k.masters[0]
+	for _, key := range keys {
+		if key.MasterKeyID == current.ID() {
+			continue
+		}
+
+		raw, err := k.unwrap(key)
+		if err != nil {
+			return rotation, err
+		}
+
+		wrapped, err := current.wrap(key.Purpose, raw)
+		if err != nil {
+			return rotation, err
+		}
+
+		if err := k.store.RewrapKey(ctx, key.ID, wrapped, current.ID()); err != nil {
+			return rotation, err
+		}
+		rotation.Rewrapped++
+	}
+
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return rotation, err
+	}
+
+	wrapped, err := current.wrap(PurposeData, raw)
+	if err != nil {
+		return rotation, err
+	}
+
+	key, err := k.store.CreateKey(ctx, PurposeData, wrapped, current.ID())
+	if err != nil {
+		return rotation, err
+	}
+	rotation.DataKeyID = key.ID
+
+	if err := k.store.RetireDataKeys(ctx, key.ID); err != nil {
+		return rotation, err
+	}
+
+	return rotation, k.Load(ctx)
+}
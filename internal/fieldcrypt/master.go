@@ -0,0 +1,135 @@
+// Package fieldcrypt encrypts single column values with envelope
+// encryption. Values are sealed with AES-256-GCM under a data key, data keys
+// are stored in the database wrapped by a master key that never is. Blind
+// indexes, keyed HMACs of normalized values, let encrypted columns still be
+// looked up by equality.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const keySize = 32
+
+// MasterKey wraps and unwraps data keys. It is identified by a hash of the
+// key, so a wrapped data key records which master key it needs.
+type MasterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func NewMasterKey(key []byte) (MasterKey, error) {
+	if len(key) != keySize {
+		return MasterKey{}, fmt.Errorf("master key is %d bytes, not %d", len(key), keySize)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return MasterKey{}, err
+	}
+
+	sum := sha256.Sum256(key)
+
+	return MasterKey{
+		id:   hex.EncodeToString(sum[:8]),
+		aead: aead,
+	}, nil
+}
+
+func (m MasterKey) ID() string {
+	return m.id
+}
+
+// ParseMasterKeys reads base64 master keys separated by commas or
+// whitespace. The first is the current one, the others are only kept to
+// unwrap data keys until they are rotated.
+func ParseMasterKeys(s string) ([]MasterKey, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if len(fields) == 0 {
+		return nil, errors.New("no master key")
+	}
+
+	keys := make([]MasterKey, len(fields))
+	for i, field := range fields {
+		b, err := base64.StdEncoding.DecodeString(field)
+		if err != nil {
+			return nil, fmt.Errorf("master key %d is not base64", i+1)
+		}
+		if keys[i], err = NewMasterKey(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// ReadMasterKeyFile reads master keys from a file, one base64 key per line
+// with the current one first. Lines starting with # are ignored.
+func ReadMasterKeyFile(path string) ([]MasterKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	return ParseMasterKeys(strings.Join(lines, "\n"))
+}
+
+// wrap seals a data key, binding it to its purpose.
+func (m MasterKey) wrap(purpose string, key []byte) ([]byte, error) {
+	return seal(m.aead, key, []byte(purpose))
+}
+
+func (m MasterKey) unwrap(purpose string, wrapped []byte) ([]byte, error) {
+	return open(m.aead, wrapped, []byte(purpose))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns a random nonce followed by the sealed plaintext.
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,180 @@
+// Package ical writes iCalendar (RFC 5545) calendars of events, for
+// calendar feeds and for invites sent by email (RFC 5546).
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType is the media type of a calendar.
+const ContentType = "text/calendar; charset=utf-8"
+
+const prodID = "-//patient-appointment-demo//Appointments//EN"
+
+// Method is the iTIP method of a calendar: PUBLISH for feeds, REQUEST or
+// CANCEL for invites.
+type Method string
+
+const (
+	MethodPublish Method = "PUBLISH"
+	MethodRequest Method = "REQUEST"
+	MethodCancel  Method = "CANCEL"
+)
+
+type Status string
+
+const (
+	StatusConfirmed Status = "CONFIRMED"
+	StatusCancelled Status = "CANCELLED"
+)
+
+type Calendar struct {
+	Method Method
+	// Name is shown by calendar apps that subscribe to the feed.
+	Name   string
+	Events []Event
+}
+
+// Event is a VEVENT. Times are written in UTC.
+type Event struct {
+	// UID stays the same for every version of the event.
+	UID string
+	// Sequence must grow whenever the event is changed or cancelled, or
+	// calendars keep the version they already have.
+	Sequence    int32
+	Stamp       time.Time
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Status      Status
+	// Organizer and Attendees are email addresses.
+	Organizer string
+	Attendees []Attendee
+}
+
+type Attendee struct {
+	Name  string
+	Email string
+}
+
+// Encode writes the calendar with CRLF line endings and long lines folded.
+func (c Calendar) Encode(w io.Writer) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", prodID)
+	e.line("CALSCALE", "GREGORIAN")
+	if c.Method != "" {
+		e.line("METHOD", string(c.Method))
+	}
+	if c.Name != "" {
+		e.line("X-WR-CALNAME", Escape(c.Name))
+	}
+
+	for _, event := range c.Events {
+		e.event(event)
+	}
+
+	e.line("END", "VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// Escape escapes a TEXT value.
+func Escape(s string) string {
+	return textEscaper.Replace(s)
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// quote makes a parameter value safe, parameters can't be escaped.
+func quote(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '"' || r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+	return `"` + s + `"`
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) event(event Event) {
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", Escape(event.UID))
+	e.line("SEQUENCE", strconv.Itoa(int(event.Sequence)))
+	e.line("DTSTAMP", formatTime(event.Stamp))
+	e.line("DTSTART", formatTime(event.Start))
+	e.line("DTEND", formatTime(event.End))
+	e.line("SUMMARY", Escape(event.Summary))
+	if event.Description != "" {
+		e.line("DESCRIPTION", Escape(event.Description))
+	}
+	if event.Status != "" {
+		e.line("STATUS", string(event.Status))
+	}
+	if event.Organizer != "" {
+		e.line("ORGANIZER", "mailto:"+event.Organizer)
+	}
+	for _, attendee := range event.Attendees {
+		name := "ATTENDEE;ROLE=REQ-PARTICIPANT"
+		if attendee.Name != "" {
+			name += ";CN=" + quote(attendee.Name)
+		}
+		e.line(name, "mailto:"+attendee.Email)
+	}
+	e.line("END", "VEVENT")
+}
+
+// line writes a content line, folded so no line is longer than 75 octets
+// and no UTF-8 sequence is split.
+func (e *encoder) line(name string, value string) {
+	if e.err != nil {
+		return
+	}
+
+	s := name + ":" + value
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		e.write(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// the leading space of a continuation line counts too
+		limit = 74
+	}
+	e.write(s + "\r\n")
+}
+
+func (e *encoder) write(s string) {
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
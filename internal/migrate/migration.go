@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is one goose annotated schema file.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	// NoTransaction is set by "-- +goose NO TRANSACTION", for statements
+	// such as CREATE INDEX CONCURRENTLY that cannot run inside one.
+	NoTransaction bool
+}
+
+// Load reads every *.sql file of dir in fsys, ordered by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := make(map[int64]string)
+
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := Parse(entry.Name(), string(content))
+		if err != nil {
+			return nil, err
+		}
+
+		if other, ok := seen[m.Version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), m.Version)
+		}
+		seen[m.Version] = entry.Name()
+
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Parse splits a file named like "004_appointment.sql" into its up and down
+// sections.
+func Parse(fileName string, content string) (Migration, error) {
+	base := strings.TrimSuffix(path.Base(fileName), ".sql")
+	versionStr, name, ok := strings.Cut(base, "_")
+	if !ok {
+		return Migration{}, fmt.Errorf("migration %s: file name must look like 001_name.sql", fileName)
+	}
+
+	version, err := strconv.ParseInt(versionStr, 10, 64)
+	if err != nil {
+		return Migration{}, fmt.Errorf("migration %s: invalid version %q", fileName, versionStr)
+	}
+
+	m := Migration{
+		Version: version,
+		Name:    name,
+	}
+
+	var up, down strings.Builder
+	var current *strings.Builder
+	seenUp := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		annotation, isAnnotation := strings.CutPrefix(strings.TrimSpace(line), "-- +goose ")
+
+		if isAnnotation {
+			switch strings.TrimSpace(annotation) {
+			case "Up":
+				// several files repeat the Up marker, keep appending
+				current = &up
+				seenUp = true
+			case "Down":
+				current = &down
+			case "NO TRANSACTION":
+				m.NoTransaction = true
+			}
+			continue
+		}
+
+		if current != nil {
+			current.WriteString(line)
+			current.WriteString("\n")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Migration{}, fmt.Errorf("migration %s: %w", fileName, err)
+	}
+
+	if !seenUp {
+		return Migration{}, fmt.Errorf("migration %s: missing -- +goose Up section", fileName)
+	}
+
+	m.Up = strings.TrimSpace(up.String())
+	m.Down = strings.TrimSpace(down.String())
+
+	return m, nil
+}
@@ -0,0 +1,285 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const versionTable = "schema_migrations"
+
+// lockKey is the advisory lock taken while a migration is applied, so that
+// two instances starting at once do not race each other.
+const lockKey = 7_246_031_113
+
+var ErrNoMigrationApplied = errors.New("no migration has been applied")
+
+type DB interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	BeginTx(ctx context.Context, options pgx.TxOptions) (pgx.Tx, error)
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// ErrSchemaBehind is returned by Check when migrations are pending.
+type ErrSchemaBehind struct {
+	Pending []Migration
+}
+
+func (e ErrSchemaBehind) Error() string {
+	names := make([]string, len(e.Pending))
+	for i, m := range e.Pending {
+		names[i] = fmt.Sprintf("%03d_%s", m.Version, m.Name)
+	}
+	return fmt.Sprintf("database schema is behind, pending migrations: %s", strings.Join(names, ", "))
+}
+
+type Migrator struct {
+	db         DB
+	migrations []Migration
+}
+
+func New(db DB, migrations []Migration) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return nil, err
+	}
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range pending {
+		ran, err := m.apply(ctx, migration, true)
+		if err != nil {
+			return applied, err
+		}
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return Migration{}, err
+	}
+
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		if _, err := m.apply(ctx, migration, false); err != nil {
+			return Migration{}, err
+		}
+		return migration, nil
+	}
+
+	return Migration{}, ErrNoMigrationApplied
+}
+
+// Redo rolls back the most recently applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) (Migration, error) {
+	migration, err := m.Down(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	if _, err := m.apply(ctx, migration, true); err != nil {
+		return Migration{}, err
+	}
+
+	return migration, nil
+}
+
+// Baseline records every migration up to and including version as applied
+// without running it, for databases whose schema was created by hand.
+func (m *Migrator) Baseline(ctx context.Context, version int64) ([]Migration, error) {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var marked []Migration
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
+		}
+
+		tag, err := m.db.Exec(ctx,
+			"INSERT INTO "+versionTable+" (version, name) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING",
+			migration.Version, migration.Name,
+		)
+		if err != nil {
+			return marked, err
+		}
+		if tag.RowsAffected() > 0 {
+			marked = append(marked, migration)
+		}
+	}
+
+	return marked, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = MigrationStatus{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Check returns ErrSchemaBehind when the database is missing migrations
+// embedded in the binary.
+func (m *Migrator) Check(ctx context.Context) error {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return err
+	}
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		return ErrSchemaBehind{Pending: pending}
+	}
+
+	return nil
+}
+
+func (m *Migrator) ensureVersionTable(ctx context.Context) error {
+	_, err := m.db.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+versionTable+` (
+    version BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`)
+	return err
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := m.db.Query(ctx, "SELECT version, applied_at FROM "+versionTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// apply runs one direction of a migration and updates the version table. It
+// reports false when another instance got there first.
+func (m *Migrator) apply(ctx context.Context, migration Migration, up bool) (bool, error) {
+	sql := migration.Down
+	if up {
+		sql = migration.Up
+	}
+
+	tx, err := m.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", lockKey); err != nil {
+		return false, err
+	}
+
+	var isApplied bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM "+versionTable+" WHERE version = $1)", migration.Version).Scan(&isApplied)
+	if err != nil {
+		return false, err
+	}
+	if isApplied == up {
+		return false, nil
+	}
+
+	if sql != "" {
+		if migration.NoTransaction {
+			_, err = m.db.Exec(ctx, sql)
+		} else {
+			_, err = tx.Exec(ctx, sql)
+		}
+		if err != nil {
+			return false, fmt.Errorf("migration %03d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	if up {
+		_, err = tx.Exec(ctx, "INSERT INTO "+versionTable+" (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+	} else {
+		_, err = tx.Exec(ctx, "DELETE FROM "+versionTable+" WHERE version = $1", migration.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
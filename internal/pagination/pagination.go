@@ -0,0 +1,109 @@
+// Package pagination pages through lists with opaque keyset cursors. A
+// cursor holds the sort key and id of the last item of a page, so the next
+// page starts right after it however many rows were added or removed in
+// between.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Params asks for one page of a list.
+type Params struct {
+	Limit int32
+	// Cursor is the NextCursor of the previous page, empty for the first.
+	Cursor string
+	SortBy string
+	Desc   bool
+}
+
+// Cursor is what an encoded cursor holds. Key is the sort key of the last
+// item as text, ID breaks ties between equal keys.
+type Cursor struct {
+	SortBy string `json:"s"`
+	Desc   bool   `json:"d"`
+	Key    string `json:"k,omitempty"`
+	ID     int32  `json:"i"`
+}
+
+// Page is one page of a list. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
+func (p Params) limit() int32 {
+	if p.Limit <= 0 {
+		return DefaultLimit
+	}
+	if p.Limit > MaxLimit {
+		return MaxLimit
+	}
+	return p.Limit
+}
+
+// FetchLimit is how many rows to query: one more than the page holds, to
+// tell whether there is a next page.
+func (p Params) FetchLimit() int32 {
+	return p.limit() + 1
+}
+
+// DecodeCursor returns the cursor to continue from, nil for the first
+// page. A cursor made for another sort order is invalid.
+func (p Params) DecodeCursor() (*Cursor, error) {
+	if p.Cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if c.SortBy != p.SortBy || c.Desc != p.Desc {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// NewPage trims rows queried with FetchLimit to the page and, when there
+// are more, makes the cursor from the last item's key and id.
+func NewPage[T any](rows []T, p Params, key func(T) (string, int32)) Page[T] {
+	limit := int(p.limit())
+	if len(rows) <= limit {
+		return Page[T]{Items: rows}
+	}
+
+	rows = rows[:limit]
+	k, id := key(rows[limit-1])
+
+	return Page[T]{
+		Items: rows,
+		NextCursor: Cursor{
+			SortBy: p.SortBy,
+			Desc:   p.Desc,
+			Key:    k,
+			ID:     id,
+		}.Encode(),
+	}
+}
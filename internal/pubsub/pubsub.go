@@ -0,0 +1,120 @@
+// Package pubsub fans messages out to the subscribers of a topic. Memory
+// keeps everything inside the process; an implementation backed by Postgres
+// LISTEN/NOTIFY can take its place wherever a PubSub is expected.
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// subscriberBuffer is how many messages a subscriber may fall behind
+// before it starts missing them.
+const subscriberBuffer = 16
+
+var ErrClosed = errors.New("pubsub is closed")
+
+type PubSub interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe delivers the payloads published on topic from now on. The
+	// channel is closed once ctx is done or the PubSub is closed.
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+}
+
+type Memory struct {
+	mu     sync.Mutex
+	topics map[string]map[chan []byte]struct{}
+	closed bool
+	done   chan struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		topics: make(map[string]map[chan []byte]struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Publish never blocks: a subscriber whose buffer is full misses the
+// message.
+func (m *Memory) Publish(ctx context.Context, topic string, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	for ch := range m.topics[topic] {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	ch := make(chan []byte, subscriberBuffer)
+	if m.topics[topic] == nil {
+		m.topics[topic] = make(map[chan []byte]struct{})
+	}
+	m.topics[topic][ch] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.unsubscribe(topic, ch)
+		case <-m.done:
+		}
+	}()
+
+	return ch, nil
+}
+
+// Close ends every subscription.
+func (m *Memory) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return
+	}
+	m.closed = true
+	close(m.done)
+
+	for _, subscribers := range m.topics {
+		for ch := range subscribers {
+			close(ch)
+		}
+	}
+	m.topics = nil
+}
+
+func (m *Memory) unsubscribe(topic string, ch chan []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscribers, ok := m.topics[topic]
+	if !ok {
+		return
+	}
+	if _, ok := subscribers[ch]; !ok {
+		return
+	}
+
+	delete(subscribers, ch)
+	close(ch)
+	if len(subscribers) == 0 {
+		delete(m.topics, topic)
+	}
+}
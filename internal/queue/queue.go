@@ -0,0 +1,148 @@
+// Package queue builds the waiting room view of a day, the token being
+// served and the ones up next, and carries the changes to it over a
+// pubsub.PubSub. The appointment sequence is the token number.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pubsub"
+	"patient-appointment-demo-go/internal/repositories"
+	"sort"
+	"time"
+)
+
+type Action string
+
+const (
+	ActionCreated       Action = "created"
+	ActionStatusChanged Action = "status_changed"
+	ActionRescheduled   Action = "rescheduled"
+	ActionDeleted       Action = "deleted"
+)
+
+// Change is what is published on a day's topic whenever one of its
+// appointments changes.
+type Change struct {
+	Action        Action `json:"action"`
+	AppointmentID int32  `json:"appointment_id"`
+	Token         int16  `json:"token"`
+	Status        string `json:"status"`
+}
+
+type Token struct {
+	AppointmentID int32
+	Number        int16
+	Status        string
+	VisitTime     time.Time
+}
+
+type Snapshot struct {
+	Date    time.Time
+	Current *Token
+	Next    []Token
+}
+
+// Topic is the pubsub topic the changes of date's appointments go to.
+func Topic(date time.Time) string {
+	return "queue." + date.Format("2006-01-02")
+}
+
+// Build works out the queue from the day's appointments. The current token
+// is the lowest one in consultation. Next lists up to size tokens still
+// waiting, the checked in patients first and then the ones not arrived yet,
+// each in token order.
+func Build(date time.Time, appointments []database.Appointment, size int) Snapshot {
+	snapshot := Snapshot{Date: date, Next: []Token{}}
+
+	var checkedIn, scheduled []Token
+	for _, a := range appointments {
+		token := Token{
+			AppointmentID: a.ID,
+			Number:        a.AppointmentSequence,
+			Status:        a.Status,
+			VisitTime:     a.VisitTimestamp.Time,
+		}
+
+		switch repositories.AppointmentStatus(a.Status) {
+		case repositories.AppointmentInConsultation:
+			if snapshot.Current == nil || token.Number < snapshot.Current.Number {
+				snapshot.Current = &token
+			}
+		case repositories.AppointmentCheckedIn:
+			checkedIn = append(checkedIn, token)
+		case repositories.AppointmentScheduled:
+			scheduled = append(scheduled, token)
+		}
+	}
+
+	byNumber := func(tokens []Token) {
+		sort.Slice(tokens, func(i, j int) bool { return tokens[i].Number < tokens[j].Number })
+	}
+	byNumber(checkedIn)
+	byNumber(scheduled)
+
+	for _, token := range append(checkedIn, scheduled...) {
+		if len(snapshot.Next) == size {
+			break
+		}
+		snapshot.Next = append(snapshot.Next, token)
+	}
+
+	return snapshot
+}
+
+// Feed publishes and subscribes to queue changes over a pubsub.PubSub.
+type Feed struct {
+	events pubsub.PubSub
+}
+
+func NewFeed(events pubsub.PubSub) *Feed {
+	return &Feed{
+		events: events,
+	}
+}
+
+// Publish announces a change to the appointment on the topic of its visit
+// date.
+func (f *Feed) Publish(ctx context.Context, action Action, appointment database.Appointment) error {
+	payload, err := json.Marshal(Change{
+		Action:        action,
+		AppointmentID: appointment.ID,
+		Token:         appointment.AppointmentSequence,
+		Status:        appointment.Status,
+	})
+	if err != nil {
+		return err
+	}
+
+	return f.events.Publish(ctx, Topic(appointment.VisitDate.Time), payload)
+}
+
+// Subscribe returns the changes to date's queue until ctx is done.
+func (f *Feed) Subscribe(ctx context.Context, date time.Time) (<-chan Change, error) {
+	payloads, err := f.events.Subscribe(ctx, Topic(date))
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan Change)
+	go func() {
+		defer close(changes)
+		for payload := range payloads {
+			var change Change
+			if err := json.Unmarshal(payload, &change); err != nil {
+				continue
+			}
+
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
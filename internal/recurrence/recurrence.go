@@ -0,0 +1,209 @@
+// Package recurrence expands RRULE style rules into the start times of the
+// individual appointments of a series.
+package recurrence
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily  Frequency = "daily"
+	Weekly Frequency = "weekly"
+)
+
+// MaxOccurrences caps how many appointments a single series may create.
+const MaxOccurrences = 200
+
+var (
+	ErrInvalidRule        = errors.New("invalid recurrence rule")
+	ErrTooManyOccurrences = errors.New("recurrence produces too many occurrences")
+	errMissingEnd         = errors.New("recurrence needs either a count or an until date")
+	errUnsupportedFreq    = errors.New("unsupported recurrence frequency")
+	errNegativeInterval   = errors.New("recurrence interval must be positive")
+	errCountAndUntil      = errors.New("recurrence cannot have both a count and an until date")
+	errUntilBeforeStart   = errors.New("recurrence until date is before the start")
+)
+
+// Rule mirrors the RRULE parts FREQ, INTERVAL, COUNT, UNTIL and BYDAY. With
+// Daily, Weekdays filters the days; with Weekly, it lists the days of every
+// week the rule fires on and defaults to the start's weekday.
+type Rule struct {
+	Frequency Frequency
+	// Interval is every how many days or weeks, 0 means 1.
+	Interval int
+	Count    int
+	// Until is the last date, inclusive, an occurrence may fall on.
+	Until    *time.Time
+	Weekdays []time.Weekday
+}
+
+func (r Rule) Validate() error {
+	var err error
+
+	switch {
+	case r.Frequency != Daily && r.Frequency != Weekly:
+		err = errUnsupportedFreq
+	case r.Interval < 0:
+		err = errNegativeInterval
+	case r.Count > 0 && r.Until != nil:
+		err = errCountAndUntil
+	case r.Count <= 0 && r.Until == nil:
+		err = errMissingEnd
+	case r.Count > MaxOccurrences:
+		err = ErrTooManyOccurrences
+	}
+
+	if err != nil {
+		return errors.Join(ErrInvalidRule, err)
+	}
+	return nil
+}
+
+// Occurrences returns the start of every occurrence, the first being start
+// itself when it matches the rule. Wall clock times are kept in loc, so a
+// 09:00 visit stays at 09:00 across daylight saving changes.
+func (r Rule) Occurrences(start time.Time, loc *time.Location) ([]time.Time, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := start.In(loc)
+
+	if r.Until != nil && dateBefore(*r.Until, local) {
+		return nil, errors.Join(ErrInvalidRule, errUntilBeforeStart)
+	}
+
+	interval := r.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	days := r.candidateDays(local, interval)
+
+	var occurrences []time.Time
+	for {
+		day, ok := days()
+		if !ok {
+			break
+		}
+
+		t := time.Date(day.Year(), day.Month(), day.Day(), local.Hour(), local.Minute(), local.Second(), 0, loc)
+		if t.Before(start) {
+			continue
+		}
+
+		if r.Until != nil && dateBefore(*r.Until, t) {
+			break
+		}
+
+		if len(occurrences) == MaxOccurrences {
+			return nil, ErrTooManyOccurrences
+		}
+
+		occurrences = append(occurrences, t)
+
+		if r.Count > 0 && len(occurrences) == r.Count {
+			break
+		}
+	}
+
+	return occurrences, nil
+}
+
+// candidateDays returns an iterator over the days the rule may fire on, in
+// order, starting from the start's day or week.
+func (r Rule) candidateDays(local time.Time, interval int) func() (time.Time, bool) {
+	weekdays := normalizeWeekdays(r.Weekdays)
+
+	if r.Frequency == Daily {
+		i := 0
+		return func() (time.Time, bool) {
+			// the weekdays repeat after at most 7 steps, if none of those
+			// match the interval never lands on an allowed day
+			for tries := 0; tries < 7; tries++ {
+				day := time.Date(local.Year(), local.Month(), local.Day()+i*interval, 0, 0, 0, 0, local.Location())
+				i++
+				if len(weekdays) == 0 || containsWeekday(weekdays, day.Weekday()) {
+					return day, true
+				}
+			}
+			return time.Time{}, false
+		}
+	}
+
+	if len(weekdays) == 0 {
+		weekdays = []time.Weekday{local.Weekday()}
+	}
+
+	// weeks start on Sunday, like time.Weekday
+	weekStart := time.Date(local.Year(), local.Month(), local.Day()-int(local.Weekday()), 0, 0, 0, 0, local.Location())
+	week, i := 0, 0
+	return func() (time.Time, bool) {
+		if i == len(weekdays) {
+			week += interval
+			i = 0
+		}
+		day := weekStart.AddDate(0, 0, week*7+int(weekdays[i]))
+		i++
+		return day, true
+	}
+}
+
+// Shift moves t by as many calendar days as from is to to and puts it on
+// to's wall clock time, all in loc. It is how the later occurrences follow
+// when one occurrence of a series is moved.
+func Shift(t time.Time, from time.Time, to time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	t, from, to = t.In(loc), from.In(loc), to.In(loc)
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(toDay.Sub(fromDay).Hours() / 24)
+
+	return time.Date(t.Year(), t.Month(), t.Day()+days, to.Hour(), to.Minute(), to.Second(), 0, loc)
+}
+
+func normalizeWeekdays(weekdays []time.Weekday) []time.Weekday {
+	seen := make(map[time.Weekday]bool, len(weekdays))
+	var result []time.Weekday
+	for _, wd := range weekdays {
+		if !seen[wd] {
+			seen[wd] = true
+			result = append(result, wd)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func containsWeekday(weekdays []time.Weekday, wd time.Weekday) bool {
+	for _, w := range weekdays {
+		if w == wd {
+			return true
+		}
+	}
+	return false
+}
+
+// dateBefore compares the calendar dates of a and b, each in its own
+// location.
+func dateBefore(a time.Time, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	if ay != by {
+		return ay < by
+	}
+	if am != bm {
+		return am < bm
+	}
+	return ad < bd
+}
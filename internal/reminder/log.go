@@ -0,0 +1,57 @@
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogNotifier writes each reminder as a JSON line instead of sending it,
+// for development and tests. Point it at a file or os.Stdout.
+type LogNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type logLine struct {
+	Channel       string    `json:"channel"`
+	AppointmentID int32     `json:"appointment_id"`
+	Email         string    `json:"email"`
+	Phone         string    `json:"phone"`
+	VisitTime     time.Time `json:"visit_time"`
+	Offset        string    `json:"offset"`
+	Subject       string    `json:"subject"`
+	Text          string    `json:"text"`
+}
+
+func NewLogNotifier(w io.Writer) *LogNotifier {
+	return &LogNotifier{w: w}
+}
+
+func (n *LogNotifier) Channel() string {
+	return "log"
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, reminder Reminder) error {
+	line, err := json.Marshal(logLine{
+		Channel:       n.Channel(),
+		AppointmentID: reminder.AppointmentID,
+		Email:         reminder.Email,
+		Phone:         reminder.Phone,
+		VisitTime:     reminder.VisitTime,
+		Offset:        reminder.Offset.String(),
+		Subject:       reminder.Subject(),
+		Text:          reminder.Text(),
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, err = n.w.Write(append(line, '\n'))
+	return err
+}
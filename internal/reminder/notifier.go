@@ -0,0 +1,11 @@
+package reminder
+
+import "context"
+
+// Notifier delivers a reminder on one channel.
+type Notifier interface {
+	// Channel names the notifier in the sent reminder records. Changing it
+	// makes every reminder due again on the renamed channel.
+	Channel() string
+	Notify(ctx context.Context, reminder Reminder) error
+}
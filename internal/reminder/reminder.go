@@ -0,0 +1,42 @@
+// Package reminder reminds patients of their appointments ahead of the
+// visit, through any number of notifiers such as email and SMS.
+package reminder
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoAddress is returned by a notifier that has no way to reach the
+// patient, e.g. SMS for a patient without a phone number. The reminder is
+// recorded as skipped rather than retried.
+var ErrNoAddress = errors.New("patient has no address for this channel")
+
+// Reminder is one appointment to remind a patient of.
+type Reminder struct {
+	AppointmentID int32
+	Token         int16
+	PatientName   string
+	Email         string
+	Phone         string
+	// VisitTime is in the doctor's timezone.
+	VisitTime time.Time
+	// Offset is how long before the visit this reminder is due.
+	Offset time.Duration
+}
+
+func (r Reminder) Subject() string {
+	return "Appointment reminder"
+}
+
+// Text is the message body, short enough for a single SMS.
+func (r Reminder) Text() string {
+	return fmt.Sprintf(
+		"Hi %s, this is a reminder of your appointment on %s at %s, token %d.",
+		r.PatientName,
+		r.VisitTime.Format("Mon 2 Jan"),
+		r.VisitTime.Format("15:04"),
+		r.Token,
+	)
+}
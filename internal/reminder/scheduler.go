@@ -0,0 +1,148 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+// Store is what the scheduler needs from the reminder repository.
+type Store interface {
+	GetDue(ctx context.Context, offset time.Duration, channel string, limit int32) ([]database.GetDueRemindersRow, error)
+	Claim(ctx context.Context, appointmentId int32, offset time.Duration, channel string, visitTimestamp time.Time) (database.AppointmentReminder, bool, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string) error
+	MarkSkipped(ctx context.Context, id int64, reason string) error
+}
+
+// Scheduler sends every notifier's reminder at every offset before a visit.
+// Each reminder is claimed in the store before it is sent, so a restart, or
+// a second instance, never sends it twice. A reminder that failed is tried
+// again on later runs until the store gives up on it.
+type Scheduler struct {
+	store       Store
+	offsets     []time.Duration
+	notifiers   []Notifier
+	interval    time.Duration
+	batchSize   int32
+	sendTimeout time.Duration
+}
+
+type Option func(*Scheduler)
+
+// WithInterval sets how often Run looks for due reminders.
+func WithInterval(interval time.Duration) Option {
+	return func(s *Scheduler) {
+		s.interval = interval
+	}
+}
+
+func NewScheduler(store Store, offsets []time.Duration, notifiers []Notifier, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		store:       store,
+		offsets:     offsets,
+		notifiers:   notifiers,
+		interval:    time.Minute,
+		batchSize:   100,
+		sendTimeout: 30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Run sends due reminders every interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println("reminders:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends a batch of due reminders for each offset and notifier.
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	var errs []error
+
+	for _, offset := range s.offsets {
+		for _, notifier := range s.notifiers {
+			if err := s.send(ctx, offset, notifier); err != nil {
+				errs = append(errs, fmt.Errorf("%s %s: %w", notifier.Channel(), offset, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Scheduler) send(ctx context.Context, offset time.Duration, notifier Notifier) error {
+	rows, err := s.store.GetDue(ctx, offset, notifier.Channel(), s.batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		reminder := FromRow(row, offset)
+
+		claimed, ok, err := s.store.Claim(ctx, row.AppointmentID, offset, notifier.Channel(), row.VisitTimestamp.Time)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, s.sendTimeout)
+		err = notifier.Notify(sendCtx, reminder)
+		cancel()
+
+		switch {
+		case err == nil:
+			err = s.store.MarkSent(ctx, claimed.ID)
+		case errors.Is(err, ErrNoAddress):
+			err = s.store.MarkSkipped(ctx, claimed.ID, err.Error())
+		default:
+			fmt.Printf("reminders: appointment %d: %v\n", row.AppointmentID, err)
+			err = s.store.MarkFailed(ctx, claimed.ID, err.Error())
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FromRow makes the reminder for a due appointment, with the visit time in
+// the doctor's timezone.
+func FromRow(row database.GetDueRemindersRow, offset time.Duration) Reminder {
+	visit := row.VisitTimestamp.Time
+	if loc, err := time.LoadLocation(row.Timezone); err == nil {
+		visit = visit.In(loc)
+	}
+
+	return Reminder{
+		AppointmentID: row.AppointmentID,
+		Token:         row.AppointmentSequence,
+		PatientName:   row.PatientName,
+		Email:         row.PatientEmail,
+		Phone:         row.PatientPhone.String,
+		VisitTime:     visit,
+		Offset:        offset,
+	}
+}
@@ -0,0 +1,73 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+type SMSGatewayConfig struct {
+	// URL receives a POST of {"to", "from", "message"} as JSON.
+	URL string
+	// APIKey is sent as a bearer token.
+	APIKey string
+	From   string
+}
+
+// SMSGatewayNotifier texts reminders through an HTTP SMS gateway.
+type SMSGatewayNotifier struct {
+	config SMSGatewayConfig
+	client *http.Client
+}
+
+func NewSMSGatewayNotifier(config SMSGatewayConfig) *SMSGatewayNotifier {
+	return &SMSGatewayNotifier{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (n *SMSGatewayNotifier) Channel() string {
+	return "sms"
+}
+
+func (n *SMSGatewayNotifier) Notify(ctx context.Context, reminder Reminder) error {
+	if reminder.Phone == "" {
+		return ErrNoAddress
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"to":      reminder.Phone,
+		"from":    n.config.From,
+		"message": reminder.Text(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+n.config.APIKey)
+	}
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("sms gateway responded %s: %s", res.Status, bytes.TrimSpace(detail))
+	}
+
+	return nil
+}
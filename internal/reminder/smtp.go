@@ -0,0 +1,78 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password are optional, the server is used without
+	// authentication when Username is empty.
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPNotifier emails reminders through an SMTP server.
+type SMTPNotifier struct {
+	addr string
+	auth smtp.Auth
+	from mail.Address
+}
+
+func NewSMTPNotifier(config SMTPConfig) (*SMTPNotifier, error) {
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("smtp from address: %w", err)
+	}
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	return &SMTPNotifier{
+		addr: net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
+		auth: auth,
+		from: *from,
+	}, nil
+}
+
+func (n *SMTPNotifier) Channel() string {
+	return "email"
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, reminder Reminder) error {
+	if reminder.Email == "" {
+		return ErrNoAddress
+	}
+
+	to := mail.Address{Name: reminder.PatientName, Address: reminder.Email}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", reminder.Subject()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(reminder.Text())
+	msg.WriteString("\r\n")
+
+	// net/smtp can't be cancelled, at least don't start once ctx is done
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return smtp.SendMail(n.addr, n.auth, n.from.Address, []string{reminder.Email}, msg.Bytes())
+}
@@ -3,27 +3,62 @@ package repositories
 import (
 	"context"
 	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type AppointmentRepositoryInterface interface {
-	GetAll(ctx context.Context) ([]database.Appointment, error)
+	GetAll(ctx context.Context, option GetAppointmentsOption) (pagination.Page[database.Appointment], error)
 	GetByDate(ctx context.Context, date time.Time) ([]database.Appointment, error)
 	GetByPatient(ctx context.Context, patientId int32) ([]database.Appointment, error)
+	GetByDoctor(ctx context.Context, doctorId int32, date *time.Time) ([]database.Appointment, error)
+	GetActiveByDoctorBetween(ctx context.Context, doctorId int32, from time.Time, to time.Time) ([]database.Appointment, error)
 	Get(ctx context.Context, id int32) (database.Appointment, error)
+	GetForUpdate(ctx context.Context, id int32) (database.Appointment, error)
+	GetWithDeleted(ctx context.Context, id int32) (database.Appointment, error)
 	Create(ctx context.Context, userId int32, patientId int32, data CreateAppointmentParams) (database.Appointment, error)
 	Update(ctx context.Context, appointmentid int32, data UpdateAppointmentParams) (database.Appointment, error)
 	Delete(ctx context.Context, id int32) error
+	Restore(ctx context.Context, id int32) (database.Appointment, error)
+	Transition(ctx context.Context, appointmentId int32, data TransitionAppointmentParams) (database.Appointment, error)
+	GetStatusHistory(ctx context.Context, appointmentId int32) ([]database.AppointmentStatusHistory, error)
+	Reschedule(ctx context.Context, appointmentId int32, data RescheduleAppointmentParams) (database.Appointment, error)
+	GetRescheduleHistory(ctx context.Context, appointmentId int32) ([]database.AppointmentRescheduleHistory, error)
+	CreateSeries(ctx context.Context, userId int32, patientId int32, data CreateAppointmentSeriesParams) (database.AppointmentSeries, []database.Appointment, error)
+	GetSeries(ctx context.Context, seriesId int32) (database.AppointmentSeries, error)
+	GetSeriesAppointments(ctx context.Context, seriesId int32) ([]database.Appointment, error)
+	PlanSeriesReschedule(ctx context.Context, appointmentId int32, scope SeriesScope, visit time.Time) ([]SeriesMove, error)
+	RescheduleSeries(ctx context.Context, moves []SeriesMove, actorId int32, reason *string) ([]database.Appointment, error)
+	UpdateSeries(ctx context.Context, appointmentId int32, scope SeriesScope, data UpdateAppointmentParams) ([]database.Appointment, error)
+	CancelSeries(ctx context.Context, appointmentId int32, scope SeriesScope, actorId int32, reason *string) ([]database.Appointment, error)
+	Reencrypt(ctx context.Context, afterId int32, limit int32) (int32, int, error)
 }
 
 type AppointmentQueriesContract interface {
-    GetAllAppointments(context.Context) ([]database.Appointment, error)
+    GetAllAppointments(context.Context, database.GetAllAppointmentsParams) ([]database.Appointment, error)
     GetAppointmentsByDate(context.Context, pgtype.Date) ([]database.Appointment, error)
     GetAppointmentsByPatient(context.Context, int32) ([]database.Appointment, error)
+    GetAppointmentsByDoctor(context.Context, database.GetAppointmentsByDoctorParams) ([]database.Appointment, error)
+    GetActiveAppointmentsByDoctorBetween(context.Context, database.GetActiveAppointmentsByDoctorBetweenParams) ([]database.Appointment, error)
+    GetOverlappingAppointments(context.Context, database.GetOverlappingAppointmentsParams) ([]database.Appointment, error)
     GetAppointmentByID(context.Context, int32) (database.Appointment, error)
+    GetAppointmentByIDForUpdate(context.Context, int32) (database.Appointment, error)
+    GetAppointmentByIDWithDeleted(context.Context, int32) (database.Appointment, error)
     CreateAppointment(context.Context, database.CreateAppointmentParams) (database.Appointment, error)
     UpdateAppointment(context.Context, database.UpdateAppointmentParams) (database.Appointment, error)
     DeleteAppointment(context.Context, int32) error
+    RestoreAppointment(context.Context, int32) (database.Appointment, error)
+    UpdateAppointmentStatus(context.Context, database.UpdateAppointmentStatusParams) (database.Appointment, error)
+    CreateAppointmentStatusHistory(context.Context, database.CreateAppointmentStatusHistoryParams) (database.AppointmentStatusHistory, error)
+    GetAppointmentStatusHistory(context.Context, int32) ([]database.AppointmentStatusHistory, error)
+    RescheduleAppointment(context.Context, database.RescheduleAppointmentParams) (database.Appointment, error)
+    CreateAppointmentRescheduleHistory(context.Context, database.CreateAppointmentRescheduleHistoryParams) (database.AppointmentRescheduleHistory, error)
+    GetAppointmentRescheduleHistory(context.Context, int32) ([]database.AppointmentRescheduleHistory, error)
+    GetAppointmentsBySeries(context.Context, pgtype.Int4) ([]database.Appointment, error)
+    CreateAppointmentSeries(context.Context, database.CreateAppointmentSeriesParams) (database.AppointmentSeries, error)
+    GetAppointmentSeries(context.Context, int32) (database.AppointmentSeries, error)
+    GetAppointmentsForReencryption(context.Context, database.GetAppointmentsForReencryptionParams) ([]database.Appointment, error)
+    UpdateAppointmentEncryption(context.Context, database.UpdateAppointmentEncryptionParams) error
 }
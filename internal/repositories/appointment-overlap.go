@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	appointmentDoctorOverlapConstraint  = "appointments_doctor_overlap_excl"
+	appointmentPatientOverlapConstraint = "appointments_patient_overlap_excl"
+	defaultAppointmentDurationMinutes   = 15
+)
+
+var ErrAppointmentOverlap = errors.New("appointment overlaps another appointment")
+
+// AppointmentOverlapError lists the active appointments of the same doctor
+// or patient that clash with the requested time.
+type AppointmentOverlapError struct {
+	Conflicts []database.Appointment
+}
+
+func (e AppointmentOverlapError) Error() string {
+	ids := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		ids[i] = fmt.Sprint(c.ID)
+	}
+	return fmt.Sprintf("appointment overlaps appointments [%s]", strings.Join(ids, ", "))
+}
+
+func (e AppointmentOverlapError) Is(target error) bool {
+	return target == ErrAppointmentOverlap
+}
+
+// appointmentSlot is the time an appointment would take up, excludeId skips
+// the appointment itself when it is being moved.
+type appointmentSlot struct {
+	excludeId int32
+	doctorId  int32
+	patientId int32
+	start     time.Time
+	duration  time.Duration
+}
+
+// findOverlaps returns an AppointmentOverlapError when slot clashes with
+// another active appointment. The exclusion constraints are what enforce
+// this, checking first keeps a surrounding transaction usable and lets the
+// error name the conflicts.
+func (a *AppointmentRepository) findOverlaps(ctx context.Context, slot appointmentSlot) error {
+	conflicts, err := a.queries.GetOverlappingAppointments(ctx, database.GetOverlappingAppointmentsParams{
+		DoctorID:  pgtype.Int4{Int32: slot.doctorId, Valid: slot.doctorId != 0},
+		PatientID: slot.patientId,
+		ExcludeID: slot.excludeId,
+		FromTime:  pgtype.Timestamptz{Time: slot.start, Valid: true},
+		ToTime:    pgtype.Timestamptz{Time: slot.start.Add(slot.duration), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(conflicts) > 0 {
+		if conflicts, err = decryptAppointments(ctx, a.cipher, conflicts); err != nil {
+			return err
+		}
+		return AppointmentOverlapError{Conflicts: conflicts}
+	}
+
+	return nil
+}
+
+// overlapError turns an exclusion violation that slipped past findOverlaps
+// into an AppointmentOverlapError. In a transaction the lookup fails too,
+// the error then carries no conflicts.
+func (a *AppointmentRepository) overlapError(ctx context.Context, slot appointmentSlot, err error) error {
+	if !IsExclusionViolation(err, appointmentDoctorOverlapConstraint) && !IsExclusionViolation(err, appointmentPatientOverlapConstraint) {
+		return err
+	}
+
+	var overlap AppointmentOverlapError
+	if lookupErr := a.findOverlaps(ctx, slot); !errors.As(lookupErr, &overlap) {
+		return errors.Join(AppointmentOverlapError{}, err)
+	}
+
+	return errors.Join(overlap, err)
+}
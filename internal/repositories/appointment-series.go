@@ -0,0 +1,279 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/recurrence"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// SeriesScope picks which occurrences of a series an edit or cancel applies
+// to, counted from the occurrence it is made on.
+type SeriesScope string
+
+const (
+	SeriesScopeThis      SeriesScope = "this"
+	SeriesScopeFollowing SeriesScope = "following"
+	SeriesScopeAll       SeriesScope = "all"
+)
+
+var ErrInvalidSeriesScope = errors.New("invalid series scope")
+
+type CreateAppointmentSeriesParams struct {
+	DoctorID int32
+	Rule     recurrence.Rule
+	Start    time.Time
+	// Location is the timezone the rule is expanded in, normally the
+	// doctor's.
+	Location     *time.Location
+	Duration     time.Duration
+	PatientNotes *string
+}
+
+// SeriesMove is an occurrence of a series and the time it is moved to.
+type SeriesMove struct {
+	Appointment    database.Appointment
+	VisitTimestamp time.Time
+}
+
+// CreateSeries stores the recurrence and books an appointment for each of
+// its occurrences. Run it in a transaction so that a clash on any
+// occurrence books none of them.
+func (a *AppointmentRepository) CreateSeries(ctx context.Context, userId int32, patientId int32, data CreateAppointmentSeriesParams) (database.AppointmentSeries, []database.Appointment, error) {
+
+	occurrences, err := data.Rule.Occurrences(data.Start, data.Location)
+	if err != nil {
+		return database.AppointmentSeries{}, nil, err
+	}
+
+	duration := data.Duration
+	if duration <= 0 {
+		duration = defaultAppointmentDurationMinutes * time.Minute
+	}
+
+	interval := data.Rule.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	var until pgtype.Date
+	if data.Rule.Until != nil {
+		until = pgtype.Date{Time: *data.Rule.Until, Valid: true}
+	}
+
+	weekdays := make([]int16, len(data.Rule.Weekdays))
+	for i, wd := range data.Rule.Weekdays {
+		weekdays[i] = int16(wd)
+	}
+
+	timezone := "UTC"
+	if data.Location != nil {
+		timezone = data.Location.String()
+	}
+
+	series, err := a.queries.CreateAppointmentSeries(ctx, database.CreateAppointmentSeriesParams{
+		PatientID:       patientId,
+		DoctorID:        pgtype.Int4{Int32: data.DoctorID, Valid: data.DoctorID != 0},
+		UserID:          pgtype.Int4{Int32: userId, Valid: userId != 0},
+		Frequency:       string(data.Rule.Frequency),
+		RepeatInterval:  int16(interval),
+		OccurrenceCount: pgtype.Int2{Int16: int16(data.Rule.Count), Valid: data.Rule.Count > 0},
+		UntilDate:       until,
+		Weekdays:        weekdays,
+		Timezone:        timezone,
+		StartsAt:        pgtype.Timestamptz{Time: data.Start, Valid: true},
+		DurationMinutes: int16(duration / time.Minute),
+	})
+	if err != nil {
+		return series, nil, err
+	}
+
+	appointments := make([]database.Appointment, 0, len(occurrences))
+	for _, start := range occurrences {
+		appointment, err := a.Create(ctx, userId, patientId, CreateAppointmentParams{
+			DoctorID:       data.DoctorID,
+			SeriesID:       series.ID,
+			VisitTimestamp: start,
+			Duration:       duration,
+			PatientNotes:   data.PatientNotes,
+		})
+		if err != nil {
+			return series, appointments, err
+		}
+		appointments = append(appointments, appointment)
+	}
+
+	return series, appointments, nil
+}
+
+func (a *AppointmentRepository) GetSeries(ctx context.Context, seriesId int32) (database.AppointmentSeries, error) {
+	res, err := a.queries.GetAppointmentSeries(ctx, seriesId)
+	return res, err
+}
+
+func (a *AppointmentRepository) GetSeriesAppointments(ctx context.Context, seriesId int32) ([]database.Appointment, error) {
+	res, err := a.queries.GetAppointmentsBySeries(ctx, pgtype.Int4{Int32: seriesId, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptAppointments(ctx, a.cipher, res)
+}
+
+// PlanSeriesReschedule works out where every scheduled occurrence in scope
+// goes when the occurrence appointmentId moves to visit. The others keep
+// their spacing and take visit's wall clock time in the series timezone.
+// The moves are ordered so that applying them one by one never makes the
+// series clash with itself.
+func (a *AppointmentRepository) PlanSeriesReschedule(ctx context.Context, appointmentId int32, scope SeriesScope, visit time.Time) ([]SeriesMove, error) {
+
+	anchor, targets, err := a.seriesTargets(ctx, appointmentId, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := time.UTC
+	if anchor.SeriesID.Valid {
+		series, err := a.queries.GetAppointmentSeries(ctx, anchor.SeriesID.Int32)
+		if err != nil {
+			return nil, err
+		}
+		if loc, err = time.LoadLocation(series.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	var moves []SeriesMove
+	for _, target := range targets {
+		if AppointmentStatus(target.Status) != AppointmentScheduled {
+			continue
+		}
+		moves = append(moves, SeriesMove{
+			Appointment:    target,
+			VisitTimestamp: recurrence.Shift(target.VisitTimestamp.Time, anchor.VisitTimestamp.Time, visit, loc),
+		})
+	}
+
+	// moving later, start from the last occurrence; earlier, from the first
+	later := visit.After(anchor.VisitTimestamp.Time)
+	sort.SliceStable(moves, func(i, j int) bool {
+		if later {
+			return moves[i].Appointment.VisitTimestamp.Time.After(moves[j].Appointment.VisitTimestamp.Time)
+		}
+		return moves[i].Appointment.VisitTimestamp.Time.Before(moves[j].Appointment.VisitTimestamp.Time)
+	})
+
+	return moves, nil
+}
+
+// RescheduleSeries applies the moves from PlanSeriesReschedule in order.
+// Run it in a transaction.
+func (a *AppointmentRepository) RescheduleSeries(ctx context.Context, moves []SeriesMove, actorId int32, reason *string) ([]database.Appointment, error) {
+
+	appointments := make([]database.Appointment, 0, len(moves))
+	for _, move := range moves {
+		appointment, err := a.Reschedule(ctx, move.Appointment.ID, RescheduleAppointmentParams{
+			ActorID:        actorId,
+			VisitTimestamp: move.VisitTimestamp,
+			Reason:         reason,
+		})
+		if err != nil {
+			return appointments, err
+		}
+		appointments = append(appointments, appointment)
+	}
+
+	return appointments, nil
+}
+
+// UpdateSeries updates the notes of every occurrence in scope that is not
+// finished yet.
+func (a *AppointmentRepository) UpdateSeries(ctx context.Context, appointmentId int32, scope SeriesScope, data UpdateAppointmentParams) ([]database.Appointment, error) {
+
+	_, targets, err := a.seriesTargets(ctx, appointmentId, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var appointments []database.Appointment
+	for _, target := range targets {
+		if AppointmentStatus(target.Status).IsFinal() {
+			continue
+		}
+
+		appointment, err := a.Update(ctx, target.ID, data)
+		if err != nil {
+			return appointments, err
+		}
+		appointments = append(appointments, appointment)
+	}
+
+	return appointments, nil
+}
+
+// CancelSeries cancels every occurrence in scope that can still be
+// cancelled and leaves the rest alone. Run it in a transaction.
+func (a *AppointmentRepository) CancelSeries(ctx context.Context, appointmentId int32, scope SeriesScope, actorId int32, reason *string) ([]database.Appointment, error) {
+
+	_, targets, err := a.seriesTargets(ctx, appointmentId, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var appointments []database.Appointment
+	for _, target := range targets {
+		// a single occurrence reports why it cannot be cancelled
+		if scope != SeriesScopeThis && !CanTransition(AppointmentStatus(target.Status), AppointmentCancelled) {
+			continue
+		}
+
+		appointment, err := a.Transition(ctx, target.ID, TransitionAppointmentParams{
+			ActorID: actorId,
+			To:      AppointmentCancelled,
+			Reason:  reason,
+		})
+		if err != nil {
+			return appointments, err
+		}
+		appointments = append(appointments, appointment)
+	}
+
+	return appointments, nil
+}
+
+// seriesTargets returns the appointment and the occurrences of its series
+// that scope covers. An appointment outside a series only covers itself.
+func (a *AppointmentRepository) seriesTargets(ctx context.Context, appointmentId int32, scope SeriesScope) (database.Appointment, []database.Appointment, error) {
+
+	if scope != SeriesScopeThis && scope != SeriesScopeFollowing && scope != SeriesScopeAll {
+		return database.Appointment{}, nil, ErrInvalidSeriesScope
+	}
+
+	anchor, err := a.Get(ctx, appointmentId)
+	if err != nil {
+		return anchor, nil, err
+	}
+
+	if scope == SeriesScopeThis || !anchor.SeriesID.Valid {
+		return anchor, []database.Appointment{anchor}, nil
+	}
+
+	series, err := a.GetSeriesAppointments(ctx, anchor.SeriesID.Int32)
+	if err != nil {
+		return anchor, nil, err
+	}
+
+	var targets []database.Appointment
+	for _, appointment := range series {
+		if scope == SeriesScopeFollowing && appointment.VisitTimestamp.Time.Before(anchor.VisitTimestamp.Time) {
+			continue
+		}
+		targets = append(targets, appointment)
+	}
+
+	return anchor, targets, nil
+}
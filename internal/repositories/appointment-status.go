@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+)
+
+type AppointmentStatus string
+
+const (
+	AppointmentScheduled      AppointmentStatus = "scheduled"
+	AppointmentCheckedIn      AppointmentStatus = "checked_in"
+	AppointmentInConsultation AppointmentStatus = "in_consultation"
+	AppointmentCompleted      AppointmentStatus = "completed"
+	AppointmentCancelled      AppointmentStatus = "cancelled"
+	AppointmentNoShow         AppointmentStatus = "no_show"
+)
+
+// appointmentTransitions lists, for every status, the statuses it may move
+// to. Completed, cancelled and no-show are final.
+var appointmentTransitions = map[AppointmentStatus][]AppointmentStatus{
+	AppointmentScheduled:      {AppointmentCheckedIn, AppointmentCancelled, AppointmentNoShow},
+	AppointmentCheckedIn:      {AppointmentInConsultation, AppointmentCancelled},
+	AppointmentInConsultation: {AppointmentCompleted},
+}
+
+var ErrInvalidStatusTransition = errors.New("invalid appointment status transition")
+
+type InvalidStatusTransitionError struct {
+	From AppointmentStatus
+	To   AppointmentStatus
+}
+
+func (e InvalidStatusTransitionError) Error() string {
+	return fmt.Sprintf("appointment cannot move from %s to %s", e.From, e.To)
+}
+
+func (e InvalidStatusTransitionError) Is(target error) bool {
+	return target == ErrInvalidStatusTransition
+}
+
+func CanTransition(from AppointmentStatus, to AppointmentStatus) bool {
+	for _, allowed := range appointmentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether no further transition is possible from status.
+func (s AppointmentStatus) IsFinal() bool {
+	return len(appointmentTransitions[s]) == 0
+}
+
+func (s AppointmentStatus) IsValid() bool {
+	switch s {
+	case AppointmentScheduled, AppointmentCheckedIn, AppointmentInConsultation,
+		AppointmentCompleted, AppointmentCancelled, AppointmentNoShow:
+		return true
+	}
+	return false
+}
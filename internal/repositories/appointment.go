@@ -2,19 +2,63 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const appointmentSequenceConstraint = "appointments_visit_date_appointment_sequence_key"
+
+// ErrAppointmentSequenceConflict is returned when the appointment number
+// allocated for the visit date is taken. next_appointment_sequence skips
+// taken numbers, so this only happens when rows are written around it.
+var ErrAppointmentSequenceConflict = errors.New("appointment sequence already taken")
+
+// ErrAppointmentNotReschedulable is returned when moving an appointment that
+// is no longer scheduled, e.g. already checked in or cancelled.
+var ErrAppointmentNotReschedulable = errors.New("only scheduled appointments can be rescheduled")
+
+// ErrAppointmentPatientDeleted is returned when restoring an appointment of
+// a deleted patient, the patient has to be restored instead.
+var ErrAppointmentPatientDeleted = errors.New("the appointment's patient is deleted")
+
 type AppointmentRepository struct {
 	queries AppointmentQueriesContract
+	cipher  FieldCipher
 }
 
 type CreateAppointmentParams struct {
+	DoctorID       int32
+	SeriesID       int32
 	VisitTimestamp time.Time
-	PatientNotes   *string
+	// Duration defaults to 15 minutes when zero.
+	Duration     time.Duration
+	PatientNotes *string
+}
+
+const (
+	AppointmentSortVisit     = "visit_timestamp"
+	AppointmentSortCreatedAt = "created_at"
+)
+
+// GetAppointmentsOption filters appointments by whichever fields are set.
+// VisitFrom and VisitTo are dates and both inclusive.
+type GetAppointmentsOption struct {
+	VisitFrom *time.Time
+	VisitTo   *time.Time
+	DoctorID  *int32
+	PatientID *int32
+	Statuses  []string
+	// IncludeDeleted lists deleted appointments along with the others.
+	IncludeDeleted bool
+	// Page.SortBy is one of the AppointmentSort values, visit_timestamp
+	// when empty.
+	Page pagination.Params
 }
 
 type UpdateAppointmentParams struct {
@@ -22,15 +66,87 @@ type UpdateAppointmentParams struct {
 	DoctorNotes  *string
 }
 
-func NewAppointmentRepository(queries AppointmentQueriesContract) AppointmentRepositoryInterface {
+type RescheduleAppointmentParams struct {
+	ActorID        int32
+	VisitTimestamp time.Time
+	Reason         *string
+}
+
+type TransitionAppointmentParams struct {
+	ActorID int32
+	To      AppointmentStatus
+	Reason  *string
+}
+
+// NewAppointmentRepository stores appointment notes encrypted with cipher
+// and decrypts them on the way out.
+func NewAppointmentRepository(queries AppointmentQueriesContract, cipher FieldCipher) AppointmentRepositoryInterface {
 	return &AppointmentRepository{
 		queries: queries,
+		cipher:  cipher,
 	}
 }
 
-func (a *AppointmentRepository) GetAll(ctx context.Context) ([]database.Appointment, error) {
-	res, err := a.queries.GetAllAppointments(ctx)
-	return res, err
+// GetAll returns a page of appointments, pagination.ErrInvalidCursor when
+// the cursor can't be continued from.
+func (a *AppointmentRepository) GetAll(ctx context.Context, option GetAppointmentsOption) (pagination.Page[database.Appointment], error) {
+	page := option.Page
+	if page.SortBy == "" {
+		page.SortBy = AppointmentSortVisit
+	}
+
+	cursor, err := page.DecodeCursor()
+	if err != nil {
+		return pagination.Page[database.Appointment]{}, err
+	}
+
+	params := database.GetAllAppointmentsParams{
+		Statuses:       option.Statuses,
+		IncludeDeleted: option.IncludeDeleted,
+		SortBy:         page.SortBy,
+		SortDesc:       page.Desc,
+		MaxResults:     page.FetchLimit(),
+	}
+
+	if option.VisitFrom != nil {
+		params.VisitFrom = pgtype.Date{Time: *option.VisitFrom, Valid: true}
+	}
+	if option.VisitTo != nil {
+		params.VisitTo = pgtype.Date{Time: *option.VisitTo, Valid: true}
+	}
+	if option.DoctorID != nil {
+		params.DoctorID = pgtype.Int4{Int32: *option.DoctorID, Valid: true}
+	}
+	if option.PatientID != nil {
+		params.PatientID = pgtype.Int4{Int32: *option.PatientID, Valid: true}
+	}
+
+	if cursor != nil {
+		cursorTime, err := parseTimeKey(cursor.Key)
+		if err != nil {
+			return pagination.Page[database.Appointment]{}, err
+		}
+
+		params.HasCursor = true
+		params.CursorID = cursor.ID
+		params.CursorTime = pgtype.Timestamptz{Time: cursorTime, Valid: true}
+	}
+
+	res, err := a.queries.GetAllAppointments(ctx, params)
+	if err != nil {
+		return pagination.Page[database.Appointment]{}, err
+	}
+
+	if res, err = decryptAppointments(ctx, a.cipher, res); err != nil {
+		return pagination.Page[database.Appointment]{}, err
+	}
+
+	return pagination.NewPage(res, page, func(appointment database.Appointment) (string, int32) {
+		if page.SortBy == AppointmentSortCreatedAt {
+			return timestamptzKey(appointment.CreatedAt), appointment.ID
+		}
+		return timestamptzKey(appointment.VisitTimestamp), appointment.ID
+	}), nil
 }
 
 func (a *AppointmentRepository) GetByDate(ctx context.Context, date time.Time) ([]database.Appointment, error) {
@@ -40,18 +156,82 @@ func (a *AppointmentRepository) GetByDate(ctx context.Context, date time.Time) (
 	}
 
 	res, err := a.queries.GetAppointmentsByDate(ctx, pgDate)
-	return res, err
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptAppointments(ctx, a.cipher, res)
 }
 
 func (a *AppointmentRepository) GetByPatient(ctx context.Context, patientId int32) ([]database.Appointment, error) {
 	res, err := a.queries.GetAppointmentsByPatient(ctx, patientId)
-	return res, err
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptAppointments(ctx, a.cipher, res)
+}
+
+func (a *AppointmentRepository) GetByDoctor(ctx context.Context, doctorId int32, date *time.Time) ([]database.Appointment, error) {
+	var pgDate pgtype.Date
+	if date != nil {
+		pgDate = pgtype.Date{Time: *date, Valid: true}
+	}
+
+	res, err := a.queries.GetAppointmentsByDoctor(ctx, database.GetAppointmentsByDoctorParams{
+		DoctorID:  pgtype.Int4{Int32: doctorId, Valid: true},
+		VisitDate: pgDate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptAppointments(ctx, a.cipher, res)
+}
+
+// GetActiveByDoctorBetween returns the doctor's appointments overlapping
+// [from, to) that still take up time, i.e. not cancelled or no-show.
+func (a *AppointmentRepository) GetActiveByDoctorBetween(ctx context.Context, doctorId int32, from time.Time, to time.Time) ([]database.Appointment, error) {
+	res, err := a.queries.GetActiveAppointmentsByDoctorBetween(ctx, database.GetActiveAppointmentsByDoctorBetweenParams{
+		DoctorID: pgtype.Int4{Int32: doctorId, Valid: true},
+		FromTime: pgtype.Timestamptz{Time: from, Valid: true},
+		ToTime:   pgtype.Timestamptz{Time: to, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptAppointments(ctx, a.cipher, res)
 }
 
 func (a *AppointmentRepository) Get(ctx context.Context, id int32) (database.Appointment, error) {
 	res, err := a.queries.GetAppointmentByID(ctx, id)
+	if err != nil {
+		return res, err
+	}
 
-	return res, err
+	return decryptAppointment(ctx, a.cipher, res)
+}
+
+// GetForUpdate returns the appointment locked until the transaction ends,
+// so a check made on it still holds when it is written.
+func (a *AppointmentRepository) GetForUpdate(ctx context.Context, id int32) (database.Appointment, error) {
+	res, err := a.queries.GetAppointmentByIDForUpdate(ctx, id)
+	if err != nil {
+		return res, err
+	}
+
+	return decryptAppointment(ctx, a.cipher, res)
+}
+
+// GetWithDeleted returns the appointment even when it was deleted.
+func (a *AppointmentRepository) GetWithDeleted(ctx context.Context, id int32) (database.Appointment, error) {
+	res, err := a.queries.GetAppointmentByIDWithDeleted(ctx, id)
+	if err != nil {
+		return res, err
+	}
+
+	return decryptAppointment(ctx, a.cipher, res)
 }
 
 func (a *AppointmentRepository) Create(ctx context.Context, userId int32, patientId int32, data CreateAppointmentParams) (database.Appointment, error) {
@@ -60,36 +240,57 @@ func (a *AppointmentRepository) Create(ctx context.Context, userId int32, patien
 	pgTimestamp.Time = data.VisitTimestamp
 	pgTimestamp.Valid = true
 
-	var pgDate pgtype.Date
-
-	visitTime := data.VisitTimestamp
-	startOfDay := time.Date(
-		visitTime.Year(),
-		visitTime.Month(),
-		visitTime.Day(),
-		0,
-		0,
-		0,
-		0,
-		visitTime.Location(),
-	)
-	pgDate.Time = startOfDay
-	pgDate.Valid = true
+	pgDate := visitDateOf(data.VisitTimestamp)
 
     var patientNotes string
     if data.PatientNotes !=nil {
         patientNotes = *data.PatientNotes
     }
 
-	res, err := a.queries.CreateAppointment(ctx, database.CreateAppointmentParams{
-		UserID:         pgtype.Int4{Int32: userId},
-		PatientID:      patientId,
-		VisitDate:      pgDate,
-		VisitTimestamp: pgTimestamp,
-		PatientNotes:   pgtype.Text{String: patientNotes, Valid: data.PatientNotes != nil},
-	})
+	duration := data.Duration
+	if duration <= 0 {
+		duration = defaultAppointmentDurationMinutes * time.Minute
+	}
 
-	return res, err
+	slot := appointmentSlot{
+		doctorId:  data.DoctorID,
+		patientId: patientId,
+		start:     data.VisitTimestamp,
+		duration:  duration,
+	}
+
+	if err := a.findOverlaps(ctx, slot); err != nil {
+		return database.Appointment{}, err
+	}
+
+	encryptedNotes, err := encryptText(ctx, a.cipher, FieldAppointmentPatientNotes, pgtype.Text{String: patientNotes, Valid: data.PatientNotes != nil})
+	if err != nil {
+		return database.Appointment{}, err
+	}
+
+	params := database.CreateAppointmentParams{
+		UserID:          pgtype.Int4{Int32: userId, Valid: userId != 0},
+		DoctorID:        pgtype.Int4{Int32: data.DoctorID, Valid: data.DoctorID != 0},
+		SeriesID:        pgtype.Int4{Int32: data.SeriesID, Valid: data.SeriesID != 0},
+		PatientID:       patientId,
+		VisitDate:       pgDate,
+		VisitTimestamp:  pgTimestamp,
+		DurationMinutes: int16(duration / time.Minute),
+		PatientNotes:    encryptedNotes,
+	}
+
+	// The sequence trigger serializes bookings per day and allocates past
+	// any number already taken. Retrying here wouldn't help anyway, the
+	// failed insert aborts the caller's transaction.
+	res, err := a.queries.CreateAppointment(ctx, params)
+	if IsUniqueViolation(err, appointmentSequenceConstraint) {
+		return res, errors.Join(ErrAppointmentSequenceConflict, err)
+	}
+	if err != nil {
+		return res, a.overlapError(ctx, slot, err)
+	}
+
+	return decryptAppointment(ctx, a.cipher, res)
 }
 
 func (a *AppointmentRepository) Update(ctx context.Context, appointmentId int32, data UpdateAppointmentParams) (database.Appointment, error) {
@@ -104,13 +305,26 @@ func (a *AppointmentRepository) Update(ctx context.Context, appointmentId int32,
         doctorNotes = *data.DoctorNotes
     }
 
+	encryptedPatientNotes, err := encryptText(ctx, a.cipher, FieldAppointmentPatientNotes, pgtype.Text{String: patientNotes, Valid: data.PatientNotes != nil})
+	if err != nil {
+		return database.Appointment{}, err
+	}
+
+	encryptedDoctorNotes, err := encryptText(ctx, a.cipher, FieldAppointmentDoctorNotes, pgtype.Text{String: doctorNotes, Valid: data.DoctorNotes != nil})
+	if err != nil {
+		return database.Appointment{}, err
+	}
+
 	updatedAppointment, err := a.queries.UpdateAppointment(ctx, database.UpdateAppointmentParams{
 		ID:           appointmentId,
-		PatientNotes: pgtype.Text{String: patientNotes, Valid: data.PatientNotes != nil},
-		DoctorNotes:  pgtype.Text{String: doctorNotes, Valid: data.DoctorNotes != nil},
+		PatientNotes: encryptedPatientNotes,
+		DoctorNotes:  encryptedDoctorNotes,
 	})
+	if err != nil {
+		return updatedAppointment, err
+	}
 
-	return updatedAppointment, err
+	return decryptAppointment(ctx, a.cipher, updatedAppointment)
 }
 
 func (a *AppointmentRepository) Delete(ctx context.Context, id int32) error {
@@ -119,3 +333,203 @@ func (a *AppointmentRepository) Delete(ctx context.Context, id int32) error {
 
 	return err
 }
+
+// Restore undeletes an appointment, ErrAppointmentPatientDeleted when its
+// patient is deleted too.
+func (a *AppointmentRepository) Restore(ctx context.Context, id int32) (database.Appointment, error) {
+	res, err := a.queries.RestoreAppointment(ctx, id)
+	if err == nil {
+		return decryptAppointment(ctx, a.cipher, res)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return res, err
+	}
+
+	// Nothing restored, tell a missing appointment from one whose patient
+	// is deleted.
+	if _, getErr := a.queries.GetAppointmentByIDWithDeleted(ctx, id); getErr != nil {
+		return res, err
+	}
+
+	return res, ErrAppointmentPatientDeleted
+}
+
+// Transition moves an appointment to another status and records who did it
+// in the status history. Both writes should share a transaction, see
+// TxManager.
+func (a *AppointmentRepository) Transition(ctx context.Context, appointmentId int32, data TransitionAppointmentParams) (database.Appointment, error) {
+
+	appointment, err := a.Get(ctx, appointmentId)
+	if err != nil {
+		return appointment, err
+	}
+
+	from := AppointmentStatus(appointment.Status)
+	if !CanTransition(from, data.To) {
+		return appointment, InvalidStatusTransitionError{From: from, To: data.To}
+	}
+
+	var reason string
+	if data.Reason != nil {
+		reason = *data.Reason
+	}
+
+	var cancelReason pgtype.Text
+	if data.To == AppointmentCancelled {
+		cancelReason = pgtype.Text{String: reason, Valid: data.Reason != nil}
+	}
+
+	updated, err := a.queries.UpdateAppointmentStatus(ctx, database.UpdateAppointmentStatusParams{
+		ID:           appointmentId,
+		FromStatus:   string(from),
+		ToStatus:     string(data.To),
+		ChangedBy:    pgtype.Int4{Int32: data.ActorID, Valid: data.ActorID != 0},
+		CancelReason: cancelReason,
+	})
+
+	// the status changed between the read and the update
+	if errors.Is(err, pgx.ErrNoRows) {
+		return appointment, InvalidStatusTransitionError{From: from, To: data.To}
+	}
+
+	if err != nil {
+		return updated, err
+	}
+
+	if updated, err = decryptAppointment(ctx, a.cipher, updated); err != nil {
+		return updated, err
+	}
+
+	_, err = a.queries.CreateAppointmentStatusHistory(ctx, database.CreateAppointmentStatusHistoryParams{
+		AppointmentID: appointmentId,
+		FromStatus:    string(from),
+		ToStatus:      string(data.To),
+		ChangedBy:     pgtype.Int4{Int32: data.ActorID, Valid: data.ActorID != 0},
+		Reason:        pgtype.Text{String: reason, Valid: data.Reason != nil},
+	})
+
+	return updated, err
+}
+
+func (a *AppointmentRepository) GetStatusHistory(ctx context.Context, appointmentId int32) ([]database.AppointmentStatusHistory, error) {
+	res, err := a.queries.GetAppointmentStatusHistory(ctx, appointmentId)
+	return res, err
+}
+
+// Reschedule moves a scheduled appointment to another time, keeping its
+// duration, and records the move in the reschedule history. Both writes
+// should share a transaction, see TxManager.
+func (a *AppointmentRepository) Reschedule(ctx context.Context, appointmentId int32, data RescheduleAppointmentParams) (database.Appointment, error) {
+
+	appointment, err := a.Get(ctx, appointmentId)
+	if err != nil {
+		return appointment, err
+	}
+
+	if AppointmentStatus(appointment.Status) != AppointmentScheduled {
+		return appointment, ErrAppointmentNotReschedulable
+	}
+
+	slot := appointmentSlot{
+		excludeId: appointment.ID,
+		doctorId:  appointment.DoctorID.Int32,
+		patientId: appointment.PatientID,
+		start:     data.VisitTimestamp,
+		duration:  time.Duration(appointment.DurationMinutes) * time.Minute,
+	}
+
+	if err := a.findOverlaps(ctx, slot); err != nil {
+		return appointment, err
+	}
+
+	updated, err := a.queries.RescheduleAppointment(ctx, database.RescheduleAppointmentParams{
+		ID:             appointmentId,
+		VisitTimestamp: pgtype.Timestamptz{Time: data.VisitTimestamp, Valid: true},
+		VisitDate:      visitDateOf(data.VisitTimestamp),
+	})
+	if err != nil {
+		return updated, a.overlapError(ctx, slot, err)
+	}
+
+	if updated, err = decryptAppointment(ctx, a.cipher, updated); err != nil {
+		return updated, err
+	}
+
+	var reason string
+	if data.Reason != nil {
+		reason = *data.Reason
+	}
+
+	_, err = a.queries.CreateAppointmentRescheduleHistory(ctx, database.CreateAppointmentRescheduleHistoryParams{
+		AppointmentID:     appointmentId,
+		OldVisitTimestamp: appointment.VisitTimestamp,
+		NewVisitTimestamp: updated.VisitTimestamp,
+		RescheduledBy:     pgtype.Int4{Int32: data.ActorID, Valid: data.ActorID != 0},
+		Reason:            pgtype.Text{String: reason, Valid: data.Reason != nil},
+	})
+
+	return updated, err
+}
+
+func (a *AppointmentRepository) GetRescheduleHistory(ctx context.Context, appointmentId int32) ([]database.AppointmentRescheduleHistory, error) {
+	res, err := a.queries.GetAppointmentRescheduleHistory(ctx, appointmentId)
+	return res, err
+}
+
+// Reencrypt moves the notes of up to limit appointments after afterId,
+// deleted ones included, to the active key. It returns the last appointment
+// looked at, 0 when there were none left, and how many were rewritten. Run
+// it in a transaction, the appointments stay locked until it ends.
+func (a *AppointmentRepository) Reencrypt(ctx context.Context, afterId int32, limit int32) (int32, int, error) {
+	appointments, err := a.queries.GetAppointmentsForReencryption(ctx, database.GetAppointmentsForReencryptionParams{
+		AfterID:    afterId,
+		MaxResults: limit,
+	})
+	if err != nil || len(appointments) == 0 {
+		return 0, 0, err
+	}
+
+	updated := 0
+	for _, appointment := range appointments {
+		patientNotesCurrent, err := isCurrentText(ctx, a.cipher, appointment.PatientNotes)
+		if err != nil {
+			return 0, updated, err
+		}
+		doctorNotesCurrent, err := isCurrentText(ctx, a.cipher, appointment.DoctorNotes)
+		if err != nil {
+			return 0, updated, err
+		}
+		if patientNotesCurrent && doctorNotesCurrent {
+			continue
+		}
+
+		patientNotes, err := reencryptText(ctx, a.cipher, FieldAppointmentPatientNotes, appointment.PatientNotes)
+		if err != nil {
+			return 0, updated, fmt.Errorf("appointment %d: %w", appointment.ID, err)
+		}
+		doctorNotes, err := reencryptText(ctx, a.cipher, FieldAppointmentDoctorNotes, appointment.DoctorNotes)
+		if err != nil {
+			return 0, updated, fmt.Errorf("appointment %d: %w", appointment.ID, err)
+		}
+
+		err = a.queries.UpdateAppointmentEncryption(ctx, database.UpdateAppointmentEncryptionParams{
+			PatientNotes: patientNotes,
+			DoctorNotes:  doctorNotes,
+			ID:           appointment.ID,
+		})
+		if err != nil {
+			return 0, updated, err
+		}
+		updated++
+	}
+
+	return appointments[len(appointments)-1].ID, updated, nil
+}
+
+// visitDateOf is the calendar day of t in t's own location.
+func visitDateOf(t time.Time) pgtype.Date {
+	return pgtype.Date{
+		Time:  time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()),
+		Valid: true,
+	}
+}
@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
+)
+
+type AuditRepositoryInterface interface {
+	RecordAccess(ctx context.Context, action string, entity string, ids ...int32) error
+	GetAll(ctx context.Context, option GetAuditEntriesOption) (pagination.Page[database.AuditLog], error)
+	GetChain(ctx context.Context, afterId int64, limit int32) ([]database.AuditLog, error)
+	ChainPending(ctx context.Context, limit int32) (int32, error)
+}
+
+type AuditQueriesContract interface {
+    SetAuditSource(context.Context, database.SetAuditSourceParams) error
+    CreateAuditEntries(context.Context, database.CreateAuditEntriesParams) error
+    GetAuditEntries(context.Context, database.GetAuditEntriesParams) ([]database.AuditLog, error)
+    GetAuditChain(context.Context, database.GetAuditChainParams) ([]database.AuditLog, error)
+    ChainAuditEntries(context.Context, int32) (int32, error)
+}
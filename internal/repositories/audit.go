@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// The records whose reads and changes are audited.
+const (
+	AuditEntityPatient     = "patient"
+	AuditEntityAppointment = "appointment"
+	AuditEntityUser        = "user"
+)
+
+var AuditEntities = []string{AuditEntityPatient, AuditEntityAppointment, AuditEntityUser}
+
+// Audit actions. Reads are recorded by the API as views of a single record
+// or lists of them, changes by triggers on the audited tables.
+const (
+	AuditActionView    = "view"
+	AuditActionList    = "list"
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	// AuditActionPurge is a row removed from the database, which the API
+	// never does.
+	AuditActionPurge = "purge"
+)
+
+// AuditSource is who makes a request and from where. It is recorded with
+// every audit entry written while handling the request, including by the
+// triggers of transactions run by TxManager.
+type AuditSource struct {
+	// ActorID is the logged in user, 0 before logging in.
+	ActorID   int32
+	IP        string
+	RequestID string
+}
+
+type auditSourceKey struct{}
+
+func WithAuditSource(ctx context.Context, source AuditSource) context.Context {
+	return context.WithValue(ctx, auditSourceKey{}, source)
+}
+
+func AuditSourceFrom(ctx context.Context) (AuditSource, bool) {
+	source, ok := ctx.Value(auditSourceKey{}).(AuditSource)
+	return source, ok
+}
+
+// setAuditSource hands the context's audit source to the triggers of the
+// transaction queries runs in.
+func setAuditSource(ctx context.Context, queries AuditQueriesContract) error {
+	source, ok := AuditSourceFrom(ctx)
+	if !ok {
+		return nil
+	}
+
+	var actorId string
+	if source.ActorID != 0 {
+		actorId = strconv.Itoa(int(source.ActorID))
+	}
+
+	return queries.SetAuditSource(ctx, database.SetAuditSourceParams{
+		ActorID:   actorId,
+		Ip:        source.IP,
+		RequestID: source.RequestID,
+	})
+}
+
+// GetAuditEntriesOption filters entries by whichever fields are set. From is
+// inclusive and To exclusive.
+type GetAuditEntriesOption struct {
+	Entity   string
+	EntityID *int32
+	ActorID  *int32
+	From     *time.Time
+	To       *time.Time
+	// Entries are always newest first, Page.SortBy and Page.Desc are
+	// ignored.
+	Page pagination.Params
+}
+
+// AuditRepository reads the audit log and records reads in it. It can't
+// change or remove entries, the table refuses to.
+type AuditRepository struct {
+	queries AuditQueriesContract
+}
+
+func NewAuditRepository(queries AuditQueriesContract) AuditRepositoryInterface {
+	return &AuditRepository{
+		queries: queries,
+	}
+}
+
+// RecordAccess writes an action entry for each of the ids, attributed to the
+// context's audit source.
+func (a *AuditRepository) RecordAccess(ctx context.Context, action string, entity string, ids ...int32) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	source, _ := AuditSourceFrom(ctx)
+
+	return a.queries.CreateAuditEntries(ctx, database.CreateAuditEntriesParams{
+		ActorID:   pgtype.Int4{Int32: source.ActorID, Valid: source.ActorID != 0},
+		Action:    action,
+		Entity:    entity,
+		Ip:        pgtype.Text{String: source.IP, Valid: source.IP != ""},
+		RequestID: pgtype.Text{String: source.RequestID, Valid: source.RequestID != ""},
+		EntityIds: ids,
+	})
+}
+
+// GetAll returns a page of entries, pagination.ErrInvalidCursor when the
+// cursor can't be continued from.
+func (a *AuditRepository) GetAll(ctx context.Context, option GetAuditEntriesOption) (pagination.Page[database.AuditLog], error) {
+	page := option.Page
+	page.SortBy = "id"
+	page.Desc = true
+
+	cursor, err := page.DecodeCursor()
+	if err != nil {
+		return pagination.Page[database.AuditLog]{}, err
+	}
+
+	params := database.GetAuditEntriesParams{
+		Entity:     pgtype.Text{String: option.Entity, Valid: option.Entity != ""},
+		MaxResults: page.FetchLimit(),
+	}
+
+	if option.EntityID != nil {
+		params.EntityID = pgtype.Int4{Int32: *option.EntityID, Valid: true}
+	}
+	if option.ActorID != nil {
+		params.ActorID = pgtype.Int4{Int32: *option.ActorID, Valid: true}
+	}
+	if option.From != nil {
+		params.FromTime = pgtype.Timestamptz{Time: *option.From, Valid: true}
+	}
+	if option.To != nil {
+		params.ToTime = pgtype.Timestamptz{Time: *option.To, Valid: true}
+	}
+
+	if cursor != nil {
+		// Entry ids outgrow the cursor's int32 id, the key holds them.
+		cursorId, err := strconv.ParseInt(cursor.Key, 10, 64)
+		if err != nil {
+			return pagination.Page[database.AuditLog]{}, pagination.ErrInvalidCursor
+		}
+
+		params.HasCursor = true
+		params.CursorID = cursorId
+	}
+
+	res, err := a.queries.GetAuditEntries(ctx, params)
+	if err != nil {
+		return pagination.Page[database.AuditLog]{}, err
+	}
+
+	return pagination.NewPage(res, page, func(entry database.AuditLog) (string, int32) {
+		return strconv.FormatInt(entry.ID, 10), 0
+	}), nil
+}
+
+// GetChain returns up to limit entries after afterId, in the order they are
+// chained.
+func (a *AuditRepository) GetChain(ctx context.Context, afterId int64, limit int32) ([]database.AuditLog, error) {
+	return a.queries.GetAuditChain(ctx, database.GetAuditChainParams{
+		AfterID:    afterId,
+		MaxResults: limit,
+	})
+}
+
+// ChainPending appends up to limit entries written since it last ran to the
+// chain and returns how many.
+func (a *AuditRepository) ChainPending(ctx context.Context, limit int32) (int32, error) {
+	return a.queries.ChainAuditEntries(ctx, limit)
+}
@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+type CalendarRepositoryInterface interface {
+	CreateFeedToken(ctx context.Context, doctorId int32, userId int32) (database.CalendarFeedToken, string, error)
+	GetFeedTokens(ctx context.Context, doctorId int32) ([]database.CalendarFeedToken, error)
+	RevokeFeedToken(ctx context.Context, doctorId int32, id int32) (database.CalendarFeedToken, error)
+	UseFeedToken(ctx context.Context, token string) (database.CalendarFeedToken, error)
+	GetDoctorCalendar(ctx context.Context, doctorId int32, from time.Time, to time.Time) ([]database.GetDoctorCalendarRow, error)
+	GetInvite(ctx context.Context, appointmentId int32) (database.GetAppointmentInviteRow, error)
+}
+
+type CalendarQueriesContract interface {
+    CreateCalendarFeedToken(context.Context, database.CreateCalendarFeedTokenParams) (database.CalendarFeedToken, error)
+    GetCalendarFeedTokens(context.Context, int32) ([]database.CalendarFeedToken, error)
+    RevokeCalendarFeedToken(context.Context, database.RevokeCalendarFeedTokenParams) (database.CalendarFeedToken, error)
+    UseCalendarFeedToken(context.Context, string) (database.CalendarFeedToken, error)
+    GetDoctorCalendar(context.Context, database.GetDoctorCalendarParams) ([]database.GetDoctorCalendarRow, error)
+    GetAppointmentInvite(context.Context, int32) (database.GetAppointmentInviteRow, error)
+}
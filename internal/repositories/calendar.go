@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxCalendarEvents bounds a feed, calendar apps poll it every few hours.
+const maxCalendarEvents = 2000
+
+type CalendarRepository struct {
+	queries CalendarQueriesContract
+	cipher  FieldCipher
+}
+
+// NewCalendarRepository decrypts the patient's email of invites with
+// cipher.
+func NewCalendarRepository(queries CalendarQueriesContract, cipher FieldCipher) CalendarRepositoryInterface {
+	return &CalendarRepository{
+		queries: queries,
+		cipher:  cipher,
+	}
+}
+
+// CreateFeedToken issues a token for the doctor's feed. Only its hash is
+// stored, the token is returned this once.
+func (cr *CalendarRepository) CreateFeedToken(ctx context.Context, doctorId int32, userId int32) (database.CalendarFeedToken, string, error) {
+
+	token, err := newCalendarFeedToken()
+	if err != nil {
+		return database.CalendarFeedToken{}, "", err
+	}
+
+	res, err := cr.queries.CreateCalendarFeedToken(ctx, database.CreateCalendarFeedTokenParams{
+		DoctorID:  doctorId,
+		TokenHash: hashCalendarFeedToken(token),
+		CreatedBy: pgtype.Int4{Int32: userId, Valid: true},
+	})
+
+	return res, token, err
+}
+
+func (cr *CalendarRepository) GetFeedTokens(ctx context.Context, doctorId int32) ([]database.CalendarFeedToken, error) {
+
+	res, err := cr.queries.GetCalendarFeedTokens(ctx, doctorId)
+
+	return res, err
+}
+
+func (cr *CalendarRepository) RevokeFeedToken(ctx context.Context, doctorId int32, id int32) (database.CalendarFeedToken, error) {
+
+	res, err := cr.queries.RevokeCalendarFeedToken(ctx, database.RevokeCalendarFeedTokenParams{
+		ID:       id,
+		DoctorID: doctorId,
+	})
+
+	return res, err
+}
+
+// UseFeedToken returns the live token matching token, pgx.ErrNoRows when it
+// is unknown or revoked.
+func (cr *CalendarRepository) UseFeedToken(ctx context.Context, token string) (database.CalendarFeedToken, error) {
+
+	res, err := cr.queries.UseCalendarFeedToken(ctx, hashCalendarFeedToken(token))
+
+	return res, err
+}
+
+func (cr *CalendarRepository) GetDoctorCalendar(ctx context.Context, doctorId int32, from time.Time, to time.Time) ([]database.GetDoctorCalendarRow, error) {
+
+	res, err := cr.queries.GetDoctorCalendar(ctx, database.GetDoctorCalendarParams{
+		DoctorID:   pgtype.Int4{Int32: doctorId, Valid: true},
+		VisitFrom:  pgtype.Timestamptz{Time: from, Valid: true},
+		VisitTo:    pgtype.Timestamptz{Time: to, Valid: true},
+		MaxResults: maxCalendarEvents,
+	})
+
+	return res, err
+}
+
+func (cr *CalendarRepository) GetInvite(ctx context.Context, appointmentId int32) (database.GetAppointmentInviteRow, error) {
+
+	res, err := cr.queries.GetAppointmentInvite(ctx, appointmentId)
+	if err != nil {
+		return res, err
+	}
+
+	res.PatientEmail, err = cr.cipher.Decrypt(ctx, FieldPatientEmail, res.PatientEmail)
+
+	return res, err
+}
+
+func newCalendarFeedToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "cal_" + hex.EncodeToString(b), nil
+}
+
+func hashCalendarFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+)
+
+type EncryptionKeyRepositoryInterface interface {
+	GetKeys(ctx context.Context) ([]database.EncryptionKey, error)
+	CreateKey(ctx context.Context, purpose string, wrappedKey []byte, masterKeyId string) (database.EncryptionKey, error)
+	RewrapKey(ctx context.Context, id int32, wrappedKey []byte, masterKeyId string) error
+	RetireDataKeys(ctx context.Context, activeId int32) error
+}
+
+type EncryptionKeyQueriesContract interface {
+    GetEncryptionKeys(context.Context) ([]database.EncryptionKey, error)
+    CreateEncryptionKey(context.Context, database.CreateEncryptionKeyParams) (database.EncryptionKey, error)
+    RewrapEncryptionKey(context.Context, database.RewrapEncryptionKeyParams) error
+    RetireEncryptionKeys(context.Context, int32) error
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+)
+
+// EncryptionKeyRepository stores the wrapped keys of fieldcrypt.Keyring.
+type EncryptionKeyRepository struct {
+	queries EncryptionKeyQueriesContract
+}
+
+func NewEncryptionKeyRepository(queries EncryptionKeyQueriesContract) EncryptionKeyRepositoryInterface {
+	return &EncryptionKeyRepository{
+		queries: queries,
+	}
+}
+
+func (er *EncryptionKeyRepository) GetKeys(ctx context.Context) ([]database.EncryptionKey, error) {
+
+	res, err := er.queries.GetEncryptionKeys(ctx)
+
+	return res, err
+}
+
+func (er *EncryptionKeyRepository) CreateKey(ctx context.Context, purpose string, wrappedKey []byte, masterKeyId string) (database.EncryptionKey, error) {
+
+	res, err := er.queries.CreateEncryptionKey(ctx, database.CreateEncryptionKeyParams{
+		Purpose:     purpose,
+		WrappedKey:  wrappedKey,
+		MasterKeyID: masterKeyId,
+	})
+
+	return res, err
+}
+
+func (er *EncryptionKeyRepository) RewrapKey(ctx context.Context, id int32, wrappedKey []byte, masterKeyId string) error {
+
+	return er.queries.RewrapEncryptionKey(ctx, database.RewrapEncryptionKeyParams{
+		ID:          id,
+		WrappedKey:  wrappedKey,
+		MasterKeyID: masterKeyId,
+	})
+}
+
+// RetireDataKeys retires every data key but activeId. Retired keys still
+// decrypt the values encrypted with them.
+func (er *EncryptionKeyRepository) RetireDataKeys(ctx context.Context, activeId int32) error {
+
+	return er.queries.RetireEncryptionKeys(ctx, activeId)
+}
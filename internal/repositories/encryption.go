@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"patient-appointment-demo-go/internal/database"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// FieldCipher encrypts the columns holding patients' health information,
+// see fieldcrypt.Keyring. field is the column a value is stored in.
+type FieldCipher interface {
+	Encrypt(ctx context.Context, field string, plaintext string) (string, error)
+	Decrypt(ctx context.Context, field string, value string) (string, error)
+	// BlindIndex is what an encrypted value is looked up by, the same for
+	// equal values.
+	BlindIndex(ctx context.Context, field string, value string) ([]byte, error)
+	// IsCurrent reports whether value is encrypted with the active key.
+	IsCurrent(ctx context.Context, value string) (bool, error)
+}
+
+// The encrypted columns. Outbox payloads are encrypted whole, they copy
+// the patient and appointment columns.
+const (
+	FieldPatientPhone            = "patients.phone"
+	FieldPatientEmail            = "patients.email"
+	FieldPatientAddress          = "patients.address"
+	FieldAppointmentPatientNotes = "appointments.patient_notes"
+	FieldAppointmentDoctorNotes  = "appointments.doctor_notes"
+	FieldOutboxPayload           = "outbox_events.payload"
+)
+
+func encryptText(ctx context.Context, cipher FieldCipher, field string, value pgtype.Text) (pgtype.Text, error) {
+	if !value.Valid {
+		return value, nil
+	}
+
+	encrypted, err := cipher.Encrypt(ctx, field, value.String)
+	return pgtype.Text{String: encrypted, Valid: true}, err
+}
+
+func decryptText(ctx context.Context, cipher FieldCipher, field string, value pgtype.Text) (pgtype.Text, error) {
+	if !value.Valid {
+		return value, nil
+	}
+
+	decrypted, err := cipher.Decrypt(ctx, field, value.String)
+	return pgtype.Text{String: decrypted, Valid: true}, err
+}
+
+// emailIndex is the blind index of an email, which matches whatever its
+// case.
+func emailIndex(ctx context.Context, cipher FieldCipher, email string) ([]byte, error) {
+	return cipher.BlindIndex(ctx, FieldPatientEmail, strings.ToLower(strings.TrimSpace(email)))
+}
+
+// phoneIndex is the blind index of a phone's digits, which matches however
+// the number is formatted.
+func phoneIndex(ctx context.Context, cipher FieldCipher, phone string) ([]byte, error) {
+	return cipher.BlindIndex(ctx, FieldPatientPhone, phoneDigits(phone))
+}
+
+func decryptPatient(ctx context.Context, cipher FieldCipher, patient database.Patient) (database.Patient, error) {
+	var err error
+
+	if patient.Phone, err = decryptText(ctx, cipher, FieldPatientPhone, patient.Phone); err != nil {
+		return patient, err
+	}
+	if patient.Email, err = cipher.Decrypt(ctx, FieldPatientEmail, patient.Email); err != nil {
+		return patient, err
+	}
+	if patient.Address, err = decryptText(ctx, cipher, FieldPatientAddress, patient.Address); err != nil {
+		return patient, err
+	}
+
+	return patient, nil
+}
+
+func decryptPatients(ctx context.Context, cipher FieldCipher, patients []database.Patient) ([]database.Patient, error) {
+	for i := range patients {
+		var err error
+		if patients[i], err = decryptPatient(ctx, cipher, patients[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return patients, nil
+}
+
+// decryptPatientJSON decrypts a patient row stored as JSON, like a merged
+// duplicate, and leaves out its blind indexes.
+func decryptPatientJSON(ctx context.Context, cipher FieldCipher, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	var row map[string]any
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, err
+	}
+
+	for key, field := range map[string]string{
+		"phone":   FieldPatientPhone,
+		"email":   FieldPatientEmail,
+		"address": FieldPatientAddress,
+	} {
+		value, ok := row[key].(string)
+		if !ok {
+			continue
+		}
+
+		decrypted, err := cipher.Decrypt(ctx, field, value)
+		if err != nil {
+			return nil, err
+		}
+		row[key] = decrypted
+	}
+
+	delete(row, "email_bidx")
+	delete(row, "phone_bidx")
+
+	return json.Marshal(row)
+}
+
+func decryptAppointment(ctx context.Context, cipher FieldCipher, appointment database.Appointment) (database.Appointment, error) {
+	var err error
+
+	if appointment.PatientNotes, err = decryptText(ctx, cipher, FieldAppointmentPatientNotes, appointment.PatientNotes); err != nil {
+		return appointment, err
+	}
+	if appointment.DoctorNotes, err = decryptText(ctx, cipher, FieldAppointmentDoctorNotes, appointment.DoctorNotes); err != nil {
+		return appointment, err
+	}
+
+	return appointment, nil
+}
+
+func decryptAppointments(ctx context.Context, cipher FieldCipher, appointments []database.Appointment) ([]database.Appointment, error) {
+	for i := range appointments {
+		var err error
+		if appointments[i], err = decryptAppointment(ctx, cipher, appointments[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return appointments, nil
+}
+
+// isCurrentText reports whether a nullable value needs no re-encryption.
+func isCurrentText(ctx context.Context, cipher FieldCipher, value pgtype.Text) (bool, error) {
+	if !value.Valid {
+		return true, nil
+	}
+
+	return cipher.IsCurrent(ctx, value.String)
+}
+
+// reencryptText decrypts value and encrypts it again with the active key.
+func reencryptText(ctx context.Context, cipher FieldCipher, field string, value pgtype.Text) (pgtype.Text, error) {
+	decrypted, err := decryptText(ctx, cipher, field, value)
+	if err != nil {
+		return value, err
+	}
+
+	return encryptText(ctx, cipher, field, decrypted)
+}
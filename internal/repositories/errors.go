@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	pgForeignKeyViolation  = "23503"
+	pgUniqueViolation      = "23505"
+	pgExclusionViolation   = "23P01"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+// IsSerializationFailure reports whether err is a Postgres error that is
+// safe to retry by re-running the whole transaction.
+func IsSerializationFailure(err error) bool {
+	code := pgErrorCode(err)
+	return code == pgSerializationFailure || code == pgDeadlockDetected
+}
+
+// IsUniqueViolation reports whether err violates the named unique
+// constraint, or any unique constraint when constraint is empty.
+func IsUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolation {
+		return false
+	}
+
+	return constraint == "" || pgErr.ConstraintName == constraint
+}
+
+// IsExclusionViolation reports whether err violates the named exclusion
+// constraint, or any exclusion constraint when constraint is empty.
+func IsExclusionViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgExclusionViolation {
+		return false
+	}
+
+	return constraint == "" || pgErr.ConstraintName == constraint
+}
+
+// IsForeignKeyViolation reports whether err violates the named foreign key
+// constraint, or any foreign key when constraint is empty.
+func IsForeignKeyViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgForeignKeyViolation {
+		return false
+	}
+
+	return constraint == "" || pgErr.ConstraintName == constraint
+}
+
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return ""
+	}
+	return pgErr.Code
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+)
+
+type OutboxRepositoryInterface interface {
+	Enqueue(ctx context.Context, eventType EventType, data any) (database.OutboxEvent, error)
+	Reencrypt(ctx context.Context, afterId int64, limit int32) (int64, int, error)
+}
+
+type OutboxQueriesContract interface {
+    CreateOutboxEvent(context.Context, database.CreateOutboxEventParams) (database.OutboxEvent, error)
+    GetOutboxEventsForReencryption(context.Context, database.GetOutboxEventsForReencryptionParams) ([]database.OutboxEvent, error)
+    UpdateOutboxEventPayload(context.Context, database.UpdateOutboxEventPayloadParams) error
+}
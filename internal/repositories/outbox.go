@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+)
+
+// EventType names an event sent to webhooks.
+type EventType string
+
+const (
+	EventAppointmentCreated        EventType = "appointment.created"
+	EventAppointmentUpdated        EventType = "appointment.updated"
+	EventAppointmentRescheduled    EventType = "appointment.rescheduled"
+	EventAppointmentCheckedIn      EventType = "appointment.checked_in"
+	EventAppointmentInConsultation EventType = "appointment.in_consultation"
+	EventAppointmentCompleted      EventType = "appointment.completed"
+	EventAppointmentNoShow         EventType = "appointment.no_show"
+	EventAppointmentCancelled      EventType = "appointment.cancelled"
+	EventAppointmentDeleted        EventType = "appointment.deleted"
+	EventAppointmentRestored       EventType = "appointment.restored"
+	EventPatientCreated            EventType = "patient.created"
+	EventPatientUpdated            EventType = "patient.updated"
+	EventPatientDeleted            EventType = "patient.deleted"
+	EventPatientMerged             EventType = "patient.merged"
+	EventPatientRestored           EventType = "patient.restored"
+	// EventWebhookTest is only sent by the test endpoint, never queued.
+	EventWebhookTest EventType = "webhook.test"
+)
+
+// EventTypes are the events a webhook can subscribe to.
+var EventTypes = []EventType{
+	EventAppointmentCreated,
+	EventAppointmentUpdated,
+	EventAppointmentRescheduled,
+	EventAppointmentCheckedIn,
+	EventAppointmentInConsultation,
+	EventAppointmentCompleted,
+	EventAppointmentNoShow,
+	EventAppointmentCancelled,
+	EventAppointmentDeleted,
+	EventAppointmentRestored,
+	EventPatientCreated,
+	EventPatientUpdated,
+	EventPatientDeleted,
+	EventPatientMerged,
+	EventPatientRestored,
+}
+
+func IsEventType(s string) bool {
+	for _, t := range EventTypes {
+		if string(t) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AppointmentStatusEvent is the event sent when an appointment moves to
+// status.
+func AppointmentStatusEvent(status AppointmentStatus) EventType {
+	return EventType("appointment." + string(status))
+}
+
+// OutboxRepository writes events for the webhook dispatcher. Use the one in
+// TxRepositories so the event commits or rolls back with the change it
+// describes. Payloads carry patients' details, they are stored encrypted
+// and decrypted by DecryptOutboxPayload when delivered.
+type OutboxRepository struct {
+	queries OutboxQueriesContract
+	cipher  FieldCipher
+}
+
+func NewOutboxRepository(queries OutboxQueriesContract, cipher FieldCipher) OutboxRepositoryInterface {
+	return &OutboxRepository{
+		queries: queries,
+		cipher:  cipher,
+	}
+}
+
+// Enqueue stores data, encoded as JSON, as the payload of an eventType event.
+func (o *OutboxRepository) Enqueue(ctx context.Context, eventType EventType, data any) (database.OutboxEvent, error) {
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return database.OutboxEvent{}, fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+
+	payload, err = encryptOutboxPayload(ctx, o.cipher, payload)
+	if err != nil {
+		return database.OutboxEvent{}, err
+	}
+
+	event, err := o.queries.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		EventType: string(eventType),
+		Payload:   payload,
+	})
+
+	return event, err
+}
+
+// Reencrypt moves the payloads of up to limit events after afterId to the
+// active key, encrypting the ones stored before payloads were. It returns
+// the last event looked at, 0 when there were none left, and how many were
+// rewritten. Run it in a transaction, the events stay locked until it ends.
+func (o *OutboxRepository) Reencrypt(ctx context.Context, afterId int64, limit int32) (int64, int, error) {
+	events, err := o.queries.GetOutboxEventsForReencryption(ctx, database.GetOutboxEventsForReencryptionParams{
+		AfterID:    afterId,
+		MaxResults: limit,
+	})
+	if err != nil || len(events) == 0 {
+		return 0, 0, err
+	}
+
+	updated := 0
+	for _, event := range events {
+		var encrypted string
+		if json.Unmarshal(event.Payload, &encrypted) == nil {
+			current, err := o.cipher.IsCurrent(ctx, encrypted)
+			if err != nil {
+				return 0, updated, err
+			}
+			if current {
+				continue
+			}
+		}
+
+		decrypted, err := DecryptOutboxPayload(ctx, o.cipher, event.Payload)
+		if err != nil {
+			return 0, updated, fmt.Errorf("outbox event %d: %w", event.ID, err)
+		}
+
+		payload, err := encryptOutboxPayload(ctx, o.cipher, decrypted)
+		if err != nil {
+			return 0, updated, err
+		}
+
+		err = o.queries.UpdateOutboxEventPayload(ctx, database.UpdateOutboxEventPayloadParams{
+			ID:      event.ID,
+			Payload: payload,
+		})
+		if err != nil {
+			return 0, updated, err
+		}
+		updated++
+	}
+
+	return events[len(events)-1].ID, updated, nil
+}
+
+// encryptOutboxPayload encrypts a JSON payload into a JSON string, which
+// the payload column takes as it is.
+func encryptOutboxPayload(ctx context.Context, cipher FieldCipher, payload []byte) ([]byte, error) {
+	encrypted, err := cipher.Encrypt(ctx, FieldOutboxPayload, string(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(encrypted)
+}
+
+// DecryptOutboxPayload returns the JSON of an event's stored payload.
+// Payloads stored before they were encrypted are returned as they are.
+func DecryptOutboxPayload(ctx context.Context, cipher FieldCipher, payload []byte) ([]byte, error) {
+	var encrypted string
+	if err := json.Unmarshal(payload, &encrypted); err != nil {
+		// an object, never encrypted
+		return payload, nil
+	}
+
+	decrypted, err := cipher.Decrypt(ctx, FieldOutboxPayload, encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(decrypted), nil
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"patient-appointment-demo-go/internal/pagination"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// timestampKey is the cursor key of a timestamp sort column. The queries
+// sort a missing created_at as the epoch, so does the key.
+func timestampKey(t pgtype.Timestamp) string {
+	if !t.Valid {
+		return time.Unix(0, 0).UTC().Format(time.RFC3339Nano)
+	}
+	return t.Time.Format(time.RFC3339Nano)
+}
+
+func timestamptzKey(t pgtype.Timestamptz) string {
+	if !t.Valid {
+		return time.Unix(0, 0).UTC().Format(time.RFC3339Nano)
+	}
+	return t.Time.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTimeKey(key string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, key)
+	if err != nil {
+		return time.Time{}, pagination.ErrInvalidCursor
+	}
+	return t, nil
+}
@@ -3,20 +3,42 @@ package repositories
 import (
 	"context"
 	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
 )
 
 type PatientRepositoryInterface interface {
-	GetAll(ctx context.Context, option GetPatientsOption) ([]database.Patient, error)
+	GetAll(ctx context.Context, option GetPatientsOption) (pagination.Page[database.Patient], error)
+	Search(ctx context.Context, query string, limit int32) ([]database.SearchPatientsRow, error)
+	FindDuplicates(ctx context.Context, data FindDuplicatesParams) ([]DuplicateCandidate, error)
+	Merge(ctx context.Context, data MergePatientsParams) (PatientMergeResult, error)
+	GetMerges(ctx context.Context, survivorId int32) ([]database.PatientMerge, error)
 	Get(ctx context.Context, id int32) (database.Patient, error)
+	GetWithDeleted(ctx context.Context, id int32) (database.Patient, error)
 	Create(ctx context.Context, data CreatePatientParams) (database.Patient, error)
 	Update(ctx context.Context, id int32, data UpdatePatientParams) (database.Patient, error)
 	Delete(ctx context.Context, id int32) error
+	Restore(ctx context.Context, id int32) (database.Patient, error)
+	Reencrypt(ctx context.Context, afterId int32, limit int32) (int32, int, error)
 }
 
 type PatientQueriesContract interface {
     GetAllPatients(context.Context, database.GetAllPatientsParams) ([]database.Patient, error)
+    SearchPatients(context.Context, database.SearchPatientsParams) ([]database.SearchPatientsRow, error)
+    FindDuplicatePatients(context.Context, database.FindDuplicatePatientsParams) ([]database.FindDuplicatePatientsRow, error)
     GetPatientByID(context.Context, int32) (database.Patient, error)
+    GetPatientByIDWithDeleted(context.Context, int32) (database.Patient, error)
+    GetPatientForUpdate(context.Context, int32) (database.Patient, error)
+    GetPatientMergeOverlaps(context.Context, database.GetPatientMergeOverlapsParams) ([]database.Appointment, error)
+    MovePatientAppointments(context.Context, database.MovePatientAppointmentsParams) ([]database.Appointment, error)
+    MovePatientAppointmentSeries(context.Context, database.MovePatientAppointmentSeriesParams) (int64, error)
+    MovePatientWaitlistEntries(context.Context, database.MovePatientWaitlistEntriesParams) (int64, error)
+    FillPatientFromDuplicate(context.Context, database.FillPatientFromDuplicateParams) (database.Patient, error)
+    CreatePatientMerge(context.Context, database.CreatePatientMergeParams) (database.PatientMerge, error)
+    GetPatientMerges(context.Context, int32) ([]database.PatientMerge, error)
     CreatePatient(context.Context, database.CreatePatientParams) (database.Patient, error)
     UpdatePatient(context.Context, database.UpdatePatientParams) (database.Patient, error)
     DeletePatient(context.Context, int32) error
+    RestorePatient(context.Context, int32) (database.Patient, error)
+    GetPatientsForReencryption(context.Context, database.GetPatientsForReencryptionParams) ([]database.Patient, error)
+    UpdatePatientEncryption(context.Context, database.UpdatePatientEncryptionParams) error
 }
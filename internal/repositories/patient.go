@@ -1,15 +1,24 @@
 package repositories
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"math/big"
 	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type PatientRepository struct {
 	queries PatientQueriesContract
+	cipher  FieldCipher
 }
 
 type CreatePatientParams struct {
@@ -34,34 +43,481 @@ type UpdatePatientParams struct {
 	Address string
 }
 
+const (
+	PatientSortName      = "name"
+	PatientSortAge       = "age"
+	PatientSortCreatedAt = "created_at"
+)
+
 type GetPatientsOption struct {
-	Name          string
-	SortBy        string
-	SortDirection string
+	Name string
+	// IncludeDeleted lists deleted patients along with the others.
+	IncludeDeleted bool
+	// Page.SortBy is one of the PatientSort values, created_at when empty.
+	Page pagination.Params
 }
 
-func NewPatientRepository(queries PatientQueriesContract) PatientRepositoryInterface {
+// What a possible duplicate has in common with a patient.
+const (
+	DuplicateReasonName  = "name"
+	DuplicateReasonEmail = "email"
+	DuplicateReasonPhone = "phone"
+	DuplicateReasonAge   = "age"
+)
+
+const (
+	// DuplicateThreshold is the score from which a patient is reported as a
+	// possible duplicate. A same email, or a same name, reaches it alone; a
+	// shared phone only does with a similar name, as families share phones.
+	DuplicateThreshold = 0.5
+
+	// similar names are those pg_trgm's % operator matches by default
+	similarNameThreshold = 0.3
+	maxDuplicateMatches  = 20
+)
+
+var ErrMergeIntoItself = errors.New("a patient can't be merged into itself")
+
+type FindDuplicatesParams struct {
+	Name  string
+	Email string
+	Phone string
+	Age   int32
+	// ExcludeID is the patient the details belong to, 0 for a new one.
+	ExcludeID int32
+}
+
+// DuplicateCandidate is a patient that may be the same person, scored from
+// 0 to 1 with the reasons it was matched on.
+type DuplicateCandidate struct {
+	Patient database.Patient
+	Score   float64
+	Reasons []string
+}
+
+type MergePatientsParams struct {
+	SurvivorID  int32
+	DuplicateID int32
+	MergedBy    int32
+}
+
+type PatientMergeResult struct {
+	Survivor database.Patient
+	Merge    database.PatientMerge
+	// Appointments are those moved from the duplicate to the survivor.
+	Appointments []database.Appointment
+}
+
+// NewPatientRepository stores patients' phone, email and address encrypted
+// with cipher and decrypts them on the way out.
+func NewPatientRepository(queries PatientQueriesContract, cipher FieldCipher) PatientRepositoryInterface {
 	return &PatientRepository{
 		queries: queries,
+		cipher:  cipher,
+	}
+}
+
+// GetAll returns a page of patients, pagination.ErrInvalidCursor when the
+// cursor can't be continued from.
+func (p *PatientRepository) GetAll(ctx context.Context, option GetPatientsOption) (pagination.Page[database.Patient], error) {
+	page := option.Page
+	if page.SortBy == "" {
+		page.SortBy = PatientSortCreatedAt
+	}
+
+	cursor, err := page.DecodeCursor()
+	if err != nil {
+		return pagination.Page[database.Patient]{}, err
+	}
+
+	params := database.GetAllPatientsParams{
+		Name:           option.Name,
+		IncludeDeleted: option.IncludeDeleted,
+		SortBy:         page.SortBy,
+		SortDesc:       page.Desc,
+		MaxResults:     page.FetchLimit(),
+	}
+
+	if cursor != nil {
+		params.HasCursor = true
+		params.CursorID = cursor.ID
+
+		switch page.SortBy {
+		case PatientSortName:
+			params.CursorName = cursor.Key
+		case PatientSortAge:
+			age, err := strconv.ParseInt(cursor.Key, 10, 32)
+			if err != nil {
+				return pagination.Page[database.Patient]{}, pagination.ErrInvalidCursor
+			}
+			params.CursorAge = int32(age)
+		default:
+			createdAt, err := parseTimeKey(cursor.Key)
+			if err != nil {
+				return pagination.Page[database.Patient]{}, err
+			}
+			params.CursorCreatedAt = pgtype.Timestamp{Time: createdAt, Valid: true}
+		}
+	}
+
+	patients, err := p.queries.GetAllPatients(ctx, params)
+	if err != nil {
+		return pagination.Page[database.Patient]{}, err
+	}
+
+	if patients, err = decryptPatients(ctx, p.cipher, patients); err != nil {
+		return pagination.Page[database.Patient]{}, err
+	}
+
+	return pagination.NewPage(patients, page, func(patient database.Patient) (string, int32) {
+		switch page.SortBy {
+		case PatientSortName:
+			return patient.Name, patient.ID
+		case PatientSortAge:
+			age := int64(-1)
+			if patient.Age.Valid {
+				age = int64(patient.Age.Int16)
+			}
+			return strconv.FormatInt(age, 10), patient.ID
+		default:
+			return timestampKey(patient.CreatedAt), patient.ID
+		}
+	}), nil
+}
+
+// Search returns up to limit patients matching query, best match first.
+// Every word of query has to start a word of the patient's name, unless the
+// query as a whole is contained in or close to their name. Emails and phones
+// are encrypted, a query only matches them whole: an email whatever its
+// case, a phone number by its digits however they are formatted.
+func (p *PatientRepository) Search(ctx context.Context, query string, limit int32) ([]database.SearchPatientsRow, error) {
+	if limit <= 0 {
+		limit = pagination.DefaultLimit
+	}
+	if limit > pagination.MaxLimit {
+		limit = pagination.MaxLimit
+	}
+
+	query = strings.TrimSpace(query)
+
+	params := database.SearchPatientsParams{
+		PrefixQuery: searchPrefixQuery(query),
+		Term:        query,
+		Pattern:     "%" + escapeLike(query) + "%",
+		MaxResults:  limit,
+	}
+
+	var err error
+	if strings.Contains(query, "@") {
+		if params.EmailBidx, err = emailIndex(ctx, p.cipher, query); err != nil {
+			return nil, err
+		}
+	}
+	if digits := searchDigits(query); digits != "" {
+		if params.PhoneBidx, err = phoneIndex(ctx, p.cipher, digits); err != nil {
+			return nil, err
+		}
 	}
+
+	patients, err := p.queries.SearchPatients(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range patients {
+		row := &patients[i]
+		if row.Phone, err = decryptText(ctx, p.cipher, FieldPatientPhone, row.Phone); err != nil {
+			return nil, err
+		}
+		if row.Email, err = p.cipher.Decrypt(ctx, FieldPatientEmail, row.Email); err != nil {
+			return nil, err
+		}
+		if row.Address, err = decryptText(ctx, p.cipher, FieldPatientAddress, row.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	return patients, nil
 }
 
-func (p *PatientRepository) GetAll(ctx context.Context, option GetPatientsOption) ([]database.Patient, error) {
+// searchPrefixQuery turns the words of query into a to_tsquery string that
+// needs every word as the prefix of a lexeme, e.g. "jo smi" into
+// "jo:* & smi:*". Anything but letters and digits only separates words, so
+// the result is always valid tsquery syntax.
+func searchPrefixQuery(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
 
-	patients, err := p.queries.GetAllPatients(ctx, database.GetAllPatientsParams{
-		Name:          option.Name,
-		SortBy:        option.SortBy,
-		SortDirection: option.SortDirection,
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+
+	return strings.Join(words, " & ")
+}
+
+// searchDigits is the digits of query when it looks like a phone number, at
+// least three digits and no letters, and empty otherwise.
+func searchDigits(query string) string {
+	var digits strings.Builder
+	for _, r := range query {
+		if unicode.IsLetter(r) {
+			return ""
+		}
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+
+	if digits.Len() < 3 {
+		return ""
+	}
+
+	return digits.String()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// FindDuplicates returns the patients scoring at least DuplicateThreshold
+// as the same person as data, highest score first.
+func (p *PatientRepository) FindDuplicates(ctx context.Context, data FindDuplicatesParams) ([]DuplicateCandidate, error) {
+	params := database.FindDuplicatePatientsParams{
+		Name:       strings.TrimSpace(data.Name),
+		Age:        data.Age,
+		ExcludeID:  data.ExcludeID,
+		MaxResults: maxDuplicateMatches,
+	}
+
+	var err error
+	if strings.TrimSpace(data.Email) != "" {
+		if params.EmailBidx, err = emailIndex(ctx, p.cipher, data.Email); err != nil {
+			return nil, err
+		}
+	}
+	if phoneDigits(data.Phone) != "" {
+		if params.PhoneBidx, err = phoneIndex(ctx, p.cipher, data.Phone); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := p.queries.FindDuplicatePatients(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []DuplicateCandidate{}
+	for _, row := range rows {
+		score, reasons := duplicateScore(row)
+		if score < DuplicateThreshold {
+			continue
+		}
+
+		patient, err := decryptPatient(ctx, p.cipher, database.Patient{
+			ID:        row.ID,
+			Name:      row.Name,
+			Phone:     row.Phone,
+			Email:     row.Email,
+			Age:       row.Age,
+			Weight:    row.Weight,
+			Height:    row.Height,
+			Gender:    row.Gender,
+			Address:   row.Address,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, DuplicateCandidate{
+			Patient: patient,
+			Score:   score,
+			Reasons: reasons,
+		})
+	}
+
+	slices.SortStableFunc(candidates, func(a, b DuplicateCandidate) int {
+		return cmp.Compare(b.Score, a.Score)
 	})
 
-	return patients, err
+	return candidates, nil
+}
+
+// duplicateScore weighs what a match has in common: up to 0.5 for the name
+// by its similarity, 0.5 for the email, 0.3 for the phone and 0.1 for the
+// age, at most 1 in total.
+func duplicateScore(row database.FindDuplicatePatientsRow) (float64, []string) {
+	var score float64
+	var reasons []string
+
+	if row.NameSimilarity >= similarNameThreshold {
+		score += 0.5 * float64(row.NameSimilarity)
+		reasons = append(reasons, DuplicateReasonName)
+	}
+	if row.EmailMatch {
+		score += 0.5
+		reasons = append(reasons, DuplicateReasonEmail)
+	}
+	if row.PhoneMatch {
+		score += 0.3
+		reasons = append(reasons, DuplicateReasonPhone)
+	}
+	if row.AgeMatch {
+		score += 0.1
+		reasons = append(reasons, DuplicateReasonAge)
+	}
+
+	return min(score, 1), reasons
+}
+
+func phoneDigits(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
+// Merge moves the appointments, series and waitlist entries of the
+// duplicate to the survivor, fills in the details the survivor lacks from
+// it, records the merge and deletes the duplicate. Run it in a transaction.
+// pgx.ErrNoRows is returned when either patient doesn't exist, an
+// AppointmentOverlapError listing the appointments of both patients that
+// overlap when the duplicate booked the same time as the survivor.
+func (p *PatientRepository) Merge(ctx context.Context, data MergePatientsParams) (PatientMergeResult, error) {
+	if data.SurvivorID == data.DuplicateID {
+		return PatientMergeResult{}, ErrMergeIntoItself
+	}
+
+	// lock in id order so two merges of the same patients can't deadlock
+	ids := []int32{data.SurvivorID, data.DuplicateID}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if _, err := p.queries.GetPatientForUpdate(ctx, id); err != nil {
+			return PatientMergeResult{}, err
+		}
+	}
+
+	// checked before moving, the patient overlap constraint would otherwise
+	// abort the transaction
+	overlaps, err := p.queries.GetPatientMergeOverlaps(ctx, database.GetPatientMergeOverlapsParams{
+		SurvivorID:  data.SurvivorID,
+		DuplicateID: data.DuplicateID,
+	})
+	if err != nil {
+		return PatientMergeResult{}, err
+	}
+	if len(overlaps) > 0 {
+		if overlaps, err = decryptAppointments(ctx, p.cipher, overlaps); err != nil {
+			return PatientMergeResult{}, err
+		}
+		return PatientMergeResult{}, AppointmentOverlapError{Conflicts: overlaps}
+	}
+
+	appointments, err := p.queries.MovePatientAppointments(ctx, database.MovePatientAppointmentsParams{
+		SurvivorID:  data.SurvivorID,
+		DuplicateID: data.DuplicateID,
+	})
+	if err != nil {
+		return PatientMergeResult{}, err
+	}
+
+	series, err := p.queries.MovePatientAppointmentSeries(ctx, database.MovePatientAppointmentSeriesParams{
+		SurvivorID:  data.SurvivorID,
+		DuplicateID: data.DuplicateID,
+	})
+	if err != nil {
+		return PatientMergeResult{}, err
+	}
+
+	waitlist, err := p.queries.MovePatientWaitlistEntries(ctx, database.MovePatientWaitlistEntriesParams{
+		SurvivorID:  data.SurvivorID,
+		DuplicateID: data.DuplicateID,
+	})
+	if err != nil {
+		return PatientMergeResult{}, err
+	}
+
+	survivor, err := p.queries.FillPatientFromDuplicate(ctx, database.FillPatientFromDuplicateParams{
+		SurvivorID:  data.SurvivorID,
+		DuplicateID: data.DuplicateID,
+	})
+	if err != nil {
+		return PatientMergeResult{}, err
+	}
+
+	merge, err := p.queries.CreatePatientMerge(ctx, database.CreatePatientMergeParams{
+		SurvivorID:           data.SurvivorID,
+		AppointmentsMoved:    int32(len(appointments)),
+		SeriesMoved:          int32(series),
+		WaitlistEntriesMoved: int32(waitlist),
+		MergedBy:             pgtype.Int4{Int32: data.MergedBy, Valid: data.MergedBy != 0},
+		DuplicateID:          data.DuplicateID,
+	})
+	if err != nil {
+		return PatientMergeResult{}, err
+	}
+
+	if err := p.queries.DeletePatient(ctx, data.DuplicateID); err != nil {
+		return PatientMergeResult{}, err
+	}
+
+	if survivor, err = decryptPatient(ctx, p.cipher, survivor); err != nil {
+		return PatientMergeResult{}, err
+	}
+	if merge.Duplicate, err = decryptPatientJSON(ctx, p.cipher, merge.Duplicate); err != nil {
+		return PatientMergeResult{}, err
+	}
+	if appointments, err = decryptAppointments(ctx, p.cipher, appointments); err != nil {
+		return PatientMergeResult{}, err
+	}
+
+	return PatientMergeResult{
+		Survivor:     survivor,
+		Merge:        merge,
+		Appointments: appointments,
+	}, nil
+}
+
+// GetMerges returns the merges into a patient, latest first.
+func (p *PatientRepository) GetMerges(ctx context.Context, survivorId int32) ([]database.PatientMerge, error) {
+
+	merges, err := p.queries.GetPatientMerges(ctx, survivorId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range merges {
+		if merges[i].Duplicate, err = decryptPatientJSON(ctx, p.cipher, merges[i].Duplicate); err != nil {
+			return nil, err
+		}
+	}
+
+	return merges, nil
 }
 
 func (p *PatientRepository) Get(ctx context.Context, id int32) (database.Patient, error) {
 
 	patient, err := p.queries.GetPatientByID(ctx, id)
+	if err != nil {
+		return patient, err
+	}
 
-	return patient, err
+	return decryptPatient(ctx, p.cipher, patient)
+}
+
+// GetWithDeleted returns the patient even when it was deleted.
+func (p *PatientRepository) GetWithDeleted(ctx context.Context, id int32) (database.Patient, error) {
+
+	patient, err := p.queries.GetPatientByIDWithDeleted(ctx, id)
+	if err != nil {
+		return patient, err
+	}
+
+	return decryptPatient(ctx, p.cipher, patient)
 }
 
 func (p *PatientRepository) Create(ctx context.Context, data CreatePatientParams) (database.Patient, error) {
@@ -76,18 +532,28 @@ func (p *PatientRepository) Create(ctx context.Context, data CreatePatientParams
 		Exp:   -2,
 		Valid: data.Height > 0,
 	}
+	contact, err := p.encryptContact(ctx, data.Phone, data.Email, data.Address)
+	if err != nil {
+		return database.Patient{}, err
+	}
+
 	patient, err := p.queries.CreatePatient(ctx, database.CreatePatientParams{
-		Name:    data.Name,
-		Phone:   pgtype.Text{String: data.Phone, Valid: data.Phone != ""},
-		Email:   data.Email,
-		Age:     pgtype.Int2{Int16: int16(data.Age), Valid: data.Age > 0},
-		Weight:  weightNumeric,
-		Height:  heightNumeric,
-		Gender:  pgtype.Text{String: data.Gender, Valid: data.Gender != ""},
-		Address: pgtype.Text{String: data.Address, Valid: data.Address != ""},
+		Name:      data.Name,
+		Phone:     contact.phone,
+		Email:     contact.email,
+		Age:       pgtype.Int2{Int16: int16(data.Age), Valid: data.Age > 0},
+		Weight:    weightNumeric,
+		Height:    heightNumeric,
+		Gender:    pgtype.Text{String: data.Gender, Valid: data.Gender != ""},
+		Address:   contact.address,
+		EmailBidx: contact.emailBidx,
+		PhoneBidx: contact.phoneBidx,
 	})
+	if err != nil {
+		return patient, err
+	}
 
-	return patient, err
+	return decryptPatient(ctx, p.cipher, patient)
 }
 
 func (p *PatientRepository) Update(ctx context.Context, id int32, data UpdatePatientParams) (database.Patient, error) {
@@ -104,24 +570,152 @@ func (p *PatientRepository) Update(ctx context.Context, id int32, data UpdatePat
 		Exp:   -2,
 		Valid: data.Height > 0,
 	}
+	contact, err := p.encryptContact(ctx, data.Phone, data.Email, data.Address)
+	if err != nil {
+		return database.Patient{}, err
+	}
+
 	updatedPatient, err := p.queries.UpdatePatient(ctx, database.UpdatePatientParams{
-		ID:      id,
-		Name:    data.Name,
-		Phone:   pgtype.Text{String: data.Phone, Valid: data.Phone != ""},
-		Email:   data.Email,
-		Age:     pgtype.Int2{Int16: int16(data.Age), Valid: data.Age > 0},
-		Weight:  weightNumeric,
-		Height:  heightNumeric,
-		Gender:  pgtype.Text{String: data.Gender, Valid: data.Gender != ""},
-		Address: pgtype.Text{String: data.Address, Valid: data.Address != ""},
+		ID:        id,
+		Name:      data.Name,
+		Phone:     contact.phone,
+		Email:     contact.email,
+		Age:       pgtype.Int2{Int16: int16(data.Age), Valid: data.Age > 0},
+		Weight:    weightNumeric,
+		Height:    heightNumeric,
+		Gender:    pgtype.Text{String: data.Gender, Valid: data.Gender != ""},
+		Address:   contact.address,
+		EmailBidx: contact.emailBidx,
+		PhoneBidx: contact.phoneBidx,
 	})
+	if err != nil {
+		return updatedPatient, err
+	}
+
+	return decryptPatient(ctx, p.cipher, updatedPatient)
+}
+
+// patientContact is a patient's contact details as they are stored.
+type patientContact struct {
+	phone     pgtype.Text
+	email     string
+	address   pgtype.Text
+	emailBidx []byte
+	phoneBidx []byte
+}
+
+// encryptContact encrypts the contact details and indexes the email and
+// phone. An empty phone or address is left out, as is the phone's index.
+func (p *PatientRepository) encryptContact(ctx context.Context, phone string, email string, address string) (patientContact, error) {
+	var contact patientContact
+	var err error
+
+	if contact.phone, err = encryptText(ctx, p.cipher, FieldPatientPhone, pgtype.Text{String: phone, Valid: phone != ""}); err != nil {
+		return contact, err
+	}
+	if phone != "" {
+		if contact.phoneBidx, err = phoneIndex(ctx, p.cipher, phone); err != nil {
+			return contact, err
+		}
+	}
 
-	return updatedPatient, err
+	if contact.email, err = p.cipher.Encrypt(ctx, FieldPatientEmail, email); err != nil {
+		return contact, err
+	}
+	if contact.emailBidx, err = emailIndex(ctx, p.cipher, email); err != nil {
+		return contact, err
+	}
+
+	contact.address, err = encryptText(ctx, p.cipher, FieldPatientAddress, pgtype.Text{String: address, Valid: address != ""})
+
+	return contact, err
 }
 
+// Delete marks the patient and its appointments as deleted, they can be
+// brought back with Restore.
 func (p *PatientRepository) Delete(ctx context.Context, id int32) error {
 
 	err := p.queries.DeletePatient(ctx, id)
 
 	return err
 }
+
+// Restore undeletes the patient and the appointments deleted along with it.
+// Appointments deleted on their own before stay deleted.
+func (p *PatientRepository) Restore(ctx context.Context, id int32) (database.Patient, error) {
+
+	patient, err := p.queries.RestorePatient(ctx, id)
+	if err != nil {
+		return patient, err
+	}
+
+	return decryptPatient(ctx, p.cipher, patient)
+}
+
+// Reencrypt moves the contact details of up to limit patients after
+// afterId, deleted ones included, to the active key and fills in missing
+// blind indexes. It returns the last patient looked at, 0 when there were
+// none left, and how many were rewritten. Run it in a transaction, the
+// patients stay locked until it ends.
+func (p *PatientRepository) Reencrypt(ctx context.Context, afterId int32, limit int32) (int32, int, error) {
+	patients, err := p.queries.GetPatientsForReencryption(ctx, database.GetPatientsForReencryptionParams{
+		AfterID:    afterId,
+		MaxResults: limit,
+	})
+	if err != nil || len(patients) == 0 {
+		return 0, 0, err
+	}
+
+	updated := 0
+	for _, patient := range patients {
+		current, err := p.isCurrent(ctx, patient)
+		if err != nil {
+			return 0, updated, err
+		}
+		if current {
+			continue
+		}
+
+		decrypted, err := decryptPatient(ctx, p.cipher, patient)
+		if err != nil {
+			return 0, updated, fmt.Errorf("patient %d: %w", patient.ID, err)
+		}
+
+		contact, err := p.encryptContact(ctx, decrypted.Phone.String, decrypted.Email, decrypted.Address.String)
+		if err != nil {
+			return 0, updated, err
+		}
+
+		err = p.queries.UpdatePatientEncryption(ctx, database.UpdatePatientEncryptionParams{
+			Phone:     contact.phone,
+			Email:     contact.email,
+			Address:   contact.address,
+			EmailBidx: contact.emailBidx,
+			PhoneBidx: contact.phoneBidx,
+			ID:        patient.ID,
+		})
+		if err != nil {
+			return 0, updated, err
+		}
+		updated++
+	}
+
+	return patients[len(patients)-1].ID, updated, nil
+}
+
+// isCurrent reports whether the patient's details are encrypted with the
+// active key and indexed.
+func (p *PatientRepository) isCurrent(ctx context.Context, patient database.Patient) (bool, error) {
+	if patient.EmailBidx == nil || (patient.Phone.Valid && patient.PhoneBidx == nil) {
+		return false, nil
+	}
+
+	for _, value := range []pgtype.Text{patient.Phone, {String: patient.Email, Valid: true}, patient.Address} {
+		current, err := isCurrentText(ctx, p.cipher, value)
+		if err != nil || !current {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
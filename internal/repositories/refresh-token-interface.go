@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+)
+
+type RefreshTokenRepositoryInterface interface {
+	Create(ctx context.Context, data CreateRefreshTokenParams) (database.RefreshToken, string, error)
+	Use(ctx context.Context, token string) (database.RefreshToken, error)
+	RevokeFamily(ctx context.Context, userId int32, familyId string) error
+}
+
+type RefreshTokenQueriesContract interface {
+    CreateRefreshToken(context.Context, database.CreateRefreshTokenParams) (database.RefreshToken, error)
+    UseRefreshToken(context.Context, string) (database.RefreshToken, error)
+    GetRefreshTokenByHash(context.Context, string) (database.RefreshToken, error)
+    RevokeRefreshTokenFamily(context.Context, string) error
+    DeleteExpiredRefreshTokens(context.Context, int32) error
+}
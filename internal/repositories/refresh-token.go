@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/utils"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RefreshTokenTTL is how long a refresh token can be used, each refresh
+// hands out a new one.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// ErrRefreshTokenInvalid is a refresh token that is unknown, expired or
+// revoked.
+var ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+
+// ErrRefreshTokenReused is a refresh token that was already used, its
+// family has been revoked.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+type RefreshTokenRepository struct {
+	queries     RefreshTokenQueriesContract
+	revocations TokenRevocations
+}
+
+// CreateRefreshTokenParams describe who a refresh token is for and the
+// device it is handed to.
+type CreateRefreshTokenParams struct {
+	UserID int32
+	// TokenVersion is the user's, see UserRepository.RevokeAllTokens.
+	TokenVersion int32
+	// FamilyID is the family of the token it replaces, a new family is
+	// started when empty.
+	FamilyID   string
+	UserAgent  string
+	IP         string
+	DeviceName string
+}
+
+func NewRefreshTokenRepository(queries RefreshTokenQueriesContract, revocations TokenRevocations) RefreshTokenRepositoryInterface {
+	return &RefreshTokenRepository{
+		queries:     queries,
+		revocations: revocations,
+	}
+}
+
+// Create issues a refresh token. Only its hash is stored, the token is
+// returned this once. The user's expired tokens are deleted along the way.
+func (rr *RefreshTokenRepository) Create(ctx context.Context, data CreateRefreshTokenParams) (database.RefreshToken, string, error) {
+
+	if err := rr.queries.DeleteExpiredRefreshTokens(ctx, data.UserID); err != nil {
+		return database.RefreshToken{}, "", err
+	}
+
+	familyId := data.FamilyID
+	if familyId == "" {
+		var err error
+		if familyId, err = newRefreshTokenFamily(); err != nil {
+			return database.RefreshToken{}, "", err
+		}
+	}
+
+	token, err := newRefreshToken()
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+
+	res, err := rr.queries.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		UserID:       data.UserID,
+		FamilyID:     familyId,
+		TokenHash:    hashRefreshToken(token),
+		TokenVersion: data.TokenVersion,
+		UserAgent:    pgtype.Text{String: data.UserAgent, Valid: data.UserAgent != ""},
+		Ip:           pgtype.Text{String: data.IP, Valid: data.IP != ""},
+		DeviceName:   pgtype.Text{String: data.DeviceName, Valid: data.DeviceName != ""},
+		ExpiresAt:    pgtype.Timestamptz{Time: time.Now().Add(RefreshTokenTTL), Valid: true},
+	})
+
+	return res, token, err
+}
+
+// Use marks the live token matching token used and returns it, it can't be
+// used again. A token that was used before revokes its whole family and
+// returns ErrRefreshTokenReused, others that can't be used
+// ErrRefreshTokenInvalid.
+func (rr *RefreshTokenRepository) Use(ctx context.Context, token string) (database.RefreshToken, error) {
+
+	hash := hashRefreshToken(token)
+
+	res, err := rr.queries.UseRefreshToken(ctx, hash)
+	if err == nil {
+		return res, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return res, err
+	}
+
+	used, err := rr.queries.GetRefreshTokenByHash(ctx, hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return used, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return used, err
+	}
+
+	if !used.UsedAt.Valid {
+		return used, ErrRefreshTokenInvalid
+	}
+
+	// either the client or whoever stole the token used it already, there
+	// is no telling which one this is
+	if err := rr.RevokeFamily(ctx, used.UserID, used.FamilyID); err != nil {
+		return used, err
+	}
+
+	return used, ErrRefreshTokenReused
+}
+
+// RevokeFamily revokes every token handed out from the same login, the
+// access tokens of the user userId included.
+func (rr *RefreshTokenRepository) RevokeFamily(ctx context.Context, userId int32, familyId string) error {
+
+	if err := rr.queries.RevokeRefreshTokenFamily(ctx, familyId); err != nil {
+		return err
+	}
+
+	// access tokens carry their family as their session, the last one
+	// handed out expires within AccessTokenTTL
+	return rr.revocations.Revoke(ctx, sessionRevocationId(familyId), userId, time.Now().Add(utils.AccessTokenTTL))
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "rt_" + hex.EncodeToString(b), nil
+}
+
+func newRefreshTokenFamily() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sessionRevocationId is what a revoked session is kept as among the
+// revoked tokens, apart from the tokens' jtis.
+func sessionRevocationId(familyId string) string {
+	return "sid:" + familyId
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+type ReminderRepositoryInterface interface {
+	GetDue(ctx context.Context, offset time.Duration, channel string, limit int32) ([]database.GetDueRemindersRow, error)
+	Claim(ctx context.Context, appointmentId int32, offset time.Duration, channel string, visitTimestamp time.Time) (database.AppointmentReminder, bool, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string) error
+	MarkSkipped(ctx context.Context, id int64, reason string) error
+}
+
+type ReminderQueriesContract interface {
+    GetDueReminders(context.Context, database.GetDueRemindersParams) ([]database.GetDueRemindersRow, error)
+    ClaimReminder(context.Context, database.ClaimReminderParams) (database.AppointmentReminder, error)
+    CompleteReminder(context.Context, database.CompleteReminderParams) error
+}
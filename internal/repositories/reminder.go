@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	ReminderSending = "sending"
+	ReminderSent    = "sent"
+	ReminderFailed  = "failed"
+	// ReminderSkipped is for patients the channel can't reach, e.g. with
+	// no phone number for SMS.
+	ReminderSkipped = "skipped"
+)
+
+// maxReminderAttempts is how often a failed reminder is tried before it is
+// left failed.
+const maxReminderAttempts = 3
+
+type ReminderRepository struct {
+	queries ReminderQueriesContract
+	cipher  FieldCipher
+}
+
+// NewReminderRepository decrypts the patients' email and phone of due
+// reminders with cipher.
+func NewReminderRepository(queries ReminderQueriesContract, cipher FieldCipher) ReminderRepositoryInterface {
+	return &ReminderRepository{
+		queries: queries,
+		cipher:  cipher,
+	}
+}
+
+// GetDue returns the appointments that are at most offset away and still
+// need that reminder on channel.
+func (rr *ReminderRepository) GetDue(ctx context.Context, offset time.Duration, channel string, limit int32) ([]database.GetDueRemindersRow, error) {
+
+	res, err := rr.queries.GetDueReminders(ctx, database.GetDueRemindersParams{
+		OffsetMinutes: int32(offset / time.Minute),
+		Channel:       channel,
+		MaxAttempts:   maxReminderAttempts,
+		MaxResults:    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range res {
+		if res[i].PatientEmail, err = rr.cipher.Decrypt(ctx, FieldPatientEmail, res[i].PatientEmail); err != nil {
+			return nil, err
+		}
+		if res[i].PatientPhone, err = decryptText(ctx, rr.cipher, FieldPatientPhone, res[i].PatientPhone); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// Claim records that the reminder is about to be sent. It reports false when
+// it was already sent, or is being sent by someone else, so the caller must
+// not send it.
+func (rr *ReminderRepository) Claim(ctx context.Context, appointmentId int32, offset time.Duration, channel string, visitTimestamp time.Time) (database.AppointmentReminder, bool, error) {
+
+	res, err := rr.queries.ClaimReminder(ctx, database.ClaimReminderParams{
+		AppointmentID:  appointmentId,
+		OffsetMinutes:  int32(offset / time.Minute),
+		Channel:        channel,
+		VisitTimestamp: pgtype.Timestamptz{Time: visitTimestamp, Valid: true},
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return res, false, nil
+	}
+
+	return res, err == nil, err
+}
+
+func (rr *ReminderRepository) MarkSent(ctx context.Context, id int64) error {
+	return rr.complete(ctx, id, ReminderSent, "")
+}
+
+// MarkFailed leaves the reminder to be tried again while it has attempts
+// left.
+func (rr *ReminderRepository) MarkFailed(ctx context.Context, id int64, reason string) error {
+	return rr.complete(ctx, id, ReminderFailed, reason)
+}
+
+func (rr *ReminderRepository) MarkSkipped(ctx context.Context, id int64, reason string) error {
+	return rr.complete(ctx, id, ReminderSkipped, reason)
+}
+
+func (rr *ReminderRepository) complete(ctx context.Context, id int64, status string, reason string) error {
+
+	err := rr.queries.CompleteReminder(ctx, database.CompleteReminderParams{
+		ID:        id,
+		Status:    status,
+		LastError: pgtype.Text{String: reason, Valid: reason != ""},
+	})
+
+	return err
+}
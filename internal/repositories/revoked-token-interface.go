@@ -0,0 +1,19 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+type RevokedTokenRepositoryInterface interface {
+	GetRevokedTokens(ctx context.Context) ([]database.RevokedToken, error)
+	RevokeToken(ctx context.Context, jti string, userId int32, expiresAt time.Time) error
+	DeleteExpiredRevokedTokens(ctx context.Context) error
+}
+
+type RevokedTokenQueriesContract interface {
+    GetRevokedTokens(context.Context) ([]database.RevokedToken, error)
+    RevokeToken(context.Context, database.RevokeTokenParams) error
+    DeleteExpiredRevokedTokens(context.Context) error
+}
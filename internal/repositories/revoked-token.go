@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RevokedTokenRepository stores the tokens of revocation.List.
+type RevokedTokenRepository struct {
+	queries RevokedTokenQueriesContract
+}
+
+func NewRevokedTokenRepository(queries RevokedTokenQueriesContract) RevokedTokenRepositoryInterface {
+	return &RevokedTokenRepository{
+		queries: queries,
+	}
+}
+
+// GetRevokedTokens returns the revoked tokens that haven't expired yet.
+func (rr *RevokedTokenRepository) GetRevokedTokens(ctx context.Context) ([]database.RevokedToken, error) {
+
+	res, err := rr.queries.GetRevokedTokens(ctx)
+
+	return res, err
+}
+
+// RevokeToken revokes the token jti, revoking it again changes nothing.
+func (rr *RevokedTokenRepository) RevokeToken(ctx context.Context, jti string, userId int32, expiresAt time.Time) error {
+
+	return rr.queries.RevokeToken(ctx, database.RevokeTokenParams{
+		Jti:       jti,
+		UserID:    userId,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+}
+
+func (rr *RevokedTokenRepository) DeleteExpiredRevokedTokens(ctx context.Context) error {
+
+	return rr.queries.DeleteExpiredRevokedTokens(ctx)
+}
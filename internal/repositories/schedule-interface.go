@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/schedule"
+	"time"
+)
+
+type ScheduleRepositoryInterface interface {
+	GetWeekly(ctx context.Context, doctorId int32) (WeeklySchedule, error)
+	SetWeekly(ctx context.Context, doctorId int32, data SetWeeklyScheduleParams) (WeeklySchedule, error)
+	GetExceptions(ctx context.Context, doctorId int32, from time.Time, to time.Time) ([]database.DoctorScheduleException, error)
+	CreateException(ctx context.Context, doctorId int32, data CreateScheduleExceptionParams) (database.DoctorScheduleException, error)
+	DeleteException(ctx context.Context, doctorId int32, id int32) error
+	GetSchedule(ctx context.Context, doctorId int32, from time.Time, to time.Time) (schedule.Schedule, error)
+}
+
+type ScheduleQueriesContract interface {
+    GetDoctorSchedule(context.Context, int32) (database.DoctorSchedule, error)
+    UpsertDoctorSchedule(context.Context, database.UpsertDoctorScheduleParams) (database.DoctorSchedule, error)
+    GetDoctorWorkingHours(context.Context, int32) ([]database.DoctorWorkingHour, error)
+    CreateDoctorWorkingHour(context.Context, database.CreateDoctorWorkingHourParams) (database.DoctorWorkingHour, error)
+    DeleteDoctorWorkingHours(context.Context, int32) error
+    GetDoctorBreaks(context.Context, int32) ([]database.DoctorBreak, error)
+    CreateDoctorBreak(context.Context, database.CreateDoctorBreakParams) (database.DoctorBreak, error)
+    DeleteDoctorBreaks(context.Context, int32) error
+    GetDoctorScheduleExceptions(context.Context, database.GetDoctorScheduleExceptionsParams) ([]database.DoctorScheduleException, error)
+    CreateDoctorScheduleException(context.Context, database.CreateDoctorScheduleExceptionParams) (database.DoctorScheduleException, error)
+    DeleteDoctorScheduleException(context.Context, database.DeleteDoctorScheduleExceptionParams) error
+}
@@ -0,0 +1,248 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/schedule"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const defaultSlotMinutes = 15
+
+type ScheduleRepository struct {
+	queries ScheduleQueriesContract
+}
+
+type ScheduleWindow struct {
+	Weekday time.Weekday
+	Start   schedule.TimeOfDay
+	End     schedule.TimeOfDay
+}
+
+type WeeklySchedule struct {
+	SlotMinutes  int16
+	Timezone     string
+	WorkingHours []ScheduleWindow
+	Breaks       []ScheduleWindow
+}
+
+type SetWeeklyScheduleParams struct {
+	SlotMinutes  int16
+	Timezone     string
+	WorkingHours []ScheduleWindow
+	Breaks       []ScheduleWindow
+}
+
+type CreateScheduleExceptionParams struct {
+	Date   time.Time
+	Kind   schedule.ExceptionKind
+	Start  *schedule.TimeOfDay
+	End    *schedule.TimeOfDay
+	Reason *string
+}
+
+func NewScheduleRepository(queries ScheduleQueriesContract) ScheduleRepositoryInterface {
+	return &ScheduleRepository{
+		queries: queries,
+	}
+}
+
+// GetWeekly returns the doctor's weekly hours. A doctor without a schedule
+// gets the default slot length in UTC and no working hours.
+func (s *ScheduleRepository) GetWeekly(ctx context.Context, doctorId int32) (WeeklySchedule, error) {
+	weekly := WeeklySchedule{
+		SlotMinutes: defaultSlotMinutes,
+		Timezone:    "UTC",
+	}
+
+	settings, err := s.queries.GetDoctorSchedule(ctx, doctorId)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return weekly, err
+	}
+	if err == nil {
+		weekly.SlotMinutes = settings.SlotMinutes
+		weekly.Timezone = settings.Timezone
+	}
+
+	hours, err := s.queries.GetDoctorWorkingHours(ctx, doctorId)
+	if err != nil {
+		return weekly, err
+	}
+	for _, h := range hours {
+		weekly.WorkingHours = append(weekly.WorkingHours, ScheduleWindow{
+			Weekday: time.Weekday(h.Weekday),
+			Start:   pgTimeToTimeOfDay(h.StartTime),
+			End:     pgTimeToTimeOfDay(h.EndTime),
+		})
+	}
+
+	breaks, err := s.queries.GetDoctorBreaks(ctx, doctorId)
+	if err != nil {
+		return weekly, err
+	}
+	for _, b := range breaks {
+		weekly.Breaks = append(weekly.Breaks, ScheduleWindow{
+			Weekday: time.Weekday(b.Weekday),
+			Start:   pgTimeToTimeOfDay(b.StartTime),
+			End:     pgTimeToTimeOfDay(b.EndTime),
+		})
+	}
+
+	return weekly, nil
+}
+
+// SetWeekly replaces the doctor's slot length, timezone, working hours and
+// breaks. Run it in a transaction so readers never see a half written week.
+func (s *ScheduleRepository) SetWeekly(ctx context.Context, doctorId int32, data SetWeeklyScheduleParams) (WeeklySchedule, error) {
+
+	_, err := s.queries.UpsertDoctorSchedule(ctx, database.UpsertDoctorScheduleParams{
+		DoctorID:    doctorId,
+		SlotMinutes: data.SlotMinutes,
+		Timezone:    data.Timezone,
+	})
+	if err != nil {
+		return WeeklySchedule{}, err
+	}
+
+	if err := s.queries.DeleteDoctorWorkingHours(ctx, doctorId); err != nil {
+		return WeeklySchedule{}, err
+	}
+	for _, h := range data.WorkingHours {
+		_, err := s.queries.CreateDoctorWorkingHour(ctx, database.CreateDoctorWorkingHourParams{
+			DoctorID:  doctorId,
+			Weekday:   int16(h.Weekday),
+			StartTime: timeOfDayToPgTime(h.Start),
+			EndTime:   timeOfDayToPgTime(h.End),
+		})
+		if err != nil {
+			return WeeklySchedule{}, err
+		}
+	}
+
+	if err := s.queries.DeleteDoctorBreaks(ctx, doctorId); err != nil {
+		return WeeklySchedule{}, err
+	}
+	for _, b := range data.Breaks {
+		_, err := s.queries.CreateDoctorBreak(ctx, database.CreateDoctorBreakParams{
+			DoctorID:  doctorId,
+			Weekday:   int16(b.Weekday),
+			StartTime: timeOfDayToPgTime(b.Start),
+			EndTime:   timeOfDayToPgTime(b.End),
+		})
+		if err != nil {
+			return WeeklySchedule{}, err
+		}
+	}
+
+	return WeeklySchedule{
+		SlotMinutes:  data.SlotMinutes,
+		Timezone:     data.Timezone,
+		WorkingHours: data.WorkingHours,
+		Breaks:       data.Breaks,
+	}, nil
+}
+
+func (s *ScheduleRepository) GetExceptions(ctx context.Context, doctorId int32, from time.Time, to time.Time) ([]database.DoctorScheduleException, error) {
+	res, err := s.queries.GetDoctorScheduleExceptions(ctx, database.GetDoctorScheduleExceptionsParams{
+		DoctorID: doctorId,
+		FromDate: pgtype.Date{Time: from, Valid: true},
+		ToDate:   pgtype.Date{Time: to, Valid: true},
+	})
+	return res, err
+}
+
+func (s *ScheduleRepository) CreateException(ctx context.Context, doctorId int32, data CreateScheduleExceptionParams) (database.DoctorScheduleException, error) {
+
+	var start, end pgtype.Time
+	if data.Start != nil && data.End != nil {
+		start = timeOfDayToPgTime(*data.Start)
+		end = timeOfDayToPgTime(*data.End)
+	}
+
+	var reason string
+	if data.Reason != nil {
+		reason = *data.Reason
+	}
+
+	res, err := s.queries.CreateDoctorScheduleException(ctx, database.CreateDoctorScheduleExceptionParams{
+		DoctorID:      doctorId,
+		ExceptionDate: pgtype.Date{Time: data.Date, Valid: true},
+		Kind:          string(data.Kind),
+		StartTime:     start,
+		EndTime:       end,
+		Reason:        pgtype.Text{String: reason, Valid: data.Reason != nil},
+	})
+
+	return res, err
+}
+
+func (s *ScheduleRepository) DeleteException(ctx context.Context, doctorId int32, id int32) error {
+	err := s.queries.DeleteDoctorScheduleException(ctx, database.DeleteDoctorScheduleExceptionParams{
+		ID:       id,
+		DoctorID: doctorId,
+	})
+	return err
+}
+
+// GetSchedule loads everything needed to compute the doctor's availability
+// between from and to.
+func (s *ScheduleRepository) GetSchedule(ctx context.Context, doctorId int32, from time.Time, to time.Time) (schedule.Schedule, error) {
+
+	weekly, err := s.GetWeekly(ctx, doctorId)
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+
+	loc, err := time.LoadLocation(weekly.Timezone)
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+
+	// exceptions are stored by local date, widen by a day for timezones
+	exceptions, err := s.GetExceptions(ctx, doctorId, from.In(loc).AddDate(0, 0, -1), to.In(loc).AddDate(0, 0, 1))
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+
+	sched := schedule.Schedule{
+		SlotLength: time.Duration(weekly.SlotMinutes) * time.Minute,
+		Location:   loc,
+	}
+
+	for _, h := range weekly.WorkingHours {
+		sched.WorkingHours = append(sched.WorkingHours, schedule.WeeklyWindow(h))
+	}
+	for _, b := range weekly.Breaks {
+		sched.Breaks = append(sched.Breaks, schedule.WeeklyWindow(b))
+	}
+
+	for _, e := range exceptions {
+		exception := schedule.Exception{
+			Year:  e.ExceptionDate.Time.Year(),
+			Month: e.ExceptionDate.Time.Month(),
+			Day:   e.ExceptionDate.Time.Day(),
+			Kind:  schedule.ExceptionKind(e.Kind),
+		}
+		if e.StartTime.Valid && e.EndTime.Valid {
+			start := pgTimeToTimeOfDay(e.StartTime)
+			end := pgTimeToTimeOfDay(e.EndTime)
+			exception.Start = &start
+			exception.End = &end
+		}
+		sched.Exceptions = append(sched.Exceptions, exception)
+	}
+
+	return sched, nil
+}
+
+func pgTimeToTimeOfDay(t pgtype.Time) schedule.TimeOfDay {
+	return schedule.TimeOfDay(time.Duration(t.Microseconds) * time.Microsecond)
+}
+
+func timeOfDayToPgTime(t schedule.TimeOfDay) pgtype.Time {
+	return pgtype.Time{Microseconds: time.Duration(t).Microseconds(), Valid: true}
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type TxManagerInterface interface {
+	RunInTx(ctx context.Context, fn func(repos TxRepositories) error) error
+}
+
+// TxRepositories are repositories bound to a single transaction.
+type TxRepositories struct {
+	Users        UserRepositoryInterface
+	Patients     PatientRepositoryInterface
+	Appointments AppointmentRepositoryInterface
+	Schedules    ScheduleRepositoryInterface
+	Waitlist     WaitlistRepositoryInterface
+	Outbox       OutboxRepositoryInterface
+}
+
+type TxBeginner interface {
+	BeginTx(ctx context.Context, options pgx.TxOptions) (pgx.Tx, error)
+}
+
+// TxQueriesContract is everything the transaction scoped repositories need
+// from the generated queries; *database.Queries satisfies it.
+type TxQueriesContract interface {
+	UserQueriesContract
+	PatientQueriesContract
+	AppointmentQueriesContract
+	ScheduleQueriesContract
+	WaitlistQueriesContract
+	OutboxQueriesContract
+	AuditQueriesContract
+}
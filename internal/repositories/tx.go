@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type TxManager struct {
+	db          TxBeginner
+	queries     func(tx pgx.Tx) TxQueriesContract
+	cipher      FieldCipher
+	revocations TokenRevocations
+	options     pgx.TxOptions
+	maxRetries  int
+	backoff     time.Duration
+}
+
+type TxOption func(*TxManager)
+
+// WithTxOptions sets the isolation level and access mode of every
+// transaction started by the manager.
+func WithTxOptions(options pgx.TxOptions) TxOption {
+	return func(m *TxManager) {
+		m.options = options
+	}
+}
+
+// WithSerializationRetries re-runs the whole transaction up to n more times
+// when Postgres aborts it with a serialization failure or a deadlock.
+func WithSerializationRetries(n int, backoff time.Duration) TxOption {
+	return func(m *TxManager) {
+		m.maxRetries = n
+		m.backoff = backoff
+	}
+}
+
+// NewTxManager hands fn repositories that encrypt with cipher, see
+// NewPatientRepository, and revoke tokens with revocations.
+func NewTxManager(db TxBeginner, queries func(tx pgx.Tx) TxQueriesContract, cipher FieldCipher, revocations TokenRevocations, opts ...TxOption) TxManagerInterface {
+	m := &TxManager{
+		db:          db,
+		queries:     queries,
+		cipher:      cipher,
+		revocations: revocations,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// RunInTx runs fn inside a transaction. The transaction is committed when fn
+// returns nil and rolled back otherwise, including when fn panics.
+func (m *TxManager) RunInTx(ctx context.Context, fn func(repos TxRepositories) error) error {
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		err = m.runOnce(ctx, fn)
+
+		if err == nil || attempt >= m.maxRetries || !IsSerializationFailure(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(m.backoff * time.Duration(attempt+1)):
+		}
+	}
+}
+
+func (m *TxManager) runOnce(ctx context.Context, fn func(repos TxRepositories) error) (err error) {
+	tx, err := m.db.BeginTx(ctx, m.options)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+	}()
+
+	queries := m.queries(tx)
+
+	// The audit triggers attribute the transaction's changes to the
+	// context's audit source.
+	err = setAuditSource(ctx, queries)
+	if err == nil {
+		err = fn(TxRepositories{
+			Users:        NewUserRepository(queries, m.revocations),
+			Patients:     NewPatientRepository(queries, m.cipher),
+			Appointments: NewAppointmentRepository(queries, m.cipher),
+			Schedules:    NewScheduleRepository(queries),
+			Waitlist:     NewWaitlistRepository(queries),
+			Outbox:       NewOutboxRepository(queries, m.cipher),
+		})
+	}
+
+	if err != nil {
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			return errors.Join(err, fmt.Errorf("rollback transaction: %w", rbErr))
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -3,22 +3,33 @@ package repositories
 import (
 	"context"
 	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
+	"time"
 )
 
 type UserRepositoryInterface interface {
-	GetAll(ctx context.Context) ([]database.User, error)
+	GetAll(ctx context.Context, option GetUsersOption) (pagination.Page[database.User], error)
 	Get(ctx context.Context, id int32) (database.User, error)
+	GetWithDeleted(ctx context.Context, id int32) (database.User, error)
     GetByEmail(ctx context.Context, email string) (database.User, error)
 	Create(ctx context.Context, data CreateUserParams) (database.User, error)
 	Update(ctx context.Context, id int32, data UpdateUserParams) (database.User, error)
 	Delete(ctx context.Context, id int32) error
+	Restore(ctx context.Context, id int32) (database.User, error)
+	RevokeToken(ctx context.Context, jti string, userId int32, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	IsSessionRevoked(ctx context.Context, sessionId string) (bool, error)
+	RevokeAllTokens(ctx context.Context, id int32) (database.User, error)
 }
 
 type UserQueriesContract interface {
-    GetAllUsers(context.Context) ([]database.User, error)
+    GetAllUsers(context.Context, database.GetAllUsersParams) ([]database.User, error)
     GetUserByEmail(context.Context, string) (database.User, error)
     GetUser(context.Context, int32) (database.User, error)
+    GetUserWithDeleted(context.Context, int32) (database.User, error)
     CreateUser(context.Context, database.CreateUserParams) (database.User, error)
     UpdateUser(context.Context, database.UpdateUserParams) (database.User, error)
     DeleteUser(context.Context, int32) error
+    RestoreUser(context.Context, int32) (database.User, error)
+    IncrementUserTokenVersion(context.Context, int32) (database.User, error)
 }
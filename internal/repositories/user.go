@@ -3,10 +3,22 @@ package repositories
 import (
 	"context"
 	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type UserRepository struct {
-	queries UserQueriesContract
+	queries     UserQueriesContract
+	revocations TokenRevocations
+}
+
+// TokenRevocations keeps the tokens revoked one at a time, see
+// revocation.List.
+type TokenRevocations interface {
+	Revoke(ctx context.Context, jti string, userId int32, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
 }
 
 type CreateUserParams struct {
@@ -15,22 +27,85 @@ type CreateUserParams struct {
 	Password string
 }
 
+const (
+	UserSortID        = "id"
+	UserSortEmail     = "email"
+	UserSortCreatedAt = "created_at"
+)
+
+type GetUsersOption struct {
+	// Type only lists users of that type when set.
+	Type string
+	// IncludeDeleted lists deleted users along with the others.
+	IncludeDeleted bool
+	// Page.SortBy is one of the UserSort values, id when empty.
+	Page pagination.Params
+}
+
 type UpdateUserParams struct {
 	Email    string
 	Password string
 }
 
-func NewUserRepository(queries UserQueriesContract) UserRepositoryInterface {
+func NewUserRepository(queries UserQueriesContract, revocations TokenRevocations) UserRepositoryInterface {
 	return &UserRepository{
-		queries: queries,
+		queries:     queries,
+		revocations: revocations,
 	}
 }
 
-func (r *UserRepository) GetAll(ctx context.Context) ([]database.User, error) {
+// GetAll returns a page of users, pagination.ErrInvalidCursor when the
+// cursor can't be continued from.
+func (r *UserRepository) GetAll(ctx context.Context, option GetUsersOption) (pagination.Page[database.User], error) {
+	page := option.Page
+	if page.SortBy == "" {
+		page.SortBy = UserSortID
+	}
 
-	res, err := r.queries.GetAllUsers(ctx)
+	cursor, err := page.DecodeCursor()
+	if err != nil {
+		return pagination.Page[database.User]{}, err
+	}
 
-	return res, err
+	params := database.GetAllUsersParams{
+		Type:           pgtype.Text{String: option.Type, Valid: option.Type != ""},
+		IncludeDeleted: option.IncludeDeleted,
+		SortBy:         page.SortBy,
+		SortDesc:       page.Desc,
+		MaxResults:     page.FetchLimit(),
+	}
+
+	if cursor != nil {
+		params.HasCursor = true
+		params.CursorID = cursor.ID
+
+		switch page.SortBy {
+		case UserSortEmail:
+			params.CursorEmail = cursor.Key
+		case UserSortCreatedAt:
+			createdAt, err := parseTimeKey(cursor.Key)
+			if err != nil {
+				return pagination.Page[database.User]{}, err
+			}
+			params.CursorCreatedAt = pgtype.Timestamp{Time: createdAt, Valid: true}
+		}
+	}
+
+	res, err := r.queries.GetAllUsers(ctx, params)
+	if err != nil {
+		return pagination.Page[database.User]{}, err
+	}
+
+	return pagination.NewPage(res, page, func(user database.User) (string, int32) {
+		switch page.SortBy {
+		case UserSortEmail:
+			return user.Email, user.ID
+		case UserSortCreatedAt:
+			return timestampKey(user.CreatedAt), user.ID
+		default:
+			return "", user.ID
+		}
+	}), nil
 }
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (database.User, error) {
@@ -47,6 +122,14 @@ func (r *UserRepository) Get(ctx context.Context, id int32) (database.User, erro
 	return res, err
 }
 
+// GetWithDeleted returns the user even when it was deleted.
+func (r *UserRepository) GetWithDeleted(ctx context.Context, id int32) (database.User, error) {
+
+	res, err := r.queries.GetUserWithDeleted(ctx, id)
+
+	return res, err
+}
+
 func (r *UserRepository) Create(ctx context.Context, data CreateUserParams) (database.User, error) {
 
 	res, err := r.queries.CreateUser(ctx, database.CreateUserParams{
@@ -75,3 +158,38 @@ func (r *UserRepository) Delete(ctx context.Context, id int32) error {
 
 	return err
 }
+
+func (r *UserRepository) Restore(ctx context.Context, id int32) (database.User, error) {
+
+	res, err := r.queries.RestoreUser(ctx, id)
+
+	return res, err
+}
+
+// RevokeToken rejects the user's token jti from now on until it expires at
+// expiresAt.
+func (r *UserRepository) RevokeToken(ctx context.Context, jti string, userId int32, expiresAt time.Time) error {
+
+	return r.revocations.Revoke(ctx, jti, userId, expiresAt)
+}
+
+func (r *UserRepository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+
+	return r.revocations.IsRevoked(ctx, jti)
+}
+
+// IsSessionRevoked reports whether the session sessionId was revoked, see
+// RefreshTokenRepository.RevokeFamily.
+func (r *UserRepository) IsSessionRevoked(ctx context.Context, sessionId string) (bool, error) {
+
+	return r.revocations.IsRevoked(ctx, sessionRevocationId(sessionId))
+}
+
+// RevokeAllTokens revokes every token issued to the user so far by bumping
+// its token version, tokens issued from then on carry the new one.
+func (r *UserRepository) RevokeAllTokens(ctx context.Context, id int32) (database.User, error) {
+
+	res, err := r.queries.IncrementUserTokenVersion(ctx, id)
+
+	return res, err
+}
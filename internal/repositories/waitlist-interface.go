@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+type WaitlistRepositoryInterface interface {
+	GetAll(ctx context.Context) ([]database.WaitlistEntry, error)
+	Get(ctx context.Context, id int32) (database.WaitlistEntry, error)
+	Create(ctx context.Context, userId int32, data CreateWaitlistEntryParams) (database.WaitlistEntry, error)
+	Update(ctx context.Context, id int32, data UpdateWaitlistEntryParams) (database.WaitlistEntry, error)
+	Delete(ctx context.Context, id int32) error
+	OfferSlot(ctx context.Context, data OfferSlotParams) ([]database.WaitlistHold, error)
+	GetActiveHolds(ctx context.Context) ([]database.WaitlistHold, error)
+	GetActiveHoldsForDoctorBetween(ctx context.Context, doctorId int32, from time.Time, to time.Time) ([]database.WaitlistHold, error)
+	ClaimHold(ctx context.Context, holdId int32) (database.WaitlistHold, database.WaitlistEntry, error)
+	ConfirmHold(ctx context.Context, hold database.WaitlistHold, appointmentId int32) (database.WaitlistHold, error)
+	ReleaseHold(ctx context.Context, holdId int32) (database.WaitlistHold, error)
+}
+
+type WaitlistQueriesContract interface {
+    GetWaitlistEntries(context.Context) ([]database.WaitlistEntry, error)
+    GetWaitlistEntry(context.Context, int32) (database.WaitlistEntry, error)
+    CreateWaitlistEntry(context.Context, database.CreateWaitlistEntryParams) (database.WaitlistEntry, error)
+    UpdateWaitlistEntry(context.Context, database.UpdateWaitlistEntryParams) (database.WaitlistEntry, error)
+    SetWaitlistEntryStatus(context.Context, database.SetWaitlistEntryStatusParams) (database.WaitlistEntry, error)
+    DeleteWaitlistEntry(context.Context, int32) error
+    FindWaitlistMatches(context.Context, database.FindWaitlistMatchesParams) ([]database.WaitlistEntry, error)
+    CreateWaitlistHold(context.Context, database.CreateWaitlistHoldParams) (database.WaitlistHold, error)
+    GetWaitlistHoldForUpdate(context.Context, int32) (database.WaitlistHold, error)
+    GetActiveWaitlistHolds(context.Context) ([]database.WaitlistHold, error)
+    GetActiveWaitlistHoldsForDoctorBetween(context.Context, database.GetActiveWaitlistHoldsForDoctorBetweenParams) ([]database.WaitlistHold, error)
+    ResolveWaitlistHold(context.Context, database.ResolveWaitlistHoldParams) (database.WaitlistHold, error)
+    ReleaseCompetingWaitlistHolds(context.Context, database.ReleaseCompetingWaitlistHoldsParams) (int64, error)
+}
@@ -0,0 +1,309 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// defaultHoldTTL is how long a freed slot stays reserved for a
+	// waitlisted patient before anyone may book it again.
+	defaultHoldTTL = 30 * time.Minute
+	// defaultSlotOffers is how many waitlist entries a freed slot is
+	// offered to at once, the first one confirmed gets it.
+	defaultSlotOffers = 3
+)
+
+type TimePreference string
+
+const (
+	TimePreferenceAny       TimePreference = "any"
+	TimePreferenceMorning   TimePreference = "morning"
+	TimePreferenceAfternoon TimePreference = "afternoon"
+	TimePreferenceEvening   TimePreference = "evening"
+)
+
+const (
+	WaitlistWaiting = "waiting"
+	WaitlistBooked  = "booked"
+
+	HoldPending   = "pending"
+	HoldConfirmed = "confirmed"
+	HoldReleased  = "released"
+)
+
+// ErrHoldNotPending is returned when confirming or releasing a hold that has
+// already been confirmed or released.
+var ErrHoldNotPending = errors.New("waitlist hold is no longer pending")
+
+// ErrHoldExpired is returned when confirming a hold after it ran out.
+var ErrHoldExpired = errors.New("waitlist hold has expired")
+
+type WaitlistRepository struct {
+	queries WaitlistQueriesContract
+}
+
+type CreateWaitlistEntryParams struct {
+	PatientID      int32
+	DoctorID       *int32
+	FromDate       time.Time
+	ToDate         time.Time
+	TimePreference TimePreference
+	Notes          *string
+}
+
+type UpdateWaitlistEntryParams struct {
+	DoctorID       *int32
+	FromDate       time.Time
+	ToDate         time.Time
+	TimePreference TimePreference
+	Notes          *string
+}
+
+type OfferSlotParams struct {
+	DoctorID       int32
+	VisitTimestamp time.Time
+	Duration       time.Duration
+	// Location is the doctor's timezone, it decides the day and the time
+	// of day the slot is matched on.
+	Location *time.Location
+	// TTL defaults to 30 minutes when zero.
+	TTL time.Duration
+	// MaxOffers defaults to 3 when zero.
+	MaxOffers int32
+}
+
+func NewWaitlistRepository(queries WaitlistQueriesContract) WaitlistRepositoryInterface {
+	return &WaitlistRepository{
+		queries: queries,
+	}
+}
+
+// TimePreferenceOf returns the part of the day t falls in: morning before
+// noon, afternoon until 17:00 and evening after.
+func TimePreferenceOf(t time.Time) TimePreference {
+	switch {
+	case t.Hour() < 12:
+		return TimePreferenceMorning
+	case t.Hour() < 17:
+		return TimePreferenceAfternoon
+	default:
+		return TimePreferenceEvening
+	}
+}
+
+func (wr *WaitlistRepository) GetAll(ctx context.Context) ([]database.WaitlistEntry, error) {
+	res, err := wr.queries.GetWaitlistEntries(ctx)
+	return res, err
+}
+
+func (wr *WaitlistRepository) Get(ctx context.Context, id int32) (database.WaitlistEntry, error) {
+	res, err := wr.queries.GetWaitlistEntry(ctx, id)
+	return res, err
+}
+
+func (wr *WaitlistRepository) Create(ctx context.Context, userId int32, data CreateWaitlistEntryParams) (database.WaitlistEntry, error) {
+
+	res, err := wr.queries.CreateWaitlistEntry(ctx, database.CreateWaitlistEntryParams{
+		PatientID:      data.PatientID,
+		DoctorID:       optionalInt4(data.DoctorID),
+		FromDate:       pgtype.Date{Time: data.FromDate, Valid: true},
+		ToDate:         pgtype.Date{Time: data.ToDate, Valid: true},
+		TimePreference: string(timePreferenceOrAny(data.TimePreference)),
+		Notes:          optionalText(data.Notes),
+		CreatedBy:      pgtype.Int4{Int32: userId, Valid: userId != 0},
+	})
+
+	return res, err
+}
+
+func (wr *WaitlistRepository) Update(ctx context.Context, id int32, data UpdateWaitlistEntryParams) (database.WaitlistEntry, error) {
+
+	res, err := wr.queries.UpdateWaitlistEntry(ctx, database.UpdateWaitlistEntryParams{
+		ID:             id,
+		DoctorID:       optionalInt4(data.DoctorID),
+		FromDate:       pgtype.Date{Time: data.FromDate, Valid: true},
+		ToDate:         pgtype.Date{Time: data.ToDate, Valid: true},
+		TimePreference: string(timePreferenceOrAny(data.TimePreference)),
+		Notes:          optionalText(data.Notes),
+	})
+
+	return res, err
+}
+
+func (wr *WaitlistRepository) Delete(ctx context.Context, id int32) error {
+	err := wr.queries.DeleteWaitlistEntry(ctx, id)
+	return err
+}
+
+// OfferSlot holds a freed slot for the best matching waitlist entries, see
+// FindWaitlistMatches for the order. It returns no holds when nobody on the
+// waitlist fits the slot.
+func (wr *WaitlistRepository) OfferSlot(ctx context.Context, data OfferSlotParams) ([]database.WaitlistHold, error) {
+
+	loc := data.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := data.VisitTimestamp.In(loc)
+
+	ttl := data.TTL
+	if ttl <= 0 {
+		ttl = defaultHoldTTL
+	}
+
+	maxOffers := data.MaxOffers
+	if maxOffers <= 0 {
+		maxOffers = defaultSlotOffers
+	}
+
+	duration := data.Duration
+	if duration <= 0 {
+		duration = defaultAppointmentDurationMinutes * time.Minute
+	}
+
+	entries, err := wr.queries.FindWaitlistMatches(ctx, database.FindWaitlistMatchesParams{
+		VisitDate:      visitDateOf(local),
+		DoctorID:       pgtype.Int4{Int32: data.DoctorID, Valid: true},
+		TimePreference: string(TimePreferenceOf(local)),
+		MaxResults:     maxOffers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(ttl)
+
+	holds := make([]database.WaitlistHold, 0, len(entries))
+	for _, entry := range entries {
+		hold, err := wr.queries.CreateWaitlistHold(ctx, database.CreateWaitlistHoldParams{
+			WaitlistEntryID: entry.ID,
+			DoctorID:        data.DoctorID,
+			VisitTimestamp:  pgtype.Timestamptz{Time: data.VisitTimestamp, Valid: true},
+			DurationMinutes: int16(duration / time.Minute),
+			ExpiresAt:       pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		})
+		if err != nil {
+			return holds, err
+		}
+		holds = append(holds, hold)
+	}
+
+	return holds, nil
+}
+
+func (wr *WaitlistRepository) GetActiveHolds(ctx context.Context) ([]database.WaitlistHold, error) {
+	res, err := wr.queries.GetActiveWaitlistHolds(ctx)
+	return res, err
+}
+
+// GetActiveHoldsForDoctorBetween returns the unexpired holds on the doctor's
+// slots that overlap from to to.
+func (wr *WaitlistRepository) GetActiveHoldsForDoctorBetween(ctx context.Context, doctorId int32, from time.Time, to time.Time) ([]database.WaitlistHold, error) {
+
+	res, err := wr.queries.GetActiveWaitlistHoldsForDoctorBetween(ctx, database.GetActiveWaitlistHoldsForDoctorBetweenParams{
+		DoctorID: doctorId,
+		FromTime: pgtype.Timestamptz{Time: from, Valid: true},
+		ToTime:   pgtype.Timestamptz{Time: to, Valid: true},
+	})
+
+	return res, err
+}
+
+// ClaimHold locks a pending, unexpired hold and returns it with its
+// waitlist entry so the caller can book the appointment. Run it in a
+// transaction together with ConfirmHold.
+func (wr *WaitlistRepository) ClaimHold(ctx context.Context, holdId int32) (database.WaitlistHold, database.WaitlistEntry, error) {
+
+	hold, err := wr.queries.GetWaitlistHoldForUpdate(ctx, holdId)
+	if err != nil {
+		return hold, database.WaitlistEntry{}, err
+	}
+
+	if hold.Status != HoldPending {
+		return hold, database.WaitlistEntry{}, ErrHoldNotPending
+	}
+
+	if !hold.ExpiresAt.Time.After(time.Now()) {
+		return hold, database.WaitlistEntry{}, ErrHoldExpired
+	}
+
+	entry, err := wr.queries.GetWaitlistEntry(ctx, hold.WaitlistEntryID)
+	return hold, entry, err
+}
+
+// ConfirmHold marks a claimed hold as booked into appointmentId, takes its
+// entry off the waitlist and withdraws the slot from the other entries it
+// was offered to.
+func (wr *WaitlistRepository) ConfirmHold(ctx context.Context, hold database.WaitlistHold, appointmentId int32) (database.WaitlistHold, error) {
+
+	confirmed, err := wr.queries.ResolveWaitlistHold(ctx, database.ResolveWaitlistHoldParams{
+		ID:            hold.ID,
+		Status:        HoldConfirmed,
+		AppointmentID: pgtype.Int4{Int32: appointmentId, Valid: true},
+	})
+	if err != nil {
+		return confirmed, err
+	}
+
+	_, err = wr.queries.SetWaitlistEntryStatus(ctx, database.SetWaitlistEntryStatusParams{
+		ID:     hold.WaitlistEntryID,
+		Status: WaitlistBooked,
+	})
+	if err != nil {
+		return confirmed, err
+	}
+
+	_, err = wr.queries.ReleaseCompetingWaitlistHolds(ctx, database.ReleaseCompetingWaitlistHoldsParams{
+		DoctorID:       hold.DoctorID,
+		VisitTimestamp: hold.VisitTimestamp,
+		ID:             hold.ID,
+	})
+
+	return confirmed, err
+}
+
+// ReleaseHold gives up a pending hold, the entry stays on the waitlist.
+func (wr *WaitlistRepository) ReleaseHold(ctx context.Context, holdId int32) (database.WaitlistHold, error) {
+
+	hold, err := wr.queries.GetWaitlistHoldForUpdate(ctx, holdId)
+	if err != nil {
+		return hold, err
+	}
+
+	if hold.Status != HoldPending {
+		return hold, ErrHoldNotPending
+	}
+
+	res, err := wr.queries.ResolveWaitlistHold(ctx, database.ResolveWaitlistHoldParams{
+		ID:     hold.ID,
+		Status: HoldReleased,
+	})
+
+	return res, err
+}
+
+func timePreferenceOrAny(p TimePreference) TimePreference {
+	if p == "" {
+		return TimePreferenceAny
+	}
+	return p
+}
+
+func optionalInt4(v *int32) pgtype.Int4 {
+	if v == nil {
+		return pgtype.Int4{}
+	}
+	return pgtype.Int4{Int32: *v, Valid: true}
+}
+
+func optionalText(v *string) pgtype.Text {
+	if v == nil {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: *v, Valid: true}
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+type WebhookRepositoryInterface interface {
+	GetAll(ctx context.Context) ([]database.Webhook, error)
+	Get(ctx context.Context, id int32) (database.Webhook, error)
+	Create(ctx context.Context, userId int32, data CreateWebhookParams) (database.Webhook, error)
+	SetActive(ctx context.Context, id int32, active bool) (database.Webhook, error)
+	Delete(ctx context.Context, id int32) error
+	GetDeliveries(ctx context.Context, webhookId int32, status *string, limit int32) ([]database.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, id int64) (database.WebhookDelivery, error)
+	ReplayDeadDeliveries(ctx context.Context, webhookId int32) (int64, error)
+	DispatchOutbox(ctx context.Context, limit int32) (int64, error)
+	ClaimDeliveries(ctx context.Context, limit int32, lease time.Duration) ([]database.ClaimWebhookDeliveriesRow, error)
+	MarkDelivered(ctx context.Context, id int64, statusCode int) error
+	MarkFailed(ctx context.Context, id int64, statusCode int, reason string, retryAt time.Time) error
+	MarkDead(ctx context.Context, id int64, statusCode int, reason string) error
+}
+
+type WebhookQueriesContract interface {
+    GetWebhooks(context.Context) ([]database.Webhook, error)
+    GetWebhook(context.Context, int32) (database.Webhook, error)
+    CreateWebhook(context.Context, database.CreateWebhookParams) (database.Webhook, error)
+    SetWebhookActive(context.Context, database.SetWebhookActiveParams) (database.Webhook, error)
+    DeleteWebhook(context.Context, int32) error
+    GetWebhookDeliveries(context.Context, database.GetWebhookDeliveriesParams) ([]database.WebhookDelivery, error)
+    ReplayWebhookDelivery(context.Context, int64) (database.WebhookDelivery, error)
+    ReplayDeadWebhookDeliveries(context.Context, int32) (int64, error)
+    DispatchOutboxEvents(context.Context, int32) (int64, error)
+    ClaimWebhookDeliveries(context.Context, database.ClaimWebhookDeliveriesParams) ([]database.ClaimWebhookDeliveriesRow, error)
+    CompleteWebhookDeliveryAttempt(context.Context, database.CompleteWebhookDeliveryAttemptParams) (database.WebhookDelivery, error)
+}
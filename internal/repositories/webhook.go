@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// maxDeliveryErrorLength keeps a chatty endpoint's error page out of the
+// table.
+const maxDeliveryErrorLength = 1000
+
+type WebhookRepository struct {
+	queries WebhookQueriesContract
+}
+
+type CreateWebhookParams struct {
+	URL string
+	// EventTypes the webhook receives, every type when empty.
+	EventTypes []EventType
+}
+
+func NewWebhookRepository(queries WebhookQueriesContract) WebhookRepositoryInterface {
+	return &WebhookRepository{
+		queries: queries,
+	}
+}
+
+func (wr *WebhookRepository) GetAll(ctx context.Context) ([]database.Webhook, error) {
+	res, err := wr.queries.GetWebhooks(ctx)
+	return res, err
+}
+
+func (wr *WebhookRepository) Get(ctx context.Context, id int32) (database.Webhook, error) {
+	res, err := wr.queries.GetWebhook(ctx, id)
+	return res, err
+}
+
+// Create registers a webhook with a newly generated signing secret.
+func (wr *WebhookRepository) Create(ctx context.Context, userId int32, data CreateWebhookParams) (database.Webhook, error) {
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return database.Webhook{}, err
+	}
+
+	eventTypes := make([]string, 0, len(data.EventTypes))
+	for _, t := range data.EventTypes {
+		eventTypes = append(eventTypes, string(t))
+	}
+
+	res, err := wr.queries.CreateWebhook(ctx, database.CreateWebhookParams{
+		Url:        data.URL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		CreatedBy:  pgtype.Int4{Int32: userId, Valid: userId != 0},
+	})
+
+	return res, err
+}
+
+// SetActive pauses or resumes a webhook. Events raised while it is paused
+// are not queued for it, deliveries already queued wait until it resumes.
+func (wr *WebhookRepository) SetActive(ctx context.Context, id int32, active bool) (database.Webhook, error) {
+
+	res, err := wr.queries.SetWebhookActive(ctx, database.SetWebhookActiveParams{
+		ID:     id,
+		Active: active,
+	})
+
+	return res, err
+}
+
+func (wr *WebhookRepository) Delete(ctx context.Context, id int32) error {
+	err := wr.queries.DeleteWebhook(ctx, id)
+	return err
+}
+
+// GetDeliveries returns the webhook's latest deliveries, only those in
+// status when it is given.
+func (wr *WebhookRepository) GetDeliveries(ctx context.Context, webhookId int32, status *string, limit int32) ([]database.WebhookDelivery, error) {
+
+	res, err := wr.queries.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{
+		WebhookID:  webhookId,
+		Status:     optionalText(status),
+		MaxResults: limit,
+	})
+
+	return res, err
+}
+
+// ReplayDelivery sends a delivery again from scratch, whatever its status.
+func (wr *WebhookRepository) ReplayDelivery(ctx context.Context, id int64) (database.WebhookDelivery, error) {
+	res, err := wr.queries.ReplayWebhookDelivery(ctx, id)
+	return res, err
+}
+
+// ReplayDeadDeliveries sends the deliveries that ran out of attempts again,
+// returning how many there were.
+func (wr *WebhookRepository) ReplayDeadDeliveries(ctx context.Context, webhookId int32) (int64, error) {
+	res, err := wr.queries.ReplayDeadWebhookDeliveries(ctx, webhookId)
+	return res, err
+}
+
+// DispatchOutbox queues deliveries for up to limit outbox events, returning
+// how many events it took.
+func (wr *WebhookRepository) DispatchOutbox(ctx context.Context, limit int32) (int64, error) {
+	res, err := wr.queries.DispatchOutboxEvents(ctx, limit)
+	return res, err
+}
+
+// ClaimDeliveries takes up to limit due deliveries, counting the attempt.
+// They are not due again until lease has passed, the caller should record
+// the outcome well before that.
+func (wr *WebhookRepository) ClaimDeliveries(ctx context.Context, limit int32, lease time.Duration) ([]database.ClaimWebhookDeliveriesRow, error) {
+
+	res, err := wr.queries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		MaxResults:   limit,
+		LeaseSeconds: lease.Seconds(),
+	})
+
+	return res, err
+}
+
+func (wr *WebhookRepository) MarkDelivered(ctx context.Context, id int64, statusCode int) error {
+	return wr.completeAttempt(ctx, id, DeliverySucceeded, statusCode, "", time.Now())
+}
+
+// MarkFailed records a failed attempt that is tried again at retryAt.
+func (wr *WebhookRepository) MarkFailed(ctx context.Context, id int64, statusCode int, reason string, retryAt time.Time) error {
+	return wr.completeAttempt(ctx, id, DeliveryPending, statusCode, reason, retryAt)
+}
+
+// MarkDead records a failed attempt after which the delivery is given up,
+// until it is replayed.
+func (wr *WebhookRepository) MarkDead(ctx context.Context, id int64, statusCode int, reason string) error {
+	return wr.completeAttempt(ctx, id, DeliveryDead, statusCode, reason, time.Now())
+}
+
+// completeAttempt stores the outcome of an attempt. A statusCode of 0 means
+// no response was received.
+func (wr *WebhookRepository) completeAttempt(ctx context.Context, id int64, status string, statusCode int, reason string, nextAttemptAt time.Time) error {
+
+	if len(reason) > maxDeliveryErrorLength {
+		reason = reason[:maxDeliveryErrorLength]
+	}
+
+	_, err := wr.queries.CompleteWebhookDeliveryAttempt(ctx, database.CompleteWebhookDeliveryAttemptParams{
+		ID:             id,
+		Status:         status,
+		NextAttemptAt:  pgtype.Timestamptz{Time: nextAttemptAt, Valid: true},
+		LastStatusCode: pgtype.Int4{Int32: int32(statusCode), Valid: statusCode != 0},
+		LastError:      pgtype.Text{String: reason, Valid: reason != ""},
+	})
+
+	return err
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
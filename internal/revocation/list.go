@@ -0,0 +1,154 @@
+// Package revocation keeps track of the tokens revoked before they expire.
+// They are stored in the database so that every instance of the API rejects
+// them, and cached in memory so that checking a token doesn't cost a query.
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+	"sync"
+	"time"
+)
+
+// Store keeps the revoked tokens, see RevokedTokenRepository.
+type Store interface {
+	GetRevokedTokens(ctx context.Context) ([]database.RevokedToken, error)
+	RevokeToken(ctx context.Context, jti string, userId int32, expiresAt time.Time) error
+	DeleteExpiredRevokedTokens(ctx context.Context) error
+}
+
+// DefaultRefreshInterval is how often the revoked tokens are reloaded unless
+// set with WithRefreshInterval. A token revoked by another instance is
+// accepted here for at most that long.
+const DefaultRefreshInterval = 30 * time.Second
+
+// List is the revoked tokens by their jti. They are loaded on first use and
+// reloaded every refresh interval, tokens revoked through the list itself
+// are rejected right away.
+type List struct {
+	store   Store
+	refresh time.Duration
+
+	mu       sync.RWMutex
+	revoked  map[string]time.Time
+	loadedAt time.Time
+}
+
+type Option func(*List)
+
+// WithRefreshInterval sets how often the revoked tokens are reloaded.
+func WithRefreshInterval(refresh time.Duration) Option {
+	return func(l *List) {
+		l.refresh = refresh
+	}
+}
+
+func NewList(store Store, opts ...Option) *List {
+	l := &List{
+		store:   store,
+		refresh: DefaultRefreshInterval,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Load deletes the revoked tokens that have expired since and reads the
+// others.
+func (l *List) Load(ctx context.Context) error {
+	if err := l.store.DeleteExpiredRevokedTokens(ctx); err != nil {
+		return err
+	}
+
+	tokens, err := l.store.GetRevokedTokens(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	revoked := make(map[string]time.Time, len(tokens))
+	for _, token := range tokens {
+		revoked[token.Jti] = token.ExpiresAt.Time
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// a token revoked here while loading may not have been read, a token
+	// once revoked stays so until it expires
+	for jti, expiresAt := range l.revoked {
+		if _, ok := revoked[jti]; !ok && expiresAt.After(now) {
+			revoked[jti] = expiresAt
+		}
+	}
+
+	l.revoked = revoked
+	l.loadedAt = now
+
+	return nil
+}
+
+// current returns the revoked tokens, reloading them when they are older
+// than the refresh interval. A failed reload keeps using the tokens loaded
+// before.
+func (l *List) current(ctx context.Context) (map[string]time.Time, error) {
+	l.mu.RLock()
+	revoked, loadedAt := l.revoked, l.loadedAt
+	l.mu.RUnlock()
+
+	if revoked != nil && time.Since(loadedAt) < l.refresh {
+		return revoked, nil
+	}
+
+	if err := l.Load(ctx); err != nil {
+		if revoked != nil {
+			fmt.Println("revoked tokens:", err)
+			return revoked, nil
+		}
+		return nil, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.revoked, nil
+}
+
+// Revoke rejects the token jti of userId until it expires at expiresAt.
+func (l *List) Revoke(ctx context.Context, jti string, userId int32, expiresAt time.Time) error {
+	if err := l.store.RevokeToken(ctx, jti, userId, expiresAt); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.revoked == nil {
+		// loaded on first use, with this token
+		return nil
+	}
+
+	// maps handed out by current are never written to
+	revoked := make(map[string]time.Time, len(l.revoked)+1)
+	for k, v := range l.revoked {
+		revoked[k] = v
+	}
+	revoked[jti] = expiresAt
+	l.revoked = revoked
+
+	return nil
+}
+
+// IsRevoked reports whether the token jti was revoked.
+func (l *List) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	revoked, err := l.current(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	expiresAt, ok := revoked[jti]
+	return ok && expiresAt.After(time.Now()), nil
+}
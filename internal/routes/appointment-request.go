@@ -3,11 +3,45 @@ package routes
 import "time"
 
 type AppointmentCreateRequest struct {
-	VisitTime    time.Time `json:"visit_time" validate:"required"`
-	PatientNotes *string   `json:"patient_notes" validate:"omitempty,max=1000"`
+	DoctorID  int32     `json:"doctor_id" validate:"required,gt=0"`
+	VisitTime time.Time `json:"visit_time" validate:"required"`
+	// DurationMinutes defaults to the doctor's slot length.
+	DurationMinutes *int16  `json:"duration_minutes" validate:"omitempty,min=5,max=480"`
+	PatientNotes    *string `json:"patient_notes" validate:"omitempty,max=1000"`
+	// Recurrence turns the booking into a series starting at VisitTime.
+	Recurrence *AppointmentRecurrenceRequest `json:"recurrence" validate:"omitempty"`
+}
+
+type AppointmentRecurrenceRequest struct {
+	Frequency string  `json:"frequency" validate:"required,oneof=daily weekly"`
+	Interval  int     `json:"interval" validate:"omitempty,min=1,max=52"`
+	Count     int     `json:"count" validate:"omitempty,min=1,max=200"`
+	Until     *string `json:"until" validate:"omitempty,datetime=2006-01-02"`
+	Weekdays  []int   `json:"weekdays" validate:"omitempty,max=7,dive,min=0,max=6"`
 }
 
 type AppointmentUpdateRequest struct {
 	PatientNotes *string `json:"patient_notes" validate:"omitempty,max=1000"`
 	DoctorNotes  *string `json:"doctor_notes" validate:"omitempty,max=1000"`
 }
+
+type AppointmentCancelRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type AppointmentRescheduleRequest struct {
+	VisitTime time.Time `json:"visit_time" validate:"required"`
+	Reason    *string   `json:"reason" validate:"omitempty,max=500"`
+}
+
+type AppointmentSeriesEditRequest struct {
+	Scope        string     `json:"scope" validate:"required,oneof=this following all"`
+	VisitTime    *time.Time `json:"visit_time"`
+	PatientNotes *string    `json:"patient_notes" validate:"omitempty,max=1000"`
+	Reason       *string    `json:"reason" validate:"omitempty,max=500"`
+}
+
+type AppointmentSeriesCancelRequest struct {
+	Scope  string `json:"scope" validate:"required,oneof=this following all"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
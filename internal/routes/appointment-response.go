@@ -9,21 +9,91 @@ type AppointmentResponse struct {
 	ID    int64 `json:"id"`
 	PatientId    int64 `json:"patient_id"`
 	UserId    int64 `json:"user_id"`
+	DoctorId  *int64 `json:"doctor_id"`
+	SeriesId  *int64 `json:"series_id"`
 	VisitTime    time.Time `json:"visit_time"`
+	VisitEnd     time.Time `json:"visit_end"`
+	DurationMinutes int16 `json:"duration_minutes"`
 	VisitDate    time.Time `json:"visit_date"`
 	PatientNotes string   `json:"patient_notes"`
 	DoctorNotes  string `json:"doctor_notes"`
+	Status       string `json:"status"`
+	StatusUpdatedAt *time.Time `json:"status_updated_at"`
+	StatusUpdatedBy *int64 `json:"status_updated_by"`
+	CancelReason *string `json:"cancel_reason"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+}
+
+type AppointmentStatusHistoryResponse struct {
+	ID         int64     `json:"id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ChangedBy  *int64    `json:"changed_by"`
+	Reason     *string   `json:"reason"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+type AppointmentRescheduleResponse struct {
+	ID            int64     `json:"id"`
+	OldVisitTime  time.Time `json:"old_visit_time"`
+	NewVisitTime  time.Time `json:"new_visit_time"`
+	RescheduledBy *int64    `json:"rescheduled_by"`
+	Reason        *string   `json:"reason"`
+	RescheduledAt time.Time `json:"rescheduled_at"`
+}
+
+// AppointmentDetailResponse is a single appointment together with the times
+// it was moved from.
+type AppointmentDetailResponse struct {
+	AppointmentResponse
+	RescheduleHistory []AppointmentRescheduleResponse `json:"reschedule_history"`
+}
+
+type AppointmentSeriesResponse struct {
+	ID              int64                 `json:"id"`
+	PatientId       int64                 `json:"patient_id"`
+	DoctorId        *int64                `json:"doctor_id"`
+	Frequency       string                `json:"frequency"`
+	Interval        int                   `json:"interval"`
+	Count           *int                  `json:"count"`
+	Until           *string               `json:"until"`
+	Weekdays        []int                 `json:"weekdays"`
+	Timezone        string                `json:"timezone"`
+	StartsAt        time.Time             `json:"starts_at"`
+	DurationMinutes int16                 `json:"duration_minutes"`
+	Appointments    []AppointmentResponse `json:"appointments"`
+}
+
+// AppointmentConflictResponse is the 409 body when a booking overlaps
+// appointments of the same doctor or patient.
+type AppointmentConflictResponse struct {
+	HttpError
+	Conflicts []AppointmentResponse `json:"conflicts"`
 }
 
 func AppointmentDbToResponse(data database.Appointment) AppointmentResponse{
+    var statusUpdatedAt *time.Time
+    if data.StatusUpdatedAt.Valid {
+        statusUpdatedAt = &data.StatusUpdatedAt.Time
+    }
+
     return AppointmentResponse {
         ID: int64(data.ID),
         PatientId: int64(data.PatientID),
         UserId: int64(data.UserID.Int32),
+        DoctorId: int4ToPtr(data.DoctorID),
+        SeriesId: int4ToPtr(data.SeriesID),
         VisitTime: data.VisitTimestamp.Time,
+        VisitEnd: data.VisitEnd.Time,
+        DurationMinutes: data.DurationMinutes,
         VisitDate: data.VisitDate.Time,
         PatientNotes: data.PatientNotes.String,
         DoctorNotes: data.DoctorNotes.String,
+        Status: data.Status,
+        StatusUpdatedAt: statusUpdatedAt,
+        StatusUpdatedBy: int4ToPtr(data.StatusUpdatedBy),
+        CancelReason: textToPtr(data.CancelReason),
+        DeletedAt: timestamptzToPtr(data.DeletedAt),
     }
 }
 
@@ -38,3 +108,77 @@ func AppointmentDbArrayToResponse(data []database.Appointment) []AppointmentResp
     return appointments
 
 }
+
+func AppointmentStatusHistoryDbArrayToResponse(data []database.AppointmentStatusHistory) []AppointmentStatusHistoryResponse {
+
+    history := make([]AppointmentStatusHistoryResponse, len(data))
+
+    for i, item := range data {
+        history[i] = AppointmentStatusHistoryResponse{
+            ID: int64(item.ID),
+            FromStatus: item.FromStatus,
+            ToStatus: item.ToStatus,
+            ChangedBy: int4ToPtr(item.ChangedBy),
+            Reason: textToPtr(item.Reason),
+            ChangedAt: item.CreatedAt.Time,
+        }
+    }
+
+    return history
+}
+
+func AppointmentDetailToResponse(data database.Appointment, history []database.AppointmentRescheduleHistory) AppointmentDetailResponse {
+
+    reschedules := make([]AppointmentRescheduleResponse, len(history))
+
+    for i, item := range history {
+        reschedules[i] = AppointmentRescheduleResponse{
+            ID: int64(item.ID),
+            OldVisitTime: item.OldVisitTimestamp.Time,
+            NewVisitTime: item.NewVisitTimestamp.Time,
+            RescheduledBy: int4ToPtr(item.RescheduledBy),
+            Reason: textToPtr(item.Reason),
+            RescheduledAt: item.CreatedAt.Time,
+        }
+    }
+
+    return AppointmentDetailResponse{
+        AppointmentResponse: AppointmentDbToResponse(data),
+        RescheduleHistory: reschedules,
+    }
+}
+
+func AppointmentSeriesToResponse(series database.AppointmentSeries, appointments []database.Appointment) AppointmentSeriesResponse {
+
+    var count *int
+    if series.OccurrenceCount.Valid {
+        c := int(series.OccurrenceCount.Int16)
+        count = &c
+    }
+
+    var until *string
+    if series.UntilDate.Valid {
+        u := series.UntilDate.Time.Format("2006-01-02")
+        until = &u
+    }
+
+    weekdays := make([]int, len(series.Weekdays))
+    for i, wd := range series.Weekdays {
+        weekdays[i] = int(wd)
+    }
+
+    return AppointmentSeriesResponse{
+        ID: int64(series.ID),
+        PatientId: int64(series.PatientID),
+        DoctorId: int4ToPtr(series.DoctorID),
+        Frequency: series.Frequency,
+        Interval: int(series.RepeatInterval),
+        Count: count,
+        Until: until,
+        Weekdays: weekdays,
+        Timezone: series.Timezone,
+        StartsAt: series.StartsAt.Time,
+        DurationMinutes: series.DurationMinutes,
+        Appointments: AppointmentDbArrayToResponse(appointments),
+    }
+}
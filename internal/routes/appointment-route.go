@@ -3,27 +3,47 @@ package routes
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
+	"patient-appointment-demo-go/internal/queue"
 	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/internal/schedule"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
 )
 
+// errNotAssignedDoctor rolls back an update of doctor notes by anyone but
+// the appointment's doctor.
+var errNotAssignedDoctor = errors.New("not the appointment's doctor")
+
 type AppointmentRouter struct {
-	mux      *http.ServeMux
-	userRepo repositories.UserRepositoryInterface
-	repo     repositories.AppointmentRepositoryInterface
+	mux          *http.ServeMux
+	userRepo     repositories.UserRepositoryInterface
+	repo         repositories.AppointmentRepositoryInterface
+	scheduleRepo repositories.ScheduleRepositoryInterface
+	waitlistRepo repositories.WaitlistRepositoryInterface
+	audit        repositories.AuditRepositoryInterface
+	tx           repositories.TxManagerInterface
+	feed         *queue.Feed
 }
 
-func NewAppointmentRouter(mux *http.ServeMux, appointmentRepo repositories.AppointmentRepositoryInterface, userRepo repositories.UserRepositoryInterface) *AppointmentRouter {
+func NewAppointmentRouter(mux *http.ServeMux, appointmentRepo repositories.AppointmentRepositoryInterface, userRepo repositories.UserRepositoryInterface, scheduleRepo repositories.ScheduleRepositoryInterface, waitlistRepo repositories.WaitlistRepositoryInterface, auditRepo repositories.AuditRepositoryInterface, tx repositories.TxManagerInterface, feed *queue.Feed) *AppointmentRouter {
     return &AppointmentRouter{
         mux: mux,
         repo: appointmentRepo,
         userRepo: userRepo,
+        scheduleRepo: scheduleRepo,
+        waitlistRepo: waitlistRepo,
+        audit: auditRepo,
+        tx: tx,
+        feed: feed,
     }
 }
 
@@ -45,6 +65,11 @@ func (r *AppointmentRouter) Register() *AppointmentRouter {
         AddMiddlewares(authMiddleware.ValidateLogin).
         Register(r.mux)
 
+	NewRoute("GET", "/api/doctors/{id}/appointments").
+        SetHandler(r.GetByDoctor).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
 	NewRoute("GET", "/api/appointments/{id}").
         SetHandler(r.Get).
         AddMiddlewares(authMiddleware.ValidateLogin).
@@ -68,22 +93,174 @@ func (r *AppointmentRouter) Register() *AppointmentRouter {
         AddMiddlewares(authMiddleware.ValidateLogin).
         Register(r.mux)
 
+	NewRoute("POST", "/api/appointments/{id}/restore").
+        SetHandler(r.Restore).
+        AddMiddlewares(
+            authMiddleware.ValidateLogin,
+            authMiddleware.ValidateRole("admin"),
+        ).
+        Register(r.mux)
+
+	NewRoute("GET", "/api/appointments/{id}/status-history").
+        SetHandler(r.GetStatusHistory).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
+	NewRoute("POST", "/api/appointments/{id}/reschedule").
+        SetHandler(r.Reschedule).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
+	NewRoute("GET", "/api/appointment-series/{id}").
+        SetHandler(r.GetSeries).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
+	NewRoute("PUT", "/api/appointments/{id}/series").
+        SetHandler(r.UpdateSeries).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
+	NewRoute("POST", "/api/appointments/{id}/series/cancel").
+        SetHandler(r.CancelSeries).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
+	NewRoute("POST", "/api/appointments/{id}/check-in").
+        SetHandler(r.CheckIn).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
+	NewRoute("POST", "/api/appointments/{id}/start").
+        SetHandler(r.Start).
+        AddMiddlewares(
+            authMiddleware.ValidateLogin,
+            authMiddleware.ValidateRole("doctor"),
+        ).
+        Register(r.mux)
+
+	NewRoute("POST", "/api/appointments/{id}/complete").
+        SetHandler(r.Complete).
+        AddMiddlewares(
+            authMiddleware.ValidateLogin,
+            authMiddleware.ValidateRole("doctor"),
+        ).
+        Register(r.mux)
+
+	NewRoute("POST", "/api/appointments/{id}/cancel").
+        SetHandler(r.Cancel).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
+	NewRoute("POST", "/api/appointments/{id}/no-show").
+        SetHandler(r.NoShow).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
 	return r
 }
 
+// GetAll lists appointments a page at a time, newest visit first unless
+// sorted otherwise. The from and to dates, doctor_id, patient_id and a
+// comma separated status list narrow it down.
 func (ac *AppointmentRouter) GetAll(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	query := r.URL.Query()
+
+	page, errs := pageFromQuery(query, repositories.AppointmentSortVisit, repositories.AppointmentSortCreatedAt)
+	if query.Get("sort_direction") == "" {
+		page.Desc = true
+	}
+
+	option := repositories.GetAppointmentsOption{Page: page}
+
+	dates := []struct {
+		key   string
+		field string
+		dst   **time.Time
+	}{
+		{"from", "From", &option.VisitFrom},
+		{"to", "To", &option.VisitTo},
+	}
+	for _, d := range dates {
+		if dateStr := query.Get(d.key); dateStr != "" {
+			date, err := time.Parse("2006-01-02", dateStr)
+			if err != nil {
+				errs[d.field] = d.key + " must be a date like 2006-01-02"
+				continue
+			}
+			*d.dst = &date
+		}
+	}
+
+	ids := []struct {
+		key   string
+		field string
+		dst   **int32
+	}{
+		{"doctor_id", "DoctorID", &option.DoctorID},
+		{"patient_id", "PatientID", &option.PatientID},
+	}
+	for _, i := range ids {
+		if idStr := query.Get(i.key); idStr != "" {
+			id, err := strconv.ParseInt(idStr, 10, 32)
+			if err != nil {
+				errs[i.field] = i.key + " must be an id"
+				continue
+			}
+			id32 := int32(id)
+			*i.dst = &id32
+		}
+	}
+
+	if statusStr := query.Get("status"); statusStr != "" {
+		for _, status := range strings.Split(statusStr, ",") {
+			if !repositories.AppointmentStatus(status).IsValid() {
+				errs["Status"] = fmt.Sprintf("unknown status %q", status)
+				break
+			}
+			option.Statuses = append(option.Statuses, status)
+		}
+	}
+
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": errs,
+		})
+		return
+	}
+
+	withDeleted, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+	option.IncludeDeleted = withDeleted
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
-	appointments, err := ac.repo.GetAll(ctx)
+	appointments, err := ac.repo.GetAll(ctx, option)
+
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"Cursor": err.Error()},
+		})
+		return
+	}
 
 	if err != nil {
+		fmt.Println(err)
 		http.Error(w, "Failed to fetch appointments", http.StatusInternalServerError)
 		return
 	}
 
+	if !auditAccess(ctx, w, ac.audit, repositories.AuditActionList, repositories.AuditEntityAppointment, entityIds(appointments.Items, appointmentId)...) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(AppointmentDbArrayToResponse(appointments))
+	json.NewEncoder(w).Encode(PageToResponse(appointments, AppointmentDbArrayToResponse))
 }
 
 func (ac *AppointmentRouter) GetByDate(w http.ResponseWriter, r *http.Request) {
@@ -97,7 +274,7 @@ func (ac *AppointmentRouter) GetByDate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
 	appointments, err := ac.repo.GetByDate(ctx, parsedDate)
@@ -107,6 +284,10 @@ func (ac *AppointmentRouter) GetByDate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !auditAccess(ctx, w, ac.audit, repositories.AuditActionList, repositories.AuditEntityAppointment, entityIds(appointments, appointmentId)...) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(AppointmentDbArrayToResponse(appointments))
 }
@@ -120,7 +301,7 @@ func (ac *AppointmentRouter) GetByPatient(w http.ResponseWriter, r *http.Request
         return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
 	appointments, err := ac.repo.GetByPatient(ctx, int32(patientId))
@@ -130,30 +311,94 @@ func (ac *AppointmentRouter) GetByPatient(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if !auditAccess(ctx, w, ac.audit, repositories.AuditActionList, repositories.AuditEntityAppointment, entityIds(appointments, appointmentId)...) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(AppointmentDbArrayToResponse(appointments))
 }
 
+func (ac *AppointmentRouter) GetByDoctor(w http.ResponseWriter, r *http.Request) {
+	doctorIdStr := r.PathValue("id")
+	doctorId, err := strconv.ParseInt(doctorIdStr, 10, 64)
+
+	if err != nil {
+		http.Error(w, "invalid doctor id", http.StatusBadRequest)
+		return
+	}
+
+	var date *time.Time
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		parsedDate, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			http.Error(w, "Invalid date", http.StatusBadRequest)
+			return
+		}
+		date = &parsedDate
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	appointments, err := ac.repo.GetByDoctor(ctx, int32(doctorId), date)
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch appointments for the doctor", http.StatusInternalServerError)
+		return
+	}
+
+	if !auditAccess(ctx, w, ac.audit, repositories.AuditActionList, repositories.AuditEntityAppointment, entityIds(appointments, appointmentId)...) {
+		return
+	}
+
+	json.NewEncoder(w).Encode(AppointmentDbArrayToResponse(appointments))
+}
+
 func (ac *AppointmentRouter) Get(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 
 	if err != nil {
 		http.Error(w, "invalid appointment id", http.StatusBadRequest)
+		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	withDeleted, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
-	appointment, err := ac.repo.Get(ctx, int32(id))
+	get := ac.repo.Get
+	if withDeleted {
+		get = ac.repo.GetWithDeleted
+	}
+
+	appointment, err := get(ctx, int32(id))
 
 	if err != nil {
 		http.Error(w, "Failed to fetch appointments for the patient", http.StatusNotFound)
 		return
 	}
 
+	history, err := ac.repo.GetRescheduleHistory(ctx, appointment.ID)
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch reschedule history", http.StatusInternalServerError)
+		return
+	}
+
+	if !auditAccess(ctx, w, ac.audit, repositories.AuditActionView, repositories.AuditEntityAppointment, appointment.ID) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(AppointmentDbToResponse(appointment))
+	json.NewEncoder(w).Encode(AppointmentDetailToResponse(appointment, history))
 
 }
 
@@ -196,15 +441,85 @@ func (ac *AppointmentRouter) Create(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
-	appointment, err := ac.repo.Create(ctx, user.ID, int32(patientId), repositories.CreateAppointmentParams{
-        VisitTimestamp: req.VisitTime,
-        PatientNotes: req.PatientNotes,
-    })
+	doctor, err := ac.userRepo.Get(ctx, req.DoctorID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch doctor", http.StatusInternalServerError)
+		return
+	}
+
+	if err != nil || doctor.Type != "doctor" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"DoctorID": "doctor_id must reference a user of type doctor"},
+		})
+		return
+	}
+
+	if req.Recurrence != nil {
+		ac.createSeries(w, r, user, int32(patientId), doctor, req)
+		return
+	}
+
+	sched, err := ac.scheduleRepo.GetSchedule(ctx, doctor.ID, req.VisitTime, req.VisitTime)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch doctor schedule", http.StatusInternalServerError)
+		return
+	}
+
+	duration := sched.SlotLength
+	if req.DurationMinutes != nil {
+		duration = time.Duration(*req.DurationMinutes) * time.Minute
+	}
+
+	visit := schedule.Interval{Start: req.VisitTime, End: req.VisitTime.Add(duration)}
+	if !sched.Covers(visit) {
+		http.Error(w, "Doctor is not available at the requested time", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if !ac.slotsFree(ctx, w, doctor.ID, []schedule.Interval{visit}) {
+		return
+	}
+
+	var appointment database.Appointment
+	err = ac.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		appointment, err = repos.Appointments.Create(ctx, user.ID, int32(patientId), repositories.CreateAppointmentParams{
+			DoctorID:       doctor.ID,
+			VisitTimestamp: req.VisitTime,
+			Duration:       duration,
+			PatientNotes:   req.PatientNotes,
+		})
+		if err != nil {
+			return err
+		}
+
+		return enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentCreated, appointment)
+	})
+
+	var overlap repositories.AppointmentOverlapError
+	if errors.As(err, &overlap) {
+		fmt.Println(err)
+		writeAppointmentConflict(w, overlap)
+		return
+	}
+
+	if errors.Is(err, repositories.ErrAppointmentSequenceConflict) {
+		fmt.Println(err)
+		http.Error(w, "Could not allocate an appointment number, please retry", http.StatusConflict)
+		return
+	}
 
 	if err != nil {
+		fmt.Println(err)
 		http.Error(w, "Failed to create appointment", http.StatusInternalServerError)
+		return
 	}
 
+	publishQueueChange(ctx, ac.feed, queue.ActionCreated, appointment)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(AppointmentDbToResponse(appointment))
 }
@@ -228,10 +543,48 @@ func (ac *AppointmentRouter) Update(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
-	appointment, err := ac.repo.Update(ctx, int32(id), repositories.UpdateAppointmentParams{
-        PatientNotes: req.PatientNotes,
-        DoctorNotes: req.DoctorNotes,
-    })
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
+		return
+	}
+
+	var appointment database.Appointment
+	err = ac.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		// only the doctor the appointment is assigned to may write its
+		// notes, checked on the locked row so a reassignment can't slip in
+		if req.DoctorNotes != nil {
+			current, err := repos.Appointments.GetForUpdate(ctx, int32(id))
+			if err != nil {
+				return err
+			}
+
+			if !current.DoctorID.Valid || current.DoctorID.Int32 != user.ID {
+				return errNotAssignedDoctor
+			}
+		}
+
+		var err error
+		appointment, err = repos.Appointments.Update(ctx, int32(id), repositories.UpdateAppointmentParams{
+			PatientNotes: req.PatientNotes,
+			DoctorNotes:  req.DoctorNotes,
+		})
+		if err != nil {
+			return err
+		}
+
+		return enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentUpdated, appointment)
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Appointment not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, errNotAssignedDoctor) {
+		http.Error(w, "Only the assigned doctor may write doctor notes", http.StatusForbidden)
+		return
+	}
 
 	if err != nil {
         fmt.Println(err)
@@ -251,13 +604,348 @@ func (ac *AppointmentRouter) Delete(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		http.Error(w, "Invalid appointment id", http.StatusBadRequest)
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
-	err = ac.repo.Delete(ctx, int32(id))
+	appointment, err := ac.repo.Get(ctx, int32(id))
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch appointment", http.StatusInternalServerError)
+		return
+	}
+
+	err = ac.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		if err := repos.Appointments.Delete(ctx, int32(id)); err != nil {
+			return err
+		}
+
+		if appointment.ID == 0 {
+			return nil
+		}
+		return enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentDeleted, appointment)
+	})
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to delete appointment", http.StatusInternalServerError)
+		return
+	}
+
+	if appointment.ID != 0 {
+		publishQueueChange(ctx, ac.feed, queue.ActionDeleted, appointment)
+
+		if !repositories.AppointmentStatus(appointment.Status).IsFinal() {
+			ac.offerFreedSlot(ctx, appointment)
+		}
+	}
 
 	w.Write([]byte(""))
 
 }
+
+// Restore brings back a deleted appointment. Appointments of a deleted
+// patient come back by restoring the patient.
+func (ac *AppointmentRouter) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid appointment id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	var appointment database.Appointment
+	err = ac.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		appointment, err = repos.Appointments.Restore(ctx, int32(id))
+		if err != nil {
+			return err
+		}
+
+		return enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentRestored, appointment)
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Appointment not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repositories.ErrAppointmentPatientDeleted) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if repositories.IsExclusionViolation(err, "") {
+		http.Error(w, "The appointment overlaps another one now", http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to restore appointment", http.StatusInternalServerError)
+		return
+	}
+
+	publishQueueChange(ctx, ac.feed, queue.ActionCreated, appointment)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AppointmentDbToResponse(appointment))
+}
+
+func (ac *AppointmentRouter) GetStatusHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid appointment id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	history, err := ac.repo.GetStatusHistory(ctx, int32(id))
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch appointment status history", http.StatusInternalServerError)
+		return
+	}
+
+	if !auditAccess(ctx, w, ac.audit, repositories.AuditActionView, repositories.AuditEntityAppointment, int32(id)) {
+		return
+	}
+
+	json.NewEncoder(w).Encode(AppointmentStatusHistoryDbArrayToResponse(history))
+}
+
+func (ac *AppointmentRouter) CheckIn(w http.ResponseWriter, r *http.Request) {
+	ac.transition(w, r, repositories.AppointmentCheckedIn, nil)
+}
+
+func (ac *AppointmentRouter) Start(w http.ResponseWriter, r *http.Request) {
+	ac.transition(w, r, repositories.AppointmentInConsultation, nil)
+}
+
+func (ac *AppointmentRouter) Complete(w http.ResponseWriter, r *http.Request) {
+	ac.transition(w, r, repositories.AppointmentCompleted, nil)
+}
+
+func (ac *AppointmentRouter) NoShow(w http.ResponseWriter, r *http.Request) {
+	ac.transition(w, r, repositories.AppointmentNoShow, nil)
+}
+
+func (ac *AppointmentRouter) Cancel(w http.ResponseWriter, r *http.Request) {
+	var req AppointmentCancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	ac.transition(w, r, repositories.AppointmentCancelled, &req.Reason)
+}
+
+func (ac *AppointmentRouter) Reschedule(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid appointment id", http.StatusBadRequest)
+		return
+	}
+
+	var req AppointmentRescheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	current, err := ac.repo.Get(ctx, int32(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Appointment not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch appointment", http.StatusInternalServerError)
+		return
+	}
+
+	if current.DoctorID.Valid {
+		sched, err := ac.scheduleRepo.GetSchedule(ctx, current.DoctorID.Int32, req.VisitTime, req.VisitTime)
+		if err != nil {
+			fmt.Println(err)
+			http.Error(w, "Failed to fetch doctor schedule", http.StatusInternalServerError)
+			return
+		}
+
+		duration := time.Duration(current.DurationMinutes) * time.Minute
+		visit := schedule.Interval{Start: req.VisitTime, End: req.VisitTime.Add(duration)}
+		if !sched.Covers(visit) {
+			http.Error(w, "Doctor is not available at the requested time", http.StatusUnprocessableEntity)
+			return
+		}
+
+		if !ac.slotsFree(ctx, w, current.DoctorID.Int32, []schedule.Interval{visit}) {
+			return
+		}
+	}
+
+	var appointment database.Appointment
+	var history []database.AppointmentRescheduleHistory
+	err = ac.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		appointment, err = repos.Appointments.Reschedule(ctx, int32(id), repositories.RescheduleAppointmentParams{
+			ActorID:        user.ID,
+			VisitTimestamp: req.VisitTime,
+			Reason:         req.Reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		history, err = repos.Appointments.GetRescheduleHistory(ctx, appointment.ID)
+		if err != nil {
+			return err
+		}
+
+		return enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentRescheduled, appointment)
+	})
+
+	var overlap repositories.AppointmentOverlapError
+	if errors.As(err, &overlap) {
+		fmt.Println(err)
+		writeAppointmentConflict(w, overlap)
+		return
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Appointment not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repositories.ErrAppointmentNotReschedulable) {
+		NewHttpError(http.StatusConflict, err.Error()).Write(w)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to reschedule appointment", http.StatusInternalServerError)
+		return
+	}
+
+	publishQueueChange(ctx, ac.feed, queue.ActionRescheduled, movedAppointments(current, appointment)...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AppointmentDetailToResponse(appointment, history))
+}
+
+func (ac *AppointmentRouter) transition(w http.ResponseWriter, r *http.Request, to repositories.AppointmentStatus, reason *string) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid appointment id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	var appointment database.Appointment
+	err = ac.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		appointment, err = repos.Appointments.Transition(ctx, int32(id), repositories.TransitionAppointmentParams{
+			ActorID: user.ID,
+			To:      to,
+			Reason:  reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		return enqueueAppointmentEvents(ctx, repos.Outbox, repositories.AppointmentStatusEvent(to), appointment)
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Appointment not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repositories.ErrInvalidStatusTransition) {
+		NewHttpError(http.StatusConflict, err.Error()).Write(w)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to update appointment status", http.StatusInternalServerError)
+		return
+	}
+
+	publishQueueChange(ctx, ac.feed, queue.ActionStatusChanged, appointment)
+
+	if to == repositories.AppointmentCancelled {
+		ac.offerFreedSlot(ctx, appointment)
+	}
+
+	json.NewEncoder(w).Encode(AppointmentDbToResponse(appointment))
+}
+
+func writeAppointmentConflict(w http.ResponseWriter, overlap repositories.AppointmentOverlapError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(AppointmentConflictResponse{
+		HttpError: NewHttpError(http.StatusConflict, "Appointment overlaps existing appointments"),
+		Conflicts: AppointmentDbArrayToResponse(overlap.Conflicts),
+	})
+}
+
+func appointmentId(appointment database.Appointment) int32 {
+	return appointment.ID
+}
@@ -0,0 +1,462 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/queue"
+	"patient-appointment-demo-go/internal/recurrence"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/internal/schedule"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+)
+
+// createSeries books every occurrence of req.Recurrence for the patient,
+// all or nothing.
+func (ac *AppointmentRouter) createSeries(w http.ResponseWriter, r *http.Request, user database.User, patientId int32, doctor database.User, req AppointmentCreateRequest) {
+	rule, err := recurrenceFromRequest(*req.Recurrence)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	// the rule is expanded in the doctor's timezone
+	sched, err := ac.scheduleRepo.GetSchedule(ctx, doctor.ID, req.VisitTime, req.VisitTime)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch doctor schedule", http.StatusInternalServerError)
+		return
+	}
+
+	occurrences, err := rule.Occurrences(req.VisitTime, sched.Location)
+	if errors.Is(err, recurrence.ErrInvalidRule) || errors.Is(err, recurrence.ErrTooManyOccurrences) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil || len(occurrences) == 0 {
+		http.Error(w, "Recurrence has no occurrences", http.StatusBadRequest)
+		return
+	}
+
+	duration := sched.SlotLength
+	if req.DurationMinutes != nil {
+		duration = time.Duration(*req.DurationMinutes) * time.Minute
+	}
+
+	sched, err = ac.scheduleRepo.GetSchedule(ctx, doctor.ID, occurrences[0], occurrences[len(occurrences)-1].Add(duration))
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch doctor schedule", http.StatusInternalServerError)
+		return
+	}
+
+	visits := make([]schedule.Interval, len(occurrences))
+	for i, start := range occurrences {
+		visits[i] = schedule.Interval{Start: start, End: start.Add(duration)}
+		if !sched.Covers(visits[i]) {
+			http.Error(w, fmt.Sprintf("Doctor is not available at %s", start.Format(time.RFC3339)), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	if !ac.slotsFree(ctx, w, doctor.ID, visits) {
+		return
+	}
+
+	var series database.AppointmentSeries
+	var appointments []database.Appointment
+	err = ac.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		series, appointments, err = repos.Appointments.CreateSeries(ctx, user.ID, patientId, repositories.CreateAppointmentSeriesParams{
+			DoctorID:     doctor.ID,
+			Rule:         rule,
+			Start:        req.VisitTime,
+			Location:     sched.Location,
+			Duration:     duration,
+			PatientNotes: req.PatientNotes,
+		})
+		if err != nil {
+			return err
+		}
+
+		return enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentCreated, appointments...)
+	})
+
+	var overlap repositories.AppointmentOverlapError
+	if errors.As(err, &overlap) {
+		fmt.Println(err)
+		writeAppointmentConflict(w, overlap)
+		return
+	}
+
+	if errors.Is(err, repositories.ErrAppointmentSequenceConflict) {
+		fmt.Println(err)
+		http.Error(w, "Could not allocate an appointment number, please retry", http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to create appointment series", http.StatusInternalServerError)
+		return
+	}
+
+	publishQueueChange(ctx, ac.feed, queue.ActionCreated, appointments...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AppointmentSeriesToResponse(series, appointments))
+}
+
+func (ac *AppointmentRouter) GetSeries(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid series id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	series, err := ac.repo.GetSeries(ctx, int32(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Appointment series not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch appointment series", http.StatusInternalServerError)
+		return
+	}
+
+	appointments, err := ac.repo.GetSeriesAppointments(ctx, series.ID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch appointment series", http.StatusInternalServerError)
+		return
+	}
+
+	if !auditAccess(ctx, w, ac.audit, repositories.AuditActionList, repositories.AuditEntityAppointment, entityIds(appointments, appointmentId)...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AppointmentSeriesToResponse(series, appointments))
+}
+
+// UpdateSeries moves and/or edits the notes of this occurrence, this and the
+// following ones, or the whole series.
+func (ac *AppointmentRouter) UpdateSeries(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid appointment id", http.StatusBadRequest)
+		return
+	}
+
+	var req AppointmentSeriesEditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	if req.VisitTime == nil && req.PatientNotes == nil {
+		http.Error(w, "Nothing to update, give visit_time or patient_notes", http.StatusBadRequest)
+		return
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	scope := repositories.SeriesScope(req.Scope)
+
+	var moves []repositories.SeriesMove
+	if req.VisitTime != nil {
+		moves, err = ac.repo.PlanSeriesReschedule(ctx, int32(id), scope, *req.VisitTime)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Appointment not found", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			fmt.Println(err)
+			http.Error(w, "Failed to plan series reschedule", http.StatusInternalServerError)
+			return
+		}
+
+		if ok := ac.movesAvailable(ctx, w, moves); !ok {
+			return
+		}
+	}
+
+	var appointments []database.Appointment
+	err = ac.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		appointments = nil
+
+		if len(moves) > 0 {
+			moved, err := repos.Appointments.RescheduleSeries(ctx, moves, user.ID, req.Reason)
+			if err != nil {
+				return err
+			}
+			if err := enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentRescheduled, moved...); err != nil {
+				return err
+			}
+			appointments = mergeAppointments(appointments, moved)
+		}
+
+		if req.PatientNotes != nil {
+			updated, err := repos.Appointments.UpdateSeries(ctx, int32(id), scope, repositories.UpdateAppointmentParams{
+				PatientNotes: req.PatientNotes,
+			})
+			if err != nil {
+				return err
+			}
+			if err := enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentUpdated, updated...); err != nil {
+				return err
+			}
+			appointments = mergeAppointments(appointments, updated)
+		}
+
+		return nil
+	})
+
+	var overlap repositories.AppointmentOverlapError
+	if errors.As(err, &overlap) {
+		fmt.Println(err)
+		writeAppointmentConflict(w, overlap)
+		return
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Appointment not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repositories.ErrAppointmentNotReschedulable) {
+		NewHttpError(http.StatusConflict, err.Error()).Write(w)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to update appointment series", http.StatusInternalServerError)
+		return
+	}
+
+	for _, move := range moves {
+		for _, appointment := range appointments {
+			if appointment.ID == move.Appointment.ID {
+				publishQueueChange(ctx, ac.feed, queue.ActionRescheduled, movedAppointments(move.Appointment, appointment)...)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AppointmentDbArrayToResponse(appointments))
+}
+
+func (ac *AppointmentRouter) CancelSeries(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid appointment id", http.StatusBadRequest)
+		return
+	}
+
+	var req AppointmentSeriesCancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	var appointments []database.Appointment
+	err = ac.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		appointments, err = repos.Appointments.CancelSeries(ctx, int32(id), repositories.SeriesScope(req.Scope), user.ID, &req.Reason)
+		if err != nil {
+			return err
+		}
+
+		return enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentCancelled, appointments...)
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Appointment not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repositories.ErrInvalidStatusTransition) {
+		NewHttpError(http.StatusConflict, err.Error()).Write(w)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to cancel appointment series", http.StatusInternalServerError)
+		return
+	}
+
+	publishQueueChange(ctx, ac.feed, queue.ActionStatusChanged, appointments...)
+
+	for _, appointment := range appointments {
+		ac.offerFreedSlot(ctx, appointment)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AppointmentDbArrayToResponse(appointments))
+}
+
+// movesAvailable checks every move against its doctor's schedule, writing a
+// 422 for the first one the doctor does not work at, and against the slots
+// held for the waitlist, writing a 409.
+func (ac *AppointmentRouter) movesAvailable(ctx context.Context, w http.ResponseWriter, moves []repositories.SeriesMove) bool {
+	schedules := make(map[int32]schedule.Schedule)
+	visits := make(map[int32][]schedule.Interval)
+
+	for _, move := range moves {
+		if !move.Appointment.DoctorID.Valid {
+			continue
+		}
+		doctorId := move.Appointment.DoctorID.Int32
+
+		sched, ok := schedules[doctorId]
+		if !ok {
+			from, to := seriesMovesRange(moves)
+			var err error
+			sched, err = ac.scheduleRepo.GetSchedule(ctx, doctorId, from, to)
+			if err != nil {
+				fmt.Println(err)
+				http.Error(w, "Failed to fetch doctor schedule", http.StatusInternalServerError)
+				return false
+			}
+			schedules[doctorId] = sched
+		}
+
+		duration := time.Duration(move.Appointment.DurationMinutes) * time.Minute
+		visit := schedule.Interval{Start: move.VisitTimestamp, End: move.VisitTimestamp.Add(duration)}
+		if !sched.Covers(visit) {
+			http.Error(w, fmt.Sprintf("Doctor is not available at %s", move.VisitTimestamp.Format(time.RFC3339)), http.StatusUnprocessableEntity)
+			return false
+		}
+		visits[doctorId] = append(visits[doctorId], visit)
+	}
+
+	for doctorId, intervals := range visits {
+		if !ac.slotsFree(ctx, w, doctorId, intervals) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func seriesMovesRange(moves []repositories.SeriesMove) (time.Time, time.Time) {
+	from, to := moves[0].VisitTimestamp, moves[0].VisitTimestamp
+	for _, move := range moves[1:] {
+		if move.VisitTimestamp.Before(from) {
+			from = move.VisitTimestamp
+		}
+		if move.VisitTimestamp.After(to) {
+			to = move.VisitTimestamp
+		}
+	}
+	return from, to.Add(24 * time.Hour)
+}
+
+// mergeAppointments adds the appointments of b to a, replacing the ones a
+// already holds by ID.
+func mergeAppointments(a []database.Appointment, b []database.Appointment) []database.Appointment {
+	index := make(map[int32]int, len(a))
+	for i, appointment := range a {
+		index[appointment.ID] = i
+	}
+
+	for _, appointment := range b {
+		if i, ok := index[appointment.ID]; ok {
+			a[i] = appointment
+			continue
+		}
+		index[appointment.ID] = len(a)
+		a = append(a, appointment)
+	}
+
+	return a
+}
+
+func recurrenceFromRequest(data AppointmentRecurrenceRequest) (recurrence.Rule, error) {
+	rule := recurrence.Rule{
+		Frequency: recurrence.Frequency(data.Frequency),
+		Interval:  data.Interval,
+		Count:     data.Count,
+	}
+
+	if data.Until != nil {
+		until, err := time.Parse("2006-01-02", *data.Until)
+		if err != nil {
+			return rule, fmt.Errorf("invalid until %q", *data.Until)
+		}
+		rule.Until = &until
+	}
+
+	for _, wd := range data.Weekdays {
+		rule.Weekdays = append(rule.Weekdays, time.Weekday(wd))
+	}
+
+	return rule, rule.Validate()
+}
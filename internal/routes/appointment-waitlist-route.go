@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/internal/schedule"
+	"time"
+)
+
+// offerFreedSlot offers the slot of a cancelled or deleted appointment to
+// the waitlist. The appointment change has already gone through, so a
+// failure here is only logged.
+func (ac *AppointmentRouter) offerFreedSlot(ctx context.Context, appointment database.Appointment) {
+	if !appointment.DoctorID.Valid || !appointment.VisitTimestamp.Time.After(time.Now()) {
+		return
+	}
+
+	weekly, err := ac.scheduleRepo.GetWeekly(ctx, appointment.DoctorID.Int32)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	loc, err := time.LoadLocation(weekly.Timezone)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	_, err = ac.waitlistRepo.OfferSlot(ctx, repositories.OfferSlotParams{
+		DoctorID:       appointment.DoctorID.Int32,
+		VisitTimestamp: appointment.VisitTimestamp.Time,
+		Duration:       time.Duration(appointment.DurationMinutes) * time.Minute,
+		Location:       loc,
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// slotsFree checks the intervals against the slots held for waitlisted
+// patients, writing a 409 for the first one that is taken.
+func (ac *AppointmentRouter) slotsFree(ctx context.Context, w http.ResponseWriter, doctorId int32, intervals []schedule.Interval) bool {
+	if len(intervals) == 0 {
+		return true
+	}
+
+	from, to := intervals[0].Start, intervals[0].End
+	for _, interval := range intervals[1:] {
+		if interval.Start.Before(from) {
+			from = interval.Start
+		}
+		if interval.End.After(to) {
+			to = interval.End
+		}
+	}
+
+	holds, err := ac.waitlistRepo.GetActiveHoldsForDoctorBetween(ctx, doctorId, from, to)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch waitlist holds", http.StatusInternalServerError)
+		return false
+	}
+
+	for _, interval := range intervals {
+		for _, hold := range holds {
+			start := hold.VisitTimestamp.Time
+			end := start.Add(time.Duration(hold.DurationMinutes) * time.Minute)
+			if start.Before(interval.End) && end.After(interval.Start) {
+				msg := fmt.Sprintf("Slot at %s is held for a waitlisted patient", interval.Start.Format(time.RFC3339))
+				NewHttpError(http.StatusConflict, msg).Write(w)
+				return false
+			}
+		}
+	}
+
+	return true
+}
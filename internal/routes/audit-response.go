@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+// AuditEntryResponse is one audit log entry. Changes is only set on changes,
+// as {"column": {"from": ..., "to": ...}}, or {"column": {"changed": true}}
+// for passwords and patients' health information.
+type AuditEntryResponse struct {
+	ID        int64           `json:"id"`
+	ActorId   *int64          `json:"actor_id"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityId  *int64          `json:"entity_id"`
+	Changes   json.RawMessage `json:"changes"`
+	IP        *string         `json:"ip"`
+	RequestId *string         `json:"request_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	// Hash is the hex SHA-256 chaining the entry to the one before it,
+	// empty until the entry is chained shortly after it is written.
+	Hash string `json:"hash"`
+}
+
+func AuditEntryDbToResponse(data database.AuditLog) AuditEntryResponse {
+	var changes json.RawMessage
+	if len(data.Changes) > 0 {
+		changes = data.Changes
+	}
+
+	return AuditEntryResponse{
+		ID:        data.ID,
+		ActorId:   int4ToPtr(data.ActorID),
+		Action:    data.Action,
+		Entity:    data.Entity,
+		EntityId:  int4ToPtr(data.EntityID),
+		Changes:   changes,
+		IP:        textToPtr(data.Ip),
+		RequestId: textToPtr(data.RequestID),
+		CreatedAt: data.CreatedAt.Time,
+		Hash:      hex.EncodeToString(data.Hash),
+	}
+}
+
+func AuditEntryDbArrayToResponse(data []database.AuditLog) []AuditEntryResponse {
+	entries := make([]AuditEntryResponse, len(data))
+
+	for i, item := range data {
+		entries[i] = AuditEntryDbToResponse(item)
+	}
+
+	return entries
+}
@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"patient-appointment-demo-go/internal/pagination"
+	"patient-appointment-demo-go/internal/repositories"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AuditRouter struct {
+	mux      *http.ServeMux
+	userRepo repositories.UserRepositoryInterface
+	repo     repositories.AuditRepositoryInterface
+}
+
+func NewAuditRouter(mux *http.ServeMux, auditRepo repositories.AuditRepositoryInterface, userRepo repositories.UserRepositoryInterface) *AuditRouter {
+	return &AuditRouter{
+		mux:      mux,
+		repo:     auditRepo,
+		userRepo: userRepo,
+	}
+}
+
+func (r *AuditRouter) Register() *AuditRouter {
+	authMiddleware := NewAuthMiddleware(r.userRepo)
+
+	NewRoute("GET", "/api/audit").
+		SetHandler(r.GetAll).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	return r
+}
+
+// GetAll lists audit entries a page at a time, newest first, optionally
+// filtered by entity, entity_id, actor and a from/to time range. from and to
+// are RFC 3339 times or dates, a to date includes that whole day.
+func (ar *AuditRouter) GetAll(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page, errs := pageFromQuery(query, "created_at")
+
+	option := repositories.GetAuditEntriesOption{
+		Entity: query.Get("entity"),
+		Page:   page,
+	}
+
+	if option.Entity != "" && !slices.Contains(repositories.AuditEntities, option.Entity) {
+		errs["Entity"] = fmt.Sprintf("entity must be one of %s", strings.Join(repositories.AuditEntities, ", "))
+	}
+
+	ids := []struct {
+		key   string
+		field string
+		dst   **int32
+	}{
+		{"entity_id", "EntityID", &option.EntityID},
+		{"actor", "Actor", &option.ActorID},
+	}
+	for _, i := range ids {
+		if idStr := query.Get(i.key); idStr != "" {
+			id, err := strconv.ParseInt(idStr, 10, 32)
+			if err != nil {
+				errs[i.field] = i.key + " must be an id"
+				continue
+			}
+			id32 := int32(id)
+			*i.dst = &id32
+		}
+	}
+
+	if fromStr := query.Get("from"); fromStr != "" {
+		from, _, err := parseAuditTime(fromStr)
+		if err != nil {
+			errs["From"] = "from must be a time like 2006-01-02T15:04:05Z or a date"
+		} else {
+			option.From = &from
+		}
+	}
+
+	if toStr := query.Get("to"); toStr != "" {
+		to, isDate, err := parseAuditTime(toStr)
+		if err != nil {
+			errs["To"] = "to must be a time like 2006-01-02T15:04:05Z or a date"
+		} else {
+			if isDate {
+				to = to.AddDate(0, 0, 1)
+			}
+			option.To = &to
+		}
+	}
+
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": errs,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	entries, err := ar.repo.GetAll(ctx, option)
+
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"Cursor": err.Error()},
+		})
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch audit entries", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(PageToResponse(entries, AuditEntryDbArrayToResponse))
+}
+
+func parseAuditTime(value string) (t time.Time, isDate bool, err error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+
+	t, err = time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// auditAccess records that the logged in user was shown the entities. It
+// writes the error response itself when that fails, nothing is shown
+// unaudited.
+func auditAccess(ctx context.Context, w http.ResponseWriter, repo repositories.AuditRepositoryInterface, action string, entity string, ids ...int32) bool {
+	if err := repo.RecordAccess(ctx, action, entity, ids...); err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to record access", http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
+// entityIds are the ids of items, to audit a list of them.
+func entityIds[T any](items []T, id func(T) int32) []int32 {
+	ids := make([]int32, len(items))
+	for i, item := range items {
+		ids[i] = id(item)
+	}
+	return ids
+}
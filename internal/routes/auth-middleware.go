@@ -29,20 +29,45 @@ func (m AuthMiddleware) ValidateLogin(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		userID, err := utils.ParseJWT(token)
+		claims, err := utils.ParseJWT(token)
 		if err != nil {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
 
-		user, err := m.userRepo.Get(r.Context(), userID)
+		revoked, err := m.userRepo.IsTokenRevoked(r.Context(), claims.ID)
+		if err == nil && !revoked && claims.SessionID != "" {
+			// logged out, or its refresh token was reused
+			revoked, err = m.userRepo.IsSessionRevoked(r.Context(), claims.SessionID)
+		}
+		if err != nil {
+			http.Error(w, "Could not validate token", http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := m.userRepo.Get(r.Context(), claims.UserID)
 		if err != nil {
 			http.Error(w, "User not found", http.StatusUnauthorized)
 			return
 		}
 
+		// issued before the user logged out of all sessions
+		if claims.TokenVersion != user.TokenVersion {
+			http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+			return
+		}
+
 		// Add user info to request context
 		ctx := context.WithValue(r.Context(), "user", user)
+		ctx = context.WithValue(ctx, "claims", claims)
+
+		source, _ := repositories.AuditSourceFrom(ctx)
+		source.ActorID = user.ID
+		ctx = repositories.WithAuditSource(ctx, source)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -90,3 +115,11 @@ func getUserFromContext(r *http.Request) (database.User, error) {
 	}
 	return user, nil
 }
+
+func getClaimsFromContext(r *http.Request) (*utils.Claims, error) {
+	claims, ok := r.Context().Value("claims").(*utils.Claims)
+	if !ok {
+		return nil, errors.New("no token claims in context")
+	}
+	return claims, nil
+}
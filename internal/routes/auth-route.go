@@ -2,7 +2,9 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"patient-appointment-demo-go/internal/database"
 	"patient-appointment-demo-go/internal/repositories"
 	"patient-appointment-demo-go/internal/utils"
 
@@ -12,20 +14,32 @@ import (
 type AuthRouter struct {
     mux *http.ServeMux
 	repo repositories.UserRepositoryInterface
+	refreshTokens repositories.RefreshTokenRepositoryInterface
 }
 
 type loginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// DeviceName optionally names the device the session is on.
+	DeviceName string `json:"device_name"`
 }
 
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// loginResponse carries a short-lived access token, sent as the bearer
+// token, and the refresh token to get the next one with.
 type loginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
 }
 
-func NewAuthRouter(mux *http.ServeMux, repo repositories.UserRepositoryInterface) *AuthRouter {
+func NewAuthRouter(mux *http.ServeMux, repo repositories.UserRepositoryInterface, refreshTokens repositories.RefreshTokenRepositoryInterface) *AuthRouter {
 	return &AuthRouter{
 		repo: repo,
+		refreshTokens: refreshTokens,
         mux: mux,
 	}
 }
@@ -36,11 +50,20 @@ func (r *AuthRouter) Register() *AuthRouter {
         SetHandler(r.Login).
         Register(r.mux)
 
+	NewRoute("POST", "/api/auth/refresh").
+        SetHandler(r.Refresh).
+        Register(r.mux)
+
 	NewRoute("POST", "/api/auth/logout").
         SetHandler(r.Logout).
         AddMiddlewares(authMiddleware.ValidateLogin).
         Register(r.mux)
 
+	NewRoute("POST", "/api/auth/logout-all").
+        SetHandler(r.LogoutAll).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
 	return r
 }
 
@@ -65,18 +88,119 @@ func (a *AuthRouter) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenString, err := utils.GenerateJWT(user.ID)
+	a.issueTokens(w, r, user, repositories.CreateRefreshTokenParams{
+		DeviceName: req.DeviceName,
+	})
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh
+// token, the one sent can't be used again. Sending one that was used
+// already logs out the session it belongs to, it was likely stolen.
+func (a *AuthRouter) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	used, err := a.refreshTokens.Use(r.Context(), req.RefreshToken)
+	if errors.Is(err, repositories.ErrRefreshTokenReused) {
+		http.Error(w, "Refresh token already used, the session has been logged out", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, repositories.ErrRefreshTokenInvalid) {
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Could not refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := a.repo.Get(r.Context(), used.UserID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+
+	// issued before the user logged out of all sessions
+	if used.TokenVersion != user.TokenVersion {
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	a.issueTokens(w, r, user, repositories.CreateRefreshTokenParams{
+		FamilyID:   used.FamilyID,
+		DeviceName: used.DeviceName.String,
+	})
+}
+
+// issueTokens responds with an access token and a refresh token for user,
+// on the device the request came from.
+func (a *AuthRouter) issueTokens(w http.ResponseWriter, r *http.Request, user database.User, device repositories.CreateRefreshTokenParams) {
+	device.UserID = user.ID
+	device.TokenVersion = user.TokenVersion
+	device.UserAgent = r.UserAgent()
+	device.IP = clientIP(r)
+
+	refresh, refreshToken, err := a.refreshTokens.Create(r.Context(), device)
+	if err != nil {
+		http.Error(w, "Could not generate token", http.StatusInternalServerError)
+		return
+	}
+
+	tokenString, err := utils.GenerateJWT(user.ID, user.TokenVersion, refresh.FamilyID)
 	if err != nil {
 		http.Error(w, "Could not generate token", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(loginResponse{Token: tokenString})
+	json.NewEncoder(w).Encode(loginResponse{
+		Token:        tokenString,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+	})
 }
 
+// Logout revokes the token the request was made with and the refresh tokens
+// issued along with it, the user's other sessions stay logged in.
 func (a *AuthRouter) Logout(w http.ResponseWriter, r *http.Request) {
-	// TODO: implement token blacklisting
+	claims, err := getClaimsFromContext(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := a.repo.RevokeToken(r.Context(), claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		http.Error(w, "Could not revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	if claims.SessionID != "" {
+		if err := a.refreshTokens.RevokeFamily(r.Context(), claims.UserID, claims.SessionID); err != nil {
+			http.Error(w, "Could not revoke token", http.StatusInternalServerError)
+			return
+		}
+	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Logout successful"))
 }
+
+// LogoutAll revokes every token issued to the user, including the one the
+// request was made with.
+func (a *AuthRouter) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := a.repo.RevokeAllTokens(r.Context(), user.ID); err != nil {
+		http.Error(w, "Could not revoke tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Logged out of all sessions"))
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/ical"
+	"time"
+)
+
+// CalendarFeedTokenResponse never carries the token, it is only shown once
+// in CalendarFeedTokenCreateResponse.
+type CalendarFeedTokenResponse struct {
+	ID         int64      `json:"id"`
+	DoctorId   int64      `json:"doctor_id"`
+	CreatedBy  *int64     `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CalendarFeedTokenCreateResponse struct {
+	CalendarFeedTokenResponse
+	Token string `json:"token"`
+	// FeedPath is the feed URL path with the token, to subscribe to.
+	FeedPath string `json:"feed_path"`
+}
+
+func CalendarFeedTokenDbToResponse(data database.CalendarFeedToken) CalendarFeedTokenResponse {
+	var lastUsedAt, revokedAt *time.Time
+	if data.LastUsedAt.Valid {
+		lastUsedAt = &data.LastUsedAt.Time
+	}
+	if data.RevokedAt.Valid {
+		revokedAt = &data.RevokedAt.Time
+	}
+
+	return CalendarFeedTokenResponse{
+		ID:         int64(data.ID),
+		DoctorId:   int64(data.DoctorID),
+		CreatedBy:  int4ToPtr(data.CreatedBy),
+		LastUsedAt: lastUsedAt,
+		RevokedAt:  revokedAt,
+		CreatedAt:  data.CreatedAt.Time,
+	}
+}
+
+func CalendarFeedTokenDbArrayToResponse(data []database.CalendarFeedToken) []CalendarFeedTokenResponse {
+
+	tokens := make([]CalendarFeedTokenResponse, len(data))
+
+	for i, item := range data {
+		tokens[i] = CalendarFeedTokenDbToResponse(item)
+	}
+
+	return tokens
+}
+
+// appointmentUID identifies an appointment's event in every calendar, feed
+// or invite, so an update replaces the event rather than adding one.
+func appointmentUID(id int32) string {
+	return fmt.Sprintf("appointment-%d@patient-appointment-demo", id)
+}
+
+func appointmentEventStatus(status string) ical.Status {
+	if status == "cancelled" {
+		return ical.StatusCancelled
+	}
+	return ical.StatusConfirmed
+}
+
+func DoctorCalendarDbToEvent(data database.GetDoctorCalendarRow, stamp time.Time) ical.Event {
+	var description string
+	if data.CancelReason.Valid {
+		description = "Cancelled: " + data.CancelReason.String
+	}
+
+	return ical.Event{
+		UID:         appointmentUID(data.ID),
+		Sequence:    data.Sequence,
+		Stamp:       stamp,
+		Start:       data.VisitTimestamp.Time,
+		End:         data.VisitEnd.Time,
+		Summary:     fmt.Sprintf("Token %d: %s", data.AppointmentSequence, data.PatientName),
+		Description: description,
+		Status:      appointmentEventStatus(data.Status),
+	}
+}
+
+func DoctorCalendarDbToCalendar(data []database.GetDoctorCalendarRow, stamp time.Time) ical.Calendar {
+	events := make([]ical.Event, len(data))
+
+	for i, item := range data {
+		events[i] = DoctorCalendarDbToEvent(item, stamp)
+	}
+
+	return ical.Calendar{
+		Method: ical.MethodPublish,
+		Name:   "Appointments",
+		Events: events,
+	}
+}
+
+// AppointmentInviteDbToCalendar is the invite to email to the patient, a
+// cancellation once the appointment is cancelled.
+func AppointmentInviteDbToCalendar(data database.GetAppointmentInviteRow, stamp time.Time) ical.Calendar {
+	method := ical.MethodRequest
+	description := fmt.Sprintf("Your token number is %d.", data.AppointmentSequence)
+	if data.Status == "cancelled" {
+		method = ical.MethodCancel
+		description = "This appointment has been cancelled."
+		if data.CancelReason.Valid {
+			description += " " + data.CancelReason.String
+		}
+	}
+
+	return ical.Calendar{
+		Method: method,
+		Events: []ical.Event{{
+			UID:         appointmentUID(data.ID),
+			Sequence:    data.Sequence,
+			Stamp:       stamp,
+			Start:       data.VisitTimestamp.Time,
+			End:         data.VisitEnd.Time,
+			Summary:     "Doctor's appointment",
+			Description: description,
+			Status:      appointmentEventStatus(data.Status),
+			Organizer:   data.DoctorEmail.String,
+			Attendees: []ical.Attendee{{
+				Name:  data.PatientName,
+				Email: data.PatientEmail,
+			}},
+		}},
+	}
+}
@@ -0,0 +1,258 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/ical"
+	"patient-appointment-demo-go/internal/repositories"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// calendarFeedPast and calendarFeedAhead are how far around now a
+	// doctor's feed reaches.
+	calendarFeedPast  = 90 * 24 * time.Hour
+	calendarFeedAhead = 365 * 24 * time.Hour
+)
+
+type CalendarRouter struct {
+	mux      *http.ServeMux
+	userRepo repositories.UserRepositoryInterface
+	repo     repositories.CalendarRepositoryInterface
+	audit    repositories.AuditRepositoryInterface
+}
+
+func NewCalendarRouter(mux *http.ServeMux, calendarRepo repositories.CalendarRepositoryInterface, userRepo repositories.UserRepositoryInterface, auditRepo repositories.AuditRepositoryInterface) *CalendarRouter {
+	return &CalendarRouter{
+		mux:      mux,
+		repo:     calendarRepo,
+		userRepo: userRepo,
+		audit:    auditRepo,
+	}
+}
+
+func (r *CalendarRouter) Register() *CalendarRouter {
+	authMiddleware := NewAuthMiddleware(r.userRepo)
+
+	// calendar apps can't log in, the feed checks its token itself
+	NewRoute("GET", "/api/doctors/{id}/calendar.ics").
+		SetHandler(r.Feed).
+		Register(r.mux)
+
+	NewRoute("GET", "/api/doctors/{id}/calendar-tokens").
+		SetHandler(r.GetTokens).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("POST", "/api/doctors/{id}/calendar-tokens").
+		SetHandler(r.CreateToken).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("DELETE", "/api/doctors/{id}/calendar-tokens/{tokenId}").
+		SetHandler(r.RevokeToken).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("GET", "/api/appointments/{id}/invite.ics").
+		SetHandler(r.Invite).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	return r
+}
+
+// Feed is the doctor's appointments as an iCalendar feed, read with a
+// token query value issued by CreateToken. The read is audited as the
+// doctor's, whose calendar app holds the token.
+func (cr *CalendarRouter) Feed(w http.ResponseWriter, r *http.Request) {
+	doctorId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid doctor id", http.StatusBadRequest)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	feedToken, err := cr.repo.UseFeedToken(ctx, token)
+
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && int64(feedToken.DoctorID) != doctorId) {
+		http.Error(w, "Invalid calendar token", http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to check calendar token", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	appointments, err := cr.repo.GetDoctorCalendar(ctx, feedToken.DoctorID, now.Add(-calendarFeedPast), now.Add(calendarFeedAhead))
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch appointments for the doctor", http.StatusInternalServerError)
+		return
+	}
+
+	source, _ := repositories.AuditSourceFrom(ctx)
+	source.ActorID = feedToken.DoctorID
+	ctx = repositories.WithAuditSource(ctx, source)
+
+	if !auditAccess(ctx, w, cr.audit, repositories.AuditActionList, repositories.AuditEntityAppointment, entityIds(appointments, doctorCalendarId)...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if err := DoctorCalendarDbToCalendar(appointments, now).Encode(w); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (cr *CalendarRouter) GetTokens(w http.ResponseWriter, r *http.Request) {
+	doctor, ok := doctorFromPath(w, r, cr.userRepo)
+	if !ok || !canManageDoctor(w, r, doctor) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	tokens, err := cr.repo.GetFeedTokens(ctx, doctor.ID)
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch calendar tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CalendarFeedTokenDbArrayToResponse(tokens))
+}
+
+// CreateToken issues a feed token. The response carries the token, the
+// only time it is shown.
+func (cr *CalendarRouter) CreateToken(w http.ResponseWriter, r *http.Request) {
+	doctor, ok := doctorFromPath(w, r, cr.userRepo)
+	if !ok || !canManageDoctor(w, r, doctor) {
+		return
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	feedToken, token, err := cr.repo.CreateFeedToken(ctx, doctor.ID, user.ID)
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to create calendar token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CalendarFeedTokenCreateResponse{
+		CalendarFeedTokenResponse: CalendarFeedTokenDbToResponse(feedToken),
+		Token:                     token,
+		FeedPath:                  fmt.Sprintf("/api/doctors/%d/calendar.ics?token=%s", doctor.ID, token),
+	})
+}
+
+// RevokeToken stops a feed token from working, for good.
+func (cr *CalendarRouter) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	doctor, ok := doctorFromPath(w, r, cr.userRepo)
+	if !ok || !canManageDoctor(w, r, doctor) {
+		return
+	}
+
+	tokenId, err := strconv.ParseInt(r.PathValue("tokenId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid calendar token id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	feedToken, err := cr.repo.RevokeFeedToken(ctx, doctor.ID, int32(tokenId))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Calendar token not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to revoke calendar token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CalendarFeedTokenDbToResponse(feedToken))
+}
+
+// Invite is an invite to email to the patient, or a cancellation once the
+// appointment is cancelled.
+func (cr *CalendarRouter) Invite(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid appointment id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	invite, err := cr.repo.GetInvite(ctx, int32(id))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Appointment not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch appointment", http.StatusInternalServerError)
+		return
+	}
+
+	// the invite carries the patient's name and email
+	if !auditAccess(ctx, w, cr.audit, repositories.AuditActionView, repositories.AuditEntityAppointment, invite.ID) ||
+		!auditAccess(ctx, w, cr.audit, repositories.AuditActionView, repositories.AuditEntityPatient, invite.PatientID) {
+		return
+	}
+
+	calendar := AppointmentInviteDbToCalendar(invite, time.Now())
+
+	w.Header().Set("Content-Type", ical.ContentType+"; method="+string(calendar.Method))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="appointment-%d.ics"`, invite.ID))
+	if err := calendar.Encode(w); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func doctorCalendarId(appointment database.GetDoctorCalendarRow) int32 {
+	return appointment.ID
+}
@@ -1,11 +1,19 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"strconv"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type HttpError struct {
@@ -38,3 +46,105 @@ func getValidationErrors(ve validator.ValidationErrors) map[string]string {
 	}
 	return errors
 }
+
+func int4ToPtr(v pgtype.Int4) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	i := int64(v.Int32)
+	return &i
+}
+
+func textToPtr(v pgtype.Text) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
+
+func timestamptzToPtr(v pgtype.Timestamptz) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Time
+}
+
+// doctorFromPath loads the doctor named by the {id} path value, writing the
+// error response itself when it cannot.
+func doctorFromPath(w http.ResponseWriter, r *http.Request, userRepo repositories.UserRepositoryInterface) (database.User, bool) {
+	doctorId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid doctor id", http.StatusBadRequest)
+		return database.User{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	doctor, err := userRepo.Get(ctx, int32(doctorId))
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && doctor.Type != "doctor") {
+		http.Error(w, "Doctor not found", http.StatusNotFound)
+		return database.User{}, false
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch doctor", http.StatusInternalServerError)
+		return database.User{}, false
+	}
+
+	return doctor, true
+}
+
+// canManageDoctor allows admins to manage any doctor's schedule and
+// calendar, and doctors their own.
+func canManageDoctor(w http.ResponseWriter, r *http.Request, doctor database.User) bool {
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	if user.Type != "admin" && user.ID != doctor.ID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// includeDeleted reads the include_deleted query value. Only admins see
+// deleted records, the error response is written when the value is invalid
+// or the user may not use it.
+func includeDeleted(w http.ResponseWriter, r *http.Request) (include bool, ok bool) {
+	value := r.URL.Query().Get("include_deleted")
+	if value == "" {
+		return false, true
+	}
+
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"IncludeDeleted": "include_deleted must be true or false"},
+		})
+		return false, false
+	}
+
+	if !include {
+		return false, true
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false, false
+	}
+
+	if user.Type != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false, false
+	}
+
+	return true, true
+}
@@ -1,14 +1,24 @@
 package routes
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
 	"net/http"
+	"patient-appointment-demo-go/internal/repositories"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 64
 )
 
 func CorsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		// return no content if OPTION Request
 		if r.Method == http.MethodOptions {
@@ -27,3 +37,50 @@ func ApiMiddleware(next http.Handler) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequestMiddleware gives every request an id, the client's X-Request-ID
+// when it sent a usable one, and returns it in the same header. The id and
+// the client's ip become the request's audit source, ValidateLogin adds the
+// user to it.
+func RequestMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(requestIDHeader)
+		if !isValidRequestID(requestId) {
+			requestId = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestId)
+
+		ctx := repositories.WithAuditSource(r.Context(), repositories.AuditSource{
+			IP:        clientIP(r),
+			RequestID: requestId,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+)
+
+// enqueueAppointmentEvents writes an eventType event for each appointment to
+// the outbox. Call it with the transaction's outbox, after the change.
+func enqueueAppointmentEvents(ctx context.Context, outbox repositories.OutboxRepositoryInterface, eventType repositories.EventType, appointments ...database.Appointment) error {
+	for _, appointment := range appointments {
+		if _, err := outbox.Enqueue(ctx, eventType, AppointmentDbToResponse(appointment)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func enqueuePatientEvent(ctx context.Context, outbox repositories.OutboxRepositoryInterface, eventType repositories.EventType, patient database.Patient) error {
+	_, err := outbox.Enqueue(ctx, eventType, PatientDbToResponse(patient))
+	return err
+}
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"fmt"
+	"net/url"
+	"patient-appointment-demo-go/internal/pagination"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PageResponse is the envelope of every paginated list. NextCursor is null
+// on the last page, otherwise pass it as the cursor query value to get the
+// next one.
+type PageResponse[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+func PageToResponse[D any, T any](page pagination.Page[D], convert func([]D) []T) PageResponse[T] {
+	var nextCursor *string
+	if page.NextCursor != "" {
+		nextCursor = &page.NextCursor
+	}
+
+	return PageResponse[T]{
+		Data:       convert(page.Items),
+		NextCursor: nextCursor,
+	}
+}
+
+// pageFromQuery reads the limit, cursor, sort_by and sort_direction query
+// values, sort_by being one of sortFields. errs holds a message for each
+// invalid value.
+func pageFromQuery(query url.Values, sortFields ...string) (page pagination.Params, errs map[string]string) {
+	errs = make(map[string]string)
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.ParseInt(limitStr, 10, 32)
+		if err != nil || limit < 1 || limit > pagination.MaxLimit {
+			errs["Limit"] = fmt.Sprintf("limit must be between 1 and %d", pagination.MaxLimit)
+		}
+		page.Limit = int32(limit)
+	}
+
+	page.Cursor = query.Get("cursor")
+
+	page.SortBy = query.Get("sort_by")
+	if page.SortBy != "" && !slices.Contains(sortFields, page.SortBy) {
+		errs["SortBy"] = fmt.Sprintf("sort_by must be one of %s", strings.Join(sortFields, ", "))
+	}
+
+	switch strings.ToLower(query.Get("sort_direction")) {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		errs["SortDirection"] = "sort_direction must be asc or desc"
+	}
+
+	return page, errs
+}
@@ -22,3 +22,6 @@ type PatientUpdateRequest struct {
 	Address string  `json:"address" validate:"omitempty,max=500"`
 }
 
+type PatientMergeRequest struct {
+	DuplicateID int32 `json:"duplicate_id" validate:"required,gt=0"`
+}
@@ -1,6 +1,13 @@
 package routes
 
-import "patient-appointment-demo-go/internal/database"
+import (
+	"encoding/json"
+	"html"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"strings"
+	"time"
+)
 
 type PatientResponse struct {
 	ID      int64   `json:"id"`
@@ -12,6 +19,8 @@ type PatientResponse struct {
 	Height  float64 `json:"height"`
 	Gender  string  `json:"gender"`
 	Address string  `json:"address"`
+	// DeletedAt is only set on deleted patients, listed with include_deleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 func PatientDbToResponse(data database.Patient) PatientResponse {
@@ -19,15 +28,16 @@ func PatientDbToResponse(data database.Patient) PatientResponse {
 	height, _ := data.Height.Float64Value()
 
 	return PatientResponse{
-		ID:      int64(data.ID),
-		Name:    data.Name,
-		Phone:   data.Phone.String,
-		Email:   data.Email,
-		Age:     data.Age.Int16,
-		Weight:  weight.Float64,
-		Height:  height.Float64,
-		Gender:  data.Gender.String,
-		Address: data.Address.String,
+		ID:        int64(data.ID),
+		Name:      data.Name,
+		Phone:     data.Phone.String,
+		Email:     data.Email,
+		Age:       data.Age.Int16,
+		Weight:    weight.Float64,
+		Height:    height.Float64,
+		Gender:    data.Gender.String,
+		Address:   data.Address.String,
+		DeletedAt: timestamptzToPtr(data.DeletedAt),
 	}
 }
 
@@ -42,3 +52,149 @@ func PatientDbArrayToResponse(data []database.Patient) []PatientResponse {
 	return patients
 
 }
+
+// PatientSearchResponse is a patient found by a search. Highlights holds
+// the fields where the search matched as HTML, with the matches wrapped in
+// <mark> tags. An email or phone matches as a whole.
+type PatientSearchResponse struct {
+	PatientResponse
+	Rank       float32           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+func PatientSearchDbToResponse(data database.SearchPatientsRow) PatientSearchResponse {
+	patient := PatientDbToResponse(database.Patient{
+		ID:        data.ID,
+		Name:      data.Name,
+		Phone:     data.Phone,
+		Email:     data.Email,
+		Age:       data.Age,
+		Weight:    data.Weight,
+		Height:    data.Height,
+		Gender:    data.Gender,
+		Address:   data.Address,
+		CreatedAt: data.CreatedAt,
+		UpdatedAt: data.UpdatedAt,
+	})
+
+	highlights := make(map[string]string)
+	if strings.Contains(data.NameHighlight, "<mark>") {
+		highlights["name"] = escapeHighlight(data.NameHighlight)
+	}
+	// encrypted fields only ever match whole
+	if data.EmailMatch {
+		highlights["email"] = "<mark>" + html.EscapeString(data.Email) + "</mark>"
+	}
+	if data.PhoneMatch {
+		highlights["phone"] = "<mark>" + html.EscapeString(data.Phone.String) + "</mark>"
+	}
+
+	return PatientSearchResponse{
+		PatientResponse: patient,
+		Rank:            data.Rank,
+		Highlights:      highlights,
+	}
+}
+
+// escapeHighlight HTML escapes the text of a highlight but keeps its <mark>
+// tags, so it can be shown as is.
+func escapeHighlight(highlight string) string {
+	parts := strings.Split(highlight, "<mark>")
+	for i, part := range parts {
+		marked := strings.Split(part, "</mark>")
+		for j := range marked {
+			marked[j] = html.EscapeString(marked[j])
+		}
+		parts[i] = strings.Join(marked, "</mark>")
+	}
+
+	return strings.Join(parts, "<mark>")
+}
+
+func PatientSearchDbArrayToResponse(data []database.SearchPatientsRow) []PatientSearchResponse {
+	patients := make([]PatientSearchResponse, len(data))
+
+	for i, item := range data {
+		patients[i] = PatientSearchDbToResponse(item)
+	}
+
+	return patients
+}
+
+type DuplicateCandidateResponse struct {
+	Patient PatientResponse `json:"patient"`
+	Score   float64         `json:"score"`
+	Reasons []string        `json:"reasons"`
+}
+
+// PatientCreateResponse is the created patient along with the existing
+// patients that may be the same person.
+type PatientCreateResponse struct {
+	PatientResponse
+	PossibleDuplicates []DuplicateCandidateResponse `json:"possible_duplicates"`
+}
+
+// PatientMergeResponse records a merge. Duplicate is the merged patient's
+// row as it was before being deleted.
+type PatientMergeResponse struct {
+	ID                   int64           `json:"id"`
+	SurvivorID           int64           `json:"survivor_id"`
+	DuplicateID          int64           `json:"duplicate_id"`
+	Duplicate            json.RawMessage `json:"duplicate"`
+	AppointmentsMoved    int32           `json:"appointments_moved"`
+	SeriesMoved          int32           `json:"series_moved"`
+	WaitlistEntriesMoved int32           `json:"waitlist_entries_moved"`
+	MergedBy             *int64          `json:"merged_by"`
+	CreatedAt            time.Time       `json:"created_at"`
+}
+
+type PatientMergeResultResponse struct {
+	Patient PatientResponse      `json:"patient"`
+	Merge   PatientMergeResponse `json:"merge"`
+}
+
+// PatientMergedEvent is sent to webhooks. It leaves out the duplicate's
+// details, which were sent when it was created.
+type PatientMergedEvent struct {
+	Patient           PatientResponse `json:"patient"`
+	DuplicateID       int64           `json:"duplicate_id"`
+	AppointmentsMoved int32           `json:"appointments_moved"`
+}
+
+func DuplicateCandidateArrayToResponse(data []repositories.DuplicateCandidate) []DuplicateCandidateResponse {
+	candidates := make([]DuplicateCandidateResponse, len(data))
+
+	for i, item := range data {
+		candidates[i] = DuplicateCandidateResponse{
+			Patient: PatientDbToResponse(item.Patient),
+			Score:   item.Score,
+			Reasons: item.Reasons,
+		}
+	}
+
+	return candidates
+}
+
+func PatientMergeDbToResponse(data database.PatientMerge) PatientMergeResponse {
+	return PatientMergeResponse{
+		ID:                   int64(data.ID),
+		SurvivorID:           int64(data.SurvivorID),
+		DuplicateID:          int64(data.DuplicateID),
+		Duplicate:            json.RawMessage(data.Duplicate),
+		AppointmentsMoved:    data.AppointmentsMoved,
+		SeriesMoved:          data.SeriesMoved,
+		WaitlistEntriesMoved: data.WaitlistEntriesMoved,
+		MergedBy:             int4ToPtr(data.MergedBy),
+		CreatedAt:            data.CreatedAt.Time,
+	}
+}
+
+func PatientMergeDbArrayToResponse(data []database.PatientMerge) []PatientMergeResponse {
+	merges := make([]PatientMergeResponse, len(data))
+
+	for i, item := range data {
+		merges[i] = PatientMergeDbToResponse(item)
+	}
+
+	return merges
+}
@@ -3,26 +3,40 @@ package routes
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
 	"patient-appointment-demo-go/internal/repositories"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	minSearchLength = 2
+	maxSearchLength = 100
 )
 
 type PatientRouter struct {
 	mux      *http.ServeMux
 	userRepo repositories.UserRepositoryInterface
 	repo     repositories.PatientRepositoryInterface
+	audit    repositories.AuditRepositoryInterface
+	tx       repositories.TxManagerInterface
 }
 
-func NewPatientRouter(mux *http.ServeMux, patientRepo repositories.PatientRepositoryInterface, userRepo repositories.UserRepositoryInterface) *PatientRouter {
+func NewPatientRouter(mux *http.ServeMux, patientRepo repositories.PatientRepositoryInterface, userRepo repositories.UserRepositoryInterface, auditRepo repositories.AuditRepositoryInterface, tx repositories.TxManagerInterface) *PatientRouter {
     return &PatientRouter{
         mux: mux,
         repo: patientRepo,
         userRepo: userRepo,
+        audit: auditRepo,
+        tx: tx,
     }
 }
 
@@ -34,12 +48,35 @@ func (r *PatientRouter) Register() *PatientRouter {
         AddMiddlewares(authMiddleware.ValidateLogin).
         Register(r.mux)
 
+	NewRoute("GET", "/api/patients/search").
+        SetHandler(r.Search).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
 	NewRoute("GET", "/api/patients/{id}").
         SetHandler(r.Get).
         AddMiddlewares(authMiddleware.ValidateLogin).
         Register(r.mux)
 
 
+	NewRoute("GET", "/api/patients/{id}/duplicates").
+        SetHandler(r.GetDuplicates).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
+	NewRoute("GET", "/api/patients/{id}/merges").
+        SetHandler(r.GetMerges).
+        AddMiddlewares(authMiddleware.ValidateLogin).
+        Register(r.mux)
+
+	NewRoute("POST", "/api/patients/{id}/merge").
+        SetHandler(r.Merge).
+        AddMiddlewares(
+            authMiddleware.ValidateLogin,
+            authMiddleware.ValidateRole("admin"),
+        ).
+        Register(r.mux)
+
 	NewRoute("POST", "/api/patients").
         SetHandler(r.Create).
         AddMiddlewares(authMiddleware.ValidateLogin).
@@ -55,32 +92,113 @@ func (r *PatientRouter) Register() *PatientRouter {
         AddMiddlewares(authMiddleware.ValidateLogin).
         Register(r.mux)
 
+	NewRoute("POST", "/api/patients/{id}/restore").
+        SetHandler(r.Restore).
+        AddMiddlewares(
+            authMiddleware.ValidateLogin,
+            authMiddleware.ValidateRole("admin"),
+        ).
+        Register(r.mux)
+
 	return r
 }
 
 
+// GetAll lists patients a page at a time, optionally filtered by name.
+// Admins can list deleted patients too with include_deleted=true.
 func (p *PatientRouter) GetAll(w http.ResponseWriter, r *http.Request) {
 
 	name := r.URL.Query().Get("name")
-	sortBy := r.URL.Query().Get("sort_by")
-	sortDirection := r.URL.Query().Get("sort_direction")
+
+	page, errs := pageFromQuery(r.URL.Query(), repositories.PatientSortName, repositories.PatientSortAge, repositories.PatientSortCreatedAt)
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": errs,
+		})
+		return
+	}
+
+	withDeleted, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
 	patients, err := p.repo.GetAll(ctx, repositories.GetPatientsOption{
-		Name:          name,
-		SortBy:        sortBy,
-		SortDirection: sortDirection,
+		Name:           name,
+		IncludeDeleted: withDeleted,
+		Page:           page,
 	})
 
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"Cursor": err.Error()},
+		})
+		return
+	}
+
 	if err != nil {
 		fmt.Println(err)
 		http.Error(w, "Failed to fetch patients", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(PatientDbArrayToResponse(patients))
+	if !auditAccess(ctx, w, p.audit, repositories.AuditActionList, repositories.AuditEntityPatient, entityIds(patients.Items, patientId)...) {
+		return
+	}
+
+	json.NewEncoder(w).Encode(PageToResponse(patients, PatientDbArrayToResponse))
+}
+
+// Search finds patients by name, phone, email or address, tolerating typos
+// and fragments, best match first.
+func (p *PatientRouter) Search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	errs := make(map[string]string)
+	if len([]rune(query)) < minSearchLength || len([]rune(query)) > maxSearchLength {
+		errs["Query"] = fmt.Sprintf("q must be between %d and %d characters", minSearchLength, maxSearchLength)
+	}
+
+	var limit int64
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.ParseInt(limitStr, 10, 32)
+		if err != nil || limit < 1 || limit > pagination.MaxLimit {
+			errs["Limit"] = fmt.Sprintf("limit must be between 1 and %d", pagination.MaxLimit)
+		}
+	}
+
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": errs,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	patients, err := p.repo.Search(ctx, query, int32(limit))
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to search patients", http.StatusInternalServerError)
+		return
+	}
+
+	ids := entityIds(patients, func(row database.SearchPatientsRow) int32 { return row.ID })
+	if !auditAccess(ctx, w, p.audit, repositories.AuditActionList, repositories.AuditEntityPatient, ids...) {
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string][]PatientSearchResponse{
+		"data": PatientSearchDbArrayToResponse(patients),
+	})
 }
 
 func (p *PatientRouter) Get(w http.ResponseWriter, r *http.Request) {
@@ -93,15 +211,29 @@ func (p *PatientRouter) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	withDeleted, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
-	patient, err := p.repo.Get(ctx, int32(id))
+	get := p.repo.Get
+	if withDeleted {
+		get = p.repo.GetWithDeleted
+	}
+
+	patient, err := get(ctx, int32(id))
 	if err != nil {
 		http.Error(w, "Patient not found", http.StatusNotFound)
 		return
 	}
 
+	if !auditAccess(ctx, w, p.audit, repositories.AuditActionView, repositories.AuditEntityPatient, patient.ID) {
+		return
+	}
+
 	json.NewEncoder(w).Encode(PatientDbToResponse(patient))
 }
 
@@ -128,24 +260,229 @@ func (p *PatientRouter) Create(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
-	patient, err := p.repo.Create(ctx, repositories.CreatePatientParams{
-		Name:    req.Name,
-		Phone:   req.Phone,
-		Email:   req.Email,
-		Age:     int32(req.Age),
-		Weight:  float32(req.Weight),
-		Height:  float32(req.Height),
-		Gender:  req.Gender,
-		Address: req.Address,
+	var patient database.Patient
+	var duplicates []repositories.DuplicateCandidate
+	err := p.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		duplicates, err = repos.Patients.FindDuplicates(ctx, repositories.FindDuplicatesParams{
+			Name:  req.Name,
+			Email: req.Email,
+			Phone: req.Phone,
+			Age:   int32(req.Age),
+		})
+		if err != nil {
+			return err
+		}
+
+		patient, err = repos.Patients.Create(ctx, repositories.CreatePatientParams{
+			Name:    req.Name,
+			Phone:   req.Phone,
+			Email:   req.Email,
+			Age:     int32(req.Age),
+			Weight:  float32(req.Weight),
+			Height:  float32(req.Height),
+			Gender:  req.Gender,
+			Address: req.Address,
+		})
+		if err != nil {
+			return err
+		}
+
+		return enqueuePatientEvent(ctx, repos.Outbox, repositories.EventPatientCreated, patient)
 	})
 
+	if err == nil || repositories.IsUniqueViolation(err, "patients_email_key") {
+		if !auditAccess(ctx, w, p.audit, repositories.AuditActionList, repositories.AuditEntityPatient, duplicateIds(duplicates)...) {
+			return
+		}
+	}
+
+	if repositories.IsUniqueViolation(err, "patients_email_key") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]any{
+			"errors":              map[string]string{"Email": "a patient with this email already exists"},
+			"possible_duplicates": DuplicateCandidateArrayToResponse(duplicates),
+		})
+		return
+	}
+
 	if err != nil {
 		fmt.Println(err)
 		http.Error(w, "Failed to create patient", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(PatientDbToResponse(patient))
+	json.NewEncoder(w).Encode(PatientCreateResponse{
+		PatientResponse:    PatientDbToResponse(patient),
+		PossibleDuplicates: DuplicateCandidateArrayToResponse(duplicates),
+	})
+}
+
+// GetDuplicates lists the patients that may be the same person as the
+// patient, to merge into it.
+func (p *PatientRouter) GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	patient, err := p.repo.Get(ctx, int32(id))
+	if err != nil {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+
+	duplicates, err := p.repo.FindDuplicates(ctx, repositories.FindDuplicatesParams{
+		Name:      patient.Name,
+		Email:     patient.Email,
+		Phone:     patient.Phone.String,
+		Age:       int32(patient.Age.Int16),
+		ExcludeID: patient.ID,
+	})
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to find duplicates", http.StatusInternalServerError)
+		return
+	}
+
+	if !auditAccess(ctx, w, p.audit, repositories.AuditActionView, repositories.AuditEntityPatient, patient.ID) ||
+		!auditAccess(ctx, w, p.audit, repositories.AuditActionList, repositories.AuditEntityPatient, duplicateIds(duplicates)...) {
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string][]DuplicateCandidateResponse{
+		"data": DuplicateCandidateArrayToResponse(duplicates),
+	})
+}
+
+// Merge merges the duplicate_id patient into the patient, moving over all
+// its appointments, and deletes the duplicate.
+func (p *PatientRouter) Merge(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+
+	var req PatientMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	var result repositories.PatientMergeResult
+	err = p.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		result, err = repos.Patients.Merge(ctx, repositories.MergePatientsParams{
+			SurvivorID:  int32(id),
+			DuplicateID: req.DuplicateID,
+			MergedBy:    user.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentUpdated, result.Appointments...); err != nil {
+			return err
+		}
+
+		_, err = repos.Outbox.Enqueue(ctx, repositories.EventPatientMerged, PatientMergedEvent{
+			Patient:           PatientDbToResponse(result.Survivor),
+			DuplicateID:       int64(req.DuplicateID),
+			AppointmentsMoved: result.Merge.AppointmentsMoved,
+		})
+		return err
+	})
+
+	if errors.Is(err, repositories.ErrMergeIntoItself) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"DuplicateID": err.Error()},
+		})
+		return
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+
+	var overlap repositories.AppointmentOverlapError
+	if errors.As(err, &overlap) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(AppointmentConflictResponse{
+			HttpError: NewHttpError(http.StatusConflict, "Both patients have appointments at the same time, cancel one of each before merging"),
+			Conflicts: AppointmentDbArrayToResponse(overlap.Conflicts),
+		})
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to merge patients", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(PatientMergeResultResponse{
+		Patient: PatientDbToResponse(result.Survivor),
+		Merge:   PatientMergeDbToResponse(result.Merge),
+	})
+}
+
+// GetMerges lists the patients merged into the patient.
+func (p *PatientRouter) GetMerges(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	merges, err := p.repo.GetMerges(ctx, int32(id))
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch merges", http.StatusInternalServerError)
+		return
+	}
+
+	if !auditAccess(ctx, w, p.audit, repositories.AuditActionView, repositories.AuditEntityPatient, int32(id)) {
+		return
+	}
+
+	json.NewEncoder(w).Encode(PatientMergeDbArrayToResponse(merges))
 }
 
 func (p *PatientRouter) Update(w http.ResponseWriter, r *http.Request) {
@@ -180,16 +517,33 @@ func (p *PatientRouter) Update(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
-	updatedPatient, err := p.repo.Update(ctx, int32(id), repositories.UpdatePatientParams{
-		Name:    req.Name,
-		Phone:   req.Phone,
-		Email:   req.Email,
-		Age:     int32(req.Age),
-		Weight:  float32(req.Weight),
-		Height:  float32(req.Height),
-		Gender:  req.Gender,
-		Address: req.Address,
+	var updatedPatient database.Patient
+	err = p.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		updatedPatient, err = repos.Patients.Update(ctx, int32(id), repositories.UpdatePatientParams{
+			Name:    req.Name,
+			Phone:   req.Phone,
+			Email:   req.Email,
+			Age:     int32(req.Age),
+			Weight:  float32(req.Weight),
+			Height:  float32(req.Height),
+			Gender:  req.Gender,
+			Address: req.Address,
+		})
+		if err != nil {
+			return err
+		}
+
+		return enqueuePatientEvent(ctx, repos.Outbox, repositories.EventPatientUpdated, updatedPatient)
 	})
+	if repositories.IsUniqueViolation(err, "patients_email_key") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"Email": "a patient with this email already exists"},
+		})
+		return
+	}
 	if err != nil {
 		fmt.Println(err)
 		http.Error(w, "Failed to update patient", http.StatusInternalServerError)
@@ -211,7 +565,21 @@ func (p *PatientRouter) Delete(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 	defer cancel()
 
-	err = p.repo.Delete(ctx, int32(id))
+	err = p.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		patient, err := repos.Patients.Get(ctx, int32(id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Patients.Delete(ctx, int32(id)); err != nil {
+			return err
+		}
+
+		return enqueuePatientEvent(ctx, repos.Outbox, repositories.EventPatientDeleted, patient)
+	})
 	if err != nil {
 		fmt.Println(err)
 		http.Error(w, "Failed to delete patient", http.StatusInternalServerError)
@@ -220,3 +588,61 @@ func (p *PatientRouter) Delete(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore brings back a deleted patient with the appointments deleted along
+// with it. It fails when another patient took the email in the meantime.
+func (p *PatientRouter) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	var patient database.Patient
+	err = p.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		patient, err = repos.Patients.Restore(ctx, int32(id))
+		if err != nil {
+			return err
+		}
+
+		return enqueuePatientEvent(ctx, repos.Outbox, repositories.EventPatientRestored, patient)
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+
+	if repositories.IsUniqueViolation(err, "patients_email_key") {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"Email": "Another patient has this email now"},
+		})
+		return
+	}
+
+	if repositories.IsExclusionViolation(err, "") {
+		http.Error(w, "An appointment of the patient overlaps another one now", http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to restore patient", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(PatientDbToResponse(patient))
+}
+
+func patientId(patient database.Patient) int32 {
+	return patient.ID
+}
+
+func duplicateIds(duplicates []repositories.DuplicateCandidate) []int32 {
+	return entityIds(duplicates, func(d repositories.DuplicateCandidate) int32 { return d.Patient.ID })
+}
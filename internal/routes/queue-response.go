@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"patient-appointment-demo-go/internal/queue"
+	"time"
+)
+
+type QueueTokenResponse struct {
+	AppointmentId int64     `json:"appointment_id"`
+	Token         int16     `json:"token"`
+	Status        string    `json:"status"`
+	VisitTime     time.Time `json:"visit_time"`
+}
+
+// QueueResponse is sent on the stream when it opens and again after every
+// change, Change being what triggered it.
+type QueueResponse struct {
+	Date    string               `json:"date"`
+	Current *QueueTokenResponse  `json:"current"`
+	Next    []QueueTokenResponse `json:"next"`
+	Change  *queue.Change        `json:"change"`
+}
+
+func QueueSnapshotToResponse(data queue.Snapshot, change *queue.Change) QueueResponse {
+	var current *QueueTokenResponse
+	if data.Current != nil {
+		token := queueTokenToResponse(*data.Current)
+		current = &token
+	}
+
+	next := make([]QueueTokenResponse, len(data.Next))
+	for i, item := range data.Next {
+		next[i] = queueTokenToResponse(item)
+	}
+
+	return QueueResponse{
+		Date:    data.Date.Format("2006-01-02"),
+		Current: current,
+		Next:    next,
+		Change:  change,
+	}
+}
+
+func queueTokenToResponse(data queue.Token) QueueTokenResponse {
+	return QueueTokenResponse{
+		AppointmentId: int64(data.AppointmentID),
+		Token:         data.Number,
+		Status:        data.Status,
+		VisitTime:     data.VisitTime,
+	}
+}
@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/queue"
+	"patient-appointment-demo-go/internal/repositories"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultQueueSize = 5
+	maxQueueSize     = 20
+	// queueHeartbeat keeps proxies from closing an idle stream.
+	queueHeartbeat = 15 * time.Second
+)
+
+type QueueRouter struct {
+	mux             *http.ServeMux
+	userRepo        repositories.UserRepositoryInterface
+	appointmentRepo repositories.AppointmentRepositoryInterface
+	feed            *queue.Feed
+}
+
+func NewQueueRouter(mux *http.ServeMux, appointmentRepo repositories.AppointmentRepositoryInterface, userRepo repositories.UserRepositoryInterface, feed *queue.Feed) *QueueRouter {
+	return &QueueRouter{
+		mux:             mux,
+		appointmentRepo: appointmentRepo,
+		userRepo:        userRepo,
+		feed:            feed,
+	}
+}
+
+func (r *QueueRouter) Register() *QueueRouter {
+	authMiddleware := NewAuthMiddleware(r.userRepo)
+
+	NewRoute("GET", "/api/queue/{date}/stream").
+		SetHandler(r.Stream).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	return r
+}
+
+// Stream sends the day's queue as Server-Sent Events: once when the client
+// connects and again whenever one of the day's appointments changes. The
+// optional next query value sets how many upcoming tokens are listed.
+func (q *QueueRouter) Stream(w http.ResponseWriter, r *http.Request) {
+	date, err := time.Parse("2006-01-02", r.PathValue("date"))
+	if err != nil {
+		http.Error(w, "Invalid date", http.StatusBadRequest)
+		return
+	}
+
+	size := defaultQueueSize
+	if sizeStr := r.URL.Query().Get("next"); sizeStr != "" {
+		size, err = strconv.Atoi(sizeStr)
+		if err != nil || size < 0 || size > maxQueueSize {
+			http.Error(w, fmt.Sprintf("next must be between 0 and %d", maxQueueSize), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	// subscribe before the first snapshot so no change falls in between
+	changes, err := q.feed.Subscribe(ctx, date)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to subscribe to the queue", http.StatusInternalServerError)
+		return
+	}
+
+	snapshot, err := q.snapshot(ctx, date, size)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch appointments", http.StatusInternalServerError)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeQueueEvent(w, rc, QueueSnapshotToResponse(snapshot, nil)); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(queueHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+
+			snapshot, err := q.snapshot(ctx, date, size)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+
+			if err := writeQueueEvent(w, rc, QueueSnapshotToResponse(snapshot, &change)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (q *QueueRouter) snapshot(ctx context.Context, date time.Time, size int) (queue.Snapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	appointments, err := q.appointmentRepo.GetByDate(ctx, date)
+	if err != nil {
+		return queue.Snapshot{}, err
+	}
+
+	return queue.Build(date, appointments, size), nil
+}
+
+// publishQueueChange tells the queue streams of the appointments' days
+// about a change that has already been committed, so failures are only
+// logged.
+func publishQueueChange(ctx context.Context, feed *queue.Feed, action queue.Action, appointments ...database.Appointment) {
+	for _, appointment := range appointments {
+		if err := feed.Publish(ctx, action, appointment); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func writeQueueEvent(w http.ResponseWriter, rc *http.ResponseController, data QueueResponse) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: queue\ndata: %s\n\n", payload); err != nil {
+		return err
+	}
+
+	return rc.Flush()
+}
+
+// movedAppointments is what to publish when an appointment moves: the old
+// day loses it and the new one gains it, on the same day once is enough.
+func movedAppointments(before database.Appointment, after database.Appointment) []database.Appointment {
+	if before.VisitDate.Time.Equal(after.VisitDate.Time) {
+		return []database.Appointment{after}
+	}
+	return []database.Appointment{before, after}
+}
@@ -0,0 +1,22 @@
+package routes
+
+type ScheduleWindowRequest struct {
+	Weekday int    `json:"weekday" validate:"min=0,max=6"`
+	Start   string `json:"start" validate:"required,datetime=15:04"`
+	End     string `json:"end" validate:"required,datetime=15:04"`
+}
+
+type ScheduleUpdateRequest struct {
+	SlotMinutes  int16                   `json:"slot_minutes" validate:"required,min=5,max=240"`
+	Timezone     string                  `json:"timezone" validate:"required,timezone"`
+	WorkingHours []ScheduleWindowRequest `json:"working_hours" validate:"dive"`
+	Breaks       []ScheduleWindowRequest `json:"breaks" validate:"dive"`
+}
+
+type ScheduleExceptionCreateRequest struct {
+	Date   string  `json:"date" validate:"required,datetime=2006-01-02"`
+	Kind   string  `json:"kind" validate:"required,oneof=leave holiday extra_hours"`
+	Start  *string `json:"start" validate:"omitempty,datetime=15:04"`
+	End    *string `json:"end" validate:"omitempty,datetime=15:04"`
+	Reason *string `json:"reason" validate:"omitempty,max=500"`
+}
@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/internal/schedule"
+	"time"
+)
+
+type ScheduleWindowResponse struct {
+	Weekday int    `json:"weekday"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+type ScheduleResponse struct {
+	DoctorId     int64                    `json:"doctor_id"`
+	SlotMinutes  int16                    `json:"slot_minutes"`
+	Timezone     string                   `json:"timezone"`
+	WorkingHours []ScheduleWindowResponse `json:"working_hours"`
+	Breaks       []ScheduleWindowResponse `json:"breaks"`
+}
+
+type ScheduleExceptionResponse struct {
+	ID     int64   `json:"id"`
+	Date   string  `json:"date"`
+	Kind   string  `json:"kind"`
+	Start  *string `json:"start"`
+	End    *string `json:"end"`
+	Reason *string `json:"reason"`
+}
+
+type SlotResponse struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type SlotsResponse struct {
+	DoctorId    int64          `json:"doctor_id"`
+	SlotMinutes int16          `json:"slot_minutes"`
+	Timezone    string         `json:"timezone"`
+	Slots       []SlotResponse `json:"slots"`
+}
+
+func ScheduleToResponse(doctorId int32, data repositories.WeeklySchedule) ScheduleResponse {
+	return ScheduleResponse{
+		DoctorId:     int64(doctorId),
+		SlotMinutes:  data.SlotMinutes,
+		Timezone:     data.Timezone,
+		WorkingHours: scheduleWindowsToResponse(data.WorkingHours),
+		Breaks:       scheduleWindowsToResponse(data.Breaks),
+	}
+}
+
+func scheduleWindowsToResponse(data []repositories.ScheduleWindow) []ScheduleWindowResponse {
+
+	windows := make([]ScheduleWindowResponse, len(data))
+
+	for i, item := range data {
+		windows[i] = ScheduleWindowResponse{
+			Weekday: int(item.Weekday),
+			Start:   item.Start.String(),
+			End:     item.End.String(),
+		}
+	}
+
+	return windows
+}
+
+func ScheduleExceptionDbToResponse(data database.DoctorScheduleException) ScheduleExceptionResponse {
+	var start, end *string
+	if data.StartTime.Valid && data.EndTime.Valid {
+		s := schedule.TimeOfDay(time.Duration(data.StartTime.Microseconds) * time.Microsecond).String()
+		e := schedule.TimeOfDay(time.Duration(data.EndTime.Microseconds) * time.Microsecond).String()
+		start, end = &s, &e
+	}
+
+	return ScheduleExceptionResponse{
+		ID:     int64(data.ID),
+		Date:   data.ExceptionDate.Time.Format("2006-01-02"),
+		Kind:   data.Kind,
+		Start:  start,
+		End:    end,
+		Reason: textToPtr(data.Reason),
+	}
+}
+
+func ScheduleExceptionDbArrayToResponse(data []database.DoctorScheduleException) []ScheduleExceptionResponse {
+
+	exceptions := make([]ScheduleExceptionResponse, len(data))
+
+	for i, item := range data {
+		exceptions[i] = ScheduleExceptionDbToResponse(item)
+	}
+
+	return exceptions
+}
+
+func SlotsToResponse(data []schedule.Interval) []SlotResponse {
+
+	slots := make([]SlotResponse, len(data))
+
+	for i, item := range data {
+		slots[i] = SlotResponse{Start: item.Start, End: item.End}
+	}
+
+	return slots
+}
@@ -0,0 +1,442 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/internal/schedule"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// maxSlotsRange caps how far apart from and to may be on the slots endpoint.
+const maxSlotsRange = 31 * 24 * time.Hour
+
+type ScheduleRouter struct {
+	mux             *http.ServeMux
+	userRepo        repositories.UserRepositoryInterface
+	repo            repositories.ScheduleRepositoryInterface
+	appointmentRepo repositories.AppointmentRepositoryInterface
+	waitlistRepo    repositories.WaitlistRepositoryInterface
+	tx              repositories.TxManagerInterface
+}
+
+func NewScheduleRouter(mux *http.ServeMux, scheduleRepo repositories.ScheduleRepositoryInterface, appointmentRepo repositories.AppointmentRepositoryInterface, waitlistRepo repositories.WaitlistRepositoryInterface, userRepo repositories.UserRepositoryInterface, tx repositories.TxManagerInterface) *ScheduleRouter {
+	return &ScheduleRouter{
+		mux:             mux,
+		repo:            scheduleRepo,
+		appointmentRepo: appointmentRepo,
+		waitlistRepo:    waitlistRepo,
+		userRepo:        userRepo,
+		tx:              tx,
+	}
+}
+
+func (r *ScheduleRouter) Register() *ScheduleRouter {
+	authMiddleware := NewAuthMiddleware(r.userRepo)
+
+	NewRoute("GET", "/api/doctors/{id}/schedule").
+		SetHandler(r.Get).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("PUT", "/api/doctors/{id}/schedule").
+		SetHandler(r.Update).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin", "doctor"),
+		).
+		Register(r.mux)
+
+	NewRoute("GET", "/api/doctors/{id}/schedule/exceptions").
+		SetHandler(r.GetExceptions).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("POST", "/api/doctors/{id}/schedule/exceptions").
+		SetHandler(r.CreateException).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin", "doctor"),
+		).
+		Register(r.mux)
+
+	NewRoute("DELETE", "/api/doctors/{id}/schedule/exceptions/{exceptionId}").
+		SetHandler(r.DeleteException).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin", "doctor"),
+		).
+		Register(r.mux)
+
+	NewRoute("GET", "/api/doctors/{id}/slots").
+		SetHandler(r.GetSlots).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	return r
+}
+
+func (s *ScheduleRouter) Get(w http.ResponseWriter, r *http.Request) {
+	doctor, ok := doctorFromPath(w, r, s.userRepo)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	weekly, err := s.repo.GetWeekly(ctx, doctor.ID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch schedule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ScheduleToResponse(doctor.ID, weekly))
+}
+
+func (s *ScheduleRouter) Update(w http.ResponseWriter, r *http.Request) {
+	doctor, ok := doctorFromPath(w, r, s.userRepo)
+	if !ok || !canManageDoctor(w, r, doctor) {
+		return
+	}
+
+	var req ScheduleUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	workingHours, err := scheduleWindowsFromRequest(req.WorkingHours)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid working hours: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	breaks, err := scheduleWindowsFromRequest(req.Breaks)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid breaks: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	var weekly repositories.WeeklySchedule
+	err = s.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		weekly, err = repos.Schedules.SetWeekly(ctx, doctor.ID, repositories.SetWeeklyScheduleParams{
+			SlotMinutes:  req.SlotMinutes,
+			Timezone:     req.Timezone,
+			WorkingHours: workingHours,
+			Breaks:       breaks,
+		})
+		return err
+	})
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to update schedule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ScheduleToResponse(doctor.ID, weekly))
+}
+
+func (s *ScheduleRouter) GetExceptions(w http.ResponseWriter, r *http.Request) {
+	doctor, ok := doctorFromPath(w, r, s.userRepo)
+	if !ok {
+		return
+	}
+
+	from, to, err := parseDateRange(r, time.UTC)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	exceptions, err := s.repo.GetExceptions(ctx, doctor.ID, from, to.Add(-time.Nanosecond))
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch schedule exceptions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ScheduleExceptionDbArrayToResponse(exceptions))
+}
+
+func (s *ScheduleRouter) CreateException(w http.ResponseWriter, r *http.Request) {
+	doctor, ok := doctorFromPath(w, r, s.userRepo)
+	if !ok || !canManageDoctor(w, r, doctor) {
+		return
+	}
+
+	var req ScheduleExceptionCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	date, _ := time.Parse("2006-01-02", req.Date)
+	params := repositories.CreateScheduleExceptionParams{
+		Date:   date,
+		Kind:   schedule.ExceptionKind(req.Kind),
+		Reason: req.Reason,
+	}
+
+	if (req.Start == nil) != (req.End == nil) {
+		http.Error(w, "start and end must be given together", http.StatusBadRequest)
+		return
+	}
+
+	if req.Start != nil {
+		window, err := scheduleWindowFromRequest(ScheduleWindowRequest{Start: *req.Start, End: *req.End})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		params.Start = &window.Start
+		params.End = &window.End
+	} else if params.Kind == schedule.ExceptionExtraHours {
+		http.Error(w, "extra_hours needs a start and an end", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	exception, err := s.repo.CreateException(ctx, doctor.ID, params)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to create schedule exception", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ScheduleExceptionDbToResponse(exception))
+}
+
+func (s *ScheduleRouter) DeleteException(w http.ResponseWriter, r *http.Request) {
+	doctor, ok := doctorFromPath(w, r, s.userRepo)
+	if !ok || !canManageDoctor(w, r, doctor) {
+		return
+	}
+
+	exceptionId, err := strconv.ParseInt(r.PathValue("exceptionId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid exception id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	if err := s.repo.DeleteException(ctx, doctor.ID, int32(exceptionId)); err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to delete schedule exception", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSlots lists the free slots between from and to. Both accept a date,
+// read in the doctor's timezone with to inclusive, or an RFC 3339 time.
+func (s *ScheduleRouter) GetSlots(w http.ResponseWriter, r *http.Request) {
+	doctor, ok := doctorFromPath(w, r, s.userRepo)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	weekly, err := s.repo.GetWeekly(ctx, doctor.ID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch schedule", http.StatusInternalServerError)
+		return
+	}
+
+	loc, err := time.LoadLocation(weekly.Timezone)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Doctor has an invalid timezone", http.StatusInternalServerError)
+		return
+	}
+
+	from, to, err := parseDateRange(r, loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if to.Sub(from) > maxSlotsRange {
+		http.Error(w, "from and to may be at most 31 days apart", http.StatusBadRequest)
+		return
+	}
+
+	// past slots cannot be booked
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+
+	sched, err := s.repo.GetSchedule(ctx, doctor.ID, from, to)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch schedule", http.StatusInternalServerError)
+		return
+	}
+
+	appointments, err := s.appointmentRepo.GetActiveByDoctorBetween(ctx, doctor.ID, from, to)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch appointments", http.StatusInternalServerError)
+		return
+	}
+
+	holds, err := s.waitlistRepo.GetActiveHoldsForDoctorBetween(ctx, doctor.ID, from, to)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch waitlist holds", http.StatusInternalServerError)
+		return
+	}
+
+	busy := make([]schedule.Interval, 0, len(appointments)+len(holds))
+	for _, a := range appointments {
+		busy = append(busy, schedule.Interval{
+			Start: a.VisitTimestamp.Time,
+			End:   a.VisitEnd.Time,
+		})
+	}
+
+	// slots held for the waitlist are not free until released or expired
+	for _, h := range holds {
+		busy = append(busy, schedule.Interval{
+			Start: h.VisitTimestamp.Time,
+			End:   h.VisitTimestamp.Time.Add(time.Duration(h.DurationMinutes) * time.Minute),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SlotsResponse{
+		DoctorId:    int64(doctor.ID),
+		SlotMinutes: weekly.SlotMinutes,
+		Timezone:    weekly.Timezone,
+		Slots:       SlotsToResponse(sched.Slots(from, to, busy)),
+	})
+}
+
+func scheduleWindowsFromRequest(data []ScheduleWindowRequest) ([]repositories.ScheduleWindow, error) {
+	windows := make([]repositories.ScheduleWindow, len(data))
+
+	for i, item := range data {
+		window, err := scheduleWindowFromRequest(item)
+		if err != nil {
+			return nil, err
+		}
+		windows[i] = window
+	}
+
+	return windows, nil
+}
+
+func scheduleWindowFromRequest(data ScheduleWindowRequest) (repositories.ScheduleWindow, error) {
+	start, err := schedule.ParseTimeOfDay(data.Start)
+	if err != nil {
+		return repositories.ScheduleWindow{}, fmt.Errorf("invalid start %q", data.Start)
+	}
+
+	end, err := schedule.ParseTimeOfDay(data.End)
+	if err != nil {
+		return repositories.ScheduleWindow{}, fmt.Errorf("invalid end %q", data.End)
+	}
+
+	if end <= start {
+		return repositories.ScheduleWindow{}, fmt.Errorf("end %s must be after start %s", data.End, data.Start)
+	}
+
+	return repositories.ScheduleWindow{
+		Weekday: time.Weekday(data.Weekday),
+		Start:   start,
+		End:     end,
+	}, nil
+}
+
+// parseDateRange reads the from and to query values. A plain date for to
+// includes that whole day.
+func parseDateRange(r *http.Request, loc *time.Location) (time.Time, time.Time, error) {
+	fromStr := r.URL.Query().Get("from")
+	toStr := r.URL.Query().Get("to")
+
+	if fromStr == "" || toStr == "" {
+		return time.Time{}, time.Time{}, errors.New("from and to are required")
+	}
+
+	from, _, err := parseDateOrTime(fromStr, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+	}
+
+	to, isDate, err := parseDateOrTime(toStr, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+	}
+	if isDate {
+		to = to.AddDate(0, 0, 1)
+	}
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("to must be after from")
+	}
+
+	return from, to, nil
+}
+
+func parseDateOrTime(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
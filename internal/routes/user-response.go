@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+// UserResponse never carries the password hash.
+type UserResponse struct {
+	ID        int64      `json:"id"`
+	Email     string     `json:"email"`
+	Type      string     `json:"type"`
+	CreatedAt *time.Time `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func UserDbToResponse(data database.User) UserResponse {
+	var createdAt *time.Time
+	if data.CreatedAt.Valid {
+		createdAt = &data.CreatedAt.Time
+	}
+
+	return UserResponse{
+		ID:        int64(data.ID),
+		Email:     data.Email,
+		Type:      data.Type,
+		CreatedAt: createdAt,
+		DeletedAt: timestamptzToPtr(data.DeletedAt),
+	}
+}
+
+func UserDbArrayToResponse(data []database.User) []UserResponse {
+
+	users := make([]UserResponse, len(data))
+
+	for i, item := range data {
+		users[i] = UserDbToResponse(item)
+	}
+
+	return users
+}
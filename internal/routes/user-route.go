@@ -0,0 +1,188 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
+	"patient-appointment-demo-go/internal/repositories"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type UserRouter struct {
+	mux   *http.ServeMux
+	repo  repositories.UserRepositoryInterface
+	audit repositories.AuditRepositoryInterface
+	tx    repositories.TxManagerInterface
+}
+
+func NewUserRouter(mux *http.ServeMux, userRepo repositories.UserRepositoryInterface, auditRepo repositories.AuditRepositoryInterface, tx repositories.TxManagerInterface) *UserRouter {
+	return &UserRouter{
+		mux:   mux,
+		repo:  userRepo,
+		audit: auditRepo,
+		tx:    tx,
+	}
+}
+
+func (r *UserRouter) Register() *UserRouter {
+	authMiddleware := NewAuthMiddleware(r.repo)
+
+	NewRoute("GET", "/api/users").
+		SetHandler(r.GetAll).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	NewRoute("DELETE", "/api/users/{id}").
+		SetHandler(r.Delete).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	NewRoute("POST", "/api/users/{id}/restore").
+		SetHandler(r.Restore).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	return r
+}
+
+// GetAll lists users a page at a time, optionally only those of one type
+// and with the deleted ones.
+func (ur *UserRouter) GetAll(w http.ResponseWriter, r *http.Request) {
+	page, errs := pageFromQuery(r.URL.Query(), repositories.UserSortID, repositories.UserSortEmail, repositories.UserSortCreatedAt)
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": errs,
+		})
+		return
+	}
+
+	withDeleted, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	users, err := ur.repo.GetAll(ctx, repositories.GetUsersOption{
+		Type:           r.URL.Query().Get("type"),
+		IncludeDeleted: withDeleted,
+		Page:           page,
+	})
+
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"Cursor": err.Error()},
+		})
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		return
+	}
+
+	ids := entityIds(users.Items, func(user database.User) int32 { return user.ID })
+	if !auditAccess(ctx, w, ur.audit, repositories.AuditActionList, repositories.AuditEntityUser, ids...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PageToResponse(users, UserDbArrayToResponse))
+}
+
+// Delete marks a user as deleted, it can't log in anymore. Admins can't
+// delete themselves.
+func (ur *UserRouter) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if user.ID == int32(id) {
+		http.Error(w, "You can't delete yourself", http.StatusConflict)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	err = ur.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		return repos.Users.Delete(ctx, int32(id))
+	})
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Restore brings back a deleted user, unless another one took the email in
+// the meantime.
+func (ur *UserRouter) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	var user database.User
+	err = ur.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		user, err = repos.Users.Restore(ctx, int32(id))
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if repositories.IsUniqueViolation(err, "users_email_key") {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"Email": "Another user has this email now"},
+		})
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to restore user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UserDbToResponse(user))
+}
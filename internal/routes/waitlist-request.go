@@ -0,0 +1,18 @@
+package routes
+
+type WaitlistEntryCreateRequest struct {
+	PatientID      int32   `json:"patient_id" validate:"required,gt=0"`
+	DoctorID       *int32  `json:"doctor_id" validate:"omitempty,gt=0"`
+	FromDate       string  `json:"from_date" validate:"required,datetime=2006-01-02"`
+	ToDate         string  `json:"to_date" validate:"required,datetime=2006-01-02"`
+	TimePreference string  `json:"time_preference" validate:"omitempty,oneof=any morning afternoon evening"`
+	Notes          *string `json:"notes" validate:"omitempty,max=1000"`
+}
+
+type WaitlistEntryUpdateRequest struct {
+	DoctorID       *int32  `json:"doctor_id" validate:"omitempty,gt=0"`
+	FromDate       string  `json:"from_date" validate:"required,datetime=2006-01-02"`
+	ToDate         string  `json:"to_date" validate:"required,datetime=2006-01-02"`
+	TimePreference string  `json:"time_preference" validate:"omitempty,oneof=any morning afternoon evening"`
+	Notes          *string `json:"notes" validate:"omitempty,max=1000"`
+}
@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+type WaitlistEntryResponse struct {
+	ID             int64     `json:"id"`
+	PatientId      int64     `json:"patient_id"`
+	DoctorId       *int64    `json:"doctor_id"`
+	FromDate       string    `json:"from_date"`
+	ToDate         string    `json:"to_date"`
+	TimePreference string    `json:"time_preference"`
+	Notes          *string   `json:"notes"`
+	Status         string    `json:"status"`
+	CreatedBy      *int64    `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type WaitlistHoldResponse struct {
+	ID              int64      `json:"id"`
+	WaitlistEntryId int64      `json:"waitlist_entry_id"`
+	DoctorId        int64      `json:"doctor_id"`
+	VisitTime       time.Time  `json:"visit_time"`
+	DurationMinutes int16      `json:"duration_minutes"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	Status          string     `json:"status"`
+	AppointmentId   *int64     `json:"appointment_id"`
+	ResolvedAt      *time.Time `json:"resolved_at"`
+}
+
+// WaitlistHoldConfirmResponse is the hold together with the appointment it
+// was booked into.
+type WaitlistHoldConfirmResponse struct {
+	Hold        WaitlistHoldResponse `json:"hold"`
+	Appointment AppointmentResponse  `json:"appointment"`
+}
+
+func WaitlistEntryDbToResponse(data database.WaitlistEntry) WaitlistEntryResponse {
+	return WaitlistEntryResponse{
+		ID:             int64(data.ID),
+		PatientId:      int64(data.PatientID),
+		DoctorId:       int4ToPtr(data.DoctorID),
+		FromDate:       data.FromDate.Time.Format("2006-01-02"),
+		ToDate:         data.ToDate.Time.Format("2006-01-02"),
+		TimePreference: data.TimePreference,
+		Notes:          textToPtr(data.Notes),
+		Status:         data.Status,
+		CreatedBy:      int4ToPtr(data.CreatedBy),
+		CreatedAt:      data.CreatedAt.Time,
+	}
+}
+
+func WaitlistEntryDbArrayToResponse(data []database.WaitlistEntry) []WaitlistEntryResponse {
+
+	entries := make([]WaitlistEntryResponse, len(data))
+
+	for i, item := range data {
+		entries[i] = WaitlistEntryDbToResponse(item)
+	}
+
+	return entries
+}
+
+func WaitlistHoldDbToResponse(data database.WaitlistHold) WaitlistHoldResponse {
+	var resolvedAt *time.Time
+	if data.ResolvedAt.Valid {
+		resolvedAt = &data.ResolvedAt.Time
+	}
+
+	return WaitlistHoldResponse{
+		ID:              int64(data.ID),
+		WaitlistEntryId: int64(data.WaitlistEntryID),
+		DoctorId:        int64(data.DoctorID),
+		VisitTime:       data.VisitTimestamp.Time,
+		DurationMinutes: data.DurationMinutes,
+		ExpiresAt:       data.ExpiresAt.Time,
+		Status:          data.Status,
+		AppointmentId:   int4ToPtr(data.AppointmentID),
+		ResolvedAt:      resolvedAt,
+	}
+}
+
+func WaitlistHoldDbArrayToResponse(data []database.WaitlistHold) []WaitlistHoldResponse {
+
+	holds := make([]WaitlistHoldResponse, len(data))
+
+	for i, item := range data {
+		holds[i] = WaitlistHoldDbToResponse(item)
+	}
+
+	return holds
+}
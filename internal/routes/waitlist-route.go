@@ -0,0 +1,470 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/queue"
+	"patient-appointment-demo-go/internal/repositories"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+)
+
+type WaitlistRouter struct {
+	mux      *http.ServeMux
+	userRepo repositories.UserRepositoryInterface
+	repo     repositories.WaitlistRepositoryInterface
+	audit    repositories.AuditRepositoryInterface
+	tx       repositories.TxManagerInterface
+	feed     *queue.Feed
+}
+
+func NewWaitlistRouter(mux *http.ServeMux, waitlistRepo repositories.WaitlistRepositoryInterface, userRepo repositories.UserRepositoryInterface, auditRepo repositories.AuditRepositoryInterface, tx repositories.TxManagerInterface, feed *queue.Feed) *WaitlistRouter {
+	return &WaitlistRouter{
+		mux:      mux,
+		repo:     waitlistRepo,
+		userRepo: userRepo,
+		audit:    auditRepo,
+		tx:       tx,
+		feed:     feed,
+	}
+}
+
+func (r *WaitlistRouter) Register() *WaitlistRouter {
+	authMiddleware := NewAuthMiddleware(r.userRepo)
+
+	NewRoute("GET", "/api/waitlist").
+		SetHandler(r.GetAll).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("POST", "/api/waitlist").
+		SetHandler(r.Create).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("GET", "/api/waitlist/holds").
+		SetHandler(r.GetHolds).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("POST", "/api/waitlist/holds/{id}/confirm").
+		SetHandler(r.ConfirmHold).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("POST", "/api/waitlist/holds/{id}/release").
+		SetHandler(r.ReleaseHold).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("GET", "/api/waitlist/{id}").
+		SetHandler(r.Get).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("PUT", "/api/waitlist/{id}").
+		SetHandler(r.Update).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	NewRoute("DELETE", "/api/waitlist/{id}").
+		SetHandler(r.Delete).
+		AddMiddlewares(authMiddleware.ValidateLogin).
+		Register(r.mux)
+
+	return r
+}
+
+func (wr *WaitlistRouter) GetAll(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	entries, err := wr.repo.GetAll(ctx)
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch waitlist", http.StatusInternalServerError)
+		return
+	}
+
+	// an entry is the patient's, with notes about them
+	if !auditAccess(ctx, w, wr.audit, repositories.AuditActionList, repositories.AuditEntityPatient, entityIds(entries, waitlistEntryPatientId)...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WaitlistEntryDbArrayToResponse(entries))
+}
+
+func (wr *WaitlistRouter) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid waitlist entry id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	entry, err := wr.repo.Get(ctx, int32(id))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch waitlist entry", http.StatusInternalServerError)
+		return
+	}
+
+	if !auditAccess(ctx, w, wr.audit, repositories.AuditActionView, repositories.AuditEntityPatient, entry.PatientID) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WaitlistEntryDbToResponse(entry))
+}
+
+func (wr *WaitlistRouter) Create(w http.ResponseWriter, r *http.Request) {
+	var req WaitlistEntryCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	from, to, ok := waitlistDatesFromRequest(w, req.FromDate, req.ToDate)
+	if !ok {
+		return
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	if !wr.validDoctor(ctx, w, req.DoctorID) {
+		return
+	}
+
+	entry, err := wr.repo.Create(ctx, user.ID, repositories.CreateWaitlistEntryParams{
+		PatientID:      req.PatientID,
+		DoctorID:       req.DoctorID,
+		FromDate:       from,
+		ToDate:         to,
+		TimePreference: repositories.TimePreference(req.TimePreference),
+		Notes:          req.Notes,
+	})
+
+	if repositories.IsForeignKeyViolation(err, "waitlist_entries_patient_id_fkey") {
+		http.Error(w, "Patient not found", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to create waitlist entry", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(WaitlistEntryDbToResponse(entry))
+}
+
+func (wr *WaitlistRouter) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid waitlist entry id", http.StatusBadRequest)
+		return
+	}
+
+	var req WaitlistEntryUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	from, to, ok := waitlistDatesFromRequest(w, req.FromDate, req.ToDate)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	if !wr.validDoctor(ctx, w, req.DoctorID) {
+		return
+	}
+
+	entry, err := wr.repo.Update(ctx, int32(id), repositories.UpdateWaitlistEntryParams{
+		DoctorID:       req.DoctorID,
+		FromDate:       from,
+		ToDate:         to,
+		TimePreference: repositories.TimePreference(req.TimePreference),
+		Notes:          req.Notes,
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to update waitlist entry", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WaitlistEntryDbToResponse(entry))
+}
+
+func (wr *WaitlistRouter) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid waitlist entry id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	if err := wr.repo.Delete(ctx, int32(id)); err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to delete waitlist entry", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetHolds lists the slots currently held for waitlisted patients, the
+// ones expiring first on top.
+func (wr *WaitlistRouter) GetHolds(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	holds, err := wr.repo.GetActiveHolds(ctx)
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch waitlist holds", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WaitlistHoldDbArrayToResponse(holds))
+}
+
+// ConfirmHold books the held slot for the waitlisted patient and withdraws
+// it from the other entries it was offered to.
+func (wr *WaitlistRouter) ConfirmHold(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid hold id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	var hold database.WaitlistHold
+	var appointment database.Appointment
+	err = wr.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		claimed, entry, err := repos.Waitlist.ClaimHold(ctx, int32(id))
+		if err != nil {
+			return err
+		}
+
+		appointment, err = repos.Appointments.Create(ctx, user.ID, entry.PatientID, repositories.CreateAppointmentParams{
+			DoctorID:       claimed.DoctorID,
+			VisitTimestamp: claimed.VisitTimestamp.Time,
+			Duration:       time.Duration(claimed.DurationMinutes) * time.Minute,
+			PatientNotes:   textToPtr(entry.Notes),
+		})
+		if err != nil {
+			return err
+		}
+
+		hold, err = repos.Waitlist.ConfirmHold(ctx, claimed, appointment.ID)
+		if err != nil {
+			return err
+		}
+
+		return enqueueAppointmentEvents(ctx, repos.Outbox, repositories.EventAppointmentCreated, appointment)
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Hold not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repositories.ErrHoldNotPending) || errors.Is(err, repositories.ErrHoldExpired) {
+		NewHttpError(http.StatusConflict, err.Error()).Write(w)
+		return
+	}
+
+	var overlap repositories.AppointmentOverlapError
+	if errors.As(err, &overlap) {
+		fmt.Println(err)
+		writeAppointmentConflict(w, overlap)
+		return
+	}
+
+	if errors.Is(err, repositories.ErrAppointmentSequenceConflict) {
+		fmt.Println(err)
+		http.Error(w, "Could not allocate an appointment number, please retry", http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to confirm hold", http.StatusInternalServerError)
+		return
+	}
+
+	publishQueueChange(ctx, wr.feed, queue.ActionCreated, appointment)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WaitlistHoldConfirmResponse{
+		Hold:        WaitlistHoldDbToResponse(hold),
+		Appointment: AppointmentDbToResponse(appointment),
+	})
+}
+
+func (wr *WaitlistRouter) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid hold id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	var hold database.WaitlistHold
+	err = wr.tx.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		var err error
+		hold, err = repos.Waitlist.ReleaseHold(ctx, int32(id))
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Hold not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repositories.ErrHoldNotPending) {
+		NewHttpError(http.StatusConflict, err.Error()).Write(w)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to release hold", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WaitlistHoldDbToResponse(hold))
+}
+
+// validDoctor checks that a preferred doctor, when given, is a doctor.
+func (wr *WaitlistRouter) validDoctor(ctx context.Context, w http.ResponseWriter, doctorId *int32) bool {
+	if doctorId == nil {
+		return true
+	}
+
+	doctor, err := wr.userRepo.Get(ctx, *doctorId)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch doctor", http.StatusInternalServerError)
+		return false
+	}
+
+	if err != nil || doctor.Type != "doctor" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": {"DoctorID": "doctor_id must reference a user of type doctor"},
+		})
+		return false
+	}
+
+	return true
+}
+
+func waitlistDatesFromRequest(w http.ResponseWriter, fromStr string, toStr string) (time.Time, time.Time, bool) {
+	from, err := time.Parse("2006-01-02", fromStr)
+	if err != nil {
+		http.Error(w, "Invalid from_date", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+
+	to, err := time.Parse("2006-01-02", toStr)
+	if err != nil {
+		http.Error(w, "Invalid to_date", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+
+	if to.Before(from) {
+		http.Error(w, "to_date must not be before from_date", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+
+	return from, to, true
+}
+
+func waitlistEntryPatientId(entry database.WaitlistEntry) int32 {
+	return entry.PatientID
+}
@@ -0,0 +1,11 @@
+package routes
+
+type WebhookCreateRequest struct {
+	URL string `json:"url" validate:"required,http_url,max=2000"`
+	// EventTypes left empty subscribes to every event.
+	EventTypes []string `json:"event_types" validate:"omitempty,dive,required"`
+}
+
+type WebhookUpdateRequest struct {
+	Active *bool `json:"active" validate:"required"`
+}
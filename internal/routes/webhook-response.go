@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"patient-appointment-demo-go/internal/database"
+	"time"
+)
+
+// WebhookResponse leaves out the secret, it is only shown once in
+// WebhookCreateResponse.
+type WebhookResponse struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedBy  *int64    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookCreateResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             int64      `json:"id"`
+	WebhookId      int64      `json:"webhook_id"`
+	EventId        int64      `json:"event_id"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode *int64     `json:"last_status_code"`
+	LastError      *string    `json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type WebhookTestResponse struct {
+	Succeeded  bool    `json:"succeeded"`
+	StatusCode int     `json:"status_code"`
+	Error      *string `json:"error"`
+}
+
+type WebhookReplayResponse struct {
+	Replayed int64 `json:"replayed"`
+}
+
+func WebhookDbToResponse(data database.Webhook) WebhookResponse {
+	eventTypes := data.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	return WebhookResponse{
+		ID:         int64(data.ID),
+		URL:        data.Url,
+		EventTypes: eventTypes,
+		Active:     data.Active,
+		CreatedBy:  int4ToPtr(data.CreatedBy),
+		CreatedAt:  data.CreatedAt.Time,
+	}
+}
+
+func WebhookDbArrayToResponse(data []database.Webhook) []WebhookResponse {
+
+	webhooks := make([]WebhookResponse, len(data))
+
+	for i, item := range data {
+		webhooks[i] = WebhookDbToResponse(item)
+	}
+
+	return webhooks
+}
+
+func WebhookDeliveryDbToResponse(data database.WebhookDelivery) WebhookDeliveryResponse {
+	var deliveredAt *time.Time
+	if data.DeliveredAt.Valid {
+		deliveredAt = &data.DeliveredAt.Time
+	}
+
+	return WebhookDeliveryResponse{
+		ID:             data.ID,
+		WebhookId:      int64(data.WebhookID),
+		EventId:        data.EventID,
+		Status:         data.Status,
+		Attempts:       data.Attempts,
+		NextAttemptAt:  data.NextAttemptAt.Time,
+		LastStatusCode: int4ToPtr(data.LastStatusCode),
+		LastError:      textToPtr(data.LastError),
+		DeliveredAt:    deliveredAt,
+		CreatedAt:      data.CreatedAt.Time,
+	}
+}
+
+func WebhookDeliveryDbArrayToResponse(data []database.WebhookDelivery) []WebhookDeliveryResponse {
+
+	deliveries := make([]WebhookDeliveryResponse, len(data))
+
+	for i, item := range data {
+		deliveries[i] = WebhookDeliveryDbToResponse(item)
+	}
+
+	return deliveries
+}
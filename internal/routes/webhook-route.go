@@ -0,0 +1,433 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/internal/webhook"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultDeliveryListSize = 50
+	maxDeliveryListSize     = 500
+)
+
+type WebhookRouter struct {
+	mux        *http.ServeMux
+	userRepo   repositories.UserRepositoryInterface
+	repo       repositories.WebhookRepositoryInterface
+	dispatcher *webhook.Dispatcher
+}
+
+func NewWebhookRouter(mux *http.ServeMux, webhookRepo repositories.WebhookRepositoryInterface, userRepo repositories.UserRepositoryInterface, dispatcher *webhook.Dispatcher) *WebhookRouter {
+	return &WebhookRouter{
+		mux:        mux,
+		repo:       webhookRepo,
+		userRepo:   userRepo,
+		dispatcher: dispatcher,
+	}
+}
+
+func (r *WebhookRouter) Register() *WebhookRouter {
+	authMiddleware := NewAuthMiddleware(r.userRepo)
+
+	NewRoute("GET", "/api/webhooks").
+		SetHandler(r.GetAll).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	NewRoute("POST", "/api/webhooks").
+		SetHandler(r.Create).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	NewRoute("GET", "/api/webhooks/{id}").
+		SetHandler(r.Get).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	NewRoute("PUT", "/api/webhooks/{id}").
+		SetHandler(r.Update).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	NewRoute("DELETE", "/api/webhooks/{id}").
+		SetHandler(r.Delete).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	NewRoute("POST", "/api/webhooks/{id}/test").
+		SetHandler(r.Test).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	NewRoute("GET", "/api/webhooks/{id}/deliveries").
+		SetHandler(r.GetDeliveries).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	NewRoute("POST", "/api/webhooks/{id}/replay").
+		SetHandler(r.ReplayDead).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	NewRoute("POST", "/api/webhook-deliveries/{id}/replay").
+		SetHandler(r.ReplayDelivery).
+		AddMiddlewares(
+			authMiddleware.ValidateLogin,
+			authMiddleware.ValidateRole("admin"),
+		).
+		Register(r.mux)
+
+	return r
+}
+
+func (wr *WebhookRouter) GetAll(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	webhooks, err := wr.repo.GetAll(ctx)
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WebhookDbArrayToResponse(webhooks))
+}
+
+func (wr *WebhookRouter) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	hook, err := wr.repo.Get(ctx, int32(id))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WebhookDbToResponse(hook))
+}
+
+// Create registers a webhook. The response carries the signing secret, the
+// only time it is shown.
+func (wr *WebhookRouter) Create(w http.ResponseWriter, r *http.Request) {
+	var req WebhookCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	eventTypes := make([]repositories.EventType, 0, len(req.EventTypes))
+	for _, eventType := range req.EventTypes {
+		if !repositories.IsEventType(eventType) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]map[string]string{
+				"errors": {"EventTypes": fmt.Sprintf("unknown event type %q", eventType)},
+			})
+			return
+		}
+		eventTypes = append(eventTypes, repositories.EventType(eventType))
+	}
+
+	user, err := getUserFromContext(r)
+	if err != nil {
+		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	hook, err := wr.repo.Create(ctx, user.ID, repositories.CreateWebhookParams{
+		URL:        req.URL,
+		EventTypes: eventTypes,
+	})
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(WebhookCreateResponse{
+		WebhookResponse: WebhookDbToResponse(hook),
+		Secret:          hook.Secret,
+	})
+}
+
+// Update pauses or resumes a webhook.
+func (wr *WebhookRouter) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+		return
+	}
+
+	var req WebhookUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(req); err != nil {
+		ve := err.(validator.ValidationErrors)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]map[string]string{
+			"errors": getValidationErrors(ve),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	hook, err := wr.repo.SetActive(ctx, int32(id), *req.Active)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WebhookDbToResponse(hook))
+}
+
+func (wr *WebhookRouter) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	if err := wr.repo.Delete(ctx, int32(id)); err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Test sends a webhook.test event to the webhook straight away and reports
+// how the endpoint answered. Nothing is recorded or retried.
+func (wr *WebhookRouter) Test(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*20)
+	defer cancel()
+
+	hook, err := wr.repo.Get(ctx, int32(id))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch webhook", http.StatusInternalServerError)
+		return
+	}
+
+	data, _ := json.Marshal(map[string]int32{"webhook_id": hook.ID})
+	statusCode, err := wr.dispatcher.Send(ctx, hook.Url, hook.Secret, webhook.Envelope{
+		Type:      string(repositories.EventWebhookTest),
+		CreatedAt: time.Now(),
+		Data:      data,
+	})
+
+	res := WebhookTestResponse{
+		Succeeded:  err == nil,
+		StatusCode: statusCode,
+	}
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// GetDeliveries lists the webhook's latest deliveries. The optional status
+// query value narrows them down, e.g. to the dead ones, and limit sets how
+// many are listed.
+func (wr *WebhookRouter) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+		return
+	}
+
+	var status *string
+	if s := r.URL.Query().Get("status"); s != "" {
+		if s != repositories.DeliveryPending && s != repositories.DeliverySucceeded && s != repositories.DeliveryDead {
+			http.Error(w, "Invalid delivery status", http.StatusBadRequest)
+			return
+		}
+		status = &s
+	}
+
+	limit := defaultDeliveryListSize
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxDeliveryListSize {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxDeliveryListSize), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	deliveries, err := wr.repo.GetDeliveries(ctx, int32(id), status, int32(limit))
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to fetch webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WebhookDeliveryDbArrayToResponse(deliveries))
+}
+
+// ReplayDead sends every dead delivery of the webhook again.
+func (wr *WebhookRouter) ReplayDead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	replayed, err := wr.repo.ReplayDeadDeliveries(ctx, int32(id))
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to replay webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WebhookReplayResponse{Replayed: replayed})
+}
+
+// ReplayDelivery sends one delivery again, whatever its status.
+func (wr *WebhookRouter) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "Invalid delivery id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+	defer cancel()
+
+	delivery, err := wr.repo.ReplayDelivery(ctx, id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Failed to replay webhook delivery", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WebhookDeliveryDbToResponse(delivery))
+}
@@ -0,0 +1,264 @@
+// Package schedule computes when a doctor can be booked from their weekly
+// working hours, breaks and date specific exceptions.
+package schedule
+
+import (
+	"sort"
+	"time"
+)
+
+type ExceptionKind string
+
+const (
+	ExceptionLeave      ExceptionKind = "leave"
+	ExceptionHoliday    ExceptionKind = "holiday"
+	ExceptionExtraHours ExceptionKind = "extra_hours"
+)
+
+// Interval is the half open range [Start, End).
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (i Interval) Overlaps(other Interval) bool {
+	return i.Start.Before(other.End) && other.Start.Before(i.End)
+}
+
+func (i Interval) Contains(other Interval) bool {
+	return !other.Start.Before(i.Start) && !other.End.After(i.End)
+}
+
+// TimeOfDay is an offset from local midnight.
+type TimeOfDay time.Duration
+
+func NewTimeOfDay(hour int, minute int) TimeOfDay {
+	return TimeOfDay(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+// ParseTimeOfDay reads a "15:04" wall clock time.
+func ParseTimeOfDay(value string) (TimeOfDay, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return NewTimeOfDay(t.Hour(), t.Minute()), nil
+}
+
+func (t TimeOfDay) String() string {
+	d := time.Duration(t)
+	return time.Date(0, 1, 1, int(d/time.Hour), int(d%time.Hour/time.Minute), 0, 0, time.UTC).Format("15:04")
+}
+
+// on returns the wall clock time t on the given day in loc.
+func (t TimeOfDay) on(year int, month time.Month, day int, loc *time.Location) time.Time {
+	d := time.Duration(t)
+	h := int(d / time.Hour)
+	m := int(d % time.Hour / time.Minute)
+	s := int(d % time.Minute / time.Second)
+	return time.Date(year, month, day, h, m, s, 0, loc)
+}
+
+// WeeklyWindow repeats every week on Weekday from Start to End.
+type WeeklyWindow struct {
+	Weekday time.Weekday
+	Start   TimeOfDay
+	End     TimeOfDay
+}
+
+// Exception changes a single date. When Start and End are nil it covers the
+// whole day.
+type Exception struct {
+	Year  int
+	Month time.Month
+	Day   int
+	Kind  ExceptionKind
+	Start *TimeOfDay
+	End   *TimeOfDay
+}
+
+type Schedule struct {
+	SlotLength   time.Duration
+	Location     *time.Location
+	WorkingHours []WeeklyWindow
+	Breaks       []WeeklyWindow
+	Exceptions   []Exception
+}
+
+// Availability returns the merged working time that overlaps [from, to),
+// without clipping the first and last interval to the range.
+func (s Schedule) Availability(from time.Time, to time.Time) []Interval {
+	loc := s.location()
+	var available []Interval
+
+	// walk local calendar days, one day either side so that windows of days
+	// whose local date differs from the range's are not missed
+	day := from.In(loc).AddDate(0, 0, -1)
+	last := to.In(loc).AddDate(0, 0, 1)
+	for !dateAfter(day, last) {
+		for _, interval := range s.dayAvailability(day.Year(), day.Month(), day.Day()) {
+			if interval.Overlaps(Interval{Start: from, End: to}) {
+				available = append(available, interval)
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return merge(available)
+}
+
+// Slots splits the availability within [from, to) into SlotLength pieces
+// and drops the ones overlapping busy.
+func (s Schedule) Slots(from time.Time, to time.Time, busy []Interval) []Interval {
+	if s.SlotLength <= 0 {
+		return nil
+	}
+
+	window := Interval{Start: from, End: to}
+	var slots []Interval
+
+	for _, interval := range s.Availability(from, to) {
+		for start := interval.Start; !start.Add(s.SlotLength).After(interval.End); start = start.Add(s.SlotLength) {
+			slot := Interval{Start: start, End: start.Add(s.SlotLength)}
+			if !window.Contains(slot) || overlapsAny(slot, busy) {
+				continue
+			}
+			slots = append(slots, slot)
+		}
+	}
+
+	return slots
+}
+
+// Covers reports whether the doctor works for the whole of booking.
+func (s Schedule) Covers(booking Interval) bool {
+	for _, interval := range s.Availability(booking.Start, booking.End) {
+		if interval.Contains(booking) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Schedule) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
+
+func (s Schedule) dayAvailability(year int, month time.Month, day int) []Interval {
+	loc := s.location()
+	weekday := time.Date(year, month, day, 0, 0, 0, 0, loc).Weekday()
+
+	var open []Interval
+	for _, w := range s.WorkingHours {
+		if w.Weekday == weekday {
+			open = append(open, Interval{Start: w.Start.on(year, month, day, loc), End: w.End.on(year, month, day, loc)})
+		}
+	}
+
+	var closed []Interval
+	for _, b := range s.Breaks {
+		if b.Weekday == weekday {
+			closed = append(closed, Interval{Start: b.Start.on(year, month, day, loc), End: b.End.on(year, month, day, loc)})
+		}
+	}
+
+	for _, e := range s.Exceptions {
+		if e.Year != year || e.Month != month || e.Day != day {
+			continue
+		}
+
+		interval := Interval{
+			Start: time.Date(year, month, day, 0, 0, 0, 0, loc),
+			End:   time.Date(year, month, day+1, 0, 0, 0, 0, loc),
+		}
+		if e.Start != nil && e.End != nil {
+			interval = Interval{Start: e.Start.on(year, month, day, loc), End: e.End.on(year, month, day, loc)}
+		}
+
+		if e.Kind == ExceptionExtraHours {
+			open = append(open, interval)
+		} else {
+			closed = append(closed, interval)
+		}
+	}
+
+	return subtract(merge(open), merge(closed))
+}
+
+func merge(intervals []Interval) []Interval {
+	if len(intervals) == 0 {
+		return nil
+	}
+
+	sorted := make([]Interval, len(intervals))
+	copy(sorted, intervals)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	merged := []Interval{sorted[0]}
+	for _, interval := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if !interval.Start.After(last.End) {
+			if interval.End.After(last.End) {
+				last.End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+// subtract removes the sorted, merged closed intervals from open.
+func subtract(open []Interval, closed []Interval) []Interval {
+	var result []Interval
+
+	for _, interval := range open {
+		current := interval
+		keep := true
+		for _, c := range closed {
+			if !c.Overlaps(current) {
+				continue
+			}
+			if c.Start.After(current.Start) {
+				result = append(result, Interval{Start: current.Start, End: c.Start})
+			}
+			if !c.End.Before(current.End) {
+				keep = false
+				break
+			}
+			current.Start = c.End
+		}
+		if keep && current.Start.Before(current.End) {
+			result = append(result, current)
+		}
+	}
+
+	return result
+}
+
+func overlapsAny(slot Interval, busy []Interval) bool {
+	for _, b := range busy {
+		if slot.Overlaps(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func dateAfter(a time.Time, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	if ay != by {
+		return ay > by
+	}
+	if am != bm {
+		return am > bm
+	}
+	return ad > bd
+}
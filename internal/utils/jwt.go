@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -9,31 +11,50 @@ import (
 
 var jwtSecret = []byte("your-secret-key") // Change this to an env var
 
+// AccessTokenTTL is how long a token is valid, a refresh token gets a new
+// one.
+const AccessTokenTTL = 15 * time.Minute
+
+// Claims of a login token. The registered ID claim (jti) identifies the
+// token so that it can be revoked, TokenVersion is the user's token version
+// it was issued with, bumping that revokes every token issued before.
+// SessionID is the family of refresh tokens it was issued along with.
 type Claims struct {
-	UserID int32 `json:"user_id"`
+	UserID       int32  `json:"user_id"`
+	TokenVersion int32  `json:"ver"`
+	SessionID    string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func ParseJWT(tokenString string) (int32, error) {
+func ParseJWT(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return jwtSecret, nil
 	})
 
-	if err != nil || !token.Valid {
-		return 0, errors.New("invalid token")
+	// tokens issued without a jti can't be revoked
+	if err != nil || !token.Valid || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, errors.New("invalid token")
 	}
 
-	return claims.UserID, nil
+	return claims, nil
 }
 
-func GenerateJWT(userID int32) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+func GenerateJWT(userID int32, tokenVersion int32, sessionID string) (string, error) {
+	expirationTime := time.Now().Add(AccessTokenTTL)
+
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
 
 	claims := &Claims{
-		UserID: userID,
+		UserID:       userID,
+		TokenVersion: tokenVersion,
+		SessionID:    sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
 	}
@@ -42,3 +63,10 @@ func GenerateJWT(userID int32) (string, error) {
 	return token.SignedString(jwtSecret)
 }
 
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store is what the dispatcher needs from the webhook repository.
+type Store interface {
+	DispatchOutbox(ctx context.Context, limit int32) (int64, error)
+	ClaimDeliveries(ctx context.Context, limit int32, lease time.Duration) ([]database.ClaimWebhookDeliveriesRow, error)
+	MarkDelivered(ctx context.Context, id int64, statusCode int) error
+	MarkFailed(ctx context.Context, id int64, statusCode int, reason string, retryAt time.Time) error
+	MarkDead(ctx context.Context, id int64, statusCode int, reason string) error
+}
+
+// Envelope is the JSON body of every webhook request. ID is the outbox
+// event id, the same on every retry and replay, so receivers can drop
+// events they already handled.
+type Envelope struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher queues deliveries for new outbox events and sends the ones
+// that are due, retrying failures with exponential backoff until they run
+// out of attempts and are left dead for an admin to replay. Payloads are
+// stored encrypted and decrypted with cipher right before they are sent.
+type Dispatcher struct {
+	store       Store
+	cipher      repositories.FieldCipher
+	client      *http.Client
+	interval    time.Duration
+	batchSize   int32
+	maxAttempts int32
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+type Option func(*Dispatcher)
+
+func WithClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithInterval sets how often Run looks for work.
+func WithInterval(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.interval = interval
+	}
+}
+
+// WithRetries sets how many attempts a delivery gets and the wait after the
+// first failure, doubling after each further one up to max.
+func WithRetries(maxAttempts int32, min, max time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = maxAttempts
+		d.minBackoff = min
+		d.maxBackoff = max
+	}
+}
+
+func NewDispatcher(store Store, cipher repositories.FieldCipher, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:       store,
+		cipher:      cipher,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		interval:    5 * time.Second,
+		batchSize:   50,
+		maxAttempts: 8,
+		minBackoff:  30 * time.Second,
+		maxBackoff:  6 * time.Hour,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Run works through the outbox every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println("webhook dispatcher:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce queues deliveries for every waiting outbox event, then sends a
+// batch of due deliveries and records how each went.
+func (d *Dispatcher) RunOnce(ctx context.Context) error {
+	for {
+		n, err := d.store.DispatchOutbox(ctx, d.batchSize)
+		if err != nil {
+			return err
+		}
+		if n < int64(d.batchSize) {
+			break
+		}
+	}
+
+	// the lease outlasts a request that runs into the client timeout
+	deliveries, err := d.store.ClaimDeliveries(ctx, d.batchSize, d.client.Timeout+time.Minute)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.deliver(ctx, delivery); err != nil {
+				fmt.Println("webhook dispatcher:", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) error {
+	payload, err := repositories.DecryptOutboxPayload(ctx, d.cipher, delivery.Payload)
+	if err != nil {
+		// a missing key may be back once the keys are reloaded
+		return fmt.Errorf("event %d: %w", delivery.EventID, err)
+	}
+
+	statusCode, err := d.Send(ctx, delivery.Url, delivery.Secret, Envelope{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.EventCreatedAt.Time,
+		Data:      payload,
+	})
+
+	if err == nil {
+		return d.store.MarkDelivered(ctx, delivery.ID, statusCode)
+	}
+
+	if delivery.Attempts >= d.maxAttempts {
+		return d.store.MarkDead(ctx, delivery.ID, statusCode, err.Error())
+	}
+
+	retryAt := time.Now().Add(d.Backoff(delivery.Attempts))
+	return d.store.MarkFailed(ctx, delivery.ID, statusCode, err.Error(), retryAt)
+}
+
+// Backoff is the wait after the given number of failed attempts.
+func (d *Dispatcher) Backoff(attempts int32) time.Duration {
+	backoff := d.minBackoff
+	for i := int32(1); i < attempts && backoff < d.maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > d.maxBackoff {
+		return d.maxBackoff
+	}
+	return backoff
+}
+
+// Send posts the signed envelope to url. It returns the response status,
+// 0 when there was no response, and an error unless the status is 2xx.
+func (d *Dispatcher) Send(ctx context.Context, url string, secret string, envelope Envelope) (int, error) {
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, strconv.FormatInt(envelope.ID, 10))
+	req.Header.Set(HeaderEvent, envelope.Type)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// read a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded %s", res.Status)
+	}
+
+	return res.StatusCode, nil
+}
@@ -2,8 +2,10 @@ package app_test
 
 import (
 	"patient-appointment-demo-go/internal/app"
+	"patient-appointment-demo-go/internal/database"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestConfigWithPort(t *testing.T) {
@@ -27,3 +29,30 @@ func TestNewApp(t *testing.T) {
         t.Errorf("app.New did not return correct type")
     }
 }
+
+func TestConfigWithPortUsesDefaultPool(t *testing.T) {
+    c := app.ConfigWithPort(8000)
+
+    if c.DB != database.DefaultPoolConfig() {
+        t.Errorf("app.ConfigWithPort did not set the default pool config")
+    }
+}
+
+func TestConfigWithDBPool(t *testing.T) {
+    pool := database.PoolConfig{
+        MinConns:          1,
+        MaxConns:          4,
+        HealthCheckPeriod: 30 * time.Second,
+        AcquireTimeout:    time.Second,
+    }
+
+    c := app.ConfigWithPort(8000).WithDBPool(pool)
+
+    if c.DB != pool {
+        t.Errorf("found incorrect pool config on app config")
+    }
+
+    if c.Port != 8000 {
+        t.Errorf("WithDBPool changed the port on app config")
+    }
+}
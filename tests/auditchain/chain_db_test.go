@@ -0,0 +1,105 @@
+package auditchain_test
+
+import (
+	"context"
+	"fmt"
+	"patient-appointment-demo-go/internal/auditchain"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/tests/testdb"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The chain the database builds must verify with the hashes computed here,
+// however many transactions append to it at once.
+func TestVerify_Database(t *testing.T) {
+	pool := testdb.Open(t)
+	queries := database.New(pool)
+	keyring := testdb.Keyring(t, pool)
+	ctx := repositories.WithAuditSource(context.Background(), repositories.AuditSource{
+		IP:        "192.0.2.1",
+		RequestID: fmt.Sprintf("chain-%d", time.Now().UnixNano()),
+	})
+
+	patient, err := repositories.NewPatientRepository(queries, keyring).Create(ctx, repositories.CreatePatientParams{
+		Name:  "Audit Chain",
+		Email: fmt.Sprintf("audit-chain-%d@example.com", time.Now().UnixNano()),
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		pool.Exec(context.Background(), "DELETE FROM patients WHERE id = $1", patient.ID)
+	})
+
+	audit := repositories.NewAuditRepository(queries)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- audit.RecordAccess(ctx, repositories.AuditActionView, repositories.AuditEntityPatient, patient.ID, patient.ID)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	_, err = auditchain.NewSequencer(audit).RunOnce(ctx)
+	require.NoError(t, err)
+
+	report, err := auditchain.Verify(ctx, audit, nil)
+
+	require.NoError(t, err)
+	assert.Nil(t, report.Broken)
+	assert.GreaterOrEqual(t, report.Entries, int64(101))
+}
+
+// An entry committed after one with a higher id is chained after it, and
+// chained entries can't be edited.
+func TestSequencer_Database(t *testing.T) {
+	pool := testdb.Open(t)
+	audit := repositories.NewAuditRepository(database.New(pool))
+	sequencer := auditchain.NewSequencer(audit)
+	requestId := fmt.Sprintf("sequencer-%d", time.Now().UnixNano())
+	ctx := repositories.WithAuditSource(context.Background(), repositories.AuditSource{RequestID: requestId})
+
+	// the first entry's transaction stays open while the second commits and
+	// is chained, it doesn't wait on the chain to write its entry
+	tx, err := pool.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+
+	require.NoError(t, repositories.NewAuditRepository(database.New(tx)).RecordAccess(ctx, repositories.AuditActionView, repositories.AuditEntityPatient, 1))
+	require.NoError(t, audit.RecordAccess(ctx, repositories.AuditActionView, repositories.AuditEntityPatient, 2))
+
+	_, err = sequencer.RunOnce(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+	_, err = sequencer.RunOnce(ctx)
+	require.NoError(t, err)
+
+	var first, second int64
+	err = pool.QueryRow(ctx, `
+		SELECT
+			(SELECT chain_seq FROM audit_log WHERE request_id = $1 AND entity_id = 1),
+			(SELECT chain_seq FROM audit_log WHERE request_id = $1 AND entity_id = 2)`, requestId).Scan(&first, &second)
+	require.NoError(t, err)
+	assert.Greater(t, first, second)
+
+	report, err := auditchain.Verify(ctx, audit, nil)
+	require.NoError(t, err)
+	assert.Nil(t, report.Broken)
+
+	_, err = pool.Exec(ctx, "UPDATE audit_log SET action = 'edit' WHERE request_id = $1", requestId)
+	assert.ErrorContains(t, err, "append-only")
+}
@@ -0,0 +1,279 @@
+package auditchain_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"patient-appointment-demo-go/internal/auditchain"
+	"patient-appointment-demo-go/internal/database"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore holds entries in id order, chained the way audit_chain_pending
+// does.
+type fakeStore struct {
+	entries []database.AuditLog
+}
+
+func (s *fakeStore) GetChain(ctx context.Context, afterId int64, limit int32) ([]database.AuditLog, error) {
+	var res []database.AuditLog
+	for _, entry := range s.entries {
+		if entry.ID > afterId && int32(len(res)) < limit {
+			res = append(res, entry)
+		}
+	}
+	return res, nil
+}
+
+func (s *fakeStore) append(action string, entityId int32) database.AuditLog {
+	entry := database.AuditLog{
+		ActorID:   pgtype.Int4{Int32: 1, Valid: true},
+		Action:    action,
+		Entity:    "patient",
+		EntityID:  pgtype.Int4{Int32: entityId, Valid: true},
+		Ip:        pgtype.Text{String: "192.0.2.1", Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: time.Date(2026, 5, 1, 9, 0, 0, 123456000, time.UTC), Valid: true},
+	}
+
+	entry.ID = 1
+	if n := len(s.entries); n > 0 {
+		entry.ID = s.entries[n-1].ID + 1
+		entry.PrevHash = s.entries[n-1].Hash
+	}
+	entry.Hash = auditchain.EntryHash(entry.PrevHash, entry)
+
+	s.entries = append(s.entries, entry)
+	return entry
+}
+
+func newChain(n int) *fakeStore {
+	s := &fakeStore{}
+	for i := 0; i < n; i++ {
+		s.append("view", int32(i+1))
+	}
+	return s
+}
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	return key
+}
+
+func TestEntryHash(t *testing.T) {
+	entry := database.AuditLog{
+		ID:        7,
+		Action:    "update",
+		Entity:    "patient",
+		EntityID:  pgtype.Int4{Int32: 3, Valid: true},
+		Changes:   []byte(`{"name": {"to": "B", "from": "A"}}`),
+		CreatedAt: pgtype.Timestamptz{Time: time.Date(2026, 5, 1, 11, 0, 0, 500000000, time.FixedZone("", 2*3600)), Valid: true},
+	}
+
+	hash := auditchain.EntryHash([]byte{1, 2}, entry)
+	assert.Len(t, hash, 32)
+
+	// the same instant in another zone is the same entry
+	utc := entry
+	utc.CreatedAt.Time = entry.CreatedAt.Time.UTC()
+	assert.Equal(t, hash, auditchain.EntryHash([]byte{1, 2}, utc))
+
+	assert.NotEqual(t, hash, auditchain.EntryHash([]byte{1, 3}, entry))
+
+	// a missing actor is not actor 0
+	withActor := entry
+	withActor.ActorID = pgtype.Int4{Int32: 0, Valid: true}
+	assert.NotEqual(t, hash, auditchain.EntryHash([]byte{1, 2}, withActor))
+}
+
+func TestVerify_Intact(t *testing.T) {
+	store := newChain(2500)
+
+	report, err := auditchain.Verify(context.Background(), store, nil)
+
+	require.NoError(t, err)
+	assert.Nil(t, report.Broken)
+	assert.Equal(t, int64(2500), report.Entries)
+	assert.Equal(t, int64(2500), report.LastID)
+}
+
+func TestVerify_EditedEntry(t *testing.T) {
+	store := newChain(10)
+	store.entries[4].Action = "list"
+
+	report, err := auditchain.Verify(context.Background(), store, nil)
+
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, int64(5), report.Broken.ID)
+	assert.Equal(t, int64(4), report.LastID)
+}
+
+func TestVerify_RemovedEntry(t *testing.T) {
+	store := newChain(10)
+	store.entries = append(store.entries[:4], store.entries[5:]...)
+
+	report, err := auditchain.Verify(context.Background(), store, nil)
+
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, int64(6), report.Broken.ID)
+}
+
+func TestVerify_RewrittenChain(t *testing.T) {
+	key := newKey(t)
+	store := newChain(10)
+	cp := auditchain.NewCheckpoint(key, 8, store.entries[7].Hash, time.Now())
+
+	// edit an entry and recompute every hash after it
+	rewritten := &fakeStore{}
+	for _, entry := range store.entries {
+		if entry.ID == 3 {
+			entry.EntityID.Int32 = 99
+		}
+		rewritten.append(entry.Action, entry.EntityID.Int32)
+	}
+
+	report, err := auditchain.Verify(context.Background(), rewritten, []auditchain.Checkpoint{cp})
+
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, int64(8), report.Broken.ID)
+}
+
+func TestVerify_TruncatedChain(t *testing.T) {
+	key := newKey(t)
+	store := newChain(10)
+	cps := []auditchain.Checkpoint{
+		auditchain.NewCheckpoint(key, 5, store.entries[4].Hash, time.Now()),
+		auditchain.NewCheckpoint(key, 9, store.entries[8].Hash, time.Now()),
+	}
+	store.entries = store.entries[:7]
+
+	report, err := auditchain.Verify(context.Background(), store, cps)
+
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, int64(9), report.Broken.ID)
+	assert.Equal(t, 1, report.Checkpoints)
+}
+
+func TestCheckpoints_RoundTrip(t *testing.T) {
+	key := newKey(t)
+	store := newChain(3)
+
+	var buf bytes.Buffer
+	cp := auditchain.NewCheckpoint(key, 3, store.entries[2].Hash, time.Now())
+	require.NoError(t, auditchain.WriteCheckpoint(&buf, cp))
+
+	read, err := auditchain.ReadCheckpoints(&buf, key.Public().(ed25519.PublicKey))
+
+	require.NoError(t, err)
+	require.Len(t, read, 1)
+	assert.Equal(t, cp.ID, read[0].ID)
+	assert.Equal(t, cp.Hash, read[0].Hash)
+	assert.True(t, cp.CreatedAt.Equal(read[0].CreatedAt))
+}
+
+func TestReadCheckpoints_Forged(t *testing.T) {
+	key := newKey(t)
+	store := newChain(3)
+
+	var buf bytes.Buffer
+	require.NoError(t, auditchain.WriteCheckpoint(&buf, auditchain.NewCheckpoint(key, 3, store.entries[2].Hash, time.Now())))
+	require.NoError(t, auditchain.WriteCheckpoint(&buf, auditchain.NewCheckpoint(newKey(t), 3, store.entries[2].Hash, time.Now())))
+
+	_, err := auditchain.ReadCheckpoints(&buf, key.Public().(ed25519.PublicKey))
+
+	assert.ErrorIs(t, err, auditchain.ErrInvalidSignature)
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := auditchain.ParsePrivateKey("AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=")
+	require.NoError(t, err)
+
+	public, err := auditchain.ParsePublicKey(auditchain.EncodePublicKey(key.Public().(ed25519.PublicKey)))
+	require.NoError(t, err)
+	assert.Equal(t, key.Public(), public)
+
+	_, err = auditchain.ParsePrivateKey("AAEC")
+	assert.Error(t, err)
+}
+
+func TestCheckpointer_RunOnce(t *testing.T) {
+	key := newKey(t)
+	store := newChain(3)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	c := auditchain.NewCheckpointer(store, key, &buf, auditchain.WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+
+	cp, ok, err := c.RunOnce(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(3), cp.ID)
+	assert.Equal(t, store.entries[2].Hash, cp.Hash)
+	assert.Equal(t, now, cp.CreatedAt)
+
+	_, ok, err = c.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok, "nothing was appended")
+
+	store.append("view", 4)
+	cp, ok, err = c.RunOnce(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(4), cp.ID)
+
+	read, err := auditchain.ReadCheckpoints(&buf, key.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	assert.Len(t, read, 2)
+}
+
+func TestCheckpointer_RunOnce_Tampered(t *testing.T) {
+	store := newChain(3)
+	store.entries[1].Action = "list"
+
+	var buf bytes.Buffer
+	c := auditchain.NewCheckpointer(store, newKey(t), &buf)
+
+	_, ok, err := c.RunOnce(context.Background())
+
+	var broken auditchain.Break
+	require.True(t, errors.As(err, &broken))
+	assert.Equal(t, int64(2), broken.ID)
+	assert.False(t, ok)
+	assert.Zero(t, buf.Len(), "a tampered chain is not signed")
+}
+
+// pendingStore has pending entries waiting to be chained.
+type pendingStore struct {
+	pending int32
+	calls   int
+}
+
+func (s *pendingStore) ChainPending(ctx context.Context, limit int32) (int32, error) {
+	s.calls++
+	n := min(s.pending, limit)
+	s.pending -= n
+	return n, nil
+}
+
+func TestSequencer_RunOnce(t *testing.T) {
+	store := &pendingStore{pending: 1200}
+
+	n, err := auditchain.NewSequencer(store).RunOnce(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1200), n)
+	assert.Zero(t, store.pending)
+	assert.Equal(t, 3, store.calls)
+}
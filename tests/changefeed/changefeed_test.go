@@ -0,0 +1,308 @@
+package changefeed_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"patient-appointment-demo-go/internal/changefeed"
+	"patient-appointment-demo-go/internal/database"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errDropped = errors.New("connection dropped")
+
+// fakeDB stands in for Postgres: a change log and the connections listening
+// on it.
+type fakeDB struct {
+	mu    sync.Mutex
+	log   []database.ChangeLog
+	conns chan *fakeConn
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{conns: make(chan *fakeConn, 4)}
+}
+
+func (db *fakeDB) connect(ctx context.Context) (changefeed.Conn, error) {
+	conn := &fakeConn{
+		db:            db,
+		notifications: make(chan []byte, 16),
+		dropped:       make(chan struct{}),
+		waiting:       make(chan struct{}),
+	}
+	db.conns <- conn
+	return conn, nil
+}
+
+// write adds a patient change to the log without notifying anyone, as if
+// it happened while the listener was away.
+func (db *fakeDB) write(id int64) []byte {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.log = append(db.log, database.ChangeLog{
+		ID:        id,
+		TableName: "patients",
+		Operation: "UPDATE",
+		RowID:     int32(id),
+		Payload:   []byte(`{"changed": ["phone"]}`),
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+
+	return []byte(fmt.Sprintf(
+		`{"id": %d, "table": "patients", "op": "UPDATE", "row_id": %d, "at": "2026-10-18T09:00:00Z", "data": {"changed": ["phone"]}}`,
+		id, id,
+	))
+}
+
+func (db *fakeDB) nextConn(t *testing.T) *fakeConn {
+	t.Helper()
+	select {
+	case conn := <-db.conns:
+		<-conn.waiting
+		return conn
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the listener to connect")
+		return nil
+	}
+}
+
+type fakeConn struct {
+	db            *fakeDB
+	notifications chan []byte
+	dropped       chan struct{}
+	// waiting is closed once the listener has caught up and waits for
+	// notifications.
+	waiting     chan struct{}
+	waitingOnce sync.Once
+}
+
+func (c *fakeConn) Listen(ctx context.Context) error {
+	return nil
+}
+
+func (c *fakeConn) WaitForNotification(ctx context.Context) ([]byte, error) {
+	c.waitingOnce.Do(func() { close(c.waiting) })
+	select {
+	case payload := <-c.notifications:
+		return payload, nil
+	case <-c.dropped:
+		return nil, errDropped
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *fakeConn) ChangesSince(ctx context.Context, afterId int64, limit int32) ([]database.ChangeLog, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	var rows []database.ChangeLog
+	for _, row := range c.db.log {
+		if row.ID > afterId && len(rows) < int(limit) {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func (c *fakeConn) LatestChangeID(ctx context.Context) (int64, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if len(c.db.log) == 0 {
+		return 0, nil
+	}
+	return c.db.log[len(c.db.log)-1].ID, nil
+}
+
+func (c *fakeConn) Close(ctx context.Context) error {
+	return nil
+}
+
+func receive(t *testing.T, ch <-chan changefeed.Event) changefeed.Event {
+	t.Helper()
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+		return changefeed.Event{}
+	}
+}
+
+// newListener returns a listener on db; Run it after subscribing so no
+// change is dispatched before anyone listens.
+func newListener(t *testing.T, db *fakeDB, opts ...changefeed.Option) (*changefeed.Listener, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	opts = append(opts, changefeed.WithBackoff(time.Millisecond, time.Millisecond))
+	return changefeed.NewListener(db.connect, opts...), ctx
+}
+
+func TestDecode_Appointment(t *testing.T) {
+	payload := []byte(`{
+		"id": 42, "table": "appointments", "op": "UPDATE", "row_id": 7,
+		"at": "2026-10-18T09:00:00Z",
+		"data": {
+			"patient_id": 3, "doctor_id": 2, "status": "checked_in",
+			"old_status": "scheduled", "visit_date": "2026-10-18",
+			"visit_timestamp": "2026-10-18T09:30:00Z", "appointment_sequence": 4
+		}
+	}`)
+
+	event, err := changefeed.Decode(payload)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(42), event.ID)
+	assert.Equal(t, changefeed.TableAppointments, event.Table)
+	assert.Equal(t, changefeed.OpUpdate, event.Op)
+	assert.Equal(t, int32(7), event.RowID)
+	assert.Nil(t, event.Patient)
+	require.NotNil(t, event.Appointment)
+	assert.Equal(t, int32(3), event.Appointment.PatientID)
+	require.NotNil(t, event.Appointment.DoctorID)
+	assert.Equal(t, int32(2), *event.Appointment.DoctorID)
+	assert.Equal(t, "checked_in", event.Appointment.Status)
+	require.NotNil(t, event.Appointment.OldStatus)
+	assert.Equal(t, "scheduled", *event.Appointment.OldStatus)
+	assert.Equal(t, "2026-10-18", event.Appointment.VisitDate.Format("2006-01-02"))
+	assert.Equal(t, int16(4), event.Appointment.Token)
+}
+
+func TestDecode_UnknownTable(t *testing.T) {
+	_, err := changefeed.Decode([]byte(`{"id": 1, "table": "users", "op": "INSERT", "row_id": 1, "data": {}}`))
+
+	assert.ErrorIs(t, err, changefeed.ErrUnknownTable)
+}
+
+func TestListener_StartsFromLatestChange(t *testing.T) {
+	db := newFakeDB()
+	db.write(1)
+	db.write(2)
+
+	listener, ctx := newListener(t, db)
+	events := listener.Subscribe(ctx)
+	go listener.Run(ctx)
+	conn := db.nextConn(t)
+
+	assert.Equal(t, int64(2), listener.LastID())
+
+	conn.notifications <- db.write(3)
+
+	event := receive(t, events)
+	assert.Equal(t, int64(3), event.ID)
+	require.NotNil(t, event.Patient)
+	assert.Equal(t, []string{"phone"}, event.Patient.Changed)
+}
+
+func TestListener_ResumesAfterReconnect(t *testing.T) {
+	db := newFakeDB()
+
+	listener, ctx := newListener(t, db)
+	events := listener.Subscribe(ctx)
+	go listener.Run(ctx)
+	conn := db.nextConn(t)
+
+	conn.notifications <- db.write(1)
+	assert.Equal(t, int64(1), receive(t, events).ID)
+
+	db.write(2)
+	three := db.write(3)
+	close(conn.dropped)
+	conn = db.nextConn(t)
+
+	assert.Equal(t, int64(2), receive(t, events).ID)
+	assert.Equal(t, int64(3), receive(t, events).ID)
+
+	// A change notified again after the catch-up is not delivered twice.
+	conn.notifications <- three
+	conn.notifications <- db.write(4)
+	assert.Equal(t, int64(4), receive(t, events).ID)
+	assert.Equal(t, int64(4), listener.LastID())
+}
+
+func TestListener_WithResumeFrom(t *testing.T) {
+	db := newFakeDB()
+	db.write(1)
+	db.write(2)
+	db.write(3)
+
+	listener, ctx := newListener(t, db, changefeed.WithResumeFrom(1))
+	events := listener.Subscribe(ctx)
+	go listener.Run(ctx)
+	db.nextConn(t)
+
+	// Changes shortly before the resume point are replayed too, in case
+	// they committed late.
+	assert.Equal(t, int64(1), receive(t, events).ID)
+	assert.Equal(t, int64(2), receive(t, events).ID)
+	assert.Equal(t, int64(3), receive(t, events).ID)
+}
+
+func TestListener_FiltersByTable(t *testing.T) {
+	db := newFakeDB()
+
+	listener, ctx := newListener(t, db)
+	appointments := listener.Subscribe(ctx, changefeed.TableAppointments)
+	all := listener.Subscribe(ctx)
+	go listener.Run(ctx)
+	conn := db.nextConn(t)
+
+	conn.notifications <- db.write(1)
+	assert.Equal(t, int64(1), receive(t, all).ID)
+
+	select {
+	case event := <-appointments:
+		t.Fatalf("unexpected event %d", event.ID)
+	default:
+	}
+}
+
+// DeleteChangesBefore makes fakeDB a PruneStore.
+func (db *fakeDB) DeleteChangesBefore(ctx context.Context, arg database.DeleteChangesBeforeParams) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var kept []database.ChangeLog
+	deleted := int64(0)
+	for _, row := range db.log {
+		if deleted < int64(arg.MaxResults) && row.CreatedAt.Time.Before(arg.Before.Time) {
+			deleted++
+			continue
+		}
+		kept = append(kept, row)
+	}
+	db.log = kept
+
+	return deleted, nil
+}
+
+func TestPruner_RunOnce(t *testing.T) {
+	db := newFakeDB()
+	now := time.Now()
+	for id := int64(1); id <= 2500; id++ {
+		// all but the last ten are past the retention
+		createdAt := now.Add(-8 * 24 * time.Hour)
+		if id > 2490 {
+			createdAt = now.Add(-time.Hour)
+		}
+		db.log = append(db.log, database.ChangeLog{ID: id, CreatedAt: pgtype.Timestamptz{Time: createdAt, Valid: true}})
+	}
+
+	pruner := changefeed.NewPruner(db, changefeed.DefaultRetention)
+
+	deleted, err := pruner.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2490), deleted)
+	require.Len(t, db.log, 10)
+	assert.Equal(t, int64(2491), db.log[0].ID)
+}
@@ -0,0 +1,233 @@
+package fieldcrypt_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/fieldcrypt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKeyStore keeps keys the way the encryption_keys table does.
+type fakeKeyStore struct {
+	keys []database.EncryptionKey
+}
+
+func (s *fakeKeyStore) GetKeys(ctx context.Context) ([]database.EncryptionKey, error) {
+	return append([]database.EncryptionKey(nil), s.keys...), nil
+}
+
+func (s *fakeKeyStore) CreateKey(ctx context.Context, purpose string, wrappedKey []byte, masterKeyId string) (database.EncryptionKey, error) {
+	key := database.EncryptionKey{
+		ID:          int32(len(s.keys) + 1),
+		Purpose:     purpose,
+		WrappedKey:  wrappedKey,
+		MasterKeyID: masterKeyId,
+	}
+	s.keys = append(s.keys, key)
+	return key, nil
+}
+
+func (s *fakeKeyStore) RewrapKey(ctx context.Context, id int32, wrappedKey []byte, masterKeyId string) error {
+	s.keys[id-1].WrappedKey = wrappedKey
+	s.keys[id-1].MasterKeyID = masterKeyId
+	return nil
+}
+
+func (s *fakeKeyStore) RetireDataKeys(ctx context.Context, activeId int32) error {
+	for i, key := range s.keys {
+		if key.Purpose == fieldcrypt.PurposeData && key.ID != activeId && !key.RetiredAt.Valid {
+			s.keys[i].RetiredAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func newMasterKey(t *testing.T) fieldcrypt.MasterKey {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	require.NoError(t, err)
+
+	key, err := fieldcrypt.NewMasterKey(raw)
+	require.NoError(t, err)
+	return key
+}
+
+func TestKeyring_RoundTrip(t *testing.T) {
+	store := &fakeKeyStore{}
+	keyring := fieldcrypt.NewKeyring(store, []fieldcrypt.MasterKey{newMasterKey(t)})
+	ctx := context.Background()
+
+	encrypted, err := keyring.Encrypt(ctx, "patients.email", "john@example.com")
+	require.NoError(t, err)
+	assert.NotContains(t, encrypted, "john")
+
+	decrypted, err := keyring.Decrypt(ctx, "patients.email", encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "john@example.com", decrypted)
+
+	// the first use created the blind index and data keys
+	assert.Len(t, store.keys, 2)
+
+	again, err := keyring.Encrypt(ctx, "patients.email", "john@example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again, "every value gets its own nonce")
+}
+
+func TestKeyring_Decrypt_OtherField(t *testing.T) {
+	keyring := fieldcrypt.NewKeyring(&fakeKeyStore{}, []fieldcrypt.MasterKey{newMasterKey(t)})
+	ctx := context.Background()
+
+	encrypted, err := keyring.Encrypt(ctx, "patients.phone", "555-0123")
+	require.NoError(t, err)
+
+	_, err = keyring.Decrypt(ctx, "patients.address", encrypted)
+	assert.ErrorIs(t, err, fieldcrypt.ErrDecrypt)
+}
+
+func TestKeyring_Decrypt_Tampered(t *testing.T) {
+	keyring := fieldcrypt.NewKeyring(&fakeKeyStore{}, []fieldcrypt.MasterKey{newMasterKey(t)})
+	ctx := context.Background()
+
+	encrypted, err := keyring.Encrypt(ctx, "patients.phone", "555-0123")
+	require.NoError(t, err)
+
+	i := strings.LastIndex(encrypted, ":")
+	sealed, err := base64.RawStdEncoding.DecodeString(encrypted[i+1:])
+	require.NoError(t, err)
+	sealed[len(sealed)-1] ^= 1
+
+	_, err = keyring.Decrypt(ctx, "patients.phone", encrypted[:i+1]+base64.RawStdEncoding.EncodeToString(sealed))
+	assert.ErrorIs(t, err, fieldcrypt.ErrDecrypt)
+}
+
+func TestKeyring_Decrypt_Plaintext(t *testing.T) {
+	keyring := fieldcrypt.NewKeyring(&fakeKeyStore{}, []fieldcrypt.MasterKey{newMasterKey(t)})
+	ctx := context.Background()
+
+	decrypted, err := keyring.Decrypt(ctx, "patients.email", "legacy@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy@example.com", decrypted)
+
+	current, err := keyring.IsCurrent(ctx, "legacy@example.com")
+	require.NoError(t, err)
+	assert.False(t, current)
+}
+
+func TestKeyring_BlindIndex(t *testing.T) {
+	store := &fakeKeyStore{}
+	master := newMasterKey(t)
+	keyring := fieldcrypt.NewKeyring(store, []fieldcrypt.MasterKey{master})
+	ctx := context.Background()
+
+	index, err := keyring.BlindIndex(ctx, "patients.email", "john@example.com")
+	require.NoError(t, err)
+
+	same, err := keyring.BlindIndex(ctx, "patients.email", "john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, index, same)
+
+	otherField, err := keyring.BlindIndex(ctx, "patients.phone", "john@example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, index, otherField)
+
+	// rotating keeps the indexes valid
+	_, err = keyring.Rotate(ctx)
+	require.NoError(t, err)
+
+	rotated, err := keyring.BlindIndex(ctx, "patients.email", "john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, index, rotated)
+}
+
+func TestKeyring_Rotate(t *testing.T) {
+	store := &fakeKeyStore{}
+	oldMaster := newMasterKey(t)
+	ctx := context.Background()
+
+	old := fieldcrypt.NewKeyring(store, []fieldcrypt.MasterKey{oldMaster})
+	encrypted, err := old.Encrypt(ctx, "appointments.doctor_notes", "Follow up")
+	require.NoError(t, err)
+
+	newMaster := newMasterKey(t)
+	keyring := fieldcrypt.NewKeyring(store, []fieldcrypt.MasterKey{newMaster, oldMaster})
+
+	rotation, err := keyring.Rotate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rotation.Rewrapped)
+	assert.Equal(t, int32(3), rotation.DataKeyID)
+
+	for _, key := range store.keys {
+		assert.Equal(t, newMaster.ID(), key.MasterKeyID)
+	}
+
+	current, err := keyring.IsCurrent(ctx, encrypted)
+	require.NoError(t, err)
+	assert.False(t, current)
+
+	reencrypted, err := keyring.Encrypt(ctx, "appointments.doctor_notes", "Follow up")
+	require.NoError(t, err)
+	current, err = keyring.IsCurrent(ctx, reencrypted)
+	require.NoError(t, err)
+	assert.True(t, current)
+
+	// the old master key is no longer needed
+	rotated := fieldcrypt.NewKeyring(store, []fieldcrypt.MasterKey{newMaster})
+	decrypted, err := rotated.Decrypt(ctx, "appointments.doctor_notes", encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "Follow up", decrypted)
+}
+
+func TestKeyring_Decrypt_KeyCreatedElsewhere(t *testing.T) {
+	store := &fakeKeyStore{}
+	master := newMasterKey(t)
+	ctx := context.Background()
+
+	keyring := fieldcrypt.NewKeyring(store, []fieldcrypt.MasterKey{master})
+	require.NoError(t, keyring.Load(ctx))
+
+	// another process rotates and encrypts with the new key
+	other := fieldcrypt.NewKeyring(store, []fieldcrypt.MasterKey{master})
+	_, err := other.Rotate(ctx)
+	require.NoError(t, err)
+	encrypted, err := other.Encrypt(ctx, "patients.address", "1 Main St")
+	require.NoError(t, err)
+
+	decrypted, err := keyring.Decrypt(ctx, "patients.address", encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "1 Main St", decrypted)
+}
+
+func TestKeyring_Load_UnknownMasterKey(t *testing.T) {
+	store := &fakeKeyStore{}
+	ctx := context.Background()
+
+	require.NoError(t, fieldcrypt.NewKeyring(store, []fieldcrypt.MasterKey{newMasterKey(t)}).Load(ctx))
+
+	err := fieldcrypt.NewKeyring(store, []fieldcrypt.MasterKey{newMasterKey(t)}).Load(ctx)
+	assert.ErrorContains(t, err, "not configured")
+}
+
+func TestParseMasterKeys(t *testing.T) {
+	first := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	second := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	keys, err := fieldcrypt.ParseMasterKeys(first + ", " + second)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.NotEqual(t, keys[0].ID(), keys[1].ID())
+
+	_, err = fieldcrypt.ParseMasterKeys(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+
+	_, err = fieldcrypt.ParseMasterKeys(" ")
+	assert.Error(t, err)
+}
@@ -0,0 +1,86 @@
+package ical_test
+
+import (
+	"bytes"
+	"patient-appointment-demo-go/internal/ical"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, calendar ical.Calendar) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, calendar.Encode(&buf))
+	return buf.String()
+}
+
+func TestEncode_Event(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	start := time.Date(2026, 10, 19, 9, 30, 0, 0, berlin)
+
+	out := encode(t, ical.Calendar{
+		Method: ical.MethodRequest,
+		Events: []ical.Event{{
+			UID:       "appointment-7@example",
+			Sequence:  2,
+			Stamp:     time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+			Start:     start,
+			End:       start.Add(15 * time.Minute),
+			Summary:   "Check-up",
+			Status:    ical.StatusConfirmed,
+			Organizer: "doctor@example.com",
+			Attendees: []ical.Attendee{{Name: `Ada "The Countess" Lovelace`, Email: "ada@example.com"}},
+		}},
+	})
+
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(out, "END:VEVENT\r\nEND:VCALENDAR\r\n"))
+	assert.Contains(t, out, "\r\nMETHOD:REQUEST\r\n")
+	assert.Contains(t, out, "\r\nUID:appointment-7@example\r\n")
+	assert.Contains(t, out, "\r\nSEQUENCE:2\r\n")
+	assert.Contains(t, out, "\r\nDTSTAMP:20261018T120000Z\r\n")
+	assert.Contains(t, out, "\r\nDTSTART:20261019T073000Z\r\n")
+	assert.Contains(t, out, "\r\nDTEND:20261019T074500Z\r\n")
+	assert.Contains(t, out, "\r\nSTATUS:CONFIRMED\r\n")
+	assert.Contains(t, out, "\r\nORGANIZER:mailto:doctor@example.com\r\n")
+	// quotes can't be escaped in a parameter, they are dropped
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	assert.Contains(t, unfolded, "\r\nATTENDEE;ROLE=REQ-PARTICIPANT;CN=\"Ada The Countess Lovelace\":mailto:ada@example.com\r\n")
+	assert.NotContains(t, out, "DESCRIPTION")
+}
+
+func TestEncode_EscapesText(t *testing.T) {
+	out := encode(t, ical.Calendar{
+		Events: []ical.Event{{
+			UID:         "1",
+			Summary:     "Token 3: Doe, Jane; follow-up",
+			Description: "line one\nline \\two",
+		}},
+	})
+
+	assert.Contains(t, out, "\r\nSUMMARY:Token 3: Doe\\, Jane\\; follow-up\r\n")
+	assert.Contains(t, out, "\r\nDESCRIPTION:line one\\nline \\\\two\r\n")
+	assert.NotContains(t, out, "METHOD")
+}
+
+func TestEncode_FoldsLongLines(t *testing.T) {
+	summary := strings.Repeat("é", 100)
+
+	out := encode(t, ical.Calendar{
+		Events: []ical.Event{{UID: "1", Summary: summary}},
+	})
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+		assert.True(t, strings.ToValidUTF8(line, "?") == line, "fold split a character: %q", line)
+	}
+
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	assert.Contains(t, unfolded, "\r\nSUMMARY:"+summary+"\r\n")
+}
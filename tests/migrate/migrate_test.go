@@ -0,0 +1,104 @@
+package migrate_test
+
+import (
+	"patient-appointment-demo-go/db"
+	"patient-appointment-demo-go/internal/migrate"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	content := `-- +goose Up
+CREATE TABLE a (id INT);
+
+-- +goose StatementBegin
+CREATE FUNCTION f() RETURNS INT AS $$ SELECT 1; $$ LANGUAGE sql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE a;
+`
+
+	m, err := migrate.Parse("007_add_a.sql", content)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), m.Version)
+	assert.Equal(t, "add_a", m.Name)
+	assert.Contains(t, m.Up, "CREATE TABLE a (id INT);")
+	assert.Contains(t, m.Up, "CREATE FUNCTION f()")
+	assert.NotContains(t, m.Up, "DROP TABLE")
+	assert.Equal(t, "DROP TABLE a;", m.Down)
+	assert.False(t, m.NoTransaction)
+}
+
+func TestParse_NoTransaction(t *testing.T) {
+	content := `-- +goose NO TRANSACTION
+-- +goose Up
+CREATE INDEX CONCURRENTLY idx ON a (id);
+`
+
+	m, err := migrate.Parse("008_idx.sql", content)
+
+	assert.NoError(t, err)
+	assert.True(t, m.NoTransaction)
+	assert.Empty(t, m.Down)
+}
+
+func TestParse_InvalidName(t *testing.T) {
+	_, err := migrate.Parse("schema.sql", "-- +goose Up\nSELECT 1;")
+	assert.Error(t, err)
+
+	_, err = migrate.Parse("abc_schema.sql", "-- +goose Up\nSELECT 1;")
+	assert.Error(t, err)
+}
+
+func TestParse_MissingUp(t *testing.T) {
+	_, err := migrate.Parse("001_empty.sql", "SELECT 1;")
+	assert.Error(t, err)
+}
+
+func TestLoad_SortsByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schema/010_b.sql":  {Data: []byte("-- +goose Up\nSELECT 10;")},
+		"schema/002_a.sql":  {Data: []byte("-- +goose Up\nSELECT 2;")},
+		"schema/readme.txt": {Data: []byte("ignored")},
+	}
+
+	migrations, err := migrate.Load(fsys, "schema")
+
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, int64(2), migrations[0].Version)
+	assert.Equal(t, int64(10), migrations[1].Version)
+}
+
+func TestLoad_DuplicateVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schema/002_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;")},
+		"schema/002_b.sql": {Data: []byte("-- +goose Up\nSELECT 2;")},
+	}
+
+	_, err := migrate.Load(fsys, "schema")
+
+	assert.Error(t, err)
+}
+
+func TestLoad_EmbeddedSchema(t *testing.T) {
+	migrations, err := migrate.Load(db.Schema, db.SchemaDir)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "migration versions should be contiguous")
+		assert.NotEmpty(t, m.Up)
+	}
+}
+
+func TestErrSchemaBehind(t *testing.T) {
+	err := migrate.ErrSchemaBehind{Pending: []migrate.Migration{{Version: 5, Name: "status"}}}
+
+	assert.Contains(t, err.Error(), "005_status")
+}
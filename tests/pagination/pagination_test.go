@@ -0,0 +1,58 @@
+package pagination_test
+
+import (
+	"patient-appointment-demo-go/internal/pagination"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func key(n int32) (string, int32) {
+	return strconv.Itoa(int(n) * 10), n
+}
+
+func TestParams_FetchLimit(t *testing.T) {
+	assert.Equal(t, int32(pagination.DefaultLimit+1), pagination.Params{}.FetchLimit())
+	assert.Equal(t, int32(6), pagination.Params{Limit: 5}.FetchLimit())
+	assert.Equal(t, int32(pagination.MaxLimit+1), pagination.Params{Limit: 1000}.FetchLimit())
+}
+
+func TestNewPage_LastPage(t *testing.T) {
+	page := pagination.NewPage([]int32{1, 2, 3}, pagination.Params{Limit: 3}, key)
+
+	assert.Equal(t, []int32{1, 2, 3}, page.Items)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestNewPage_CursorRoundTrip(t *testing.T) {
+	params := pagination.Params{Limit: 2, SortBy: "age", Desc: true}
+	page := pagination.NewPage([]int32{1, 2, 3}, params, key)
+
+	assert.Equal(t, []int32{1, 2}, page.Items)
+	require.NotEmpty(t, page.NextCursor)
+
+	params.Cursor = page.NextCursor
+	cursor, err := params.DecodeCursor()
+
+	require.NoError(t, err)
+	assert.Equal(t, &pagination.Cursor{SortBy: "age", Desc: true, Key: "20", ID: 2}, cursor)
+}
+
+func TestParams_DecodeCursor(t *testing.T) {
+	cursor := pagination.Cursor{SortBy: "name", Key: "Jane", ID: 4}.Encode()
+
+	first, err := pagination.Params{SortBy: "name"}.DecodeCursor()
+	assert.NoError(t, err)
+	assert.Nil(t, first)
+
+	_, err = pagination.Params{SortBy: "name", Cursor: "not a cursor"}.DecodeCursor()
+	assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+
+	_, err = pagination.Params{SortBy: "age", Cursor: cursor}.DecodeCursor()
+	assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+
+	_, err = pagination.Params{SortBy: "name", Desc: true, Cursor: cursor}.DecodeCursor()
+	assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+}
@@ -0,0 +1,98 @@
+package pubsub_test
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/pubsub"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch <-chan []byte) ([]byte, bool) {
+	t.Helper()
+	select {
+	case payload, ok := <-ch:
+		return payload, ok
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+		return nil, false
+	}
+}
+
+func TestMemory_DeliversToTopicSubscribers(t *testing.T) {
+	ps := pubsub.NewMemory()
+	defer ps.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, err := ps.Subscribe(ctx, "a")
+	require.NoError(t, err)
+	a2, err := ps.Subscribe(ctx, "a")
+	require.NoError(t, err)
+	b, err := ps.Subscribe(ctx, "b")
+	require.NoError(t, err)
+
+	require.NoError(t, ps.Publish(ctx, "a", []byte("hello")))
+
+	payload, _ := receive(t, a)
+	assert.Equal(t, "hello", string(payload))
+	payload, _ = receive(t, a2)
+	assert.Equal(t, "hello", string(payload))
+	assert.Empty(t, b)
+}
+
+func TestMemory_SubscriptionEndsWithContext(t *testing.T) {
+	ps := pubsub.NewMemory()
+	defer ps.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, err := ps.Subscribe(ctx, "a")
+	require.NoError(t, err)
+
+	cancel()
+
+	_, ok := receive(t, ch)
+	assert.False(t, ok)
+	assert.NoError(t, ps.Publish(context.Background(), "a", []byte("late")))
+}
+
+func TestMemory_SlowSubscriberDoesNotBlock(t *testing.T) {
+	ps := pubsub.NewMemory()
+	defer ps.Close()
+	ctx := context.Background()
+
+	_, err := ps.Subscribe(ctx, "a")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			ps.Publish(ctx, "a", []byte("x"))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a full subscriber")
+	}
+}
+
+func TestMemory_Close(t *testing.T) {
+	ps := pubsub.NewMemory()
+	ctx := context.Background()
+
+	ch, err := ps.Subscribe(ctx, "a")
+	require.NoError(t, err)
+
+	ps.Close()
+
+	_, ok := receive(t, ch)
+	assert.False(t, ok)
+	assert.ErrorIs(t, ps.Publish(ctx, "a", nil), pubsub.ErrClosed)
+	_, err = ps.Subscribe(ctx, "a")
+	assert.ErrorIs(t, err, pubsub.ErrClosed)
+}
@@ -0,0 +1,91 @@
+package queue_test
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pubsub"
+	"patient-appointment-demo-go/internal/queue"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var day = time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+
+func appointment(id int32, token int16, status string) database.Appointment {
+	return database.Appointment{
+		ID:                  id,
+		AppointmentSequence: token,
+		Status:              status,
+		VisitDate:           pgtype.Date{Time: day, Valid: true},
+		VisitTimestamp:      pgtype.Timestamptz{Time: day.Add(9 * time.Hour), Valid: true},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	appointments := []database.Appointment{
+		appointment(1, 1, "completed"),
+		appointment(2, 2, "in_consultation"),
+		appointment(3, 3, "scheduled"),
+		appointment(4, 4, "checked_in"),
+		appointment(5, 5, "cancelled"),
+		appointment(6, 6, "checked_in"),
+		appointment(7, 7, "scheduled"),
+	}
+
+	snapshot := queue.Build(day, appointments, 3)
+
+	require.NotNil(t, snapshot.Current)
+	assert.Equal(t, int16(2), snapshot.Current.Number)
+
+	var next []int16
+	for _, token := range snapshot.Next {
+		next = append(next, token.Number)
+	}
+	// checked in patients are called before the ones not arrived yet
+	assert.Equal(t, []int16{4, 6, 3}, next)
+}
+
+func TestBuild_EmptyDay(t *testing.T) {
+	snapshot := queue.Build(day, nil, 5)
+
+	assert.Nil(t, snapshot.Current)
+	assert.Empty(t, snapshot.Next)
+	assert.NotNil(t, snapshot.Next)
+}
+
+func TestFeed_PublishesOnTheVisitDate(t *testing.T) {
+	ps := pubsub.NewMemory()
+	defer ps.Close()
+	feed := queue.NewFeed(ps)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	today, err := feed.Subscribe(ctx, day)
+	require.NoError(t, err)
+	tomorrow, err := feed.Subscribe(ctx, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+
+	require.NoError(t, feed.Publish(ctx, queue.ActionStatusChanged, appointment(4, 4, "checked_in")))
+
+	select {
+	case change := <-today:
+		assert.Equal(t, queue.Change{
+			Action:        queue.ActionStatusChanged,
+			AppointmentID: 4,
+			Token:         4,
+			Status:        "checked_in",
+		}, change)
+	case <-time.After(time.Second):
+		t.Fatal("no change received")
+	}
+
+	select {
+	case change := <-tomorrow:
+		t.Fatalf("unexpected change %+v", change)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
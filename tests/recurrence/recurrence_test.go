@@ -0,0 +1,124 @@
+package recurrence_test
+
+import (
+	"patient-appointment-demo-go/internal/recurrence"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRule_Validate(t *testing.T) {
+	until := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, recurrence.Rule{Frequency: recurrence.Weekly, Count: 4}.Validate())
+	assert.NoError(t, recurrence.Rule{Frequency: recurrence.Daily, Until: &until}.Validate())
+
+	invalid := []recurrence.Rule{
+		{Frequency: "monthly", Count: 4},
+		{Frequency: recurrence.Weekly},
+		{Frequency: recurrence.Weekly, Count: 4, Until: &until},
+		{Frequency: recurrence.Weekly, Count: 4, Interval: -1},
+		{Frequency: recurrence.Weekly, Count: recurrence.MaxOccurrences + 1},
+	}
+	for _, rule := range invalid {
+		assert.ErrorIs(t, rule.Validate(), recurrence.ErrInvalidRule, "%+v", rule)
+	}
+}
+
+func TestRule_Occurrences_EveryNDays(t *testing.T) {
+	start := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+	rule := recurrence.Rule{Frequency: recurrence.Daily, Interval: 3, Count: 3}
+
+	occurrences, err := rule.Occurrences(start, time.UTC)
+
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		start,
+		start.AddDate(0, 0, 3),
+		start.AddDate(0, 0, 6),
+	}, occurrences)
+}
+
+func TestRule_Occurrences_WeeklyOnWeekdaysUntil(t *testing.T) {
+	// a Wednesday
+	start := time.Date(2025, time.March, 5, 14, 30, 0, 0, time.UTC)
+	until := time.Date(2025, time.March, 17, 0, 0, 0, 0, time.UTC)
+	rule := recurrence.Rule{
+		Frequency: recurrence.Weekly,
+		Until:     &until,
+		Weekdays:  []time.Weekday{time.Friday, time.Monday, time.Wednesday},
+	}
+
+	occurrences, err := rule.Occurrences(start, time.UTC)
+
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2025, time.March, 5, 14, 30, 0, 0, time.UTC),
+		time.Date(2025, time.March, 7, 14, 30, 0, 0, time.UTC),
+		time.Date(2025, time.March, 10, 14, 30, 0, 0, time.UTC),
+		time.Date(2025, time.March, 12, 14, 30, 0, 0, time.UTC),
+		time.Date(2025, time.March, 14, 14, 30, 0, 0, time.UTC),
+		time.Date(2025, time.March, 17, 14, 30, 0, 0, time.UTC),
+	}, occurrences)
+}
+
+func TestRule_Occurrences_EveryOtherWeek(t *testing.T) {
+	start := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+	rule := recurrence.Rule{Frequency: recurrence.Weekly, Interval: 2, Count: 3}
+
+	occurrences, err := rule.Occurrences(start, time.UTC)
+
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		start,
+		start.AddDate(0, 0, 14),
+		start.AddDate(0, 0, 28),
+	}, occurrences)
+}
+
+func TestRule_Occurrences_KeepsWallClockAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// clocks go forward on March 30th 2025
+	start := time.Date(2025, time.March, 24, 9, 0, 0, 0, loc)
+	rule := recurrence.Rule{Frequency: recurrence.Weekly, Count: 2}
+
+	occurrences, err := rule.Occurrences(start, loc)
+
+	require.NoError(t, err)
+	require.Len(t, occurrences, 2)
+	assert.Equal(t, 9, occurrences[1].In(loc).Hour())
+	assert.Equal(t, 7*24*time.Hour-time.Hour, occurrences[1].Sub(occurrences[0]))
+}
+
+func TestRule_Occurrences_TooMany(t *testing.T) {
+	start := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+	until := start.AddDate(5, 0, 0)
+	rule := recurrence.Rule{Frequency: recurrence.Daily, Until: &until}
+
+	_, err := rule.Occurrences(start, time.UTC)
+
+	assert.ErrorIs(t, err, recurrence.ErrTooManyOccurrences)
+}
+
+func TestRule_Occurrences_UnreachableWeekday(t *testing.T) {
+	// every 7 days from a Monday never lands on a Tuesday
+	start := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+	rule := recurrence.Rule{Frequency: recurrence.Daily, Interval: 7, Count: 2, Weekdays: []time.Weekday{time.Tuesday}}
+
+	occurrences, err := rule.Occurrences(start, time.UTC)
+
+	assert.NoError(t, err)
+	assert.Empty(t, occurrences)
+}
+
+func TestShift(t *testing.T) {
+	from := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+	to := time.Date(2025, time.March, 4, 11, 15, 0, 0, time.UTC)
+	later := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2025, time.March, 11, 11, 15, 0, 0, time.UTC), recurrence.Shift(later, from, to, time.UTC))
+}
@@ -0,0 +1,221 @@
+package reminder_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/reminder"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type claimKey struct {
+	appointmentID int32
+	offset        time.Duration
+	channel       string
+}
+
+// fakeStore keeps reminders in memory the way appointment_reminders does:
+// one per appointment, offset and channel, claimable again only after it
+// failed.
+type fakeStore struct {
+	mu       sync.Mutex
+	due      []database.GetDueRemindersRow
+	nextID   int64
+	claims   map[claimKey]int64
+	statuses map[int64]string
+}
+
+func newFakeStore(due ...database.GetDueRemindersRow) *fakeStore {
+	return &fakeStore{
+		due:      due,
+		claims:   make(map[claimKey]int64),
+		statuses: make(map[int64]string),
+	}
+}
+
+func (s *fakeStore) GetDue(ctx context.Context, offset time.Duration, channel string, limit int32) ([]database.GetDueRemindersRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []database.GetDueRemindersRow
+	for _, row := range s.due {
+		id, ok := s.claims[claimKey{row.AppointmentID, offset, channel}]
+		if !ok || s.statuses[id] == "failed" {
+			due = append(due, row)
+		}
+	}
+	return due, nil
+}
+
+func (s *fakeStore) Claim(ctx context.Context, appointmentId int32, offset time.Duration, channel string, visitTimestamp time.Time) (database.AppointmentReminder, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := claimKey{appointmentId, offset, channel}
+	if id, ok := s.claims[key]; ok {
+		if s.statuses[id] != "failed" {
+			return database.AppointmentReminder{}, false, nil
+		}
+		s.statuses[id] = "sending"
+		return database.AppointmentReminder{ID: id}, true, nil
+	}
+
+	s.nextID++
+	s.claims[key] = s.nextID
+	s.statuses[s.nextID] = "sending"
+	return database.AppointmentReminder{ID: s.nextID}, true, nil
+}
+
+func (s *fakeStore) MarkSent(ctx context.Context, id int64) error {
+	return s.mark(id, "sent")
+}
+
+func (s *fakeStore) MarkFailed(ctx context.Context, id int64, reason string) error {
+	return s.mark(id, "failed")
+}
+
+func (s *fakeStore) MarkSkipped(ctx context.Context, id int64, reason string) error {
+	return s.mark(id, "skipped")
+}
+
+func (s *fakeStore) mark(id int64, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[id] = status
+	return nil
+}
+
+func (s *fakeStore) status(appointmentId int32, offset time.Duration, channel string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statuses[s.claims[claimKey{appointmentId, offset, channel}]]
+}
+
+type failingNotifier struct {
+	err error
+}
+
+func (n *failingNotifier) Channel() string {
+	return "sms"
+}
+
+func (n *failingNotifier) Notify(ctx context.Context, r reminder.Reminder) error {
+	return n.err
+}
+
+func dueRow(id int32, phone string) database.GetDueRemindersRow {
+	return database.GetDueRemindersRow{
+		AppointmentID:       id,
+		AppointmentSequence: 3,
+		VisitTimestamp:      pgtype.Timestamptz{Time: time.Date(2026, 10, 19, 7, 30, 0, 0, time.UTC), Valid: true},
+		PatientName:         "Ada Lovelace",
+		PatientEmail:        "ada@example.com",
+		PatientPhone:        pgtype.Text{String: phone, Valid: phone != ""},
+		Timezone:            "Europe/Berlin",
+	}
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]any
+		require.NoError(t, dec.Decode(&line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestFromRow_UsesDoctorTimezone(t *testing.T) {
+	r := reminder.FromRow(dueRow(1, ""), 24*time.Hour)
+
+	assert.Equal(t, "Europe/Berlin", r.VisitTime.Location().String())
+	assert.Equal(t, 9, r.VisitTime.Hour())
+	assert.Equal(t, "Hi Ada Lovelace, this is a reminder of your appointment on Mon 19 Oct at 09:30, token 3.", r.Text())
+}
+
+func TestScheduler_SendsEachReminderOnce(t *testing.T) {
+	store := newFakeStore(dueRow(1, ""), dueRow(2, ""))
+	var buf bytes.Buffer
+	offsets := []time.Duration{24 * time.Hour, 2 * time.Hour}
+	scheduler := reminder.NewScheduler(store, offsets, []reminder.Notifier{reminder.NewLogNotifier(&buf)})
+
+	require.NoError(t, scheduler.RunOnce(context.Background()))
+	// a restarted scheduler finds them recorded as sent
+	restarted := reminder.NewScheduler(store, offsets, []reminder.Notifier{reminder.NewLogNotifier(&buf)})
+	require.NoError(t, restarted.RunOnce(context.Background()))
+
+	lines := logLines(t, &buf)
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "log", lines[0]["channel"])
+	assert.Equal(t, "ada@example.com", lines[0]["email"])
+	assert.Equal(t, "24h0m0s", lines[0]["offset"])
+	assert.Equal(t, "sent", store.status(1, 2*time.Hour, "log"))
+}
+
+func TestScheduler_RetriesFailures(t *testing.T) {
+	store := newFakeStore(dueRow(1, "+4915112345678"))
+	notifier := &failingNotifier{err: errors.New("gateway down")}
+	scheduler := reminder.NewScheduler(store, []time.Duration{2 * time.Hour}, []reminder.Notifier{notifier})
+
+	require.NoError(t, scheduler.RunOnce(context.Background()))
+	assert.Equal(t, "failed", store.status(1, 2*time.Hour, "sms"))
+
+	notifier.err = nil
+	require.NoError(t, scheduler.RunOnce(context.Background()))
+	assert.Equal(t, "sent", store.status(1, 2*time.Hour, "sms"))
+}
+
+func TestScheduler_SkipsPatientsWithoutAddress(t *testing.T) {
+	store := newFakeStore(dueRow(1, ""))
+	sms := reminder.NewSMSGatewayNotifier(reminder.SMSGatewayConfig{URL: "http://127.0.0.1:0"})
+	scheduler := reminder.NewScheduler(store, []time.Duration{2 * time.Hour}, []reminder.Notifier{sms})
+
+	require.NoError(t, scheduler.RunOnce(context.Background()))
+
+	assert.Equal(t, "skipped", store.status(1, 2*time.Hour, "sms"))
+}
+
+func TestSMSGatewayNotifier(t *testing.T) {
+	var got map[string]string
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sms := reminder.NewSMSGatewayNotifier(reminder.SMSGatewayConfig{URL: server.URL, APIKey: "key", From: "Clinic"})
+	err := sms.Notify(context.Background(), reminder.FromRow(dueRow(1, "+4915112345678"), 2*time.Hour))
+
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer key", auth)
+	assert.Equal(t, "+4915112345678", got["to"])
+	assert.Equal(t, "Clinic", got["from"])
+	assert.Contains(t, got["message"], "09:30")
+}
+
+func TestSMSGatewayNotifier_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid number", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sms := reminder.NewSMSGatewayNotifier(reminder.SMSGatewayConfig{URL: server.URL})
+	err := sms.Notify(context.Background(), reminder.FromRow(dueRow(1, "+4915112345678"), 2*time.Hour))
+
+	assert.ErrorContains(t, err, "invalid number")
+}
@@ -0,0 +1,94 @@
+package repositories_test
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAppointmentRepository_Reschedule(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	oldVisit := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+	newVisit := time.Date(2025, time.March, 4, 10, 30, 0, 0, time.UTC)
+	reason := "patient asked"
+	current := database.Appointment{
+		ID:              1,
+		PatientID:       2,
+		DoctorID:        pgtype.Int4{Int32: 5, Valid: true},
+		Status:          "scheduled",
+		VisitTimestamp:  pgtype.Timestamptz{Time: oldVisit, Valid: true},
+		DurationMinutes: 30,
+	}
+	updated := current
+	updated.VisitTimestamp = pgtype.Timestamptz{Time: newVisit, Valid: true}
+
+	mockQueries.On("GetAppointmentByID", ctx, int32(1)).Return(current, nil)
+	mockQueries.On("GetOverlappingAppointments", ctx, database.GetOverlappingAppointmentsParams{
+		DoctorID:  pgtype.Int4{Int32: 5, Valid: true},
+		PatientID: 2,
+		ExcludeID: 1,
+		FromTime:  pgtype.Timestamptz{Time: newVisit, Valid: true},
+		ToTime:    pgtype.Timestamptz{Time: newVisit.Add(30 * time.Minute), Valid: true},
+	}).Return([]database.Appointment{}, nil)
+	mockQueries.On("RescheduleAppointment", ctx, database.RescheduleAppointmentParams{
+		ID:             1,
+		VisitTimestamp: pgtype.Timestamptz{Time: newVisit, Valid: true},
+		VisitDate:      pgtype.Date{Time: time.Date(2025, time.March, 4, 0, 0, 0, 0, time.UTC), Valid: true},
+	}).Return(updated, nil)
+	mockQueries.On("CreateAppointmentRescheduleHistory", ctx, database.CreateAppointmentRescheduleHistoryParams{
+		AppointmentID:     1,
+		OldVisitTimestamp: pgtype.Timestamptz{Time: oldVisit, Valid: true},
+		NewVisitTimestamp: pgtype.Timestamptz{Time: newVisit, Valid: true},
+		RescheduledBy:     pgtype.Int4{Int32: 3, Valid: true},
+		Reason:            pgtype.Text{String: reason, Valid: true},
+	}).Return(database.AppointmentRescheduleHistory{}, nil)
+
+	result, err := repo.Reschedule(ctx, 1, repositories.RescheduleAppointmentParams{
+		ActorID:        3,
+		VisitTimestamp: newVisit,
+		Reason:         &reason,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, updated, result)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestAppointmentRepository_Reschedule_RejectsNotScheduled(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("GetAppointmentByID", ctx, int32(1)).Return(database.Appointment{ID: 1, Status: "checked_in"}, nil)
+
+	_, err := repo.Reschedule(ctx, 1, repositories.RescheduleAppointmentParams{VisitTimestamp: time.Now()})
+
+	assert.ErrorIs(t, err, repositories.ErrAppointmentNotReschedulable)
+	mockQueries.AssertNotCalled(t, "RescheduleAppointment", mock.Anything, mock.Anything)
+}
+
+func TestAppointmentRepository_Reschedule_ReturnsOverlaps(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	conflicts := []database.Appointment{{ID: 9}}
+
+	mockQueries.On("GetAppointmentByID", ctx, int32(1)).Return(database.Appointment{ID: 1, Status: "scheduled", DurationMinutes: 15}, nil)
+	mockQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return(conflicts, nil)
+
+	_, err := repo.Reschedule(ctx, 1, repositories.RescheduleAppointmentParams{VisitTimestamp: time.Now()})
+
+	var overlap repositories.AppointmentOverlapError
+	assert.ErrorAs(t, err, &overlap)
+	assert.Equal(t, conflicts, overlap.Conflicts)
+	mockQueries.AssertNotCalled(t, "RescheduleAppointment", mock.Anything, mock.Anything)
+	mockQueries.AssertNotCalled(t, "CreateAppointmentRescheduleHistory", mock.Anything, mock.Anything)
+}
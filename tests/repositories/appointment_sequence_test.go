@@ -0,0 +1,115 @@
+package repositories_test
+
+import (
+	"context"
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/tests/testdb"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppointmentRepository_Create_ConcurrentSequence(t *testing.T) {
+	pool := testdb.Open(t)
+	queries := database.New(pool)
+	keyring := testdb.Keyring(t, pool)
+	ctx := context.Background()
+	const bookings = 300
+
+	patient, err := repositories.NewPatientRepository(queries, keyring).Create(ctx, repositories.CreatePatientParams{
+		Name:  "Concurrent Booking",
+		Email: fmt.Sprintf("concurrent-%d@example.com", time.Now().UnixNano()),
+	})
+	require.NoError(t, err)
+
+	// a date far enough out that no other test books on it
+	visitDay := time.Date(2100+int(time.Now().UnixNano()%500), time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Cleanup(func() {
+		pool.Exec(ctx, "DELETE FROM appointments WHERE visit_date = $1", visitDay)
+		pool.Exec(ctx, "DELETE FROM appointment_sequence_counters WHERE visit_date = $1", visitDay)
+		pool.Exec(ctx, "DELETE FROM patients WHERE id = $1", patient.ID)
+	})
+
+	repo := repositories.NewAppointmentRepository(queries, keyring)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, bookings)
+	for i := 0; i < bookings; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := repo.Create(ctx, 0, patient.ID, repositories.CreateAppointmentParams{
+				VisitTimestamp: visitDay.Add(time.Duration(i) * time.Minute),
+				// one patient books them all, keep them from overlapping
+				Duration: time.Minute,
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	appointments, err := repo.GetByDate(ctx, visitDay)
+	require.NoError(t, err)
+	require.Len(t, appointments, bookings)
+
+	sequences := make([]int, len(appointments))
+	for i, a := range appointments {
+		sequences[i] = int(a.AppointmentSequence)
+	}
+	sort.Ints(sequences)
+
+	for i, seq := range sequences {
+		assert.Equal(t, i+1, seq, "sequences must be 1..N without gaps or duplicates")
+	}
+}
+
+func TestAppointmentRepository_Create_CounterBehind(t *testing.T) {
+	pool := testdb.Open(t)
+	queries := database.New(pool)
+	keyring := testdb.Keyring(t, pool)
+	ctx := context.Background()
+
+	patient, err := repositories.NewPatientRepository(queries, keyring).Create(ctx, repositories.CreatePatientParams{
+		Name:  "Counter Behind",
+		Email: fmt.Sprintf("counter-behind-%d@example.com", time.Now().UnixNano()),
+	})
+	require.NoError(t, err)
+
+	visitDay := time.Date(2100+int(time.Now().UnixNano()%500), time.February, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Cleanup(func() {
+		pool.Exec(ctx, "DELETE FROM appointments WHERE visit_date = $1", visitDay)
+		pool.Exec(ctx, "DELETE FROM appointment_sequence_counters WHERE visit_date = $1", visitDay)
+		pool.Exec(ctx, "DELETE FROM patients WHERE id = $1", patient.ID)
+	})
+
+	repo := repositories.NewAppointmentRepository(queries, keyring)
+
+	first, err := repo.Create(ctx, 0, patient.ID, repositories.CreateAppointmentParams{
+		VisitTimestamp: visitDay.Add(9 * time.Hour),
+	})
+	require.NoError(t, err)
+
+	// like rows written before the counter existed
+	_, err = pool.Exec(ctx, "UPDATE appointment_sequence_counters SET last_sequence = 0 WHERE visit_date = $1", visitDay)
+	require.NoError(t, err)
+
+	second, err := repo.Create(ctx, 0, patient.ID, repositories.CreateAppointmentParams{
+		VisitTimestamp: visitDay.Add(10 * time.Hour),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, first.AppointmentSequence+1, second.AppointmentSequence)
+}
@@ -0,0 +1,147 @@
+package repositories_test
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/recurrence"
+	"patient-appointment-demo-go/internal/repositories"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func seriesAppointment(id int32, visit time.Time, status string) database.Appointment {
+	return database.Appointment{
+		ID:              id,
+		PatientID:       2,
+		DoctorID:        pgtype.Int4{Int32: 5, Valid: true},
+		SeriesID:        pgtype.Int4{Int32: 9, Valid: true},
+		Status:          status,
+		VisitTimestamp:  pgtype.Timestamptz{Time: visit, Valid: true},
+		DurationMinutes: 30,
+	}
+}
+
+func TestAppointmentRepository_CreateSeries(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	start := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+
+	mockQueries.On("CreateAppointmentSeries", ctx, mock.MatchedBy(func(p database.CreateAppointmentSeriesParams) bool {
+		return p.Frequency == "weekly" &&
+			p.RepeatInterval == 1 &&
+			p.OccurrenceCount == pgtype.Int2{Int16: 3, Valid: true} &&
+			!p.UntilDate.Valid &&
+			p.DurationMinutes == 30 &&
+			p.Timezone == "UTC"
+	})).Return(database.AppointmentSeries{ID: 9}, nil)
+	mockQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return([]database.Appointment{}, nil)
+	for i := 0; i < 3; i++ {
+		visit := start.AddDate(0, 0, 7*i)
+		mockQueries.On("CreateAppointment", ctx, mock.MatchedBy(func(p database.CreateAppointmentParams) bool {
+			return p.SeriesID == pgtype.Int4{Int32: 9, Valid: true} && p.VisitTimestamp.Time.Equal(visit)
+		})).Return(database.Appointment{ID: int32(i + 1)}, nil).Once()
+	}
+
+	series, appointments, err := repo.CreateSeries(ctx, 1, 2, repositories.CreateAppointmentSeriesParams{
+		DoctorID: 5,
+		Rule:     recurrence.Rule{Frequency: recurrence.Weekly, Count: 3},
+		Start:    start,
+		Location: time.UTC,
+		Duration: 30 * time.Minute,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(9), series.ID)
+	assert.Len(t, appointments, 3)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestAppointmentRepository_CreateSeries_StopsOnOverlap(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	start := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+
+	mockQueries.On("CreateAppointmentSeries", ctx, mock.Anything).Return(database.AppointmentSeries{ID: 9}, nil)
+	mockQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return([]database.Appointment{}, nil).Once()
+	mockQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return([]database.Appointment{{ID: 40}}, nil).Once()
+	mockQueries.On("CreateAppointment", ctx, mock.Anything).Return(database.Appointment{ID: 1}, nil).Once()
+
+	_, _, err := repo.CreateSeries(ctx, 1, 2, repositories.CreateAppointmentSeriesParams{
+		DoctorID: 5,
+		Rule:     recurrence.Rule{Frequency: recurrence.Daily, Count: 3},
+		Start:    start,
+	})
+
+	assert.ErrorIs(t, err, repositories.ErrAppointmentOverlap)
+	mockQueries.AssertNumberOfCalls(t, "CreateAppointment", 1)
+}
+
+func TestAppointmentRepository_PlanSeriesReschedule_Following(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2025, time.March, d, 9, 0, 0, 0, time.UTC) }
+	occurrences := []database.Appointment{
+		seriesAppointment(1, day(3), "completed"),
+		seriesAppointment(2, day(10), "scheduled"),
+		seriesAppointment(3, day(17), "cancelled"),
+		seriesAppointment(4, day(24), "scheduled"),
+	}
+
+	mockQueries.On("GetAppointmentByID", ctx, int32(2)).Return(occurrences[1], nil)
+	mockQueries.On("GetAppointmentsBySeries", ctx, pgtype.Int4{Int32: 9, Valid: true}).Return(occurrences, nil)
+	mockQueries.On("GetAppointmentSeries", ctx, int32(9)).Return(database.AppointmentSeries{ID: 9, Timezone: "UTC"}, nil)
+
+	moves, err := repo.PlanSeriesReschedule(ctx, 2, repositories.SeriesScopeFollowing, time.Date(2025, time.March, 11, 10, 0, 0, 0, time.UTC))
+
+	require.NoError(t, err)
+	require.Len(t, moves, 2)
+	// moving later, the last occurrence goes first
+	assert.Equal(t, int32(4), moves[0].Appointment.ID)
+	assert.Equal(t, time.Date(2025, time.March, 25, 10, 0, 0, 0, time.UTC), moves[0].VisitTimestamp)
+	assert.Equal(t, int32(2), moves[1].Appointment.ID)
+	assert.Equal(t, time.Date(2025, time.March, 11, 10, 0, 0, 0, time.UTC), moves[1].VisitTimestamp)
+}
+
+func TestAppointmentRepository_CancelSeries_All(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2025, time.March, d, 9, 0, 0, 0, time.UTC) }
+	occurrences := []database.Appointment{
+		seriesAppointment(1, day(3), "completed"),
+		seriesAppointment(2, day(10), "scheduled"),
+		seriesAppointment(3, day(17), "scheduled"),
+	}
+	reason := "course finished early"
+
+	mockQueries.On("GetAppointmentByID", ctx, int32(2)).Return(occurrences[1], nil)
+	mockQueries.On("GetAppointmentsBySeries", ctx, pgtype.Int4{Int32: 9, Valid: true}).Return(occurrences, nil)
+	mockQueries.On("GetAppointmentByID", ctx, int32(3)).Return(occurrences[2], nil)
+	mockQueries.On("UpdateAppointmentStatus", ctx, mock.MatchedBy(func(p database.UpdateAppointmentStatusParams) bool {
+		return p.ToStatus == "cancelled" && (p.ID == 2 || p.ID == 3)
+	})).Return(database.Appointment{Status: "cancelled"}, nil).Twice()
+	mockQueries.On("CreateAppointmentStatusHistory", ctx, mock.Anything).Return(database.AppointmentStatusHistory{}, nil).Twice()
+
+	cancelled, err := repo.CancelSeries(ctx, 2, repositories.SeriesScopeAll, 1, &reason)
+
+	assert.NoError(t, err)
+	assert.Len(t, cancelled, 2)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestAppointmentRepository_CancelSeries_InvalidScope(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+
+	_, err := repo.CancelSeries(context.Background(), 2, "some", 1, nil)
+
+	assert.ErrorIs(t, err, repositories.ErrInvalidSeriesScope)
+}
@@ -0,0 +1,135 @@
+package repositories_test
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCanTransition(t *testing.T) {
+	allowed := []struct{ from, to repositories.AppointmentStatus }{
+		{repositories.AppointmentScheduled, repositories.AppointmentCheckedIn},
+		{repositories.AppointmentScheduled, repositories.AppointmentCancelled},
+		{repositories.AppointmentScheduled, repositories.AppointmentNoShow},
+		{repositories.AppointmentCheckedIn, repositories.AppointmentInConsultation},
+		{repositories.AppointmentCheckedIn, repositories.AppointmentCancelled},
+		{repositories.AppointmentInConsultation, repositories.AppointmentCompleted},
+	}
+	for _, tt := range allowed {
+		assert.True(t, repositories.CanTransition(tt.from, tt.to), "%s -> %s should be allowed", tt.from, tt.to)
+	}
+
+	rejected := []struct{ from, to repositories.AppointmentStatus }{
+		{repositories.AppointmentScheduled, repositories.AppointmentCompleted},
+		{repositories.AppointmentScheduled, repositories.AppointmentInConsultation},
+		{repositories.AppointmentCheckedIn, repositories.AppointmentNoShow},
+		{repositories.AppointmentInConsultation, repositories.AppointmentCancelled},
+		{repositories.AppointmentCompleted, repositories.AppointmentCancelled},
+		{repositories.AppointmentCancelled, repositories.AppointmentScheduled},
+		{repositories.AppointmentNoShow, repositories.AppointmentCheckedIn},
+	}
+	for _, tt := range rejected {
+		assert.False(t, repositories.CanTransition(tt.from, tt.to), "%s -> %s should be rejected", tt.from, tt.to)
+	}
+}
+
+func TestAppointmentStatus_IsFinal(t *testing.T) {
+	assert.False(t, repositories.AppointmentScheduled.IsFinal())
+	assert.True(t, repositories.AppointmentCompleted.IsFinal())
+	assert.True(t, repositories.AppointmentCancelled.IsFinal())
+	assert.True(t, repositories.AppointmentNoShow.IsFinal())
+}
+
+func TestAppointmentRepository_Transition(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	current := database.Appointment{ID: 1, Status: "scheduled"}
+	updated := database.Appointment{ID: 1, Status: "checked_in"}
+
+	mockQueries.On("GetAppointmentByID", ctx, int32(1)).Return(current, nil)
+	mockQueries.On("UpdateAppointmentStatus", ctx, database.UpdateAppointmentStatusParams{
+		ID:         1,
+		FromStatus: "scheduled",
+		ToStatus:   "checked_in",
+		ChangedBy:  pgtype.Int4{Int32: 7, Valid: true},
+	}).Return(updated, nil)
+	mockQueries.On("CreateAppointmentStatusHistory", ctx, database.CreateAppointmentStatusHistoryParams{
+		AppointmentID: 1,
+		FromStatus:    "scheduled",
+		ToStatus:      "checked_in",
+		ChangedBy:     pgtype.Int4{Int32: 7, Valid: true},
+	}).Return(database.AppointmentStatusHistory{ID: 1}, nil)
+
+	result, err := repo.Transition(ctx, 1, repositories.TransitionAppointmentParams{
+		ActorID: 7,
+		To:      repositories.AppointmentCheckedIn,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, updated, result)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestAppointmentRepository_Transition_CancelStoresReason(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	reason := "patient called in sick"
+
+	mockQueries.On("GetAppointmentByID", ctx, int32(1)).Return(database.Appointment{ID: 1, Status: "scheduled"}, nil)
+	mockQueries.On("UpdateAppointmentStatus", ctx, mock.MatchedBy(func(p database.UpdateAppointmentStatusParams) bool {
+		return p.ToStatus == "cancelled" && p.CancelReason == pgtype.Text{String: reason, Valid: true}
+	})).Return(database.Appointment{ID: 1, Status: "cancelled"}, nil)
+	mockQueries.On("CreateAppointmentStatusHistory", ctx, mock.MatchedBy(func(p database.CreateAppointmentStatusHistoryParams) bool {
+		return p.Reason == pgtype.Text{String: reason, Valid: true}
+	})).Return(database.AppointmentStatusHistory{ID: 1}, nil)
+
+	_, err := repo.Transition(ctx, 1, repositories.TransitionAppointmentParams{
+		ActorID: 7,
+		To:      repositories.AppointmentCancelled,
+		Reason:  &reason,
+	})
+
+	assert.NoError(t, err)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestAppointmentRepository_Transition_Invalid(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("GetAppointmentByID", ctx, int32(1)).Return(database.Appointment{ID: 1, Status: "completed"}, nil)
+
+	_, err := repo.Transition(ctx, 1, repositories.TransitionAppointmentParams{
+		ActorID: 7,
+		To:      repositories.AppointmentCheckedIn,
+	})
+
+	assert.ErrorIs(t, err, repositories.ErrInvalidStatusTransition)
+	mockQueries.AssertNotCalled(t, "UpdateAppointmentStatus", mock.Anything, mock.Anything)
+}
+
+func TestAppointmentRepository_Transition_ConcurrentChange(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("GetAppointmentByID", ctx, int32(1)).Return(database.Appointment{ID: 1, Status: "scheduled"}, nil)
+	mockQueries.On("UpdateAppointmentStatus", ctx, mock.Anything).Return(database.Appointment{}, pgx.ErrNoRows)
+
+	_, err := repo.Transition(ctx, 1, repositories.TransitionAppointmentParams{
+		ActorID: 7,
+		To:      repositories.AppointmentNoShow,
+	})
+
+	assert.ErrorIs(t, err, repositories.ErrInvalidStatusTransition)
+	mockQueries.AssertNotCalled(t, "CreateAppointmentStatusHistory", mock.Anything, mock.Anything)
+}
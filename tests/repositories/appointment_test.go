@@ -3,10 +3,13 @@ package repositories_test
 import (
 	"context"
 	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
 	"patient-appointment-demo-go/internal/repositories"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -16,8 +19,8 @@ type MockAppointmentQueries struct {
 	mock.Mock
 }
 
-func (m *MockAppointmentQueries) GetAllAppointments(ctx context.Context) ([]database.Appointment, error) {
-	args := m.Called(ctx)
+func (m *MockAppointmentQueries) GetAllAppointments(ctx context.Context, params database.GetAllAppointmentsParams) ([]database.Appointment, error) {
+	args := m.Called(ctx, params)
 	return args.Get(0).([]database.Appointment), args.Error(1)
 }
 
@@ -31,6 +34,51 @@ func (m *MockAppointmentQueries) GetAppointmentsByPatient(ctx context.Context, p
 	return args.Get(0).([]database.Appointment), args.Error(1)
 }
 
+func (m *MockAppointmentQueries) GetAppointmentsByDoctor(ctx context.Context, params database.GetAppointmentsByDoctorParams) ([]database.Appointment, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) RescheduleAppointment(ctx context.Context, params database.RescheduleAppointmentParams) (database.Appointment, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) CreateAppointmentRescheduleHistory(ctx context.Context, params database.CreateAppointmentRescheduleHistoryParams) (database.AppointmentRescheduleHistory, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.AppointmentRescheduleHistory), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) GetAppointmentRescheduleHistory(ctx context.Context, appointmentID int32) ([]database.AppointmentRescheduleHistory, error) {
+	args := m.Called(ctx, appointmentID)
+	return args.Get(0).([]database.AppointmentRescheduleHistory), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) GetAppointmentsBySeries(ctx context.Context, seriesID pgtype.Int4) ([]database.Appointment, error) {
+	args := m.Called(ctx, seriesID)
+	return args.Get(0).([]database.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) CreateAppointmentSeries(ctx context.Context, params database.CreateAppointmentSeriesParams) (database.AppointmentSeries, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.AppointmentSeries), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) GetAppointmentSeries(ctx context.Context, id int32) (database.AppointmentSeries, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.AppointmentSeries), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) GetOverlappingAppointments(ctx context.Context, params database.GetOverlappingAppointmentsParams) ([]database.Appointment, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) GetActiveAppointmentsByDoctorBetween(ctx context.Context, params database.GetActiveAppointmentsByDoctorBetweenParams) ([]database.Appointment, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.Appointment), args.Error(1)
+}
+
 func (m *MockAppointmentQueries) GetAppointmentByID(ctx context.Context, id int32) (database.Appointment, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(database.Appointment), args.Error(1)
@@ -46,29 +94,128 @@ func (m *MockAppointmentQueries) UpdateAppointment(ctx context.Context, params d
 	return args.Get(0).(database.Appointment), args.Error(1)
 }
 
+func (m *MockAppointmentQueries) GetAppointmentByIDForUpdate(ctx context.Context, id int32) (database.Appointment, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) GetAppointmentByIDWithDeleted(ctx context.Context, id int32) (database.Appointment, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Appointment), args.Error(1)
+}
+
 func (m *MockAppointmentQueries) DeleteAppointment(ctx context.Context, id int32) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *MockAppointmentQueries) RestoreAppointment(ctx context.Context, id int32) (database.Appointment, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) UpdateAppointmentStatus(ctx context.Context, params database.UpdateAppointmentStatusParams) (database.Appointment, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) CreateAppointmentStatusHistory(ctx context.Context, params database.CreateAppointmentStatusHistoryParams) (database.AppointmentStatusHistory, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.AppointmentStatusHistory), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) GetAppointmentStatusHistory(ctx context.Context, appointmentId int32) ([]database.AppointmentStatusHistory, error) {
+	args := m.Called(ctx, appointmentId)
+	return args.Get(0).([]database.AppointmentStatusHistory), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) GetAppointmentsForReencryption(ctx context.Context, params database.GetAppointmentsForReencryptionParams) ([]database.Appointment, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentQueries) UpdateAppointmentEncryption(ctx context.Context, params database.UpdateAppointmentEncryptionParams) error {
+	args := m.Called(ctx, params)
+	return args.Error(0)
+}
+
 func TestAppointmentRepository_GetAll(t *testing.T) {
 	mockQueries := new(MockAppointmentQueries)
-	repo := repositories.NewAppointmentRepository(mockQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 	appointments := []database.Appointment{{ID: 1}}
+	doctorId := int32(2)
+	params := database.GetAllAppointmentsParams{
+		DoctorID:   pgtype.Int4{Int32: 2, Valid: true},
+		Statuses:   []string{"scheduled", "checked_in"},
+		SortBy:     "visit_timestamp",
+		SortDesc:   true,
+		MaxResults: 21,
+	}
 
-	mockQueries.On("GetAllAppointments", ctx).Return(appointments, nil)
+	mockQueries.On("GetAllAppointments", ctx, params).Return(appointments, nil)
 
-	result, err := repo.GetAll(ctx)
+	result, err := repo.GetAll(ctx, repositories.GetAppointmentsOption{
+		DoctorID: &doctorId,
+		Statuses: []string{"scheduled", "checked_in"},
+		Page:     pagination.Params{Desc: true},
+	})
 
 	assert.NoError(t, err)
-	assert.Equal(t, appointments, result)
+	assert.Equal(t, appointments, result.Items)
+	assert.Empty(t, result.NextCursor)
 	mockQueries.AssertExpectations(t)
 }
 
+func TestAppointmentRepository_GetAll_NextPage(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	visit := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	appointments := []database.Appointment{
+		{ID: 1, VisitTimestamp: pgtype.Timestamptz{Time: visit, Valid: true}},
+		{ID: 2, VisitTimestamp: pgtype.Timestamptz{Time: visit.Add(time.Hour), Valid: true}},
+	}
+
+	mockQueries.On("GetAllAppointments", ctx, mock.MatchedBy(func(arg database.GetAllAppointmentsParams) bool {
+		return !arg.HasCursor && arg.MaxResults == 2
+	})).Return(appointments, nil)
+
+	first, err := repo.GetAll(ctx, repositories.GetAppointmentsOption{Page: pagination.Params{Limit: 1}})
+
+	assert.NoError(t, err)
+	assert.Len(t, first.Items, 1)
+	assert.NotEmpty(t, first.NextCursor)
+
+	// the next page continues after the last appointment of the first
+	mockQueries.On("GetAllAppointments", ctx, mock.MatchedBy(func(arg database.GetAllAppointmentsParams) bool {
+		return arg.HasCursor && arg.CursorID == 1 && arg.CursorTime.Time.Equal(visit)
+	})).Return(appointments[1:], nil)
+
+	second, err := repo.GetAll(ctx, repositories.GetAppointmentsOption{Page: pagination.Params{Limit: 1, Cursor: first.NextCursor}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, appointments[1:], second.Items)
+	assert.Empty(t, second.NextCursor)
+}
+
+func TestAppointmentRepository_GetAll_CursorOfAnotherSort(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	cursor := pagination.Cursor{SortBy: "visit_timestamp", Key: "2026-10-19T09:30:00Z", ID: 1}.Encode()
+
+	_, err := repo.GetAll(ctx, repositories.GetAppointmentsOption{
+		Page: pagination.Params{Cursor: cursor, SortBy: "created_at"},
+	})
+
+	assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+	mockQueries.AssertNotCalled(t, "GetAllAppointments", mock.Anything, mock.Anything)
+}
+
 func TestAppointmentRepository_GetByDate(t *testing.T) {
 	mockQueries := new(MockAppointmentQueries)
-	repo := repositories.NewAppointmentRepository(mockQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 	date := time.Now()
 	appointments := []database.Appointment{{ID: 1}}
@@ -82,9 +229,28 @@ func TestAppointmentRepository_GetByDate(t *testing.T) {
 	mockQueries.AssertExpectations(t)
 }
 
+func TestAppointmentRepository_GetByDoctor(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	date := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	appointments := []database.Appointment{{ID: 1}}
+
+	mockQueries.On("GetAppointmentsByDoctor", ctx, database.GetAppointmentsByDoctorParams{
+		DoctorID:  pgtype.Int4{Int32: 4, Valid: true},
+		VisitDate: pgtype.Date{Time: date, Valid: true},
+	}).Return(appointments, nil)
+
+	result, err := repo.GetByDoctor(ctx, 4, &date)
+
+	assert.NoError(t, err)
+	assert.Equal(t, appointments, result)
+	mockQueries.AssertExpectations(t)
+}
+
 func TestAppointmentRepository_Get(t *testing.T) {
 	mockQueries := new(MockAppointmentQueries)
-	repo := repositories.NewAppointmentRepository(mockQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 	appointment := database.Appointment{ID: 1}
 
@@ -97,9 +263,29 @@ func TestAppointmentRepository_Get(t *testing.T) {
 	mockQueries.AssertExpectations(t)
 }
 
+func TestAppointmentRepository_GetForUpdate(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	appointment := database.Appointment{
+		ID:          1,
+		DoctorID:    pgtype.Int4{Int32: 2, Valid: true},
+		DoctorNotes: pgtype.Text{String: "enc:appointments.doctor_notes:Follow up", Valid: true},
+	}
+
+	mockQueries.On("GetAppointmentByIDForUpdate", ctx, int32(1)).Return(appointment, nil)
+
+	result, err := repo.GetForUpdate(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), result.DoctorID.Int32)
+	assert.Equal(t, "Follow up", result.DoctorNotes.String)
+	mockQueries.AssertExpectations(t)
+}
+
 func TestAppointmentRepository_Create(t *testing.T) {
 	mockQueries := new(MockAppointmentQueries)
-	repo := repositories.NewAppointmentRepository(mockQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 	appointment := database.Appointment{ID: 1}
 	params := repositories.CreateAppointmentParams{
@@ -107,6 +293,7 @@ func TestAppointmentRepository_Create(t *testing.T) {
 		PatientNotes:   nil,
 	}
 
+	mockQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return([]database.Appointment{}, nil)
 	mockQueries.On("CreateAppointment", ctx, mock.Anything).Return(appointment, nil)
 
 	result, err := repo.Create(ctx, 1, 2, params)
@@ -116,9 +303,30 @@ func TestAppointmentRepository_Create(t *testing.T) {
 	mockQueries.AssertExpectations(t)
 }
 
+func TestAppointmentRepository_Create_SetsDoctorAndBooker(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return([]database.Appointment{}, nil)
+	mockQueries.On("CreateAppointment", ctx, mock.MatchedBy(func(p database.CreateAppointmentParams) bool {
+		return p.UserID == pgtype.Int4{Int32: 1, Valid: true} &&
+			p.DoctorID == pgtype.Int4{Int32: 5, Valid: true} &&
+			p.PatientID == 2
+	})).Return(database.Appointment{ID: 1}, nil)
+
+	_, err := repo.Create(ctx, 1, 2, repositories.CreateAppointmentParams{
+		DoctorID:       5,
+		VisitTimestamp: time.Now(),
+	})
+
+	assert.NoError(t, err)
+	mockQueries.AssertExpectations(t)
+}
+
 func TestAppointmentRepository_Update(t *testing.T) {
 	mockQueries := new(MockAppointmentQueries)
-	repo := repositories.NewAppointmentRepository(mockQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 	appointment := database.Appointment{ID: 1}
 	params := repositories.UpdateAppointmentParams{
@@ -137,7 +345,7 @@ func TestAppointmentRepository_Update(t *testing.T) {
 
 func TestAppointmentRepository_Delete(t *testing.T) {
 	mockQueries := new(MockAppointmentQueries)
-	repo := repositories.NewAppointmentRepository(mockQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 
 	mockQueries.On("DeleteAppointment", ctx, int32(1)).Return(nil)
@@ -148,3 +356,151 @@ func TestAppointmentRepository_Delete(t *testing.T) {
 	mockQueries.AssertExpectations(t)
 }
 
+func TestAppointmentRepository_GetAll_IncludeDeleted(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	appointments := []database.Appointment{{ID: 1, DeletedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}}}
+	params := database.GetAllAppointmentsParams{
+		IncludeDeleted: true,
+		SortBy:         "visit_timestamp",
+		MaxResults:     21,
+	}
+
+	mockQueries.On("GetAllAppointments", ctx, params).Return(appointments, nil)
+
+	result, err := repo.GetAll(ctx, repositories.GetAppointmentsOption{IncludeDeleted: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, appointments, result.Items)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestAppointmentRepository_Restore(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	appointment := database.Appointment{ID: 1, PatientID: 2}
+
+	mockQueries.On("RestoreAppointment", ctx, int32(1)).Return(appointment, nil)
+
+	result, err := repo.Restore(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, appointment, result)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestAppointmentRepository_Restore_PatientDeleted(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	deleted := database.Appointment{ID: 1, PatientID: 2, DeletedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}}
+
+	mockQueries.On("RestoreAppointment", ctx, int32(1)).Return(database.Appointment{}, pgx.ErrNoRows)
+	mockQueries.On("GetAppointmentByIDWithDeleted", ctx, int32(1)).Return(deleted, nil)
+
+	_, err := repo.Restore(ctx, 1)
+
+	assert.ErrorIs(t, err, repositories.ErrAppointmentPatientDeleted)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestAppointmentRepository_Restore_Missing(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("RestoreAppointment", ctx, int32(1)).Return(database.Appointment{}, pgx.ErrNoRows)
+	mockQueries.On("GetAppointmentByIDWithDeleted", ctx, int32(1)).Return(database.Appointment{}, pgx.ErrNoRows)
+
+	_, err := repo.Restore(ctx, 1)
+
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	mockQueries.AssertExpectations(t)
+}
+
+
+func TestAppointmentRepository_Create_SequenceConflict(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	conflict := &pgconn.PgError{Code: "23505", ConstraintName: "appointments_visit_date_appointment_sequence_key"}
+
+	mockQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return([]database.Appointment{}, nil)
+	mockQueries.On("CreateAppointment", ctx, mock.Anything).Return(database.Appointment{}, conflict)
+
+	_, err := repo.Create(ctx, 1, 2, repositories.CreateAppointmentParams{VisitTimestamp: time.Now()})
+
+	// not retried, the insert aborted the transaction it ran in
+	assert.ErrorIs(t, err, repositories.ErrAppointmentSequenceConflict)
+	mockQueries.AssertNumberOfCalls(t, "CreateAppointment", 1)
+}
+
+func TestAppointmentRepository_Create_DefaultsDuration(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	visit := time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+
+	mockQueries.On("GetOverlappingAppointments", ctx, database.GetOverlappingAppointmentsParams{
+		DoctorID:  pgtype.Int4{Int32: 5, Valid: true},
+		PatientID: 2,
+		FromTime:  pgtype.Timestamptz{Time: visit, Valid: true},
+		ToTime:    pgtype.Timestamptz{Time: visit.Add(15 * time.Minute), Valid: true},
+	}).Return([]database.Appointment{}, nil)
+	mockQueries.On("CreateAppointment", ctx, mock.MatchedBy(func(p database.CreateAppointmentParams) bool {
+		return p.DurationMinutes == 15
+	})).Return(database.Appointment{ID: 1}, nil)
+
+	_, err := repo.Create(ctx, 1, 2, repositories.CreateAppointmentParams{
+		DoctorID:       5,
+		VisitTimestamp: visit,
+	})
+
+	assert.NoError(t, err)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestAppointmentRepository_Create_ReturnsOverlaps(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	conflicts := []database.Appointment{{ID: 7}, {ID: 8}}
+
+	mockQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return(conflicts, nil)
+
+	_, err := repo.Create(ctx, 1, 2, repositories.CreateAppointmentParams{
+		DoctorID:       5,
+		VisitTimestamp: time.Now(),
+		Duration:       30 * time.Minute,
+	})
+
+	var overlap repositories.AppointmentOverlapError
+	assert.ErrorIs(t, err, repositories.ErrAppointmentOverlap)
+	assert.ErrorAs(t, err, &overlap)
+	assert.Equal(t, conflicts, overlap.Conflicts)
+	mockQueries.AssertNotCalled(t, "CreateAppointment", mock.Anything, mock.Anything)
+}
+
+func TestAppointmentRepository_Create_MapsExclusionViolation(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	violation := &pgconn.PgError{Code: "23P01", ConstraintName: "appointments_doctor_overlap_excl"}
+	conflicts := []database.Appointment{{ID: 7}}
+
+	// a concurrent booking lands between the check and the insert
+	mockQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return([]database.Appointment{}, nil).Once()
+	mockQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return(conflicts, nil).Once()
+	mockQueries.On("CreateAppointment", ctx, mock.Anything).Return(database.Appointment{}, violation)
+
+	_, err := repo.Create(ctx, 1, 2, repositories.CreateAppointmentParams{DoctorID: 5, VisitTimestamp: time.Now()})
+
+	var overlap repositories.AppointmentOverlapError
+	assert.ErrorIs(t, err, repositories.ErrAppointmentOverlap)
+	assert.ErrorIs(t, err, violation)
+	assert.ErrorAs(t, err, &overlap)
+	assert.Equal(t, conflicts, overlap.Conflicts)
+	mockQueries.AssertNumberOfCalls(t, "CreateAppointment", 1)
+}
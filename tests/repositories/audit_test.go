@@ -0,0 +1,197 @@
+package repositories_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/tests/testdb"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAuditQueries struct {
+	mock.Mock
+}
+
+func (m *MockAuditQueries) SetAuditSource(ctx context.Context, arg database.SetAuditSourceParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func (m *MockAuditQueries) CreateAuditEntries(ctx context.Context, arg database.CreateAuditEntriesParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func (m *MockAuditQueries) GetAuditEntries(ctx context.Context, arg database.GetAuditEntriesParams) ([]database.AuditLog, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.AuditLog), args.Error(1)
+}
+
+func (m *MockAuditQueries) GetAuditChain(ctx context.Context, arg database.GetAuditChainParams) ([]database.AuditLog, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.AuditLog), args.Error(1)
+}
+
+func (m *MockAuditQueries) ChainAuditEntries(ctx context.Context, maxResults int32) (int32, error) {
+	args := m.Called(ctx, maxResults)
+	return args.Get(0).(int32), args.Error(1)
+}
+
+func TestAuditRepository_RecordAccess(t *testing.T) {
+	queries := new(MockAuditQueries)
+	repo := repositories.NewAuditRepository(queries)
+	ctx := repositories.WithAuditSource(context.Background(), repositories.AuditSource{
+		ActorID:   3,
+		IP:        "192.0.2.1",
+		RequestID: "abc",
+	})
+
+	queries.On("CreateAuditEntries", ctx, database.CreateAuditEntriesParams{
+		ActorID:   pgtype.Int4{Int32: 3, Valid: true},
+		Action:    "list",
+		Entity:    "patient",
+		Ip:        pgtype.Text{String: "192.0.2.1", Valid: true},
+		RequestID: pgtype.Text{String: "abc", Valid: true},
+		EntityIds: []int32{1, 2},
+	}).Return(nil)
+
+	err := repo.RecordAccess(ctx, repositories.AuditActionList, repositories.AuditEntityPatient, 1, 2)
+
+	assert.NoError(t, err)
+	queries.AssertExpectations(t)
+}
+
+func TestAuditRepository_RecordAccess_Nothing(t *testing.T) {
+	queries := new(MockAuditQueries)
+	repo := repositories.NewAuditRepository(queries)
+
+	err := repo.RecordAccess(context.Background(), repositories.AuditActionList, repositories.AuditEntityPatient)
+
+	assert.NoError(t, err)
+	queries.AssertNotCalled(t, "CreateAuditEntries", mock.Anything, mock.Anything)
+}
+
+func TestAuditRepository_GetAll(t *testing.T) {
+	queries := new(MockAuditQueries)
+	repo := repositories.NewAuditRepository(queries)
+	ctx := context.Background()
+	actorId := int32(3)
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	entries := []database.AuditLog{{ID: 9}, {ID: 8}, {ID: 7}}
+
+	queries.On("GetAuditEntries", ctx, database.GetAuditEntriesParams{
+		Entity:     pgtype.Text{String: "patient", Valid: true},
+		ActorID:    pgtype.Int4{Int32: 3, Valid: true},
+		FromTime:   pgtype.Timestamptz{Time: from, Valid: true},
+		MaxResults: 3,
+	}).Return(entries, nil)
+
+	page, err := repo.GetAll(ctx, repositories.GetAuditEntriesOption{
+		Entity:  repositories.AuditEntityPatient,
+		ActorID: &actorId,
+		From:    &from,
+		Page:    pagination.Params{Limit: 2},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, entries[:2], page.Items)
+	require.NotEmpty(t, page.NextCursor)
+
+	queries.On("GetAuditEntries", ctx, database.GetAuditEntriesParams{
+		HasCursor:  true,
+		CursorID:   8,
+		MaxResults: 3,
+	}).Return(entries[2:], nil)
+
+	next, err := repo.GetAll(ctx, repositories.GetAuditEntriesOption{
+		Page: pagination.Params{Limit: 2, Cursor: page.NextCursor},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, entries[2:], next.Items)
+	assert.Empty(t, next.NextCursor)
+	queries.AssertExpectations(t)
+}
+
+func TestAuditRepository_GetChain(t *testing.T) {
+	queries := new(MockAuditQueries)
+	repo := repositories.NewAuditRepository(queries)
+	ctx := context.Background()
+
+	entries := []database.AuditLog{{ID: 11}, {ID: 12}}
+	queries.On("GetAuditChain", ctx, database.GetAuditChainParams{AfterID: 10, MaxResults: 2}).Return(entries, nil)
+
+	res, err := repo.GetChain(ctx, 10, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, entries, res)
+	queries.AssertExpectations(t)
+}
+
+func TestAuditRepository_ChainPending(t *testing.T) {
+	queries := new(MockAuditQueries)
+	repo := repositories.NewAuditRepository(queries)
+	ctx := context.Background()
+
+	queries.On("ChainAuditEntries", ctx, int32(500)).Return(int32(3), nil)
+
+	n, err := repo.ChainPending(ctx, 500)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), n)
+	queries.AssertExpectations(t)
+}
+
+// Patients' health information is recorded as changed, never copied.
+func TestAuditLog_RedactsHealthInformation(t *testing.T) {
+	pool := testdb.Open(t)
+	queries := database.New(pool)
+	ctx := context.Background()
+	email := fmt.Sprintf("audit-redact-%d@example.com", time.Now().UnixNano())
+
+	patient, err := repositories.NewPatientRepository(queries, testdb.Keyring(t, pool)).Create(ctx, repositories.CreatePatientParams{
+		Name:  "Audit Redact",
+		Email: email,
+		Phone: "555 0100",
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		pool.Exec(context.Background(), "DELETE FROM patients WHERE id = $1", patient.ID)
+	})
+
+	// like a row written before encryption
+	_, err = pool.Exec(ctx, "UPDATE patients SET address = '1 Plain Street' WHERE id = $1", patient.ID)
+	require.NoError(t, err)
+
+	rows, err := pool.Query(ctx, "SELECT action, changes::text FROM audit_log WHERE entity = 'patient' AND entity_id = $1 ORDER BY id", patient.ID)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	changes := map[string]map[string]json.RawMessage{}
+	for rows.Next() {
+		var action, text string
+		require.NoError(t, rows.Scan(&action, &text))
+		assert.NotContains(t, text, email)
+		assert.NotContains(t, text, "Plain Street")
+
+		entry := map[string]json.RawMessage{}
+		require.NoError(t, json.Unmarshal([]byte(text), &entry))
+		changes[action] = entry
+	}
+	require.NoError(t, rows.Err())
+
+	assert.JSONEq(t, `{"changed": true}`, string(changes["create"]["email"]))
+	assert.JSONEq(t, `{"changed": true}`, string(changes["create"]["phone"]))
+	assert.JSONEq(t, `{"from": null, "to": "Audit Redact"}`, string(changes["create"]["name"]))
+	assert.JSONEq(t, `{"changed": true}`, string(changes["update"]["address"]))
+}
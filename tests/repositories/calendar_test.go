@@ -0,0 +1,107 @@
+package repositories_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCalendarQueries struct {
+	mock.Mock
+}
+
+func (m *MockCalendarQueries) CreateCalendarFeedToken(ctx context.Context, arg database.CreateCalendarFeedTokenParams) (database.CalendarFeedToken, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.CalendarFeedToken), args.Error(1)
+}
+
+func (m *MockCalendarQueries) GetCalendarFeedTokens(ctx context.Context, doctorID int32) ([]database.CalendarFeedToken, error) {
+	args := m.Called(ctx, doctorID)
+	return args.Get(0).([]database.CalendarFeedToken), args.Error(1)
+}
+
+func (m *MockCalendarQueries) RevokeCalendarFeedToken(ctx context.Context, arg database.RevokeCalendarFeedTokenParams) (database.CalendarFeedToken, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.CalendarFeedToken), args.Error(1)
+}
+
+func (m *MockCalendarQueries) UseCalendarFeedToken(ctx context.Context, tokenHash string) (database.CalendarFeedToken, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(database.CalendarFeedToken), args.Error(1)
+}
+
+func (m *MockCalendarQueries) GetDoctorCalendar(ctx context.Context, arg database.GetDoctorCalendarParams) ([]database.GetDoctorCalendarRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.GetDoctorCalendarRow), args.Error(1)
+}
+
+func (m *MockCalendarQueries) GetAppointmentInvite(ctx context.Context, id int32) (database.GetAppointmentInviteRow, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.GetAppointmentInviteRow), args.Error(1)
+}
+
+func TestCalendarRepository_CreateFeedToken_StoresHash(t *testing.T) {
+	queries := new(MockCalendarQueries)
+	repo := repositories.NewCalendarRepository(queries, fakeCipher{})
+	ctx := context.Background()
+
+	var stored database.CreateCalendarFeedTokenParams
+	queries.On("CreateCalendarFeedToken", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(database.CreateCalendarFeedTokenParams)
+	}).Return(database.CalendarFeedToken{ID: 1, DoctorID: 2}, nil)
+
+	_, token, err := repo.CreateFeedToken(ctx, 2, 9)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "cal_"))
+	assert.Equal(t, int32(2), stored.DoctorID)
+	assert.Equal(t, int32(9), stored.CreatedBy.Int32)
+	sum := sha256.Sum256([]byte(token))
+	assert.Equal(t, hex.EncodeToString(sum[:]), stored.TokenHash)
+	assert.NotContains(t, stored.TokenHash, token)
+	queries.AssertExpectations(t)
+}
+
+func TestCalendarRepository_UseFeedToken_LooksUpHash(t *testing.T) {
+	queries := new(MockCalendarQueries)
+	repo := repositories.NewCalendarRepository(queries, fakeCipher{})
+	ctx := context.Background()
+
+	sum := sha256.Sum256([]byte("cal_abc"))
+	queries.On("UseCalendarFeedToken", ctx, hex.EncodeToString(sum[:])).Return(database.CalendarFeedToken{ID: 1, DoctorID: 2}, nil)
+
+	feedToken, err := repo.UseFeedToken(ctx, "cal_abc")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), feedToken.DoctorID)
+	queries.AssertExpectations(t)
+}
+
+func TestCalendarRepository_GetDoctorCalendar(t *testing.T) {
+	queries := new(MockCalendarQueries)
+	repo := repositories.NewCalendarRepository(queries, fakeCipher{})
+	ctx := context.Background()
+	from := time.Date(2026, 7, 20, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	queries.On("GetDoctorCalendar", ctx, mock.MatchedBy(func(arg database.GetDoctorCalendarParams) bool {
+		return arg.DoctorID.Int32 == 2 &&
+			arg.VisitFrom.Time.Equal(from) &&
+			arg.VisitTo.Time.Equal(to) &&
+			arg.MaxResults > 0
+	})).Return([]database.GetDoctorCalendarRow{{ID: 5}}, nil)
+
+	rows, err := repo.GetDoctorCalendar(ctx, 2, from, to)
+
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	queries.AssertExpectations(t)
+}
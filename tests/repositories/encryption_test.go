@@ -0,0 +1,240 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeCipher "encrypts" a value by prefixing it with enc: and its field.
+// Like the keyring, it reads values without the prefix as they are.
+type fakeCipher struct{}
+
+func (fakeCipher) Encrypt(ctx context.Context, field string, plaintext string) (string, error) {
+	return "enc:" + field + ":" + plaintext, nil
+}
+
+func (fakeCipher) Decrypt(ctx context.Context, field string, value string) (string, error) {
+	if !strings.HasPrefix(value, "enc:") {
+		return value, nil
+	}
+
+	plaintext, ok := strings.CutPrefix(value, "enc:"+field+":")
+	if !ok {
+		return "", errors.New("encrypted for another field")
+	}
+
+	return plaintext, nil
+}
+
+func (fakeCipher) BlindIndex(ctx context.Context, field string, value string) ([]byte, error) {
+	return []byte("bidx:" + field + ":" + value), nil
+}
+
+func (fakeCipher) IsCurrent(ctx context.Context, value string) (bool, error) {
+	return strings.HasPrefix(value, "enc:"), nil
+}
+
+func TestPatientRepository_Create_EncryptsContact(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("CreatePatient", ctx, mock.MatchedBy(func(arg database.CreatePatientParams) bool {
+		return arg.Name == "John Doe" &&
+			arg.Phone == pgtype.Text{String: "enc:patients.phone:(555) 01-23", Valid: true} &&
+			arg.Email == "enc:patients.email:John@Example.com" &&
+			!arg.Address.Valid &&
+			string(arg.EmailBidx) == "bidx:patients.email:john@example.com" &&
+			string(arg.PhoneBidx) == "bidx:patients.phone:5550123"
+	})).Return(database.Patient{
+		ID:    1,
+		Name:  "John Doe",
+		Phone: pgtype.Text{String: "enc:patients.phone:(555) 01-23", Valid: true},
+		Email: "enc:patients.email:John@Example.com",
+	}, nil)
+
+	result, err := repo.Create(ctx, repositories.CreatePatientParams{
+		Name:  "John Doe",
+		Phone: "(555) 01-23",
+		Email: "John@Example.com",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "(555) 01-23", result.Phone.String)
+	assert.Equal(t, "John@Example.com", result.Email)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_Get_Decrypts(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("GetPatientByID", ctx, int32(1)).Return(database.Patient{
+		ID:      1,
+		Email:   "enc:patients.email:john@example.com",
+		Address: pgtype.Text{String: "enc:patients.address:123 Street", Valid: true},
+	}, nil)
+
+	result, err := repo.Get(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", result.Email)
+	assert.Equal(t, pgtype.Text{String: "123 Street", Valid: true}, result.Address)
+	assert.False(t, result.Phone.Valid)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_Get_MovedCiphertext(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	// a phone copied into the address column
+	mockQueries.On("GetPatientByID", ctx, int32(1)).Return(database.Patient{
+		ID:      1,
+		Address: pgtype.Text{String: "enc:patients.phone:5550123", Valid: true},
+	}, nil)
+
+	_, err := repo.Get(ctx, 1)
+
+	assert.Error(t, err)
+}
+
+func TestPatientRepository_Search_Email(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("SearchPatients", ctx, mock.MatchedBy(func(arg database.SearchPatientsParams) bool {
+		return string(arg.EmailBidx) == "bidx:patients.email:jon@example.com" && arg.PhoneBidx == nil
+	})).Return([]database.SearchPatientsRow{
+		{ID: 1, Email: "enc:patients.email:Jon@example.com", EmailMatch: true},
+	}, nil)
+
+	result, err := repo.Search(ctx, "Jon@Example.com", 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Jon@example.com", result[0].Email)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_GetMerges_DecryptsDuplicate(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("GetPatientMerges", ctx, int32(7)).Return([]database.PatientMerge{{
+		ID:        1,
+		Duplicate: []byte(`{"id": 3, "email": "enc:patients.email:jon@example.com", "phone": null, "email_bidx": "\\x01"}`),
+	}}, nil)
+
+	merges, err := repo.GetMerges(ctx, 7)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id": 3, "email": "jon@example.com", "phone": null}`, string(merges[0].Duplicate))
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_Reencrypt(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("GetPatientsForReencryption", ctx, database.GetPatientsForReencryptionParams{AfterID: 10, MaxResults: 2}).Return([]database.Patient{
+		{
+			ID:        11,
+			Email:     "enc:patients.email:ann@example.com",
+			EmailBidx: []byte("bidx:patients.email:ann@example.com"),
+		},
+		// written before encryption
+		{
+			ID:      12,
+			Email:   "Bob@example.com",
+			Phone:   pgtype.Text{String: "555-0123", Valid: true},
+			Address: pgtype.Text{String: "1 Main St", Valid: true},
+		},
+	}, nil)
+	mockQueries.On("UpdatePatientEncryption", ctx, database.UpdatePatientEncryptionParams{
+		Phone:     pgtype.Text{String: "enc:patients.phone:555-0123", Valid: true},
+		Email:     "enc:patients.email:Bob@example.com",
+		Address:   pgtype.Text{String: "enc:patients.address:1 Main St", Valid: true},
+		EmailBidx: []byte("bidx:patients.email:bob@example.com"),
+		PhoneBidx: []byte("bidx:patients.phone:5550123"),
+		ID:        12,
+	}).Return(nil)
+
+	lastId, updated, err := repo.Reencrypt(ctx, 10, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(12), lastId)
+	assert.Equal(t, 1, updated)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_Reencrypt_Done(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("GetPatientsForReencryption", ctx, database.GetPatientsForReencryptionParams{AfterID: 12, MaxResults: 2}).Return([]database.Patient{}, nil)
+
+	lastId, updated, err := repo.Reencrypt(ctx, 12, 2)
+
+	assert.NoError(t, err)
+	assert.Zero(t, lastId)
+	assert.Zero(t, updated)
+}
+
+func TestAppointmentRepository_Update_EncryptsNotes(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	notes := "Bring the X-rays"
+
+	mockQueries.On("UpdateAppointment", ctx, database.UpdateAppointmentParams{
+		ID:          1,
+		DoctorNotes: pgtype.Text{String: "enc:appointments.doctor_notes:Bring the X-rays", Valid: true},
+	}).Return(database.Appointment{
+		ID:          1,
+		DoctorNotes: pgtype.Text{String: "enc:appointments.doctor_notes:Bring the X-rays", Valid: true},
+	}, nil)
+
+	result, err := repo.Update(ctx, 1, repositories.UpdateAppointmentParams{DoctorNotes: &notes})
+
+	assert.NoError(t, err)
+	assert.Equal(t, pgtype.Text{String: notes, Valid: true}, result.DoctorNotes)
+	assert.False(t, result.PatientNotes.Valid)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestAppointmentRepository_Reencrypt(t *testing.T) {
+	mockQueries := new(MockAppointmentQueries)
+	repo := repositories.NewAppointmentRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("GetAppointmentsForReencryption", ctx, database.GetAppointmentsForReencryptionParams{AfterID: 0, MaxResults: 100}).Return([]database.Appointment{
+		{ID: 1},
+		{ID: 2, PatientNotes: pgtype.Text{String: "Back pain", Valid: true}},
+		{ID: 3, DoctorNotes: pgtype.Text{String: "enc:appointments.doctor_notes:Follow up", Valid: true}},
+	}, nil)
+	mockQueries.On("UpdateAppointmentEncryption", ctx, database.UpdateAppointmentEncryptionParams{
+		PatientNotes: pgtype.Text{String: "enc:appointments.patient_notes:Back pain", Valid: true},
+		ID:           2,
+	}).Return(nil)
+
+	lastId, updated, err := repo.Reencrypt(ctx, 0, 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), lastId)
+	assert.Equal(t, 1, updated)
+	mockQueries.AssertExpectations(t)
+}
@@ -0,0 +1,115 @@
+package repositories_test
+
+import (
+	"context"
+	"encoding/json"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOutboxQueries struct {
+	mock.Mock
+}
+
+func (m *MockOutboxQueries) CreateOutboxEvent(ctx context.Context, arg database.CreateOutboxEventParams) (database.OutboxEvent, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.OutboxEvent), args.Error(1)
+}
+
+func (m *MockOutboxQueries) GetOutboxEventsForReencryption(ctx context.Context, arg database.GetOutboxEventsForReencryptionParams) ([]database.OutboxEvent, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.OutboxEvent), args.Error(1)
+}
+
+func (m *MockOutboxQueries) UpdateOutboxEventPayload(ctx context.Context, arg database.UpdateOutboxEventPayloadParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func TestOutboxRepository_Enqueue(t *testing.T) {
+	queries := new(MockOutboxQueries)
+	repo := repositories.NewOutboxRepository(queries, fakeCipher{})
+	ctx := context.Background()
+	expected := database.OutboxEvent{ID: 1, EventType: "patient.updated"}
+
+	queries.On("CreateOutboxEvent", ctx, mock.MatchedBy(func(arg database.CreateOutboxEventParams) bool {
+		// stored encrypted, as a JSON string
+		var encrypted string
+		return arg.EventType == "patient.updated" &&
+			json.Unmarshal(arg.Payload, &encrypted) == nil &&
+			encrypted == `enc:outbox_events.payload:{"name":"John Doe"}`
+	})).Return(expected, nil)
+
+	event, err := repo.Enqueue(ctx, repositories.EventPatientUpdated, map[string]string{"name": "John Doe"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, event)
+	queries.AssertExpectations(t)
+}
+
+func TestOutboxRepository_Enqueue_UnencodableData(t *testing.T) {
+	queries := new(MockOutboxQueries)
+	repo := repositories.NewOutboxRepository(queries, fakeCipher{})
+
+	_, err := repo.Enqueue(context.Background(), repositories.EventPatientUpdated, make(chan int))
+
+	assert.Error(t, err)
+	queries.AssertNotCalled(t, "CreateOutboxEvent", mock.Anything, mock.Anything)
+}
+
+func TestOutboxRepository_Reencrypt(t *testing.T) {
+	queries := new(MockOutboxQueries)
+	repo := repositories.NewOutboxRepository(queries, fakeCipher{})
+	ctx := context.Background()
+
+	queries.On("GetOutboxEventsForReencryption", ctx, database.GetOutboxEventsForReencryptionParams{AfterID: 0, MaxResults: 100}).
+		Return([]database.OutboxEvent{
+			{ID: 1, Payload: []byte(`{"name": "John Doe"}`)},
+			{ID: 2, Payload: []byte(`"enc:outbox_events.payload:{\"name\":\"Jane Doe\"}"`)},
+		}, nil)
+	queries.On("UpdateOutboxEventPayload", ctx, database.UpdateOutboxEventPayloadParams{
+		ID:      1,
+		Payload: []byte(`"enc:outbox_events.payload:{\"name\": \"John Doe\"}"`),
+	}).Return(nil)
+
+	lastId, updated, err := repo.Reencrypt(ctx, 0, 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), lastId)
+	// only the payload stored before encryption
+	assert.Equal(t, 1, updated)
+	queries.AssertExpectations(t)
+}
+
+func TestDecryptOutboxPayload(t *testing.T) {
+	ctx := context.Background()
+
+	payload, err := repositories.DecryptOutboxPayload(ctx, fakeCipher{}, []byte(`"enc:outbox_events.payload:{\"id\":7}"`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":7}`, string(payload))
+
+	// stored before payloads were encrypted
+	payload, err = repositories.DecryptOutboxPayload(ctx, fakeCipher{}, []byte(`{"id":7}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":7}`, string(payload))
+}
+
+func TestAppointmentStatusEvent(t *testing.T) {
+	for _, status := range []repositories.AppointmentStatus{
+		repositories.AppointmentCheckedIn,
+		repositories.AppointmentInConsultation,
+		repositories.AppointmentCompleted,
+		repositories.AppointmentNoShow,
+		repositories.AppointmentCancelled,
+	} {
+		eventType := repositories.AppointmentStatusEvent(status)
+		assert.True(t, repositories.IsEventType(string(eventType)), eventType)
+	}
+
+	assert.Equal(t, repositories.EventAppointmentCancelled, repositories.AppointmentStatusEvent(repositories.AppointmentCancelled))
+	assert.False(t, repositories.IsEventType(string(repositories.EventWebhookTest)))
+}
@@ -3,9 +3,13 @@ package repositories_test
 import (
 	"context"
 	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/pagination"
 	"patient-appointment-demo-go/internal/repositories"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -19,6 +23,11 @@ func (m *MockQueries) GetAllPatients(ctx context.Context, params database.GetAll
 	return args.Get(0).([]database.Patient), args.Error(1)
 }
 
+func (m *MockQueries) SearchPatients(ctx context.Context, params database.SearchPatientsParams) ([]database.SearchPatientsRow, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.SearchPatientsRow), args.Error(1)
+}
+
 func (m *MockQueries) GetPatientByID(ctx context.Context, id int32) (database.Patient, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(database.Patient), args.Error(1)
@@ -39,25 +48,124 @@ func (m *MockQueries) DeletePatient(ctx context.Context, id int32) error {
 	return args.Error(0)
 }
 
+func (m *MockQueries) GetPatientByIDWithDeleted(ctx context.Context, id int32) (database.Patient, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Patient), args.Error(1)
+}
+
+func (m *MockQueries) RestorePatient(ctx context.Context, id int32) (database.Patient, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Patient), args.Error(1)
+}
+
+func (m *MockQueries) FindDuplicatePatients(ctx context.Context, params database.FindDuplicatePatientsParams) ([]database.FindDuplicatePatientsRow, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.FindDuplicatePatientsRow), args.Error(1)
+}
+
+func (m *MockQueries) GetPatientForUpdate(ctx context.Context, id int32) (database.Patient, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Patient), args.Error(1)
+}
+
+func (m *MockQueries) GetPatientMergeOverlaps(ctx context.Context, params database.GetPatientMergeOverlapsParams) ([]database.Appointment, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.Appointment), args.Error(1)
+}
+
+func (m *MockQueries) MovePatientAppointments(ctx context.Context, params database.MovePatientAppointmentsParams) ([]database.Appointment, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.Appointment), args.Error(1)
+}
+
+func (m *MockQueries) MovePatientAppointmentSeries(ctx context.Context, params database.MovePatientAppointmentSeriesParams) (int64, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQueries) MovePatientWaitlistEntries(ctx context.Context, params database.MovePatientWaitlistEntriesParams) (int64, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQueries) FillPatientFromDuplicate(ctx context.Context, params database.FillPatientFromDuplicateParams) (database.Patient, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.Patient), args.Error(1)
+}
+
+func (m *MockQueries) CreatePatientMerge(ctx context.Context, params database.CreatePatientMergeParams) (database.PatientMerge, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.PatientMerge), args.Error(1)
+}
+
+func (m *MockQueries) GetPatientMerges(ctx context.Context, survivorID int32) ([]database.PatientMerge, error) {
+	args := m.Called(ctx, survivorID)
+	return args.Get(0).([]database.PatientMerge), args.Error(1)
+}
+
+func (m *MockQueries) GetPatientsForReencryption(ctx context.Context, params database.GetPatientsForReencryptionParams) ([]database.Patient, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.Patient), args.Error(1)
+}
+
+func (m *MockQueries) UpdatePatientEncryption(ctx context.Context, params database.UpdatePatientEncryptionParams) error {
+	args := m.Called(ctx, params)
+	return args.Error(0)
+}
+
 func TestPatientRepository_GetAll(t *testing.T) {
 	mockQueries := new(MockQueries)
-	repo := repositories.NewPatientRepository(mockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 	patients := []database.Patient{{ID: 1, Name: "John Doe"}}
-	params := database.GetAllPatientsParams{Name: "John"}
+	params := database.GetAllPatientsParams{Name: "John", SortBy: "created_at", MaxResults: 21}
 
 	mockQueries.On("GetAllPatients", ctx, params).Return(patients, nil)
 
 	result, err := repo.GetAll(ctx, repositories.GetPatientsOption{Name: "John"})
 
 	assert.NoError(t, err)
-	assert.Equal(t, patients, result)
+	assert.Equal(t, patients, result.Items)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_GetAll_SortsByAgeDescending(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	page := pagination.Params{Limit: 2, SortBy: "age", Desc: true}
+	patients := []database.Patient{
+		{ID: 4, Age: pgtype.Int2{Int16: 70, Valid: true}},
+		{ID: 9, Age: pgtype.Int2{Int16: 41, Valid: true}},
+		{ID: 3, Age: pgtype.Int2{Int16: 41, Valid: true}},
+	}
+
+	mockQueries.On("GetAllPatients", ctx, database.GetAllPatientsParams{
+		SortBy: "age", SortDesc: true, MaxResults: 3,
+	}).Return(patients, nil)
+
+	first, err := repo.GetAll(ctx, repositories.GetPatientsOption{Page: page})
+
+	assert.NoError(t, err)
+	assert.Equal(t, patients[:2], first.Items)
+
+	page.Cursor = first.NextCursor
+	mockQueries.On("GetAllPatients", ctx, database.GetAllPatientsParams{
+		SortBy: "age", SortDesc: true, MaxResults: 3,
+		HasCursor: true, CursorAge: 41, CursorID: 9,
+	}).Return(patients[2:], nil)
+
+	second, err := repo.GetAll(ctx, repositories.GetPatientsOption{Page: page})
+
+	assert.NoError(t, err)
+	assert.Equal(t, patients[2:], second.Items)
+	assert.Empty(t, second.NextCursor)
 	mockQueries.AssertExpectations(t)
 }
 
 func TestPatientRepository_Get(t *testing.T) {
 	mockQueries := new(MockQueries)
-	repo := repositories.NewPatientRepository(mockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 	patient := database.Patient{ID: 1, Name: "John Doe"}
 
@@ -72,7 +180,7 @@ func TestPatientRepository_Get(t *testing.T) {
 
 func TestPatientRepository_Create(t *testing.T) {
 	mockQueries := new(MockQueries)
-	repo := repositories.NewPatientRepository(mockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 	patient := database.Patient{ID: 1, Name: "John Doe"}
 	params := repositories.CreatePatientParams{
@@ -90,7 +198,7 @@ func TestPatientRepository_Create(t *testing.T) {
 
 func TestPatientRepository_Update(t *testing.T) {
 	mockQueries := new(MockQueries)
-	repo := repositories.NewPatientRepository(mockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 	patient := database.Patient{ID: 1, Name: "John Updated"}
 	params := repositories.UpdatePatientParams{
@@ -108,7 +216,7 @@ func TestPatientRepository_Update(t *testing.T) {
 
 func TestPatientRepository_Delete(t *testing.T) {
 	mockQueries := new(MockQueries)
-	repo := repositories.NewPatientRepository(mockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
 	ctx := context.Background()
 
 	mockQueries.On("DeletePatient", ctx, int32(1)).Return(nil)
@@ -119,3 +227,220 @@ func TestPatientRepository_Delete(t *testing.T) {
 	mockQueries.AssertExpectations(t)
 }
 
+func TestPatientRepository_GetAll_IncludeDeleted(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	patients := []database.Patient{{ID: 1, Name: "John Doe", DeletedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}}}
+	params := database.GetAllPatientsParams{IncludeDeleted: true, SortBy: "created_at", MaxResults: 21}
+
+	mockQueries.On("GetAllPatients", ctx, params).Return(patients, nil)
+
+	result, err := repo.GetAll(ctx, repositories.GetPatientsOption{IncludeDeleted: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, patients, result.Items)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_GetWithDeleted(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	patient := database.Patient{ID: 1, Name: "John Doe", DeletedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}}
+
+	mockQueries.On("GetPatientByIDWithDeleted", ctx, int32(1)).Return(patient, nil)
+
+	result, err := repo.GetWithDeleted(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, patient, result)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_Restore(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	patient := database.Patient{ID: 1, Name: "John Doe"}
+
+	mockQueries.On("RestorePatient", ctx, int32(1)).Return(patient, nil)
+
+	result, err := repo.Restore(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, patient, result)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_Search(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	rows := []database.SearchPatientsRow{{ID: 1, Name: "John Smith", NameHighlight: "<mark>John</mark> <mark>Smith</mark>"}}
+
+	mockQueries.On("SearchPatients", ctx, database.SearchPatientsParams{
+		PrefixQuery: "jo:* & smi:*",
+		Term:        "Jo Smi",
+		Pattern:     "%Jo Smi%",
+		MaxResults:  20,
+	}).Return(rows, nil)
+
+	result, err := repo.Search(ctx, "  Jo Smi ", 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, rows, result)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_Search_PhoneNumber(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("SearchPatients", ctx, database.SearchPatientsParams{
+		PrefixQuery: "555:* & 01:* & 23:*",
+		Term:        "(555) 01-23",
+		Pattern:     "%(555) 01-23%",
+		PhoneBidx:   []byte("bidx:patients.phone:5550123"),
+		MaxResults:  100,
+	}).Return([]database.SearchPatientsRow{}, nil)
+
+	_, err := repo.Search(ctx, "(555) 01-23", 500)
+
+	assert.NoError(t, err)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_Search_EscapesLikePattern(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("SearchPatients", ctx, mock.MatchedBy(func(arg database.SearchPatientsParams) bool {
+		return arg.Pattern == `%100\%\_a\\b%` && arg.PrefixQuery == "100:* & a:* & b:*" && arg.PhoneBidx == nil && arg.EmailBidx == nil
+	})).Return([]database.SearchPatientsRow{}, nil)
+
+	_, err := repo.Search(ctx, `100%_a\b`, 5)
+
+	assert.NoError(t, err)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_FindDuplicates(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("FindDuplicatePatients", ctx, database.FindDuplicatePatientsParams{
+		Name:       "Jon Smith",
+		EmailBidx:  []byte("bidx:patients.email:jon@example.com"),
+		PhoneBidx:  []byte("bidx:patients.phone:5550123"),
+		Age:        42,
+		MaxResults: 20,
+	}).Return([]database.FindDuplicatePatientsRow{
+		// a relative sharing the phone
+		{ID: 1, Name: "Mary Smith", NameSimilarity: 0.35, PhoneMatch: true},
+		{ID: 2, Name: "John Smith", NameSimilarity: 0.6, PhoneMatch: true, AgeMatch: true},
+		{ID: 3, Name: "Jonathan Smith", NameSimilarity: 0.5, EmailMatch: true, PhoneMatch: true, AgeMatch: true},
+	}, nil)
+
+	duplicates, err := repo.FindDuplicates(ctx, repositories.FindDuplicatesParams{
+		Name:  " Jon Smith ",
+		Email: "jon@example.com",
+		Phone: "(555) 01-23",
+		Age:   42,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, duplicates, 2)
+
+	assert.Equal(t, int32(3), duplicates[0].Patient.ID)
+	assert.Equal(t, 1.0, duplicates[0].Score)
+	assert.Equal(t, []string{"name", "email", "phone", "age"}, duplicates[0].Reasons)
+
+	assert.Equal(t, int32(2), duplicates[1].Patient.ID)
+	assert.InDelta(t, 0.7, duplicates[1].Score, 0.001)
+	assert.Equal(t, []string{"name", "phone", "age"}, duplicates[1].Reasons)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_Merge(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	survivor := database.Patient{ID: 7, Name: "John Smith"}
+	appointments := []database.Appointment{{ID: 30, PatientID: 7}, {ID: 31, PatientID: 7}}
+	merge := database.PatientMerge{ID: 1, SurvivorID: 7, DuplicateID: 3, AppointmentsMoved: 2}
+
+	var locked []int32
+	mockQueries.On("GetPatientForUpdate", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		locked = append(locked, args.Get(1).(int32))
+	}).Return(database.Patient{}, nil)
+	mockQueries.On("GetPatientMergeOverlaps", ctx, database.GetPatientMergeOverlapsParams{SurvivorID: 7, DuplicateID: 3}).Return([]database.Appointment{}, nil)
+	mockQueries.On("MovePatientAppointments", ctx, database.MovePatientAppointmentsParams{SurvivorID: 7, DuplicateID: 3}).Return(appointments, nil)
+	mockQueries.On("MovePatientAppointmentSeries", ctx, database.MovePatientAppointmentSeriesParams{SurvivorID: 7, DuplicateID: 3}).Return(int64(1), nil)
+	mockQueries.On("MovePatientWaitlistEntries", ctx, database.MovePatientWaitlistEntriesParams{SurvivorID: 7, DuplicateID: 3}).Return(int64(0), nil)
+	mockQueries.On("FillPatientFromDuplicate", ctx, database.FillPatientFromDuplicateParams{SurvivorID: 7, DuplicateID: 3}).Return(survivor, nil)
+	mockQueries.On("CreatePatientMerge", ctx, database.CreatePatientMergeParams{
+		SurvivorID:        7,
+		AppointmentsMoved: 2,
+		SeriesMoved:       1,
+		MergedBy:          pgtype.Int4{Int32: 5, Valid: true},
+		DuplicateID:       3,
+	}).Return(merge, nil)
+	mockQueries.On("DeletePatient", ctx, int32(3)).Return(nil)
+
+	result, err := repo.Merge(ctx, repositories.MergePatientsParams{SurvivorID: 7, DuplicateID: 3, MergedBy: 5})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{3, 7}, locked)
+	assert.Equal(t, survivor, result.Survivor)
+	assert.Equal(t, merge, result.Merge)
+	assert.Equal(t, appointments, result.Appointments)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestPatientRepository_Merge_OverlappingAppointments(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+	overlaps := []database.Appointment{{ID: 30, PatientID: 7}, {ID: 41, PatientID: 3}}
+
+	mockQueries.On("GetPatientForUpdate", ctx, mock.Anything).Return(database.Patient{}, nil)
+	mockQueries.On("GetPatientMergeOverlaps", ctx, database.GetPatientMergeOverlapsParams{SurvivorID: 7, DuplicateID: 3}).Return(overlaps, nil)
+
+	_, err := repo.Merge(ctx, repositories.MergePatientsParams{SurvivorID: 7, DuplicateID: 3})
+
+	var overlap repositories.AppointmentOverlapError
+	assert.ErrorAs(t, err, &overlap)
+	assert.ErrorIs(t, err, repositories.ErrAppointmentOverlap)
+	assert.Equal(t, overlaps, overlap.Conflicts)
+	mockQueries.AssertNotCalled(t, "MovePatientAppointments", mock.Anything, mock.Anything)
+	mockQueries.AssertNotCalled(t, "DeletePatient", mock.Anything, mock.Anything)
+}
+
+func TestPatientRepository_Merge_MissingPatient(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+	ctx := context.Background()
+
+	mockQueries.On("GetPatientForUpdate", ctx, int32(3)).Return(database.Patient{}, nil)
+	mockQueries.On("GetPatientForUpdate", ctx, int32(7)).Return(database.Patient{}, pgx.ErrNoRows)
+
+	_, err := repo.Merge(ctx, repositories.MergePatientsParams{SurvivorID: 7, DuplicateID: 3})
+
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	mockQueries.AssertNotCalled(t, "MovePatientAppointments", mock.Anything, mock.Anything)
+	mockQueries.AssertNotCalled(t, "DeletePatient", mock.Anything, mock.Anything)
+}
+
+func TestPatientRepository_Merge_IntoItself(t *testing.T) {
+	mockQueries := new(MockQueries)
+	repo := repositories.NewPatientRepository(mockQueries, fakeCipher{})
+
+	_, err := repo.Merge(context.Background(), repositories.MergePatientsParams{SurvivorID: 7, DuplicateID: 7})
+
+	assert.ErrorIs(t, err, repositories.ErrMergeIntoItself)
+	mockQueries.AssertNotCalled(t, "GetPatientForUpdate", mock.Anything, mock.Anything)
+}
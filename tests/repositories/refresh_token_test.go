@@ -0,0 +1,168 @@
+package repositories_test
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRefreshTokenQueries struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenQueries) CreateRefreshToken(ctx context.Context, params database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenQueries) UseRefreshToken(ctx context.Context, tokenHash string) (database.RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(database.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenQueries) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (database.RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(database.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenQueries) RevokeRefreshTokenFamily(ctx context.Context, familyId string) error {
+	args := m.Called(ctx, familyId)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenQueries) DeleteExpiredRefreshTokens(ctx context.Context, userId int32) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
+func TestRefreshTokenRepository_Create(t *testing.T) {
+	mockQueries := new(MockRefreshTokenQueries)
+	repo := repositories.NewRefreshTokenRepository(mockQueries, new(MockTokenRevocations))
+	ctx := context.Background()
+
+	var created database.CreateRefreshTokenParams
+	mockQueries.On("DeleteExpiredRefreshTokens", ctx, int32(1)).Return(nil)
+	mockQueries.On("CreateRefreshToken", ctx, mock.Anything).
+		Run(func(args mock.Arguments) {
+			created = args.Get(1).(database.CreateRefreshTokenParams)
+		}).
+		Return(database.RefreshToken{ID: 1}, nil)
+
+	_, token, err := repo.Create(ctx, repositories.CreateRefreshTokenParams{
+		UserID:       1,
+		TokenVersion: 2,
+		UserAgent:    "Mozilla/5.0",
+		IP:           "203.0.113.7",
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "rt_"))
+	// only the hash is stored
+	assert.NotEqual(t, token, created.TokenHash)
+	assert.Len(t, created.TokenHash, 64)
+	assert.NotEmpty(t, created.FamilyID)
+	assert.Equal(t, int32(2), created.TokenVersion)
+	assert.Equal(t, pgtype.Text{String: "Mozilla/5.0", Valid: true}, created.UserAgent)
+	assert.Equal(t, pgtype.Text{String: "203.0.113.7", Valid: true}, created.Ip)
+	assert.False(t, created.DeviceName.Valid)
+	assert.WithinDuration(t, time.Now().Add(repositories.RefreshTokenTTL), created.ExpiresAt.Time, time.Minute)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestRefreshTokenRepository_Create_SameFamily(t *testing.T) {
+	mockQueries := new(MockRefreshTokenQueries)
+	repo := repositories.NewRefreshTokenRepository(mockQueries, new(MockTokenRevocations))
+	ctx := context.Background()
+
+	mockQueries.On("DeleteExpiredRefreshTokens", ctx, int32(1)).Return(nil)
+	mockQueries.On("CreateRefreshToken", ctx, mock.MatchedBy(func(params database.CreateRefreshTokenParams) bool {
+		return params.FamilyID == "family-1"
+	})).Return(database.RefreshToken{ID: 2, FamilyID: "family-1"}, nil)
+
+	result, _, err := repo.Create(ctx, repositories.CreateRefreshTokenParams{
+		UserID:   1,
+		FamilyID: "family-1",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "family-1", result.FamilyID)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestRefreshTokenRepository_Use(t *testing.T) {
+	mockQueries := new(MockRefreshTokenQueries)
+	repo := repositories.NewRefreshTokenRepository(mockQueries, new(MockTokenRevocations))
+	ctx := context.Background()
+	token := database.RefreshToken{ID: 1, UserID: 1, FamilyID: "family-1"}
+
+	mockQueries.On("UseRefreshToken", ctx, mock.AnythingOfType("string")).Return(token, nil)
+
+	result, err := repo.Use(ctx, "rt_abc")
+
+	assert.NoError(t, err)
+	assert.Equal(t, token, result)
+	mockQueries.AssertNotCalled(t, "RevokeRefreshTokenFamily", mock.Anything, mock.Anything)
+}
+
+func TestRefreshTokenRepository_Use_Reused(t *testing.T) {
+	mockQueries := new(MockRefreshTokenQueries)
+	revocations := new(MockTokenRevocations)
+	repo := repositories.NewRefreshTokenRepository(mockQueries, revocations)
+	ctx := context.Background()
+	used := database.RefreshToken{
+		ID:       1,
+		UserID:   1,
+		FamilyID: "family-1",
+		UsedAt:   pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true},
+	}
+
+	mockQueries.On("UseRefreshToken", ctx, mock.AnythingOfType("string")).Return(database.RefreshToken{}, pgx.ErrNoRows)
+	mockQueries.On("GetRefreshTokenByHash", ctx, mock.AnythingOfType("string")).Return(used, nil)
+	mockQueries.On("RevokeRefreshTokenFamily", ctx, "family-1").Return(nil)
+	// the family's access tokens too, for as long as the last one is valid
+	revocations.On("Revoke", ctx, "sid:family-1", int32(1), mock.MatchedBy(func(expiresAt time.Time) bool {
+		return expiresAt.After(time.Now().Add(utils.AccessTokenTTL - time.Minute))
+	})).Return(nil)
+
+	_, err := repo.Use(ctx, "rt_abc")
+
+	assert.ErrorIs(t, err, repositories.ErrRefreshTokenReused)
+	mockQueries.AssertExpectations(t)
+	revocations.AssertExpectations(t)
+}
+
+func TestRefreshTokenRepository_Use_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		token database.RefreshToken
+		err   error
+	}{
+		{"unknown", database.RefreshToken{}, pgx.ErrNoRows},
+		{"revoked or expired", database.RefreshToken{ID: 1, FamilyID: "family-1"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueries := new(MockRefreshTokenQueries)
+			repo := repositories.NewRefreshTokenRepository(mockQueries, new(MockTokenRevocations))
+			ctx := context.Background()
+
+			mockQueries.On("UseRefreshToken", ctx, mock.AnythingOfType("string")).Return(database.RefreshToken{}, pgx.ErrNoRows)
+			mockQueries.On("GetRefreshTokenByHash", ctx, mock.AnythingOfType("string")).Return(tt.token, tt.err)
+
+			_, err := repo.Use(ctx, "rt_abc")
+
+			assert.ErrorIs(t, err, repositories.ErrRefreshTokenInvalid)
+			mockQueries.AssertNotCalled(t, "RevokeRefreshTokenFamily", mock.Anything, mock.Anything)
+		})
+	}
+}
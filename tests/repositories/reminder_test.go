@@ -0,0 +1,98 @@
+package repositories_test
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReminderQueries struct {
+	mock.Mock
+}
+
+func (m *MockReminderQueries) GetDueReminders(ctx context.Context, arg database.GetDueRemindersParams) ([]database.GetDueRemindersRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.GetDueRemindersRow), args.Error(1)
+}
+
+func (m *MockReminderQueries) ClaimReminder(ctx context.Context, arg database.ClaimReminderParams) (database.AppointmentReminder, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.AppointmentReminder), args.Error(1)
+}
+
+func (m *MockReminderQueries) CompleteReminder(ctx context.Context, arg database.CompleteReminderParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func TestReminderRepository_GetDue_PassesOffsetInMinutes(t *testing.T) {
+	queries := new(MockReminderQueries)
+	repo := repositories.NewReminderRepository(queries, fakeCipher{})
+	ctx := context.Background()
+
+	queries.On("GetDueReminders", ctx, database.GetDueRemindersParams{
+		OffsetMinutes: 120,
+		Channel:       "sms",
+		MaxAttempts:   3,
+		MaxResults:    100,
+	}).Return([]database.GetDueRemindersRow{{AppointmentID: 4}}, nil)
+
+	rows, err := repo.GetDue(ctx, 2*time.Hour, "sms", 100)
+
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	queries.AssertExpectations(t)
+}
+
+func TestReminderRepository_Claim(t *testing.T) {
+	queries := new(MockReminderQueries)
+	repo := repositories.NewReminderRepository(queries, fakeCipher{})
+	ctx := context.Background()
+	visit := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+
+	queries.On("ClaimReminder", ctx, mock.MatchedBy(func(arg database.ClaimReminderParams) bool {
+		return arg.AppointmentID == 4 &&
+			arg.OffsetMinutes == 1440 &&
+			arg.Channel == "email" &&
+			arg.VisitTimestamp.Time.Equal(visit)
+	})).Return(database.AppointmentReminder{ID: 11}, nil)
+
+	reminder, claimed, err := repo.Claim(ctx, 4, 24*time.Hour, "email", visit)
+
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, int64(11), reminder.ID)
+	queries.AssertExpectations(t)
+}
+
+func TestReminderRepository_Claim_AlreadySent(t *testing.T) {
+	queries := new(MockReminderQueries)
+	repo := repositories.NewReminderRepository(queries, fakeCipher{})
+	ctx := context.Background()
+
+	queries.On("ClaimReminder", ctx, mock.Anything).Return(database.AppointmentReminder{}, pgx.ErrNoRows)
+
+	_, claimed, err := repo.Claim(ctx, 4, 24*time.Hour, "email", time.Now())
+
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+}
+
+func TestReminderRepository_MarkFailed(t *testing.T) {
+	queries := new(MockReminderQueries)
+	repo := repositories.NewReminderRepository(queries, fakeCipher{})
+	ctx := context.Background()
+
+	queries.On("CompleteReminder", ctx, mock.MatchedBy(func(arg database.CompleteReminderParams) bool {
+		return arg.ID == 11 && arg.Status == "failed" && arg.LastError.String == "gateway down"
+	})).Return(nil)
+
+	assert.NoError(t, repo.MarkFailed(ctx, 11, "gateway down"))
+	queries.AssertExpectations(t)
+}
@@ -0,0 +1,170 @@
+package repositories_test
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/internal/schedule"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockScheduleQueries struct {
+	mock.Mock
+}
+
+func (m *MockScheduleQueries) GetDoctorSchedule(ctx context.Context, doctorID int32) (database.DoctorSchedule, error) {
+	args := m.Called(ctx, doctorID)
+	return args.Get(0).(database.DoctorSchedule), args.Error(1)
+}
+
+func (m *MockScheduleQueries) UpsertDoctorSchedule(ctx context.Context, params database.UpsertDoctorScheduleParams) (database.DoctorSchedule, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.DoctorSchedule), args.Error(1)
+}
+
+func (m *MockScheduleQueries) GetDoctorWorkingHours(ctx context.Context, doctorID int32) ([]database.DoctorWorkingHour, error) {
+	args := m.Called(ctx, doctorID)
+	return args.Get(0).([]database.DoctorWorkingHour), args.Error(1)
+}
+
+func (m *MockScheduleQueries) CreateDoctorWorkingHour(ctx context.Context, params database.CreateDoctorWorkingHourParams) (database.DoctorWorkingHour, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.DoctorWorkingHour), args.Error(1)
+}
+
+func (m *MockScheduleQueries) DeleteDoctorWorkingHours(ctx context.Context, doctorID int32) error {
+	args := m.Called(ctx, doctorID)
+	return args.Error(0)
+}
+
+func (m *MockScheduleQueries) GetDoctorBreaks(ctx context.Context, doctorID int32) ([]database.DoctorBreak, error) {
+	args := m.Called(ctx, doctorID)
+	return args.Get(0).([]database.DoctorBreak), args.Error(1)
+}
+
+func (m *MockScheduleQueries) CreateDoctorBreak(ctx context.Context, params database.CreateDoctorBreakParams) (database.DoctorBreak, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.DoctorBreak), args.Error(1)
+}
+
+func (m *MockScheduleQueries) DeleteDoctorBreaks(ctx context.Context, doctorID int32) error {
+	args := m.Called(ctx, doctorID)
+	return args.Error(0)
+}
+
+func (m *MockScheduleQueries) GetDoctorScheduleExceptions(ctx context.Context, params database.GetDoctorScheduleExceptionsParams) ([]database.DoctorScheduleException, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.DoctorScheduleException), args.Error(1)
+}
+
+func (m *MockScheduleQueries) CreateDoctorScheduleException(ctx context.Context, params database.CreateDoctorScheduleExceptionParams) (database.DoctorScheduleException, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.DoctorScheduleException), args.Error(1)
+}
+
+func (m *MockScheduleQueries) DeleteDoctorScheduleException(ctx context.Context, params database.DeleteDoctorScheduleExceptionParams) error {
+	args := m.Called(ctx, params)
+	return args.Error(0)
+}
+
+func pgTime(hour int, minute int) pgtype.Time {
+	return pgtype.Time{Microseconds: int64(time.Duration(hour)*time.Hour+time.Duration(minute)*time.Minute) / 1000, Valid: true}
+}
+
+func TestScheduleRepository_GetWeekly_Defaults(t *testing.T) {
+	mockQueries := new(MockScheduleQueries)
+	repo := repositories.NewScheduleRepository(mockQueries)
+	ctx := context.Background()
+
+	mockQueries.On("GetDoctorSchedule", ctx, int32(4)).Return(database.DoctorSchedule{}, pgx.ErrNoRows)
+	mockQueries.On("GetDoctorWorkingHours", ctx, int32(4)).Return([]database.DoctorWorkingHour{}, nil)
+	mockQueries.On("GetDoctorBreaks", ctx, int32(4)).Return([]database.DoctorBreak{}, nil)
+
+	weekly, err := repo.GetWeekly(ctx, 4)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int16(15), weekly.SlotMinutes)
+	assert.Equal(t, "UTC", weekly.Timezone)
+	assert.Empty(t, weekly.WorkingHours)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestScheduleRepository_SetWeekly(t *testing.T) {
+	mockQueries := new(MockScheduleQueries)
+	repo := repositories.NewScheduleRepository(mockQueries)
+	ctx := context.Background()
+
+	mockQueries.On("UpsertDoctorSchedule", ctx, database.UpsertDoctorScheduleParams{
+		DoctorID:    4,
+		SlotMinutes: 30,
+		Timezone:    "Europe/Berlin",
+	}).Return(database.DoctorSchedule{}, nil)
+	mockQueries.On("DeleteDoctorWorkingHours", ctx, int32(4)).Return(nil)
+	mockQueries.On("CreateDoctorWorkingHour", ctx, database.CreateDoctorWorkingHourParams{
+		DoctorID:  4,
+		Weekday:   1,
+		StartTime: pgTime(9, 0),
+		EndTime:   pgTime(17, 0),
+	}).Return(database.DoctorWorkingHour{}, nil)
+	mockQueries.On("DeleteDoctorBreaks", ctx, int32(4)).Return(nil)
+
+	_, err := repo.SetWeekly(ctx, 4, repositories.SetWeeklyScheduleParams{
+		SlotMinutes: 30,
+		Timezone:    "Europe/Berlin",
+		WorkingHours: []repositories.ScheduleWindow{
+			{Weekday: time.Monday, Start: schedule.NewTimeOfDay(9, 0), End: schedule.NewTimeOfDay(17, 0)},
+		},
+	})
+
+	assert.NoError(t, err)
+	mockQueries.AssertExpectations(t)
+	mockQueries.AssertNotCalled(t, "CreateDoctorBreak", mock.Anything, mock.Anything)
+}
+
+func TestScheduleRepository_GetSchedule(t *testing.T) {
+	mockQueries := new(MockScheduleQueries)
+	repo := repositories.NewScheduleRepository(mockQueries)
+	ctx := context.Background()
+	loc, _ := time.LoadLocation("Europe/Berlin")
+
+	mockQueries.On("GetDoctorSchedule", ctx, int32(4)).Return(database.DoctorSchedule{
+		DoctorID:    4,
+		SlotMinutes: 30,
+		Timezone:    "Europe/Berlin",
+	}, nil)
+	mockQueries.On("GetDoctorWorkingHours", ctx, int32(4)).Return([]database.DoctorWorkingHour{
+		{DoctorID: 4, Weekday: 1, StartTime: pgTime(9, 0), EndTime: pgTime(12, 0)},
+	}, nil)
+	mockQueries.On("GetDoctorBreaks", ctx, int32(4)).Return([]database.DoctorBreak{}, nil)
+	mockQueries.On("GetDoctorScheduleExceptions", ctx, mock.Anything).Return([]database.DoctorScheduleException{
+		{
+			DoctorID:      4,
+			ExceptionDate: pgtype.Date{Time: time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC), Valid: true},
+			Kind:          "leave",
+		},
+	}, nil)
+
+	sched, err := repo.GetSchedule(ctx, 4, time.Date(2025, time.March, 3, 0, 0, 0, 0, loc), time.Date(2025, time.March, 11, 0, 0, 0, 0, loc))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, sched.SlotLength)
+	assert.Equal(t, "Europe/Berlin", sched.Location.String())
+	assert.Equal(t, []schedule.WeeklyWindow{
+		{Weekday: time.Monday, Start: schedule.NewTimeOfDay(9, 0), End: schedule.NewTimeOfDay(12, 0)},
+	}, sched.WorkingHours)
+	assert.True(t, sched.Covers(schedule.Interval{
+		Start: time.Date(2025, time.March, 3, 9, 0, 0, 0, loc),
+		End:   time.Date(2025, time.March, 3, 9, 30, 0, 0, loc),
+	}))
+	assert.False(t, sched.Covers(schedule.Interval{
+		Start: time.Date(2025, time.March, 10, 9, 0, 0, 0, loc),
+		End:   time.Date(2025, time.March, 10, 9, 30, 0, 0, loc),
+	}))
+	mockQueries.AssertExpectations(t)
+}
@@ -0,0 +1,220 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTx struct {
+	pgx.Tx
+	mock.Mock
+}
+
+func (m *MockTx) Commit(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockTx) Rollback(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+type MockTxBeginner struct {
+	mock.Mock
+}
+
+func (m *MockTxBeginner) BeginTx(ctx context.Context, options pgx.TxOptions) (pgx.Tx, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(pgx.Tx), args.Error(1)
+}
+
+// MockTxQueries combines the per-table query mocks into one
+// TxQueriesContract.
+type MockTxQueries struct {
+	*MockUserQueries
+	*MockQueries
+	*MockAppointmentQueries
+	*MockScheduleQueries
+	*MockWaitlistQueries
+	*MockOutboxQueries
+	*MockAuditQueries
+}
+
+func newMockTxQueries() MockTxQueries {
+	return MockTxQueries{
+		MockUserQueries:        new(MockUserQueries),
+		MockQueries:            new(MockQueries),
+		MockAppointmentQueries: new(MockAppointmentQueries),
+		MockScheduleQueries:    new(MockScheduleQueries),
+		MockWaitlistQueries:    new(MockWaitlistQueries),
+		MockOutboxQueries:      new(MockOutboxQueries),
+		MockAuditQueries:       new(MockAuditQueries),
+	}
+}
+
+func newTestTxManager(beginner *MockTxBeginner, queries MockTxQueries, opts ...repositories.TxOption) repositories.TxManagerInterface {
+	return repositories.NewTxManager(beginner, func(tx pgx.Tx) repositories.TxQueriesContract {
+		return queries
+	}, fakeCipher{}, new(MockTokenRevocations), opts...)
+}
+
+func TestTxManager_RunInTx_Commits(t *testing.T) {
+	beginner := new(MockTxBeginner)
+	tx := new(MockTx)
+	queries := newMockTxQueries()
+	manager := newTestTxManager(beginner, queries)
+	ctx := context.Background()
+	patient := database.Patient{ID: 1, Name: "John Doe"}
+	appointment := database.Appointment{ID: 2, PatientID: 1}
+
+	beginner.On("BeginTx", ctx, pgx.TxOptions{}).Return(tx, nil)
+	tx.On("Commit", ctx).Return(nil)
+	queries.MockQueries.On("CreatePatient", ctx, mock.Anything).Return(patient, nil)
+	queries.MockAppointmentQueries.On("GetOverlappingAppointments", ctx, mock.Anything).Return([]database.Appointment{}, nil)
+	queries.MockAppointmentQueries.On("CreateAppointment", ctx, mock.Anything).Return(appointment, nil)
+
+	err := manager.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		p, err := repos.Patients.Create(ctx, repositories.CreatePatientParams{Name: "John Doe"})
+		if err != nil {
+			return err
+		}
+
+		_, err = repos.Appointments.Create(ctx, 3, p.ID, repositories.CreateAppointmentParams{})
+		return err
+	})
+
+	assert.NoError(t, err)
+	beginner.AssertExpectations(t)
+	tx.AssertExpectations(t)
+	tx.AssertNotCalled(t, "Rollback", mock.Anything)
+	queries.MockQueries.AssertExpectations(t)
+	queries.MockAppointmentQueries.AssertExpectations(t)
+}
+
+func TestTxManager_RunInTx_SetsAuditSource(t *testing.T) {
+	beginner := new(MockTxBeginner)
+	tx := new(MockTx)
+	queries := newMockTxQueries()
+	manager := newTestTxManager(beginner, queries)
+	ctx := repositories.WithAuditSource(context.Background(), repositories.AuditSource{
+		ActorID:   7,
+		IP:        "10.0.0.1",
+		RequestID: "req-1",
+	})
+
+	beginner.On("BeginTx", ctx, pgx.TxOptions{}).Return(tx, nil)
+	tx.On("Commit", ctx).Return(nil)
+	queries.MockAuditQueries.On("SetAuditSource", ctx, database.SetAuditSourceParams{
+		ActorID:   "7",
+		Ip:        "10.0.0.1",
+		RequestID: "req-1",
+	}).Return(nil)
+	queries.MockQueries.On("DeletePatient", ctx, int32(1)).Return(nil)
+
+	err := manager.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		return repos.Patients.Delete(ctx, 1)
+	})
+
+	assert.NoError(t, err)
+	tx.AssertExpectations(t)
+	queries.MockAuditQueries.AssertExpectations(t)
+	queries.MockQueries.AssertExpectations(t)
+}
+
+func TestTxManager_RunInTx_RollsBackOnError(t *testing.T) {
+	beginner := new(MockTxBeginner)
+	tx := new(MockTx)
+	queries := newMockTxQueries()
+	manager := newTestTxManager(beginner, queries)
+	ctx := context.Background()
+	createErr := errors.New("insert failed")
+
+	beginner.On("BeginTx", ctx, pgx.TxOptions{}).Return(tx, nil)
+	tx.On("Rollback", mock.Anything).Return(nil)
+	queries.MockQueries.On("CreatePatient", ctx, mock.Anything).Return(database.Patient{}, createErr)
+
+	err := manager.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		_, err := repos.Patients.Create(ctx, repositories.CreatePatientParams{Name: "John Doe"})
+		return err
+	})
+
+	assert.ErrorIs(t, err, createErr)
+	tx.AssertExpectations(t)
+	tx.AssertNotCalled(t, "Commit", mock.Anything)
+}
+
+func TestTxManager_RunInTx_RollsBackOnPanic(t *testing.T) {
+	beginner := new(MockTxBeginner)
+	tx := new(MockTx)
+	manager := newTestTxManager(beginner, newMockTxQueries())
+	ctx := context.Background()
+
+	beginner.On("BeginTx", ctx, pgx.TxOptions{}).Return(tx, nil)
+	tx.On("Rollback", mock.Anything).Return(nil)
+
+	assert.Panics(t, func() {
+		manager.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+			panic("boom")
+		})
+	})
+
+	tx.AssertExpectations(t)
+	tx.AssertNotCalled(t, "Commit", mock.Anything)
+}
+
+func TestTxManager_RunInTx_RetriesSerializationFailure(t *testing.T) {
+	beginner := new(MockTxBeginner)
+	failedTx := new(MockTx)
+	tx := new(MockTx)
+	queries := newMockTxQueries()
+	manager := newTestTxManager(beginner, queries, repositories.WithSerializationRetries(2, 0))
+	ctx := context.Background()
+	serializationErr := &pgconn.PgError{Code: "40001"}
+
+	beginner.On("BeginTx", ctx, pgx.TxOptions{}).Return(failedTx, nil).Once()
+	beginner.On("BeginTx", ctx, pgx.TxOptions{}).Return(tx, nil).Once()
+	failedTx.On("Commit", ctx).Return(serializationErr)
+	tx.On("Commit", ctx).Return(nil)
+
+	calls := 0
+	err := manager.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		calls++
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	beginner.AssertExpectations(t)
+	failedTx.AssertExpectations(t)
+	tx.AssertExpectations(t)
+}
+
+func TestTxManager_RunInTx_DoesNotRetryOtherErrors(t *testing.T) {
+	beginner := new(MockTxBeginner)
+	tx := new(MockTx)
+	manager := newTestTxManager(beginner, newMockTxQueries(), repositories.WithSerializationRetries(2, 0))
+	ctx := context.Background()
+	uniqueErr := &pgconn.PgError{Code: "23505"}
+
+	beginner.On("BeginTx", ctx, pgx.TxOptions{}).Return(tx, nil).Once()
+	tx.On("Rollback", mock.Anything).Return(nil)
+
+	calls := 0
+	err := manager.RunInTx(ctx, func(repos repositories.TxRepositories) error {
+		calls++
+		return uniqueErr
+	})
+
+	assert.ErrorIs(t, err, uniqueErr)
+	assert.Equal(t, 1, calls)
+	beginner.AssertExpectations(t)
+}
@@ -5,7 +5,9 @@ import (
 	"patient-appointment-demo-go/internal/database"
 	"patient-appointment-demo-go/internal/repositories"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -14,8 +16,8 @@ type MockUserQueries struct {
 	mock.Mock
 }
 
-func (m *MockUserQueries) GetAllUsers(ctx context.Context) ([]database.User, error) {
-	args := m.Called(ctx)
+func (m *MockUserQueries) GetAllUsers(ctx context.Context, params database.GetAllUsersParams) ([]database.User, error) {
+	args := m.Called(ctx, params)
 	return args.Get(0).([]database.User), args.Error(1)
 }
 
@@ -44,24 +46,59 @@ func (m *MockUserQueries) DeleteUser(ctx context.Context, id int32) error {
 	return args.Error(0)
 }
 
+func (m *MockUserQueries) GetUserWithDeleted(ctx context.Context, id int32) (database.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.User), args.Error(1)
+}
+
+func (m *MockUserQueries) RestoreUser(ctx context.Context, id int32) (database.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.User), args.Error(1)
+}
+
+func (m *MockUserQueries) IncrementUserTokenVersion(ctx context.Context, id int32) (database.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.User), args.Error(1)
+}
+
+type MockTokenRevocations struct {
+	mock.Mock
+}
+
+func (m *MockTokenRevocations) Revoke(ctx context.Context, jti string, userId int32, expiresAt time.Time) error {
+	args := m.Called(ctx, jti, userId, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockTokenRevocations) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	args := m.Called(ctx, jti)
+	return args.Bool(0), args.Error(1)
+}
+
 func TestUserRepository_GetAll(t *testing.T) {
 	mockQueries := new(MockUserQueries)
-	repo := repositories.NewUserRepository(mockQueries)
+	repo := repositories.NewUserRepository(mockQueries, new(MockTokenRevocations))
 	ctx := context.Background()
 	users := []database.User{{ID: 1, Email: "test@example.com"}}
 
-	mockQueries.On("GetAllUsers", ctx).Return(users, nil)
+	params := database.GetAllUsersParams{
+		Type:       pgtype.Text{String: "doctor", Valid: true},
+		SortBy:     "id",
+		MaxResults: 21,
+	}
+
+	mockQueries.On("GetAllUsers", ctx, params).Return(users, nil)
 
-	result, err := repo.GetAll(ctx)
+	result, err := repo.GetAll(ctx, repositories.GetUsersOption{Type: "doctor"})
 
 	assert.NoError(t, err)
-	assert.Equal(t, users, result)
+	assert.Equal(t, users, result.Items)
 	mockQueries.AssertExpectations(t)
 }
 
 func TestUserRepository_GetByEmail(t *testing.T) {
 	mockQueries := new(MockUserQueries)
-	repo := repositories.NewUserRepository(mockQueries)
+	repo := repositories.NewUserRepository(mockQueries, new(MockTokenRevocations))
 	ctx := context.Background()
 	user := database.User{ID: 1, Email: "test@example.com"}
 
@@ -76,7 +113,7 @@ func TestUserRepository_GetByEmail(t *testing.T) {
 
 func TestUserRepository_Get(t *testing.T) {
 	mockQueries := new(MockUserQueries)
-	repo := repositories.NewUserRepository(mockQueries)
+	repo := repositories.NewUserRepository(mockQueries, new(MockTokenRevocations))
 	ctx := context.Background()
 	user := database.User{ID: 1, Email: "test@example.com"}
 
@@ -91,7 +128,7 @@ func TestUserRepository_Get(t *testing.T) {
 
 func TestUserRepository_Create(t *testing.T) {
 	mockQueries := new(MockUserQueries)
-	repo := repositories.NewUserRepository(mockQueries)
+	repo := repositories.NewUserRepository(mockQueries, new(MockTokenRevocations))
 	ctx := context.Background()
 	user := database.User{ID: 1, Email: "test@example.com"}
 	params := repositories.CreateUserParams{
@@ -111,7 +148,7 @@ func TestUserRepository_Create(t *testing.T) {
 
 func TestUserRepository_Update(t *testing.T) {
 	mockQueries := new(MockUserQueries)
-	repo := repositories.NewUserRepository(mockQueries)
+	repo := repositories.NewUserRepository(mockQueries, new(MockTokenRevocations))
 	ctx := context.Background()
 	user := database.User{ID: 1, Email: "updated@example.com"}
 	params := repositories.UpdateUserParams{
@@ -130,7 +167,7 @@ func TestUserRepository_Update(t *testing.T) {
 
 func TestUserRepository_Delete(t *testing.T) {
 	mockQueries := new(MockUserQueries)
-	repo := repositories.NewUserRepository(mockQueries)
+	repo := repositories.NewUserRepository(mockQueries, new(MockTokenRevocations))
 	ctx := context.Background()
 
 	mockQueries.On("DeleteUser", ctx, int32(1)).Return(nil)
@@ -141,3 +178,52 @@ func TestUserRepository_Delete(t *testing.T) {
 	mockQueries.AssertExpectations(t)
 }
 
+func TestUserRepository_Restore(t *testing.T) {
+	mockQueries := new(MockUserQueries)
+	repo := repositories.NewUserRepository(mockQueries, new(MockTokenRevocations))
+	ctx := context.Background()
+	user := database.User{ID: 1, Email: "test@example.com"}
+
+	mockQueries.On("RestoreUser", ctx, int32(1)).Return(user, nil)
+
+	result, err := repo.Restore(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, user, result)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestUserRepository_RevokeToken(t *testing.T) {
+	mockQueries := new(MockUserQueries)
+	revocations := new(MockTokenRevocations)
+	repo := repositories.NewUserRepository(mockQueries, revocations)
+	ctx := context.Background()
+	expiresAt := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	revocations.On("Revoke", ctx, "jti-1", int32(1), expiresAt).Return(nil)
+	revocations.On("IsRevoked", ctx, "jti-1").Return(true, nil)
+
+	err := repo.RevokeToken(ctx, "jti-1", 1, expiresAt)
+	assert.NoError(t, err)
+
+	revoked, err := repo.IsTokenRevoked(ctx, "jti-1")
+
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revocations.AssertExpectations(t)
+}
+
+func TestUserRepository_RevokeAllTokens(t *testing.T) {
+	mockQueries := new(MockUserQueries)
+	repo := repositories.NewUserRepository(mockQueries, new(MockTokenRevocations))
+	ctx := context.Background()
+	user := database.User{ID: 1, Email: "test@example.com", TokenVersion: 2}
+
+	mockQueries.On("IncrementUserTokenVersion", ctx, int32(1)).Return(user, nil)
+
+	result, err := repo.RevokeAllTokens(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), result.TokenVersion)
+	mockQueries.AssertExpectations(t)
+}
@@ -0,0 +1,276 @@
+package repositories_test
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWaitlistQueries struct {
+	mock.Mock
+}
+
+func (m *MockWaitlistQueries) GetWaitlistEntries(ctx context.Context) ([]database.WaitlistEntry, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]database.WaitlistEntry), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) GetWaitlistEntry(ctx context.Context, id int32) (database.WaitlistEntry, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.WaitlistEntry), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) CreateWaitlistEntry(ctx context.Context, params database.CreateWaitlistEntryParams) (database.WaitlistEntry, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.WaitlistEntry), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) UpdateWaitlistEntry(ctx context.Context, params database.UpdateWaitlistEntryParams) (database.WaitlistEntry, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.WaitlistEntry), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) SetWaitlistEntryStatus(ctx context.Context, params database.SetWaitlistEntryStatusParams) (database.WaitlistEntry, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.WaitlistEntry), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) DeleteWaitlistEntry(ctx context.Context, id int32) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockWaitlistQueries) FindWaitlistMatches(ctx context.Context, params database.FindWaitlistMatchesParams) ([]database.WaitlistEntry, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.WaitlistEntry), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) CreateWaitlistHold(ctx context.Context, params database.CreateWaitlistHoldParams) (database.WaitlistHold, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.WaitlistHold), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) GetWaitlistHoldForUpdate(ctx context.Context, id int32) (database.WaitlistHold, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.WaitlistHold), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) GetActiveWaitlistHolds(ctx context.Context) ([]database.WaitlistHold, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]database.WaitlistHold), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) GetActiveWaitlistHoldsForDoctorBetween(ctx context.Context, params database.GetActiveWaitlistHoldsForDoctorBetweenParams) ([]database.WaitlistHold, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).([]database.WaitlistHold), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) ResolveWaitlistHold(ctx context.Context, params database.ResolveWaitlistHoldParams) (database.WaitlistHold, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.WaitlistHold), args.Error(1)
+}
+
+func (m *MockWaitlistQueries) ReleaseCompetingWaitlistHolds(ctx context.Context, params database.ReleaseCompetingWaitlistHoldsParams) (int64, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestWaitlistRepository_Create_DefaultsToAnyTime(t *testing.T) {
+	mockQueries := new(MockWaitlistQueries)
+	repo := repositories.NewWaitlistRepository(mockQueries)
+	ctx := context.Background()
+	from := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, time.March, 7, 0, 0, 0, 0, time.UTC)
+	expected := database.WaitlistEntry{ID: 1, PatientID: 2, TimePreference: "any", Status: "waiting"}
+
+	mockQueries.On("CreateWaitlistEntry", ctx, database.CreateWaitlistEntryParams{
+		PatientID:      2,
+		FromDate:       pgtype.Date{Time: from, Valid: true},
+		ToDate:         pgtype.Date{Time: to, Valid: true},
+		TimePreference: "any",
+		CreatedBy:      pgtype.Int4{Int32: 9, Valid: true},
+	}).Return(expected, nil)
+
+	result, err := repo.Create(ctx, 9, repositories.CreateWaitlistEntryParams{
+		PatientID: 2,
+		FromDate:  from,
+		ToDate:    to,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestWaitlistRepository_OfferSlot(t *testing.T) {
+	mockQueries := new(MockWaitlistQueries)
+	repo := repositories.NewWaitlistRepository(mockQueries)
+	ctx := context.Background()
+	loc, _ := time.LoadLocation("Europe/Berlin")
+	// 23:30 UTC is already the next morning in Berlin
+	visit := time.Date(2025, time.March, 3, 23, 30, 0, 0, time.UTC)
+
+	mockQueries.On("FindWaitlistMatches", ctx, database.FindWaitlistMatchesParams{
+		VisitDate:      pgtype.Date{Time: time.Date(2025, time.March, 4, 0, 0, 0, 0, loc), Valid: true},
+		DoctorID:       pgtype.Int4{Int32: 5, Valid: true},
+		TimePreference: "morning",
+		MaxResults:     3,
+	}).Return([]database.WaitlistEntry{{ID: 11}, {ID: 12}}, nil)
+	for _, entryId := range []int32{11, 12} {
+		mockQueries.On("CreateWaitlistHold", ctx, mock.MatchedBy(func(params database.CreateWaitlistHoldParams) bool {
+			return params.WaitlistEntryID == entryId &&
+				params.DoctorID == 5 &&
+				params.VisitTimestamp.Time.Equal(visit) &&
+				params.DurationMinutes == 20 &&
+				params.ExpiresAt.Time.After(time.Now().Add(29*time.Minute))
+		})).Return(database.WaitlistHold{ID: entryId + 100, WaitlistEntryID: entryId}, nil).Once()
+	}
+
+	holds, err := repo.OfferSlot(ctx, repositories.OfferSlotParams{
+		DoctorID:       5,
+		VisitTimestamp: visit,
+		Duration:       20 * time.Minute,
+		Location:       loc,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, holds, 2)
+	assert.Equal(t, int32(111), holds[0].ID)
+	assert.Equal(t, int32(112), holds[1].ID)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestWaitlistRepository_OfferSlot_NoMatches(t *testing.T) {
+	mockQueries := new(MockWaitlistQueries)
+	repo := repositories.NewWaitlistRepository(mockQueries)
+	ctx := context.Background()
+
+	mockQueries.On("FindWaitlistMatches", ctx, mock.Anything).Return([]database.WaitlistEntry{}, nil)
+
+	holds, err := repo.OfferSlot(ctx, repositories.OfferSlotParams{
+		DoctorID:       5,
+		VisitTimestamp: time.Date(2025, time.March, 3, 15, 0, 0, 0, time.UTC),
+	})
+
+	assert.NoError(t, err)
+	assert.Empty(t, holds)
+	mockQueries.AssertNotCalled(t, "CreateWaitlistHold", mock.Anything, mock.Anything)
+}
+
+func TestTimePreferenceOf(t *testing.T) {
+	day := func(hour int) time.Time {
+		return time.Date(2025, time.March, 3, hour, 0, 0, 0, time.UTC)
+	}
+
+	assert.Equal(t, repositories.TimePreferenceMorning, repositories.TimePreferenceOf(day(8)))
+	assert.Equal(t, repositories.TimePreferenceAfternoon, repositories.TimePreferenceOf(day(12)))
+	assert.Equal(t, repositories.TimePreferenceAfternoon, repositories.TimePreferenceOf(day(16)))
+	assert.Equal(t, repositories.TimePreferenceEvening, repositories.TimePreferenceOf(day(17)))
+}
+
+func TestWaitlistRepository_ClaimHold(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("pending", func(t *testing.T) {
+		mockQueries := new(MockWaitlistQueries)
+		repo := repositories.NewWaitlistRepository(mockQueries)
+		hold := database.WaitlistHold{
+			ID:              1,
+			WaitlistEntryID: 2,
+			Status:          "pending",
+			ExpiresAt:       pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+		}
+		entry := database.WaitlistEntry{ID: 2, PatientID: 7}
+
+		mockQueries.On("GetWaitlistHoldForUpdate", ctx, int32(1)).Return(hold, nil)
+		mockQueries.On("GetWaitlistEntry", ctx, int32(2)).Return(entry, nil)
+
+		claimed, claimedEntry, err := repo.ClaimHold(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, hold, claimed)
+		assert.Equal(t, entry, claimedEntry)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		mockQueries := new(MockWaitlistQueries)
+		repo := repositories.NewWaitlistRepository(mockQueries)
+
+		mockQueries.On("GetWaitlistHoldForUpdate", ctx, int32(1)).Return(database.WaitlistHold{
+			ID:        1,
+			Status:    "pending",
+			ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true},
+		}, nil)
+
+		_, _, err := repo.ClaimHold(ctx, 1)
+
+		assert.ErrorIs(t, err, repositories.ErrHoldExpired)
+		mockQueries.AssertNotCalled(t, "GetWaitlistEntry", mock.Anything, mock.Anything)
+	})
+
+	t.Run("already confirmed", func(t *testing.T) {
+		mockQueries := new(MockWaitlistQueries)
+		repo := repositories.NewWaitlistRepository(mockQueries)
+
+		mockQueries.On("GetWaitlistHoldForUpdate", ctx, int32(1)).Return(database.WaitlistHold{
+			ID:        1,
+			Status:    "confirmed",
+			ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+		}, nil)
+
+		_, _, err := repo.ClaimHold(ctx, 1)
+
+		assert.ErrorIs(t, err, repositories.ErrHoldNotPending)
+	})
+}
+
+func TestWaitlistRepository_ConfirmHold(t *testing.T) {
+	mockQueries := new(MockWaitlistQueries)
+	repo := repositories.NewWaitlistRepository(mockQueries)
+	ctx := context.Background()
+	visit := pgtype.Timestamptz{Time: time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC), Valid: true}
+	hold := database.WaitlistHold{ID: 1, WaitlistEntryID: 2, DoctorID: 5, VisitTimestamp: visit, Status: "pending"}
+	confirmed := hold
+	confirmed.Status = "confirmed"
+	confirmed.AppointmentID = pgtype.Int4{Int32: 40, Valid: true}
+
+	mockQueries.On("ResolveWaitlistHold", ctx, database.ResolveWaitlistHoldParams{
+		ID:            1,
+		Status:        "confirmed",
+		AppointmentID: pgtype.Int4{Int32: 40, Valid: true},
+	}).Return(confirmed, nil)
+	mockQueries.On("SetWaitlistEntryStatus", ctx, database.SetWaitlistEntryStatusParams{
+		ID:     2,
+		Status: "booked",
+	}).Return(database.WaitlistEntry{ID: 2, Status: "booked"}, nil)
+	mockQueries.On("ReleaseCompetingWaitlistHolds", ctx, database.ReleaseCompetingWaitlistHoldsParams{
+		DoctorID:       5,
+		VisitTimestamp: visit,
+		ID:             1,
+	}).Return(int64(2), nil)
+
+	result, err := repo.ConfirmHold(ctx, hold, 40)
+
+	assert.NoError(t, err)
+	assert.Equal(t, confirmed, result)
+	mockQueries.AssertExpectations(t)
+}
+
+func TestWaitlistRepository_ReleaseHold_NotPending(t *testing.T) {
+	mockQueries := new(MockWaitlistQueries)
+	repo := repositories.NewWaitlistRepository(mockQueries)
+	ctx := context.Background()
+
+	mockQueries.On("GetWaitlistHoldForUpdate", ctx, int32(1)).Return(database.WaitlistHold{ID: 1, Status: "released"}, nil)
+
+	_, err := repo.ReleaseHold(ctx, 1)
+
+	assert.ErrorIs(t, err, repositories.ErrHoldNotPending)
+	mockQueries.AssertNotCalled(t, "ResolveWaitlistHold", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,178 @@
+package repositories_test
+
+import (
+	"context"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWebhookQueries struct {
+	mock.Mock
+}
+
+func (m *MockWebhookQueries) GetWebhooks(ctx context.Context) ([]database.Webhook, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]database.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookQueries) GetWebhook(ctx context.Context, id int32) (database.Webhook, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookQueries) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookQueries) SetWebhookActive(ctx context.Context, arg database.SetWebhookActiveParams) (database.Webhook, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookQueries) DeleteWebhook(ctx context.Context, id int32) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockWebhookQueries) GetWebhookDeliveries(ctx context.Context, arg database.GetWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookQueries) ReplayWebhookDelivery(ctx context.Context, id int64) (database.WebhookDelivery, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookQueries) ReplayDeadWebhookDeliveries(ctx context.Context, webhookID int32) (int64, error) {
+	args := m.Called(ctx, webhookID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWebhookQueries) DispatchOutboxEvents(ctx context.Context, limit int32) (int64, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWebhookQueries) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.ClaimWebhookDeliveriesRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.ClaimWebhookDeliveriesRow), args.Error(1)
+}
+
+func (m *MockWebhookQueries) CompleteWebhookDeliveryAttempt(ctx context.Context, arg database.CompleteWebhookDeliveryAttemptParams) (database.WebhookDelivery, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.WebhookDelivery), args.Error(1)
+}
+
+func TestWebhookRepository_Create_GeneratesSecret(t *testing.T) {
+	queries := new(MockWebhookQueries)
+	repo := repositories.NewWebhookRepository(queries)
+	ctx := context.Background()
+
+	var secrets []string
+	queries.On("CreateWebhook", ctx, mock.MatchedBy(func(arg database.CreateWebhookParams) bool {
+		return arg.Url == "https://billing.example.com/hooks" &&
+			assert.ObjectsAreEqual([]string{"appointment.created"}, arg.EventTypes) &&
+			arg.CreatedBy.Int32 == 7
+	})).Run(func(args mock.Arguments) {
+		secrets = append(secrets, args.Get(1).(database.CreateWebhookParams).Secret)
+	}).Return(database.Webhook{ID: 1}, nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := repo.Create(ctx, 7, repositories.CreateWebhookParams{
+			URL:        "https://billing.example.com/hooks",
+			EventTypes: []repositories.EventType{repositories.EventAppointmentCreated},
+		})
+		assert.NoError(t, err)
+	}
+
+	assert.Len(t, secrets, 2)
+	assert.True(t, strings.HasPrefix(secrets[0], "whsec_"))
+	assert.Len(t, secrets[0], len("whsec_")+64)
+	assert.NotEqual(t, secrets[0], secrets[1])
+	queries.AssertExpectations(t)
+}
+
+func TestWebhookRepository_GetDeliveries_FiltersByStatus(t *testing.T) {
+	queries := new(MockWebhookQueries)
+	repo := repositories.NewWebhookRepository(queries)
+	ctx := context.Background()
+	dead := repositories.DeliveryDead
+
+	queries.On("GetWebhookDeliveries", ctx, mock.MatchedBy(func(arg database.GetWebhookDeliveriesParams) bool {
+		return arg.WebhookID == 3 && arg.Status.Valid && arg.Status.String == "dead" && arg.MaxResults == 20
+	})).Return([]database.WebhookDelivery{{ID: 9}}, nil)
+
+	deliveries, err := repo.GetDeliveries(ctx, 3, &dead, 20)
+
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	queries.AssertExpectations(t)
+}
+
+func TestWebhookRepository_ClaimDeliveries_PassesLease(t *testing.T) {
+	queries := new(MockWebhookQueries)
+	repo := repositories.NewWebhookRepository(queries)
+	ctx := context.Background()
+
+	queries.On("ClaimWebhookDeliveries", ctx, database.ClaimWebhookDeliveriesParams{
+		MaxResults:   50,
+		LeaseSeconds: 90,
+	}).Return([]database.ClaimWebhookDeliveriesRow{}, nil)
+
+	_, err := repo.ClaimDeliveries(ctx, 50, 90*time.Second)
+
+	assert.NoError(t, err)
+	queries.AssertExpectations(t)
+}
+
+func TestWebhookRepository_MarkDelivered(t *testing.T) {
+	queries := new(MockWebhookQueries)
+	repo := repositories.NewWebhookRepository(queries)
+	ctx := context.Background()
+
+	queries.On("CompleteWebhookDeliveryAttempt", ctx, mock.MatchedBy(func(arg database.CompleteWebhookDeliveryAttemptParams) bool {
+		return arg.ID == 5 && arg.Status == "succeeded" && arg.LastStatusCode.Int32 == 204 && !arg.LastError.Valid
+	})).Return(database.WebhookDelivery{}, nil)
+
+	assert.NoError(t, repo.MarkDelivered(ctx, 5, 204))
+	queries.AssertExpectations(t)
+}
+
+func TestWebhookRepository_MarkFailed_SchedulesRetry(t *testing.T) {
+	queries := new(MockWebhookQueries)
+	repo := repositories.NewWebhookRepository(queries)
+	ctx := context.Background()
+	retryAt := time.Now().Add(time.Minute)
+
+	queries.On("CompleteWebhookDeliveryAttempt", ctx, mock.MatchedBy(func(arg database.CompleteWebhookDeliveryAttemptParams) bool {
+		return arg.ID == 5 &&
+			arg.Status == "pending" &&
+			arg.NextAttemptAt.Time.Equal(retryAt) &&
+			!arg.LastStatusCode.Valid &&
+			arg.LastError.String == "connection refused"
+	})).Return(database.WebhookDelivery{}, nil)
+
+	assert.NoError(t, repo.MarkFailed(ctx, 5, 0, "connection refused", retryAt))
+	queries.AssertExpectations(t)
+}
+
+func TestWebhookRepository_MarkDead_TruncatesError(t *testing.T) {
+	queries := new(MockWebhookQueries)
+	repo := repositories.NewWebhookRepository(queries)
+	ctx := context.Background()
+
+	queries.On("CompleteWebhookDeliveryAttempt", ctx, mock.MatchedBy(func(arg database.CompleteWebhookDeliveryAttemptParams) bool {
+		return arg.Status == "dead" && arg.LastStatusCode.Int32 == 500 && len(arg.LastError.String) == 1000
+	})).Return(database.WebhookDelivery{}, nil)
+
+	assert.NoError(t, repo.MarkDead(ctx, 5, 500, strings.Repeat("x", 5000)))
+	queries.AssertExpectations(t)
+}
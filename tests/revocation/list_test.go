@@ -0,0 +1,132 @@
+package revocation_test
+
+import (
+	"context"
+	"errors"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/revocation"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps tokens the way the revoked_tokens table does, shared by
+// every list like the table is by every instance.
+type fakeStore struct {
+	tokens []database.RevokedToken
+	loads  int
+	err    error
+}
+
+func (s *fakeStore) GetRevokedTokens(ctx context.Context) ([]database.RevokedToken, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.loads++
+
+	var tokens []database.RevokedToken
+	for _, token := range s.tokens {
+		if token.ExpiresAt.Time.After(time.Now()) {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *fakeStore) RevokeToken(ctx context.Context, jti string, userId int32, expiresAt time.Time) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.tokens = append(s.tokens, database.RevokedToken{
+		Jti:       jti,
+		UserID:    userId,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	return nil
+}
+
+func (s *fakeStore) DeleteExpiredRevokedTokens(ctx context.Context) error {
+	return s.err
+}
+
+func TestList_Revoke(t *testing.T) {
+	store := &fakeStore{}
+	list := revocation.NewList(store)
+	ctx := context.Background()
+
+	revoked, err := list.IsRevoked(ctx, "jti-1")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, list.Revoke(ctx, "jti-1", 1, time.Now().Add(time.Hour)))
+
+	revoked, err = list.IsRevoked(ctx, "jti-1")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = list.IsRevoked(ctx, "jti-2")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	// checked from memory, loaded once
+	assert.Equal(t, 1, store.loads)
+}
+
+func TestList_Expired(t *testing.T) {
+	store := &fakeStore{}
+	list := revocation.NewList(store)
+	ctx := context.Background()
+
+	require.NoError(t, list.Load(ctx))
+	require.NoError(t, list.Revoke(ctx, "jti-1", 1, time.Now().Add(-time.Minute)))
+
+	// an expired token is rejected for having expired
+	revoked, err := list.IsRevoked(ctx, "jti-1")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestList_RevokedElsewhere(t *testing.T) {
+	store := &fakeStore{}
+	list := revocation.NewList(store, revocation.WithRefreshInterval(time.Millisecond))
+	other := revocation.NewList(store)
+	ctx := context.Background()
+
+	revoked, err := list.IsRevoked(ctx, "jti-1")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, other.Revoke(ctx, "jti-1", 1, time.Now().Add(time.Hour)))
+	time.Sleep(5 * time.Millisecond)
+
+	revoked, err = list.IsRevoked(ctx, "jti-1")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestList_FailedReloadKeepsTokens(t *testing.T) {
+	store := &fakeStore{}
+	list := revocation.NewList(store, revocation.WithRefreshInterval(time.Millisecond))
+	ctx := context.Background()
+
+	require.NoError(t, list.Revoke(ctx, "jti-1", 1, time.Now().Add(time.Hour)))
+	require.NoError(t, list.Load(ctx))
+
+	store.err = errors.New("connection refused")
+	time.Sleep(5 * time.Millisecond)
+
+	revoked, err := list.IsRevoked(ctx, "jti-1")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestList_FirstLoadFails(t *testing.T) {
+	store := &fakeStore{err: errors.New("connection refused")}
+	list := revocation.NewList(store)
+
+	_, err := list.IsRevoked(context.Background(), "jti-1")
+	assert.Error(t, err)
+}
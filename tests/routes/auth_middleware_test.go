@@ -0,0 +1,131 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/repositories"
+	"patient-appointment-demo-go/internal/revocation"
+	"patient-appointment-demo-go/internal/routes"
+	"patient-appointment-demo-go/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore keeps revoked tokens the way the revoked_tokens table does.
+type memStore struct {
+	tokens []database.RevokedToken
+}
+
+func (s *memStore) GetRevokedTokens(ctx context.Context) ([]database.RevokedToken, error) {
+	return s.tokens, nil
+}
+
+func (s *memStore) RevokeToken(ctx context.Context, jti string, userId int32, expiresAt time.Time) error {
+	s.tokens = append(s.tokens, database.RevokedToken{
+		Jti:       jti,
+		UserID:    userId,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	return nil
+}
+
+func (s *memStore) DeleteExpiredRevokedTokens(ctx context.Context) error {
+	return nil
+}
+
+type MockRefreshTokenQueries struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenQueries) CreateRefreshToken(ctx context.Context, params database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenQueries) UseRefreshToken(ctx context.Context, tokenHash string) (database.RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(database.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenQueries) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (database.RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(database.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenQueries) RevokeRefreshTokenFamily(ctx context.Context, familyId string) error {
+	args := m.Called(ctx, familyId)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenQueries) DeleteExpiredRefreshTokens(ctx context.Context, userId int32) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
+// userRepo checks revocations like UserRepository and always finds user.
+type userRepo struct {
+	repositories.UserRepositoryInterface
+	user database.User
+}
+
+func (r userRepo) Get(ctx context.Context, id int32) (database.User, error) {
+	return r.user, nil
+}
+
+func validateLogin(t *testing.T, users userRepo, token string) int {
+	t.Helper()
+
+	handler := routes.NewAuthMiddleware(users).ValidateLogin(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/patients", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res := httptest.NewRecorder()
+	handler(res, req)
+
+	return res.Code
+}
+
+func TestValidateLogin_RefreshTokenReused(t *testing.T) {
+	revocations := revocation.NewList(&memStore{})
+	users := userRepo{
+		UserRepositoryInterface: repositories.NewUserRepository(nil, revocations),
+		user:                    database.User{ID: 1, TokenVersion: 1},
+	}
+	queries := new(MockRefreshTokenQueries)
+	refreshTokens := repositories.NewRefreshTokenRepository(queries, revocations)
+	ctx := context.Background()
+
+	token, err := utils.GenerateJWT(1, 1, "family-1")
+	require.NoError(t, err)
+	other, err := utils.GenerateJWT(1, 1, "family-2")
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, validateLogin(t, users, token))
+
+	queries.On("UseRefreshToken", ctx, mock.AnythingOfType("string")).Return(database.RefreshToken{}, pgx.ErrNoRows)
+	queries.On("GetRefreshTokenByHash", ctx, mock.AnythingOfType("string")).Return(database.RefreshToken{
+		ID:       1,
+		UserID:   1,
+		FamilyID: "family-1",
+		UsedAt:   pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true},
+	}, nil)
+	queries.On("RevokeRefreshTokenFamily", ctx, "family-1").Return(nil)
+
+	_, err = refreshTokens.Use(ctx, "rt_abc")
+	require.ErrorIs(t, err, repositories.ErrRefreshTokenReused)
+
+	// the family's access token is rejected with its refresh tokens, other
+	// sessions are left alone
+	assert.Equal(t, http.StatusUnauthorized, validateLogin(t, users, token))
+	assert.Equal(t, http.StatusOK, validateLogin(t, users, other))
+}
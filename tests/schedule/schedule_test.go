@@ -0,0 +1,104 @@
+package schedule_test
+
+import (
+	"patient-appointment-demo-go/internal/schedule"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func weekdaySchedule() schedule.Schedule {
+	return schedule.Schedule{
+		SlotLength: 30 * time.Minute,
+		Location:   time.UTC,
+		WorkingHours: []schedule.WeeklyWindow{
+			{Weekday: time.Monday, Start: schedule.NewTimeOfDay(9, 0), End: schedule.NewTimeOfDay(13, 0)},
+			{Weekday: time.Monday, Start: schedule.NewTimeOfDay(14, 0), End: schedule.NewTimeOfDay(16, 0)},
+		},
+		Breaks: []schedule.WeeklyWindow{
+			{Weekday: time.Monday, Start: schedule.NewTimeOfDay(11, 0), End: schedule.NewTimeOfDay(11, 30)},
+		},
+	}
+}
+
+func at(day int, hour int, minute int) time.Time {
+	// March 2025 starts on a Saturday, so the 3rd and 10th are Mondays
+	return time.Date(2025, time.March, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	tod, err := schedule.ParseTimeOfDay("09:45")
+
+	require.NoError(t, err)
+	assert.Equal(t, schedule.NewTimeOfDay(9, 45), tod)
+	assert.Equal(t, "09:45", tod.String())
+
+	_, err = schedule.ParseTimeOfDay("25:00")
+	assert.Error(t, err)
+}
+
+func TestSchedule_Availability_SubtractsBreaks(t *testing.T) {
+	available := weekdaySchedule().Availability(at(3, 0, 0), at(4, 0, 0))
+
+	assert.Equal(t, []schedule.Interval{
+		{Start: at(3, 9, 0), End: at(3, 11, 0)},
+		{Start: at(3, 11, 30), End: at(3, 13, 0)},
+		{Start: at(3, 14, 0), End: at(3, 16, 0)},
+	}, available)
+}
+
+func TestSchedule_Availability_Exceptions(t *testing.T) {
+	start := schedule.NewTimeOfDay(18, 0)
+	end := schedule.NewTimeOfDay(19, 0)
+
+	s := weekdaySchedule()
+	s.Exceptions = []schedule.Exception{
+		{Year: 2025, Month: time.March, Day: 10, Kind: schedule.ExceptionHoliday},
+		{Year: 2025, Month: time.March, Day: 4, Kind: schedule.ExceptionExtraHours, Start: &start, End: &end},
+	}
+
+	assert.Empty(t, s.Availability(at(10, 0, 0), at(11, 0, 0)))
+	assert.Equal(t, []schedule.Interval{
+		{Start: at(4, 18, 0), End: at(4, 19, 0)},
+	}, s.Availability(at(4, 0, 0), at(5, 0, 0)))
+}
+
+func TestSchedule_Availability_Timezone(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	s := weekdaySchedule()
+	s.Location = loc
+	s.Breaks = nil
+
+	available := s.Availability(at(3, 0, 0), at(4, 0, 0))
+
+	require.Len(t, available, 2)
+	assert.True(t, available[0].Start.Equal(at(3, 3, 30)))
+	assert.True(t, available[0].End.Equal(at(3, 7, 30)))
+}
+
+func TestSchedule_Slots_SkipsBusy(t *testing.T) {
+	busy := []schedule.Interval{
+		{Start: at(3, 9, 30), End: at(3, 10, 0)},
+	}
+
+	slots := weekdaySchedule().Slots(at(3, 9, 0), at(3, 11, 0), busy)
+
+	assert.Equal(t, []schedule.Interval{
+		{Start: at(3, 9, 0), End: at(3, 9, 30)},
+		{Start: at(3, 10, 0), End: at(3, 10, 30)},
+		{Start: at(3, 10, 30), End: at(3, 11, 0)},
+	}, slots)
+}
+
+func TestSchedule_Covers(t *testing.T) {
+	s := weekdaySchedule()
+
+	assert.True(t, s.Covers(schedule.Interval{Start: at(3, 9, 0), End: at(3, 9, 30)}))
+	assert.False(t, s.Covers(schedule.Interval{Start: at(3, 10, 45), End: at(3, 11, 15)}))
+	assert.False(t, s.Covers(schedule.Interval{Start: at(3, 12, 45), End: at(3, 13, 15)}))
+	assert.False(t, s.Covers(schedule.Interval{Start: at(4, 9, 0), End: at(4, 9, 30)}))
+}
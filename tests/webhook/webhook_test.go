@@ -0,0 +1,214 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"patient-appointment-demo-go/internal/database"
+	"patient-appointment-demo-go/internal/webhook"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type outcome struct {
+	status     string
+	statusCode int
+	reason     string
+	retryAt    time.Time
+}
+
+// fakeStore hands out the deliveries it was given once and records how
+// each went.
+type fakeStore struct {
+	mu         sync.Mutex
+	dispatched int
+	due        []database.ClaimWebhookDeliveriesRow
+	outcomes   map[int64]outcome
+}
+
+func newFakeStore(due ...database.ClaimWebhookDeliveriesRow) *fakeStore {
+	return &fakeStore{due: due, outcomes: make(map[int64]outcome)}
+}
+
+func (s *fakeStore) DispatchOutbox(ctx context.Context, limit int32) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dispatched++
+	return 0, nil
+}
+
+func (s *fakeStore) ClaimDeliveries(ctx context.Context, limit int32, lease time.Duration) ([]database.ClaimWebhookDeliveriesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := s.due
+	s.due = nil
+	return due, nil
+}
+
+func (s *fakeStore) MarkDelivered(ctx context.Context, id int64, statusCode int) error {
+	return s.record(id, outcome{status: "succeeded", statusCode: statusCode})
+}
+
+func (s *fakeStore) MarkFailed(ctx context.Context, id int64, statusCode int, reason string, retryAt time.Time) error {
+	return s.record(id, outcome{status: "pending", statusCode: statusCode, reason: reason, retryAt: retryAt})
+}
+
+func (s *fakeStore) MarkDead(ctx context.Context, id int64, statusCode int, reason string) error {
+	return s.record(id, outcome{status: "dead", statusCode: statusCode, reason: reason})
+}
+
+func (s *fakeStore) record(id int64, o outcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outcomes[id] = o
+	return nil
+}
+
+// fakeCipher "encrypts" a value by prefixing it with enc: and its field.
+type fakeCipher struct{}
+
+func (fakeCipher) Encrypt(ctx context.Context, field string, plaintext string) (string, error) {
+	return "enc:" + field + ":" + plaintext, nil
+}
+
+func (fakeCipher) Decrypt(ctx context.Context, field string, value string) (string, error) {
+	plaintext, _ := strings.CutPrefix(value, "enc:"+field+":")
+	return plaintext, nil
+}
+
+func (fakeCipher) BlindIndex(ctx context.Context, field string, value string) ([]byte, error) {
+	return []byte(field + ":" + value), nil
+}
+
+func (fakeCipher) IsCurrent(ctx context.Context, value string) (bool, error) {
+	return strings.HasPrefix(value, "enc:"), nil
+}
+
+func delivery(id int64, url string, attempts int32) database.ClaimWebhookDeliveriesRow {
+	return database.ClaimWebhookDeliveriesRow{
+		ID:             id,
+		WebhookID:      1,
+		EventID:        100 + id,
+		Attempts:       attempts,
+		Url:            url,
+		Secret:         "whsec_test",
+		EventType:      "appointment.created",
+		Payload:        []byte(`{"id":7}`),
+		EventCreatedAt: pgtype.Timestamptz{Time: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC), Valid: true},
+	}
+}
+
+func TestSign_Verify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	signature := webhook.Sign("secret", 1760000000, body)
+
+	assert.True(t, webhook.Verify("secret", 1760000000, body, signature))
+	assert.False(t, webhook.Verify("other", 1760000000, body, signature))
+	assert.False(t, webhook.Verify("secret", 1760000001, body, signature))
+	assert.False(t, webhook.Verify("secret", 1760000000, []byte(`{"id":2}`), signature))
+	assert.False(t, webhook.Verify("secret", 1760000000, body, signature[len("sha256="):]))
+}
+
+func TestDispatcher_RunOnce_SendsSignedEnvelope(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := newFakeStore(delivery(1, server.URL, 1))
+	dispatcher := webhook.NewDispatcher(store, fakeCipher{})
+
+	require.NoError(t, dispatcher.RunOnce(context.Background()))
+
+	assert.Equal(t, 1, store.dispatched)
+	assert.Equal(t, outcome{status: "succeeded", statusCode: http.StatusNoContent}, store.outcomes[1])
+
+	require.NotNil(t, received)
+	assert.Equal(t, "101", received.Header.Get(webhook.HeaderID))
+	assert.Equal(t, "appointment.created", received.Header.Get(webhook.HeaderEvent))
+
+	timestamp, err := strconv.ParseInt(received.Header.Get(webhook.HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.True(t, webhook.Verify("whsec_test", timestamp, body, received.Header.Get(webhook.HeaderSignature)))
+
+	var envelope webhook.Envelope
+	require.NoError(t, json.Unmarshal(body, &envelope))
+	assert.Equal(t, int64(101), envelope.ID)
+	assert.Equal(t, "appointment.created", envelope.Type)
+	assert.JSONEq(t, `{"id":7}`, string(envelope.Data))
+}
+
+func TestDispatcher_RunOnce_DecryptsPayload(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	encrypted := delivery(1, server.URL, 1)
+	encrypted.Payload = []byte(`"enc:outbox_events.payload:{\"id\":7}"`)
+	store := newFakeStore(encrypted)
+	dispatcher := webhook.NewDispatcher(store, fakeCipher{})
+
+	require.NoError(t, dispatcher.RunOnce(context.Background()))
+
+	var envelope webhook.Envelope
+	require.NoError(t, json.Unmarshal(body, &envelope))
+	assert.JSONEq(t, `{"id":7}`, string(envelope.Data))
+}
+
+func TestDispatcher_RunOnce_RetriesWithBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	store := newFakeStore(delivery(1, server.URL, 3))
+	dispatcher := webhook.NewDispatcher(store, fakeCipher{}, webhook.WithRetries(5, time.Minute, time.Hour))
+
+	before := time.Now()
+	require.NoError(t, dispatcher.RunOnce(context.Background()))
+
+	got := store.outcomes[1]
+	assert.Equal(t, "pending", got.status)
+	assert.Equal(t, http.StatusServiceUnavailable, got.statusCode)
+	assert.Contains(t, got.reason, "503")
+	// third failure: 1m, 2m, then 4m
+	assert.WithinDuration(t, before.Add(4*time.Minute), got.retryAt, 5*time.Second)
+}
+
+func TestDispatcher_RunOnce_DeadAfterLastAttempt(t *testing.T) {
+	store := newFakeStore(delivery(1, "http://127.0.0.1:1", 5))
+	dispatcher := webhook.NewDispatcher(store, fakeCipher{}, webhook.WithRetries(5, time.Minute, time.Hour))
+
+	require.NoError(t, dispatcher.RunOnce(context.Background()))
+
+	got := store.outcomes[1]
+	assert.Equal(t, "dead", got.status)
+	assert.Equal(t, 0, got.statusCode)
+	assert.NotEmpty(t, got.reason)
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	dispatcher := webhook.NewDispatcher(newFakeStore(), fakeCipher{}, webhook.WithRetries(10, 30*time.Second, 10*time.Minute))
+
+	assert.Equal(t, 30*time.Second, dispatcher.Backoff(1))
+	assert.Equal(t, time.Minute, dispatcher.Backoff(2))
+	assert.Equal(t, 8*time.Minute, dispatcher.Backoff(5))
+	assert.Equal(t, 10*time.Minute, dispatcher.Backoff(6))
+	assert.Equal(t, 10*time.Minute, dispatcher.Backoff(40))
+}